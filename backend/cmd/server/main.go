@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/DamienFooxx/CVWOForum/internal/config"
 	"github.com/DamienFooxx/CVWOForum/internal/database"
@@ -10,6 +15,9 @@ import (
 	"github.com/DamienFooxx/CVWOForum/internal/router"
 )
 
+// shutdownTimeout is how long open requests get to finish once the server is asked to stop
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Initialise .env vars into environment
 	cfg, err := config.Load()
@@ -18,23 +26,63 @@ func main() {
 	}
 
 	// Initialise database using internal/dbConnection/dbConnection.go
-	databaseConnection, err := dbConnection.NewDB(cfg.DatabaseURL)
+	databaseConnection, err := dbConnection.NewDB(cfg.DatabaseURL, cfg.DB)
 	if err != nil {
 		panic(err)
 	}
-	// Close dbConnection connection once main() stops
-	defer databaseConnection.Close()
 	fmt.Println("Connected to database at", cfg.DatabaseURL)
 
-	queries := database.New(databaseConnection)
+	// Route read-only queries to the replica if one is configured
+	var db database.DBTX = databaseConnection
+	if cfg.DB.ReplicaURL != "" {
+		replicaConnection, err := dbConnection.NewDB(cfg.DB.ReplicaURL, cfg.DB)
+		if err != nil {
+			panic(err)
+		}
+		routedDB := dbConnection.NewRoutedDB(databaseConnection, replicaConnection, cfg.DB.ReplicaMaxLag, cfg.DB.ReplicaCheckPeriod)
+		// Closes both the primary and replica pools once main() stops
+		defer routedDB.Close()
+		db = routedDB
+		fmt.Println("Connected to read replica at", cfg.DB.ReplicaURL)
+	} else {
+		// Close dbConnection connection once main() stops
+		defer databaseConnection.Close()
+	}
+
+	queries := database.New(db)
 
 	// Initialise chi router using internal/router/router.go New() function
-	r := router.NewRouter(queries)
+	r, workers := router.NewRouter(cfg, queries)
+
+	// Stop on Ctrl+C or when the platform asks the server to shut down
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	// Background workers run until shutdown, stopping them waits for in-flight jobs and deliveries
+	stopWorkers := workers.Start(ctx)
+	defer stopWorkers()
+
 	addr := ":" + cfg.Port
+	server := &http.Server{Addr: addr, Handler: r}
 	fmt.Println("listening on", addr)
 
 	// Start HTTP server
-	if err := http.ListenAndServe(addr, r); err != nil {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
 		panic(err)
+	case <-ctx.Done():
+	}
+
+	// Let open requests finish, streams and WebSockets are cut off after the timeout
+	fmt.Println("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Println("Failed to shut down cleanly:", err)
 	}
 }
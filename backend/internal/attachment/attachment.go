@@ -0,0 +1,154 @@
+package attachment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	_ "golang.org/x/image/webp" // Register the WebP decoder for image.Decode
+)
+
+/**
+Attachment processing
+The content type is sniffed from the file instead of trusting the client, and images are
+re-encoded so metadata such as EXIF GPS coordinates is never served to other users
+*/
+
+const (
+	MaxDimension      = 8192
+	MaxPixels         = 40_000_000 // Checked before decoding so a small file cannot expand into gigabytes
+	MaxFilenameLength = 255
+	jpegQuality       = 90
+)
+
+// AllowedTypes are the content types accepted for upload, with the extension stored files get
+var AllowedTypes = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+}
+
+var (
+	ErrUnsupportedType = errors.New("attachments must be PNG, JPEG, GIF or WebP images, PDFs or plain text")
+	ErrDimensions      = fmt.Errorf("images must be at most %dx%d pixels", MaxDimension, MaxDimension)
+)
+
+// DetectType sniffs the content type of an upload from its first bytes, ignoring what the client claimed
+func DetectType(data []byte) (string, error) {
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		return "", ErrUnsupportedType
+	}
+	if _, ok := AllowedTypes[contentType]; !ok {
+		return "", ErrUnsupportedType
+	}
+	return contentType, nil
+}
+
+// IsImage reports whether contentType is one of the image types
+func IsImage(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/")
+}
+
+// Sanitize returns the file as it should be stored along with its content type.
+// Images are decoded and re-encoded, dropping EXIF and any other metadata. WebP is stored as
+// PNG since there is no WebP encoder in the standard library. Other files are returned as is.
+func Sanitize(data []byte, contentType string) ([]byte, string, error) {
+	if !IsImage(contentType) {
+		return data, contentType, nil
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedType
+	}
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension || cfg.Width*cfg.Height > MaxPixels {
+		return nil, "", ErrDimensions
+	}
+
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/gif":
+		// Keep every frame of animated GIFs, comments and application extensions are dropped
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, "", ErrUnsupportedType
+		}
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), contentType, nil
+
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, "", ErrUnsupportedType
+		}
+		// The orientation tag is lost with the rest of the EXIF data, so apply it to the pixels
+		img = applyOrientation(img, jpegOrientation(data))
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), contentType, nil
+
+	default:
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, "", ErrUnsupportedType
+		}
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+}
+
+// CleanFilename makes a client supplied filename safe to store and send back in Content-Disposition.
+// The extension is replaced when it does not match the sniffed content type.
+func CleanFilename(name, contentType string) string {
+	// Clients may send a full path, only the last element is kept
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' || r == '/' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "." || name == ".." {
+		name = ""
+	}
+
+	ext := AllowedTypes[contentType]
+	current := strings.ToLower(filepath.Ext(name))
+	if current != ext && !(contentType == "image/jpeg" && current == ".jpeg") {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + ext
+	}
+	if name == ext {
+		name = "attachment" + ext
+	}
+
+	// Trim the stem rather than cutting off the extension, without splitting a multi-byte character
+	if len(name) > MaxFilenameLength {
+		ext = filepath.Ext(name)
+		stem := []byte(strings.TrimSuffix(name, ext))[:MaxFilenameLength-len(ext)]
+		for !utf8.Valid(stem) {
+			stem = stem[:len(stem)-1]
+		}
+		name = string(stem) + ext
+	}
+	return name
+}
@@ -0,0 +1,59 @@
+package attachment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/storage"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const cleanupBatchSize = 100
+
+// Cleaner deletes uploads that were never added to a post or comment
+type Cleaner struct {
+	q      *database.Queries
+	store  storage.Storage
+	maxAge time.Duration
+}
+
+func NewCleaner(q *database.Queries, store storage.Storage, maxAge time.Duration) *Cleaner {
+	return &Cleaner{q: q, store: store, maxAge: maxAge}
+}
+
+// Sweep deletes uploads older than maxAge that are still unused, returning how many were removed
+func (c *Cleaner) Sweep(ctx context.Context, now time.Time) (int, error) {
+	cutoff := pgtype.Timestamptz{Time: now.Add(-c.maxAge), Valid: true}
+	deleted := 0
+
+	for {
+		orphans, err := c.q.ListOrphanedAttachments(ctx, database.ListOrphanedAttachmentsParams{
+			CreatedBefore: cutoff,
+			PageLimit:     cleanupBatchSize,
+		})
+		if err != nil {
+			return deleted, err
+		}
+
+		for _, orphan := range orphans {
+			// The row goes first and only if still unused, so an attachment linked meanwhile keeps its file
+			rows, err := c.q.DeleteOrphanedAttachment(ctx, orphan.AttachmentID)
+			if err != nil {
+				return deleted, err
+			}
+			if rows == 0 {
+				continue
+			}
+			if err := c.store.Delete(ctx, orphan.StorageKey); err != nil {
+				fmt.Printf("Failed to delete attachment file %s: %v\n", orphan.StorageKey, err)
+			}
+			deleted++
+		}
+
+		if len(orphans) < cleanupBatchSize {
+			return deleted, nil
+		}
+	}
+}
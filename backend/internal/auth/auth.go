@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -15,13 +16,47 @@ type contextKey string
 
 const UserIDKey contextKey = "user_id"
 
+// RoleKey holds the user's role, only set on routes guarded by middleware.RequireRole
+const RoleKey contextKey = "role"
+
+// Roles a user can have, stored in users.role
+const (
+	RoleMember    = "member"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// MFAKey is true when the session completed two-factor authentication at login, or the token was created from such a session
+const MFAKey contextKey = "mfa"
+
+// PurposeMFA marks the short-lived token returned by /login while the second factor is pending
+const PurposeMFA = "mfa"
+
 type Claims struct {
-	UserID int64 `json:"user_id"`
+	UserID  int64  `json:"user_id"`
+	MFA     bool   `json:"mfa,omitempty"`     // Session completed two-factor authentication
+	Purpose string `json:"purpose,omitempty"` // Empty for sessions, PurposeMFA for the interim login token
 	jwt.RegisteredClaims
 }
 
 // GenerateToken creates the JWT Token for the User for that userid
 func GenerateToken(userID int64) (string, error) {
+	// Token valid for 24 hours after user login
+	return signToken(&Claims{UserID: userID}, 24*time.Hour)
+}
+
+// GenerateVerifiedToken creates a session token for a user who also passed two-factor authentication
+func GenerateVerifiedToken(userID int64) (string, error) {
+	return signToken(&Claims{UserID: userID, MFA: true}, 24*time.Hour)
+}
+
+// GenerateMFAToken creates the interim token exchanged at /login/2fa, it is not accepted as a session
+func GenerateMFAToken(userID int64) (string, error) {
+	return signToken(&Claims{UserID: userID, Purpose: PurposeMFA}, 5*time.Minute)
+}
+
+// signToken signs claims with the JWT secret, valid for ttl
+func signToken(claims *Claims, ttl time.Duration) (string, error) {
 	// Reload secret
 	if len(jwtSecret) == 0 {
 		jwtSecret = []byte(os.Getenv("JWT_SECRET"))
@@ -31,15 +66,9 @@ func GenerateToken(userID int64) (string, error) {
 		return "", errors.New("JWT_SECRET is not set")
 	}
 
-	// Token valid for 24 hours after user login
-	expirationTime := time.Now().Add(24 * time.Hour)
-
-	claims := &Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime), // Set expiry so it cannot be reused indefinitely
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)), // Set expiry so it cannot be reused indefinitely
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
 	}
 
 	// Construct the token using SHA256 algorithm
@@ -49,6 +78,45 @@ func GenerateToken(userID int64) (string, error) {
 
 // ValidateToken validates the JWT Token, then returns UserID for encapsulation
 func ValidateToken(tokenString string) (int64, error) {
+	claims, err := ParseSessionToken(tokenString)
+	if err != nil {
+		return 0, err
+	}
+	return claims.UserID, nil
+}
+
+// ParseSessionToken validates a session JWT and returns its claims
+func ParseSessionToken(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("token is not a session token")
+	}
+	return claims, nil
+}
+
+// ValidateMFAToken validates the interim token from /login and returns its UserID
+func ValidateMFAToken(tokenString string) (int64, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return 0, err
+	}
+	if claims.Purpose != PurposeMFA {
+		return 0, errors.New("token is not a two-factor login token")
+	}
+	return claims.UserID, nil
+}
+
+// IsMFAVerified reports whether the request's session or token completed two-factor authentication
+func IsMFAVerified(ctx context.Context) bool {
+	verified, _ := ctx.Value(MFAKey).(bool)
+	return verified
+}
+
+// parseToken checks the signature and expiry of any token we issued
+func parseToken(tokenString string) (*Claims, error) {
 	// Reload secret
 	if len(jwtSecret) == 0 {
 		jwtSecret = []byte(os.Getenv("JWT_SECRET"))
@@ -67,12 +135,12 @@ func ValidateToken(tokenString string) (int64, error) {
 
 	// Likely when token has expired
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	if !token.Valid {
-		return 0, errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
-	return claims.UserID, nil
+	return claims, nil
 }
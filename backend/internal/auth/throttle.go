@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/notification"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+/**
+Brute-force protection for logins
+Failures are counted per account and per IP
+Once a count reaches its limit the account or IP is locked, and every further failure doubles the lockout
+*/
+
+// LoginThrottle tracks failed logins and lockouts
+type LoginThrottle struct {
+	q   *database.Queries
+	cfg config.LoginThrottleConfig
+}
+
+func NewLoginThrottle(q *database.Queries, cfg config.LoginThrottleConfig) *LoginThrottle {
+	return &LoginThrottle{q: q, cfg: cfg}
+}
+
+func accountKey(userID int64) string {
+	return "account:" + strconv.FormatInt(userID, 10)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LockedFor returns how long until the account or IP may try again, 0 if neither is locked.
+// Pass a userID of 0 to only check the IP.
+func (t *LoginThrottle) LockedFor(ctx context.Context, userID int64, ip string) (time.Duration, error) {
+	keys := []string{ipKey(ip)}
+	if userID != 0 {
+		keys = append(keys, accountKey(userID))
+	}
+
+	var lockedFor time.Duration
+	for _, key := range keys {
+		throttle, err := t.q.GetLoginThrottle(ctx, key)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return 0, err
+		}
+		if throttle.LockedUntil.Valid {
+			lockedFor = max(lockedFor, time.Until(throttle.LockedUntil.Time))
+		}
+	}
+	return lockedFor, nil
+}
+
+// RecordFailure counts a failed login against the account and IP.
+// Returns how long the account or IP is now locked for, 0 if the failure did not cause a lockout.
+func (t *LoginThrottle) RecordFailure(ctx context.Context, userID int64, ip string) (time.Duration, error) {
+	ipLock, err := t.recordFailure(ctx, ipKey(ip), t.cfg.MaxIPFailures)
+	if err != nil {
+		return 0, err
+	}
+
+	accountLock, err := t.recordFailure(ctx, accountKey(userID), t.cfg.MaxAccountFailures)
+	if err != nil {
+		return 0, err
+	}
+
+	// Let the owner know someone is trying to get into their account
+	if accountLock > 0 {
+		payload, _ := json.Marshal(map[string]interface{}{
+			"locked_until": time.Now().Add(accountLock).UTC().Format(time.RFC3339),
+			"ip_address":   ip,
+		})
+		if _, err := t.q.CreateNotification(ctx, database.CreateNotificationParams{
+			UserID:  userID,
+			Type:    notification.TypeAccountLocked,
+			Payload: payload,
+		}); err != nil {
+			fmt.Printf("Failed to notify user %d of lockout: %v\n", userID, err)
+		}
+	}
+
+	return max(ipLock, accountLock), nil
+}
+
+// RecordSuccess clears the failure streak of the account, the IP keeps its count until the window passes
+func (t *LoginThrottle) RecordSuccess(ctx context.Context, userID int64) error {
+	return t.q.DeleteLoginThrottle(ctx, accountKey(userID))
+}
+
+// recordFailure bumps the failure count for key and locks it once the count reaches limit
+func (t *LoginThrottle) recordFailure(ctx context.Context, key string, limit int32) (time.Duration, error) {
+	failures, err := t.q.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		ThrottleKey: key,
+		WindowStart: pgtype.Timestamptz{Time: time.Now().Add(-t.cfg.FailureWindow), Valid: true},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	lock := t.lockoutFor(failures, limit)
+	if lock == 0 {
+		return 0, nil
+	}
+
+	err = t.q.LockLoginThrottle(ctx, database.LockLoginThrottleParams{
+		ThrottleKey: key,
+		LockedUntil: pgtype.Timestamptz{Time: time.Now().Add(lock), Valid: true},
+	})
+	return lock, err
+}
+
+// lockoutFor is LockoutBase doubled for every failure past limit, capped at LockoutMax
+func (t *LoginThrottle) lockoutFor(failures, limit int32) time.Duration {
+	if failures < limit {
+		return 0
+	}
+	lock := t.cfg.LockoutBase
+	for i := limit; i < failures && lock < t.cfg.LockoutMax; i++ {
+		lock *= 2
+	}
+	return min(lock, t.cfg.LockoutMax)
+}
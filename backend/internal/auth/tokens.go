@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+// Scopes a personal access token can be granted
+const (
+	ScopeRead     = "read"     // Authenticated reads such as notifications
+	ScopePost     = "post"     // Create and delete topics and posts
+	ScopeComment  = "comment"  // Create and delete comments
+	ScopeModerate = "moderate" // Moderator and admin actions, still subject to the user's role
+)
+
+var AllScopes = []string{ScopeRead, ScopePost, ScopeComment, ScopeModerate}
+
+// ScopesKey holds the scopes of the personal access token used for the request.
+// It is not set for JWT sessions, which can do everything the user can.
+const ScopesKey contextKey = "scopes"
+
+// PersonalAccessTokenPrefix marks a bearer token as a personal access token rather than a JWT
+const PersonalAccessTokenPrefix = "cvwo_pat_"
+
+// GeneratePersonalAccessToken returns a new random token and the SHA-256 hash to store for it
+func GeneratePersonalAccessToken() (token string, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token = PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, HashPersonalAccessToken(token), nil
+}
+
+// HashPersonalAccessToken hashes a token for lookup. Tokens are random so a fast hash is enough.
+func HashPersonalAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal access token
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// HasScope reports whether the request is allowed to act with scope
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := ctx.Value(ScopesKey).([]string)
+	if !ok {
+		// JWT session
+		return true
+	}
+	return slices.Contains(scopes, scope)
+}
+
+// IsSession reports whether the request was authenticated with a JWT from /login rather than a token
+func IsSession(ctx context.Context) bool {
+	_, ok := ctx.Value(ScopesKey).([]string)
+	return !ok
+}
+
+// GenerateEmailToken returns a random token for a link sent by email and the SHA-256 hash to store for it
+func GenerateEmailToken() (token string, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(secret)
+	return token, HashEmailToken(token), nil
+}
+
+// HashEmailToken hashes an emailed token for lookup
+func HashEmailToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
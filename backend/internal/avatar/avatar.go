@@ -0,0 +1,90 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Register the JPEG and WebP decoders for image.Decode
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+/**
+Avatar image processing
+Uploads are decoded, cropped to a square and re-encoded as PNG at each size,
+which also drops any metadata or trailing data that came with the original file
+*/
+
+const (
+	SizeLarge = 256 // Profile pages
+	SizeSmall = 64  // Thumbnails next to posts and comments
+
+	MinDimension = 32
+	MaxDimension = 4096 // Checked before decoding so huge images cannot exhaust memory
+)
+
+// Sizes lists every size generated for an avatar
+var Sizes = []int{SizeLarge, SizeSmall}
+
+// AllowedTypes are the content types accepted for upload
+var AllowedTypes = []string{"image/png", "image/jpeg", "image/webp"}
+
+var (
+	ErrUnsupportedType = errors.New("avatar must be a PNG, JPEG or WebP image")
+	ErrDimensions      = fmt.Errorf("avatar must be between %dx%d and %dx%d pixels", MinDimension, MinDimension, MaxDimension, MaxDimension)
+)
+
+// DetectType sniffs the content type of an upload from its first bytes, ignoring what the client claimed
+func DetectType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	for _, allowed := range AllowedTypes {
+		if contentType == allowed {
+			return contentType, nil
+		}
+	}
+	return "", ErrUnsupportedType
+}
+
+// Process validates an uploaded image and returns it re-encoded as a square PNG for every size in Sizes
+func Process(data []byte) (map[int][]byte, error) {
+	if _, err := DetectType(data); err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if cfg.Width < MinDimension || cfg.Height < MinDimension || cfg.Width > MaxDimension || cfg.Height > MaxDimension {
+		return nil, ErrDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	// Centre crop to a square
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+	square := image.Rect(x0, y0, x0+side, y0+side)
+
+	images := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, square, draw.Src, nil)
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, dst); err != nil {
+			return nil, err
+		}
+		images[size] = buf.Bytes()
+	}
+	return images, nil
+}
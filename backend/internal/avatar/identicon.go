@@ -0,0 +1,46 @@
+package avatar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+// Identicon renders a symmetric 5x5 pattern derived from seed as a size x size PNG.
+// The same seed always gives the same image, so users without an upload keep a stable picture.
+func Identicon(seed string, size int) ([]byte, error) {
+	sum := sha256.Sum256([]byte(seed))
+
+	// Keep the colour away from white so it shows against the background
+	fg := color.NRGBA{R: sum[0]/2 + 32, G: sum[1]/2 + 32, B: sum[2]/2 + 32, A: 255}
+	bg := color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+
+	// 5 cells plus half a cell of padding on each side
+	cell := size / 6
+	padding := (size - cell*5) / 2
+	for row := 0; row < 5; row++ {
+		for col := 0; col < 3; col++ {
+			// One bit per cell of the left half, mirrored onto the right
+			bit := row*3 + col
+			if sum[3+bit/8]>>(bit%8)&1 == 0 {
+				continue
+			}
+			for _, c := range []int{col, 4 - col} {
+				rect := image.Rect(padding+c*cell, padding+row*cell, padding+(c+1)*cell, padding+(row+1)*cell)
+				draw.Draw(img, rect, &image.Uniform{C: fg}, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,6 +14,179 @@ type Config struct {
 	Port        string
 	DatabaseURL string
 	FrontendURL string
+	APIURL      string // Where this API is reached from outside, for links mail clients call directly
+	DB          DBConfig
+	RateLimit   RateLimitConfig
+	Login       LoginThrottleConfig
+	OIDC        OIDCConfig
+	TwoFactor   TwoFactorConfig
+	Storage     StorageConfig
+	Uploads     UploadConfig
+	Events      EventsConfig
+	WebSocket   WebSocketConfig
+	Webhooks    WebhookConfig
+	Jobs        JobsConfig
+	Outbox      OutboxConfig
+	Mail        MailConfig
+	Accounts    AccountConfig
+	Digests     DigestConfig
+}
+
+// DBConfig holds the connection pool and read replica settings.
+// Zero values fall back to the pgxpool defaults.
+type DBConfig struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	StatementTimeout  time.Duration // Default statement_timeout applied to every connection
+
+	ReplicaURL         string        // Optional, read-only queries are routed here when set
+	ReplicaMaxLag      time.Duration // Reads fall back to the primary once the replica lags beyond this
+	ReplicaCheckPeriod time.Duration // How often the replica lag is measured
+}
+
+// RateLimitConfig holds the token bucket limits for each route group
+type RateLimitConfig struct {
+	Enabled           bool
+	Backend           string // "memory" for a single instance, "postgres" to share limits across instances
+	TrustProxyHeaders bool   // Take the client IP from the last X-Forwarded-For entry, only safe behind a reverse proxy
+	Auth              RateLimitRule
+	Writes            RateLimitRule
+	Reads             RateLimitRule
+}
+
+// RateLimitRule allows Requests per Period, written as "10/1m" in the environment
+type RateLimitRule struct {
+	Requests int
+	Period   time.Duration
+}
+
+// LoginThrottleConfig controls brute-force protection on POST /login
+type LoginThrottleConfig struct {
+	MaxAccountFailures int32         // Failures on one account before it is locked
+	MaxIPFailures      int32         // Failures from one IP, across all accounts, before it is locked
+	FailureWindow      time.Duration // A failure streak resets after this long without failures
+	LockoutBase        time.Duration // First lockout, doubled for every further failure
+	LockoutMax         time.Duration
+}
+
+// OIDCConfig configures single sign-on through an OpenID Connect identity provider.
+// SSO is disabled when IssuerURL is empty.
+type OIDCConfig struct {
+	IssuerURL         string
+	ClientID          string
+	ClientSecret      string
+	RedirectURL       string   // Must point at /auth/oidc/callback and be registered with the IdP
+	Scopes            []string // Requested on top of "openid"
+	GroupsClaim       string   // ID token claim listing the user's groups
+	RoleMapping       map[string]string
+	PostLoginRedirect string // Frontend URL that receives the token in its fragment, JSON is returned when empty
+	LinkExistingUsers bool   // Link IdP accounts to forum users whose verified email matches the verified email claim
+}
+
+// TwoFactorConfig controls TOTP two-factor authentication
+type TwoFactorConfig struct {
+	Issuer        string   // Name shown in authenticator apps
+	RequiredRoles []string // Roles that must enable 2FA before using their elevated routes
+}
+
+// StorageConfig selects where uploaded files are kept
+type StorageConfig struct {
+	Backend  string // "local" or "s3"
+	LocalDir string // Root directory of the local backend
+	S3       S3Config
+}
+
+// S3Config points at an S3 compatible bucket such as AWS S3 or MinIO
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.ap-southeast-1.amazonaws.com or http://localhost:9000
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool // Address objects as endpoint/bucket/key, which MinIO needs
+}
+
+// UploadConfig limits user uploads and controls how attachments are served
+type UploadConfig struct {
+	MaxAvatarBytes       int64
+	MaxAttachmentBytes   int64
+	AttachmentQuotaBytes int64         // Total size of all attachments one user may keep
+	AttachmentURLTTL     time.Duration // How long a signed download URL stays valid
+	AttachmentOrphanTTL  time.Duration // Uploads never added to a post or comment are deleted after this long
+	AttachmentSigningKey string        // Signs download URLs, defaults to JWT_SECRET, required so URLs work on every instance
+}
+
+// EventsConfig controls the real-time event stream at /events
+type EventsConfig struct {
+	Heartbeat time.Duration // Idle streams get a comment this often so proxies keep them open
+	Retention time.Duration // Events stay available for Last-Event-ID resumes this long
+}
+
+// WebSocketConfig limits the live thread connections at /ws
+type WebSocketConfig struct {
+	MaxConnsPerUser int           // Open connections one user may hold, e.g. one per browser tab
+	TypingInterval  time.Duration // Typing indicators from a user are relayed at most this often per post
+}
+
+// WebhookConfig controls outgoing webhook deliveries
+type WebhookConfig struct {
+	PollInterval time.Duration // How often the delivery queue is checked
+	Timeout      time.Duration // Per delivery request
+	MaxAttempts  int32         // Deliveries are marked failed after this many attempts
+}
+
+// JobsConfig controls the background job queue
+type JobsConfig struct {
+	Concurrency  int           // Jobs one instance runs at the same time
+	PollInterval time.Duration // How often idle workers check for due jobs
+	Timeout      time.Duration // A job running longer is cancelled, and taken over if its worker died
+	MaxAttempts  int32         // Default attempts before a job is moved to the dead state
+	Retention    time.Duration // Succeeded jobs are deleted after this long, dead ones are kept
+}
+
+// OutboxConfig controls the dispatch of domain events from the outbox table
+type OutboxConfig struct {
+	PollInterval time.Duration // How often events written by other instances, and retries, are picked up
+	Retention    time.Duration // Dispatched events are deleted after this long
+}
+
+// MailConfig selects how emails are sent
+type MailConfig struct {
+	Driver string // "log", "file" or "smtp"
+	From   string // e.g. "CVWO Forum <noreply@example.com>"
+	Dir    string // Where the file driver writes .eml files
+	SMTP   SMTPConfig
+}
+
+// SMTPConfig points at the mail server used by the smtp driver
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // Authenticates with PLAIN when set
+	Password string
+	TLS      string        // "starttls" (required), "tls" (implicit, usually port 465) or "none"
+	Timeout  time.Duration // Per email, from connecting to the end of the exchange
+}
+
+// AccountConfig controls the links emailed to verify an address or reset a password
+type AccountConfig struct {
+	EmailVerificationTTL time.Duration
+	PasswordResetTTL     time.Duration
+}
+
+// DigestConfig controls the daily and weekly digest emails
+type DigestConfig struct {
+	MaxItems   int32         // New posts, and separately replies, listed in one digest
+	Retention  time.Duration // Records of sent digests are deleted after this long
+	SigningKey string        // Signs unsubscribe links, defaults to JWT_SECRET, required so links in sent emails keep working
+}
+
+// Enabled reports whether SSO is configured
+func (c OIDCConfig) Enabled() bool {
+	return c.IssuerURL != ""
 }
 
 // Load the env vars.
@@ -32,9 +208,640 @@ func Load() (*Config, error) {
 
 	frontendURL := os.Getenv("FRONTEND_URL")
 
+	apiURL := strings.TrimRight(os.Getenv("API_URL"), "/")
+	if apiURL == "" {
+		apiURL = "http://localhost:" + port
+	}
+
+	dbConfig, err := loadDBConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitConfig, err := loadRateLimitConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	loginConfig, err := loadLoginThrottleConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	oidcConfig, err := loadOIDCConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	twoFactorConfig, err := loadTwoFactorConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	storageConfig, err := loadStorageConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	uploadConfig, err := loadUploadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	eventsConfig, err := loadEventsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	webSocketConfig, err := loadWebSocketConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	webhookConfig, err := loadWebhookConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	jobsConfig, err := loadJobsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	outboxConfig, err := loadOutboxConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	mailConfig, err := loadMailConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	accountConfig, err := loadAccountConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	digestConfig, err := loadDigestConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
 		Port:        port,
 		DatabaseURL: databaseURL,
 		FrontendURL: frontendURL,
+		APIURL:      apiURL,
+		DB:          dbConfig,
+		RateLimit:   rateLimitConfig,
+		Login:       loginConfig,
+		OIDC:        oidcConfig,
+		TwoFactor:   twoFactorConfig,
+		Storage:     storageConfig,
+		Uploads:     uploadConfig,
+		Events:      eventsConfig,
+		WebSocket:   webSocketConfig,
+		Webhooks:    webhookConfig,
+		Jobs:        jobsConfig,
+		Outbox:      outboxConfig,
+		Mail:        mailConfig,
+		Accounts:    accountConfig,
+		Digests:     digestConfig,
 	}, nil
 }
+
+// loadDBConfig reads the optional DB_* pool settings
+func loadDBConfig() (DBConfig, error) {
+	var cfg DBConfig
+	var err error
+
+	if cfg.MaxConns, err = getEnvInt32("DB_MAX_CONNS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.MinConns, err = getEnvInt32("DB_MIN_CONNS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxConns > 0 && cfg.MinConns > cfg.MaxConns {
+		return cfg, fmt.Errorf("DB_MIN_CONNS (%d) cannot exceed DB_MAX_CONNS (%d)", cfg.MinConns, cfg.MaxConns)
+	}
+	if cfg.MaxConnLifetime, err = getEnvDuration("DB_MAX_CONN_LIFETIME", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxConnIdleTime, err = getEnvDuration("DB_MAX_CONN_IDLE_TIME", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.HealthCheckPeriod, err = getEnvDuration("DB_HEALTH_CHECK_PERIOD", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.StatementTimeout, err = getEnvDuration("DB_STATEMENT_TIMEOUT", 30*time.Second); err != nil {
+		return cfg, err
+	}
+
+	cfg.ReplicaURL = os.Getenv("DATABASE_REPLICA_URL")
+	if cfg.ReplicaMaxLag, err = getEnvDuration("DB_REPLICA_MAX_LAG", 5*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.ReplicaCheckPeriod, err = getEnvDuration("DB_REPLICA_CHECK_PERIOD", 2*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.ReplicaCheckPeriod <= 0 {
+		return cfg, fmt.Errorf("DB_REPLICA_CHECK_PERIOD must be positive")
+	}
+
+	return cfg, nil
+}
+
+// loadRateLimitConfig reads the RATE_LIMIT_* settings
+func loadRateLimitConfig() (RateLimitConfig, error) {
+	var cfg RateLimitConfig
+	var err error
+
+	if cfg.Enabled, err = getEnvBool("RATE_LIMIT_ENABLED", true); err != nil {
+		return cfg, err
+	}
+	if cfg.TrustProxyHeaders, err = getEnvBool("TRUST_PROXY_HEADERS", false); err != nil {
+		return cfg, err
+	}
+
+	cfg.Backend = os.Getenv("RATE_LIMIT_BACKEND")
+	if cfg.Backend == "" {
+		cfg.Backend = "memory"
+	}
+	if cfg.Backend != "memory" && cfg.Backend != "postgres" {
+		return cfg, fmt.Errorf("RATE_LIMIT_BACKEND must be \"memory\" or \"postgres\", got %q", cfg.Backend)
+	}
+
+	if cfg.Auth, err = getEnvRateLimitRule("RATE_LIMIT_AUTH", RateLimitRule{Requests: 10, Period: time.Minute}); err != nil {
+		return cfg, err
+	}
+	if cfg.Writes, err = getEnvRateLimitRule("RATE_LIMIT_WRITES", RateLimitRule{Requests: 30, Period: time.Minute}); err != nil {
+		return cfg, err
+	}
+	if cfg.Reads, err = getEnvRateLimitRule("RATE_LIMIT_READS", RateLimitRule{Requests: 300, Period: time.Minute}); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// loadLoginThrottleConfig reads the LOGIN_* brute-force protection settings
+func loadLoginThrottleConfig() (LoginThrottleConfig, error) {
+	var cfg LoginThrottleConfig
+	var err error
+
+	if cfg.MaxAccountFailures, err = getEnvInt32("LOGIN_MAX_ACCOUNT_FAILURES", 5); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxIPFailures, err = getEnvInt32("LOGIN_MAX_IP_FAILURES", 20); err != nil {
+		return cfg, err
+	}
+	if cfg.FailureWindow, err = getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute); err != nil {
+		return cfg, err
+	}
+	if cfg.LockoutBase, err = getEnvDuration("LOGIN_LOCKOUT_BASE", time.Minute); err != nil {
+		return cfg, err
+	}
+	if cfg.LockoutMax, err = getEnvDuration("LOGIN_LOCKOUT_MAX", time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxAccountFailures < 1 || cfg.MaxIPFailures < 1 {
+		return cfg, fmt.Errorf("LOGIN_MAX_ACCOUNT_FAILURES and LOGIN_MAX_IP_FAILURES must be at least 1")
+	}
+
+	return cfg, nil
+}
+
+// loadOIDCConfig reads the OIDC_* single sign-on settings
+func loadOIDCConfig() (OIDCConfig, error) {
+	cfg := OIDCConfig{
+		IssuerURL:         os.Getenv("OIDC_ISSUER_URL"),
+		ClientID:          os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:      os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:       os.Getenv("OIDC_REDIRECT_URL"),
+		GroupsClaim:       os.Getenv("OIDC_GROUPS_CLAIM"),
+		PostLoginRedirect: os.Getenv("OIDC_POST_LOGIN_REDIRECT"),
+		RoleMapping:       map[string]string{},
+	}
+	if !cfg.Enabled() {
+		return cfg, nil
+	}
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return cfg, fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER_URL is set")
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+
+	scopes := os.Getenv("OIDC_SCOPES")
+	if scopes == "" {
+		scopes = "profile email"
+	}
+	cfg.Scopes = strings.Fields(scopes)
+
+	// "forum-admins=admin,forum-mods=moderator"
+	if mapping := os.Getenv("OIDC_ROLE_MAPPING"); mapping != "" {
+		for _, pair := range strings.Split(mapping, ",") {
+			group, role, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if !found || group == "" || (role != "member" && role != "moderator" && role != "admin") {
+				return cfg, fmt.Errorf("OIDC_ROLE_MAPPING must look like \"group=admin,other=moderator\", got %q", mapping)
+			}
+			cfg.RoleMapping[group] = role
+		}
+	}
+
+	var err error
+	if cfg.LinkExistingUsers, err = getEnvBool("OIDC_LINK_EXISTING_USERS", false); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// loadTwoFactorConfig reads the TOTP_* two-factor settings
+func loadTwoFactorConfig() (TwoFactorConfig, error) {
+	cfg := TwoFactorConfig{Issuer: os.Getenv("TOTP_ISSUER")}
+	if cfg.Issuer == "" {
+		cfg.Issuer = "CVWO Forum"
+	}
+
+	// "moderator,admin", or "none" to make 2FA optional for everyone
+	roles := os.Getenv("TOTP_REQUIRED_ROLES")
+	if roles == "" {
+		roles = "moderator,admin"
+	}
+	if roles == "none" {
+		return cfg, nil
+	}
+	for _, role := range strings.Split(roles, ",") {
+		role = strings.TrimSpace(role)
+		if role != "member" && role != "moderator" && role != "admin" {
+			return cfg, fmt.Errorf("TOTP_REQUIRED_ROLES must be a list of roles like \"moderator,admin\" or \"none\", got %q", roles)
+		}
+		cfg.RequiredRoles = append(cfg.RequiredRoles, role)
+	}
+
+	return cfg, nil
+}
+
+// loadStorageConfig reads the STORAGE_* and S3_* file storage settings
+func loadStorageConfig() (StorageConfig, error) {
+	cfg := StorageConfig{
+		Backend:  os.Getenv("STORAGE_BACKEND"),
+		LocalDir: os.Getenv("STORAGE_LOCAL_DIR"),
+		S3: S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		},
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = "local"
+	}
+	if cfg.LocalDir == "" {
+		cfg.LocalDir = "./uploads"
+	}
+	if cfg.S3.Region == "" {
+		cfg.S3.Region = "us-east-1"
+	}
+
+	var err error
+	if cfg.S3.PathStyle, err = getEnvBool("S3_FORCE_PATH_STYLE", false); err != nil {
+		return cfg, err
+	}
+
+	switch cfg.Backend {
+	case "local":
+	case "s3":
+		if cfg.S3.Endpoint == "" || cfg.S3.Bucket == "" || cfg.S3.AccessKeyID == "" || cfg.S3.SecretAccessKey == "" {
+			return cfg, fmt.Errorf("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required when STORAGE_BACKEND is s3")
+		}
+	default:
+		return cfg, fmt.Errorf("STORAGE_BACKEND must be \"local\" or \"s3\", got %q", cfg.Backend)
+	}
+
+	return cfg, nil
+}
+
+// loadUploadConfig reads the upload size limits and attachment settings
+func loadUploadConfig() (UploadConfig, error) {
+	var cfg UploadConfig
+	var err error
+
+	if cfg.MaxAvatarBytes, err = getEnvInt64("AVATAR_MAX_BYTES", 5<<20); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxAttachmentBytes, err = getEnvInt64("ATTACHMENT_MAX_BYTES", 10<<20); err != nil {
+		return cfg, err
+	}
+	if cfg.AttachmentQuotaBytes, err = getEnvInt64("ATTACHMENT_QUOTA_BYTES", 100<<20); err != nil {
+		return cfg, err
+	}
+	if cfg.AttachmentURLTTL, err = getEnvDuration("ATTACHMENT_URL_TTL", time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.AttachmentOrphanTTL, err = getEnvDuration("ATTACHMENT_ORPHAN_TTL", 24*time.Hour); err != nil {
+		return cfg, err
+	}
+
+	cfg.AttachmentSigningKey = os.Getenv("ATTACHMENT_SIGNING_KEY")
+	if cfg.AttachmentSigningKey == "" {
+		cfg.AttachmentSigningKey = os.Getenv("JWT_SECRET")
+	}
+	if cfg.AttachmentSigningKey == "" {
+		return cfg, fmt.Errorf("ATTACHMENT_SIGNING_KEY or JWT_SECRET must be set to sign download URLs")
+	}
+
+	return cfg, nil
+}
+
+// loadEventsConfig reads the event stream heartbeat and retention
+func loadEventsConfig() (EventsConfig, error) {
+	var cfg EventsConfig
+	var err error
+
+	if cfg.Heartbeat, err = getEnvDuration("EVENTS_HEARTBEAT", 25*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.Heartbeat <= 0 {
+		return cfg, fmt.Errorf("EVENTS_HEARTBEAT must be positive")
+	}
+	if cfg.Retention, err = getEnvDuration("EVENTS_RETENTION", 24*time.Hour); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// loadWebSocketConfig reads the per-user connection cap and typing indicator throttle
+func loadWebSocketConfig() (WebSocketConfig, error) {
+	var cfg WebSocketConfig
+
+	maxConns, err := getEnvInt32("WS_MAX_CONNS_PER_USER", 5)
+	if err != nil {
+		return cfg, err
+	}
+	if maxConns < 1 {
+		return cfg, fmt.Errorf("WS_MAX_CONNS_PER_USER must be at least 1")
+	}
+	cfg.MaxConnsPerUser = int(maxConns)
+
+	if cfg.TypingInterval, err = getEnvDuration("WS_TYPING_INTERVAL", 3*time.Second); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// loadWebhookConfig reads the webhook delivery queue settings
+func loadWebhookConfig() (WebhookConfig, error) {
+	var cfg WebhookConfig
+	var err error
+
+	if cfg.PollInterval, err = getEnvDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.PollInterval <= 0 {
+		return cfg, fmt.Errorf("WEBHOOK_POLL_INTERVAL must be positive")
+	}
+	if cfg.Timeout, err = getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxAttempts, err = getEnvInt32("WEBHOOK_MAX_ATTEMPTS", 8); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxAttempts < 1 {
+		return cfg, fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be at least 1")
+	}
+
+	return cfg, nil
+}
+
+// loadJobsConfig reads the JOBS_* background worker settings
+func loadJobsConfig() (JobsConfig, error) {
+	var cfg JobsConfig
+
+	concurrency, err := getEnvInt32("JOBS_CONCURRENCY", 4)
+	if err != nil {
+		return cfg, err
+	}
+	if concurrency < 1 {
+		return cfg, fmt.Errorf("JOBS_CONCURRENCY must be at least 1")
+	}
+	cfg.Concurrency = int(concurrency)
+
+	if cfg.PollInterval, err = getEnvDuration("JOBS_POLL_INTERVAL", time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.PollInterval <= 0 {
+		return cfg, fmt.Errorf("JOBS_POLL_INTERVAL must be positive")
+	}
+	if cfg.Timeout, err = getEnvDuration("JOBS_TIMEOUT", 5*time.Minute); err != nil {
+		return cfg, err
+	}
+	if cfg.Timeout <= 0 {
+		return cfg, fmt.Errorf("JOBS_TIMEOUT must be positive")
+	}
+	if cfg.MaxAttempts, err = getEnvInt32("JOBS_MAX_ATTEMPTS", 10); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxAttempts < 1 {
+		return cfg, fmt.Errorf("JOBS_MAX_ATTEMPTS must be at least 1")
+	}
+	if cfg.Retention, err = getEnvDuration("JOBS_RETENTION", 7*24*time.Hour); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// loadOutboxConfig reads the OUTBOX_* event dispatch settings
+func loadOutboxConfig() (OutboxConfig, error) {
+	var cfg OutboxConfig
+	var err error
+
+	if cfg.PollInterval, err = getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.PollInterval <= 0 {
+		return cfg, fmt.Errorf("OUTBOX_POLL_INTERVAL must be positive")
+	}
+	if cfg.Retention, err = getEnvDuration("OUTBOX_RETENTION", 72*time.Hour); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// loadMailConfig reads the MAIL_* and SMTP_* settings
+func loadMailConfig() (MailConfig, error) {
+	cfg := MailConfig{
+		Driver: os.Getenv("MAIL_DRIVER"),
+		From:   os.Getenv("MAIL_FROM"),
+		Dir:    os.Getenv("MAIL_DIR"),
+		SMTP: SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			TLS:      os.Getenv("SMTP_TLS"),
+		},
+	}
+	if cfg.Driver == "" {
+		cfg.Driver = "log"
+	}
+	if cfg.From == "" {
+		cfg.From = "CVWO Forum <noreply@localhost>"
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "./mail"
+	}
+	if cfg.SMTP.TLS == "" {
+		cfg.SMTP.TLS = "starttls"
+	}
+
+	port, err := getEnvInt32("SMTP_PORT", 587)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.SMTP.Port = int(port)
+	if cfg.SMTP.Timeout, err = getEnvDuration("SMTP_TIMEOUT", 30*time.Second); err != nil {
+		return cfg, err
+	}
+
+	switch cfg.Driver {
+	case "log", "file":
+	case "smtp":
+		if cfg.SMTP.Host == "" {
+			return cfg, fmt.Errorf("SMTP_HOST is required when MAIL_DRIVER is smtp")
+		}
+	default:
+		return cfg, fmt.Errorf("MAIL_DRIVER must be \"log\", \"file\" or \"smtp\", got %q", cfg.Driver)
+	}
+	if cfg.SMTP.TLS != "starttls" && cfg.SMTP.TLS != "tls" && cfg.SMTP.TLS != "none" {
+		return cfg, fmt.Errorf("SMTP_TLS must be \"starttls\", \"tls\" or \"none\", got %q", cfg.SMTP.TLS)
+	}
+
+	return cfg, nil
+}
+
+// loadAccountConfig reads how long emailed account links stay valid
+func loadAccountConfig() (AccountConfig, error) {
+	var cfg AccountConfig
+	var err error
+
+	if cfg.EmailVerificationTTL, err = getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.PasswordResetTTL, err = getEnvDuration("PASSWORD_RESET_TTL", time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.EmailVerificationTTL <= 0 || cfg.PasswordResetTTL <= 0 {
+		return cfg, fmt.Errorf("EMAIL_VERIFICATION_TTL and PASSWORD_RESET_TTL must be positive")
+	}
+
+	return cfg, nil
+}
+
+// loadDigestConfig reads the DIGEST_* settings
+func loadDigestConfig() (DigestConfig, error) {
+	var cfg DigestConfig
+	var err error
+
+	if cfg.MaxItems, err = getEnvInt32("DIGEST_MAX_ITEMS", 20); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxItems == 0 {
+		return cfg, fmt.Errorf("DIGEST_MAX_ITEMS must be positive")
+	}
+	// Long enough to cover the period of a weekly digest, which is checked for items already sent
+	if cfg.Retention, err = getEnvDuration("DIGEST_RETENTION", 30*24*time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.Retention < 7*24*time.Hour {
+		return cfg, fmt.Errorf("DIGEST_RETENTION must be at least 168h")
+	}
+
+	cfg.SigningKey = os.Getenv("DIGEST_SIGNING_KEY")
+	if cfg.SigningKey == "" {
+		cfg.SigningKey = os.Getenv("JWT_SECRET")
+	}
+	if cfg.SigningKey == "" {
+		return cfg, fmt.Errorf("DIGEST_SIGNING_KEY or JWT_SECRET must be set to sign unsubscribe links")
+	}
+
+	return cfg, nil
+}
+
+// getEnvInt32 parses an integer env var, returning fallback if it is unset
+func getEnvInt32(key string, fallback int32) (int32, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", key, value)
+	}
+	return int32(parsed), nil
+}
+
+// getEnvInt64 parses an integer env var, returning fallback if it is unset
+func getEnvInt64(key string, fallback int64) (int64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", key, value)
+	}
+	return parsed, nil
+}
+
+// getEnvDuration parses a duration env var such as "30s" or "5m", returning fallback if it is unset
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative duration like \"30s\", got %q", key, value)
+	}
+	return parsed, nil
+}
+
+// getEnvBool parses a boolean env var such as "true" or "0", returning fallback if it is unset
+func getEnvBool(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false, got %q", key, value)
+	}
+	return parsed, nil
+}
+
+// getEnvRateLimitRule parses a "<requests>/<period>" env var such as "10/1m", returning fallback if it is unset
+func getEnvRateLimitRule(key string, fallback RateLimitRule) (RateLimitRule, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	requestsStr, periodStr, found := strings.Cut(value, "/")
+	requests, err := strconv.Atoi(requestsStr)
+	if !found || err != nil || requests < 1 {
+		return RateLimitRule{}, fmt.Errorf("%s must look like \"10/1m\", got %q", key, value)
+	}
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period <= 0 {
+		return RateLimitRule{}, fmt.Errorf("%s must look like \"10/1m\", got %q", key, value)
+	}
+
+	return RateLimitRule{Requests: requests, Period: period}, nil
+}
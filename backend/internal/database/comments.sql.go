@@ -12,9 +12,9 @@ import (
 )
 
 const createComment = `-- name: CreateComment :one
-INSERT INTO comments (post_id, commented_by, parent_id, body)
-VALUES ($1, $2, $3, $4)
-RETURNING comment_id, post_id, commented_by, parent_id, body, created_at, status
+INSERT INTO comments (post_id, commented_by, parent_id, body, body_html)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING comment_id, post_id, commented_by, parent_id, body, body_html, created_at, status
 `
 
 type CreateCommentParams struct {
@@ -22,6 +22,7 @@ type CreateCommentParams struct {
 	CommentedBy int64
 	ParentID    pgtype.Int8
 	Body        string
+	BodyHtml    pgtype.Text
 }
 
 type CreateCommentRow struct {
@@ -30,6 +31,7 @@ type CreateCommentRow struct {
 	CommentedBy int64
 	ParentID    pgtype.Int8
 	Body        string
+	BodyHtml    pgtype.Text
 	CreatedAt   pgtype.Timestamptz
 	Status      string
 }
@@ -40,6 +42,7 @@ func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (C
 		arg.CommentedBy,
 		arg.ParentID,
 		arg.Body,
+		arg.BodyHtml,
 	)
 	var i CreateCommentRow
 	err := row.Scan(
@@ -48,6 +51,7 @@ func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (C
 		&i.CommentedBy,
 		&i.ParentID,
 		&i.Body,
+		&i.BodyHtml,
 		&i.CreatedAt,
 		&i.Status,
 	)
@@ -75,7 +79,7 @@ func (q *Queries) DeleteComment(ctx context.Context, arg DeleteCommentParams) (i
 }
 
 const getComment = `-- name: GetComment :one
-SELECT comment_id, post_id, commented_by, parent_id, body, created_at, edited_at, status
+SELECT comment_id, post_id, commented_by, parent_id, body, body_html, created_at, edited_at, status
 FROM comments
 WHERE comment_id = $1
 `
@@ -86,6 +90,7 @@ type GetCommentRow struct {
 	CommentedBy int64
 	ParentID    pgtype.Int8
 	Body        string
+	BodyHtml    pgtype.Text
 	CreatedAt   pgtype.Timestamptz
 	EditedAt    pgtype.Timestamptz
 	Status      string
@@ -100,6 +105,7 @@ func (q *Queries) GetComment(ctx context.Context, commentID int64) (GetCommentRo
 		&i.CommentedBy,
 		&i.ParentID,
 		&i.Body,
+		&i.BodyHtml,
 		&i.CreatedAt,
 		&i.EditedAt,
 		&i.Status,
@@ -114,6 +120,7 @@ SELECT
     c.commented_by,
     c.parent_id,
     c.body,
+    c.body_html,
     c.created_at,
     c.edited_at,
     c.status,
@@ -130,6 +137,7 @@ type ListCommentsByPostRow struct {
 	CommentedBy int64
 	ParentID    pgtype.Int8
 	Body        string
+	BodyHtml    pgtype.Text
 	CreatedAt   pgtype.Timestamptz
 	EditedAt    pgtype.Timestamptz
 	Status      string
@@ -151,6 +159,7 @@ func (q *Queries) ListCommentsByPost(ctx context.Context, postID int64) ([]ListC
 			&i.CommentedBy,
 			&i.ParentID,
 			&i.Body,
+			&i.BodyHtml,
 			&i.CreatedAt,
 			&i.EditedAt,
 			&i.Status,
@@ -166,28 +175,217 @@ func (q *Queries) ListCommentsByPost(ctx context.Context, postID int64) ([]ListC
 	return items, nil
 }
 
+const listCommentsByUser = `-- name: ListCommentsByUser :many
+SELECT
+    c.comment_id,
+    c.post_id,
+    c.commented_by,
+    c.parent_id,
+    c.body,
+    c.body_html,
+    c.created_at,
+    c.edited_at,
+    c.status,
+    p.title AS post_title
+FROM comments c
+JOIN posts p ON c.post_id = p.post_id
+WHERE c.commented_by = $1
+    AND ((c.status = 'active' AND p.status = 'active') OR $2::boolean)
+ORDER BY c.created_at DESC, c.comment_id DESC
+LIMIT $3 OFFSET $4
+`
+
+type ListCommentsByUserParams struct {
+	UserID         int64
+	IncludeRemoved bool
+	PageLimit      int32
+	PageOffset     int32
+}
+
+type ListCommentsByUserRow struct {
+	CommentID   int64
+	PostID      int64
+	CommentedBy int64
+	ParentID    pgtype.Int8
+	Body        string
+	BodyHtml    pgtype.Text
+	CreatedAt   pgtype.Timestamptz
+	EditedAt    pgtype.Timestamptz
+	Status      string
+	PostTitle   string
+}
+
+func (q *Queries) ListCommentsByUser(ctx context.Context, arg ListCommentsByUserParams) ([]ListCommentsByUserRow, error) {
+	rows, err := q.db.Query(ctx, listCommentsByUser,
+		arg.UserID,
+		arg.IncludeRemoved,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCommentsByUserRow
+	for rows.Next() {
+		var i ListCommentsByUserRow
+		if err := rows.Scan(
+			&i.CommentID,
+			&i.PostID,
+			&i.CommentedBy,
+			&i.ParentID,
+			&i.Body,
+			&i.BodyHtml,
+			&i.CreatedAt,
+			&i.EditedAt,
+			&i.Status,
+			&i.PostTitle,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCommentsByUserOldestFirst = `-- name: ListCommentsByUserOldestFirst :many
+SELECT
+    c.comment_id,
+    c.post_id,
+    c.commented_by,
+    c.parent_id,
+    c.body,
+    c.body_html,
+    c.created_at,
+    c.edited_at,
+    c.status,
+    p.title AS post_title
+FROM comments c
+JOIN posts p ON c.post_id = p.post_id
+WHERE c.commented_by = $1
+    AND ((c.status = 'active' AND p.status = 'active') OR $2::boolean)
+ORDER BY c.created_at ASC, c.comment_id ASC
+LIMIT $3 OFFSET $4
+`
+
+type ListCommentsByUserOldestFirstParams struct {
+	UserID         int64
+	IncludeRemoved bool
+	PageLimit      int32
+	PageOffset     int32
+}
+
+type ListCommentsByUserOldestFirstRow struct {
+	CommentID   int64
+	PostID      int64
+	CommentedBy int64
+	ParentID    pgtype.Int8
+	Body        string
+	BodyHtml    pgtype.Text
+	CreatedAt   pgtype.Timestamptz
+	EditedAt    pgtype.Timestamptz
+	Status      string
+	PostTitle   string
+}
+
+func (q *Queries) ListCommentsByUserOldestFirst(ctx context.Context, arg ListCommentsByUserOldestFirstParams) ([]ListCommentsByUserOldestFirstRow, error) {
+	rows, err := q.db.Query(ctx, listCommentsByUserOldestFirst,
+		arg.UserID,
+		arg.IncludeRemoved,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCommentsByUserOldestFirstRow
+	for rows.Next() {
+		var i ListCommentsByUserOldestFirstRow
+		if err := rows.Scan(
+			&i.CommentID,
+			&i.PostID,
+			&i.CommentedBy,
+			&i.ParentID,
+			&i.Body,
+			&i.BodyHtml,
+			&i.CreatedAt,
+			&i.EditedAt,
+			&i.Status,
+			&i.PostTitle,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moderateComment = `-- name: ModerateComment :one
+UPDATE comments
+SET status = 'removed', removed_at = NOW(), removed_by = $2
+WHERE comment_id = $1 AND status = 'active'
+RETURNING comment_id, post_id, commented_by
+`
+
+type ModerateCommentParams struct {
+	CommentID int64
+	RemovedBy pgtype.Int8
+}
+
+type ModerateCommentRow struct {
+	CommentID   int64
+	PostID      int64
+	CommentedBy int64
+}
+
+func (q *Queries) ModerateComment(ctx context.Context, arg ModerateCommentParams) (ModerateCommentRow, error) {
+	row := q.db.QueryRow(ctx, moderateComment, arg.CommentID, arg.RemovedBy)
+	var i ModerateCommentRow
+	err := row.Scan(&i.CommentID, &i.PostID, &i.CommentedBy)
+	return i, err
+}
+
 const updateComment = `-- name: UpdateComment :one
 UPDATE comments
-SET body = $2, edited_at = NOW()
-WHERE comment_id = $1 AND commented_by = $3
-    RETURNING comment_id, body, edited_at
+SET body = $2, body_html = $4, edited_at = NOW()
+WHERE comment_id = $1 AND commented_by = $3 AND status = 'active'
+    RETURNING comment_id, body, body_html, edited_at
 `
 
 type UpdateCommentParams struct {
 	CommentID   int64
 	Body        string
 	CommentedBy int64
+	BodyHtml    pgtype.Text
 }
 
 type UpdateCommentRow struct {
 	CommentID int64
 	Body      string
+	BodyHtml  pgtype.Text
 	EditedAt  pgtype.Timestamptz
 }
 
 func (q *Queries) UpdateComment(ctx context.Context, arg UpdateCommentParams) (UpdateCommentRow, error) {
-	row := q.db.QueryRow(ctx, updateComment, arg.CommentID, arg.Body, arg.CommentedBy)
+	row := q.db.QueryRow(ctx, updateComment,
+		arg.CommentID,
+		arg.Body,
+		arg.CommentedBy,
+		arg.BodyHtml,
+	)
 	var i UpdateCommentRow
-	err := row.Scan(&i.CommentID, &i.Body, &i.EditedAt)
+	err := row.Scan(
+		&i.CommentID,
+		&i.Body,
+		&i.BodyHtml,
+		&i.EditedAt,
+	)
 	return i, err
 }
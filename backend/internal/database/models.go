@@ -8,6 +8,36 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type Attachment struct {
+	AttachmentID int64
+	UploadedBy   int64
+	StorageKey   string
+	Filename     string
+	ContentType  string
+	SizeBytes    int64
+	PostID       pgtype.Int8
+	CommentID    pgtype.Int8
+	CreatedAt    pgtype.Timestamptz
+}
+
+type Bookmark struct {
+	BookmarkID int64
+	UserID     int64
+	PostID     pgtype.Int8
+	CommentID  pgtype.Int8
+	FolderID   pgtype.Int8
+	Note       string
+	RemindAt   pgtype.Timestamptz
+	CreatedAt  pgtype.Timestamptz
+}
+
+type BookmarkFolder struct {
+	FolderID  int64
+	UserID    int64
+	Name      string
+	CreatedAt pgtype.Timestamptz
+}
+
 type Comment struct {
 	CommentID     int64
 	PostID        int64
@@ -20,6 +50,125 @@ type Comment struct {
 	RemovedAt     pgtype.Timestamptz
 	RemovedBy     pgtype.Int8
 	RemovalReason pgtype.Text
+	BodyHtml      pgtype.Text
+}
+
+type Digest struct {
+	DigestID    int64
+	UserID      int64
+	Frequency   string
+	PeriodStart pgtype.Timestamptz
+	PeriodEnd   pgtype.Timestamptz
+	PostIds     []int64
+	CommentIds  []int64
+	SentAt      pgtype.Timestamptz
+}
+
+type DigestSubscription struct {
+	UserID       int64
+	Frequency    string
+	CoveredUntil pgtype.Timestamptz
+	CreatedAt    pgtype.Timestamptz
+}
+
+type EmailToken struct {
+	TokenID   int64
+	UserID    int64
+	Purpose   string
+	TokenHash string
+	Email     string
+	ExpiresAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+type Event struct {
+	EventID   int64
+	Channel   string
+	Type      string
+	Payload   []byte
+	CreatedAt pgtype.Timestamptz
+}
+
+type Job struct {
+	JobID       int64
+	Kind        string
+	Payload     []byte
+	UniqueKey   pgtype.Text
+	Status      string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       pgtype.Timestamptz
+	LockedUntil pgtype.Timestamptz
+	LastError   pgtype.Text
+	CreatedAt   pgtype.Timestamptz
+	FinishedAt  pgtype.Timestamptz
+}
+
+type LoginAttempt struct {
+	AttemptID     int64
+	Username      string
+	UserID        pgtype.Int8
+	IpAddress     string
+	UserAgent     string
+	Success       bool
+	FailureReason pgtype.Text
+	CreatedAt     pgtype.Timestamptz
+}
+
+type LoginThrottle struct {
+	ThrottleKey   string
+	FailureCount  int32
+	LastFailureAt pgtype.Timestamptz
+	LockedUntil   pgtype.Timestamptz
+}
+
+type Notification struct {
+	NotificationID int64
+	UserID         int64
+	Type           string
+	Payload        []byte
+	ReadAt         pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
+}
+
+type NotificationPreference struct {
+	UserID  int64
+	Type    string
+	Enabled bool
+}
+
+type OidcLoginState struct {
+	State        string
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    pgtype.Timestamptz
+}
+
+type OutboxEvent struct {
+	EventID       int64
+	Type          string
+	Payload       []byte
+	DeliveredTo   []string
+	Attempts      int32
+	NextAttemptAt pgtype.Timestamptz
+	LastError     pgtype.Text
+	DispatchedAt  pgtype.Timestamptz
+	CreatedAt     pgtype.Timestamptz
+}
+
+type PersonalAccessToken struct {
+	TokenID     int64
+	UserID      int64
+	Name        string
+	TokenHash   string
+	TokenPrefix string
+	Scopes      []string
+	ExpiresAt   pgtype.Timestamptz
+	LastUsedAt  pgtype.Timestamptz
+	RevokedAt   pgtype.Timestamptz
+	CreatedAt   pgtype.Timestamptz
+	MfaVerified bool
 }
 
 type Post struct {
@@ -34,6 +183,28 @@ type Post struct {
 	RemovedAt     pgtype.Timestamptz
 	RemovedBy     pgtype.Int8
 	RemovalReason pgtype.Text
+	BodyHtml      pgtype.Text
+}
+
+type PostRead struct {
+	UserID            int64
+	PostID            int64
+	LastReadCommentID int64
+	ReadAt            pgtype.Timestamptz
+}
+
+type PostWatch struct {
+	UserID    int64
+	PostID    int64
+	Level     string
+	CreatedAt pgtype.Timestamptz
+}
+
+type RateLimitBucket struct {
+	BucketKey string
+	Tokens    float64
+	Allowed   bool
+	UpdatedAt pgtype.Timestamptz
 }
 
 type Topic struct {
@@ -50,10 +221,86 @@ type Topic struct {
 	PostCount     int64
 }
 
+type TopicFollow struct {
+	UserID    int64
+	TopicID   int64
+	CreatedAt pgtype.Timestamptz
+}
+
+type TopicMute struct {
+	UserID    int64
+	TopicID   int64
+	CreatedAt pgtype.Timestamptz
+}
+
+type TopicVisit struct {
+	UserID        int64
+	TopicID       int64
+	VisitedAt     pgtype.Timestamptz
+	SeenPostID    int64
+	ReadCommentID int64
+}
+
+type TotpRecoveryCode struct {
+	CodeID    int64
+	UserID    int64
+	CodeHash  string
+	UsedAt    pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
 type User struct {
+	UserID            int64
+	Username          string
+	PasswordHash      string
+	Bio               string
+	CreatedAt         pgtype.Timestamptz
+	Role              string
+	DisplayName       string
+	ProfileVisibility string
+	ShowActivity      bool
+	AvatarUpdatedAt   pgtype.Timestamptz
+	Email             pgtype.Text
+	EmailVerifiedAt   pgtype.Timestamptz
+	SessionsRevokedAt pgtype.Timestamptz
+}
+
+type UserIdentity struct {
+	IdentityID int64
+	UserID     int64
+	Issuer     string
+	Subject    string
+	CreatedAt  pgtype.Timestamptz
+}
+
+type UserTotp struct {
 	UserID       int64
-	Username     string
-	PasswordHash string
-	Bio          string
+	Secret       string
+	EnabledAt    pgtype.Timestamptz
+	LastUsedStep int64
 	CreatedAt    pgtype.Timestamptz
 }
+
+type Webhook struct {
+	WebhookID  int64
+	Url        string
+	Secret     string
+	EventTypes []string
+	TopicID    pgtype.Int8
+	CreatedBy  pgtype.Int8
+	CreatedAt  pgtype.Timestamptz
+}
+
+type WebhookDelivery struct {
+	DeliveryID     int64
+	WebhookID      int64
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int32
+	NextAttemptAt  pgtype.Timestamptz
+	LastStatusCode pgtype.Int4
+	LastError      pgtype.Text
+	DeliveredAt    pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
+}
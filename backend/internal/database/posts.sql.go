@@ -12,9 +12,9 @@ import (
 )
 
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (topic_id, created_by, title, body)
-VALUES ($1, $2, $3, $4)
-RETURNING post_id, topic_id, created_by, title, body, created_at, status
+INSERT INTO posts (topic_id, created_by, title, body, body_html)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING post_id, topic_id, created_by, title, body, body_html, created_at, status
 `
 
 type CreatePostParams struct {
@@ -22,6 +22,7 @@ type CreatePostParams struct {
 	CreatedBy int64
 	Title     string
 	Body      string
+	BodyHtml  pgtype.Text
 }
 
 type CreatePostRow struct {
@@ -30,6 +31,7 @@ type CreatePostRow struct {
 	CreatedBy int64
 	Title     string
 	Body      string
+	BodyHtml  pgtype.Text
 	CreatedAt pgtype.Timestamptz
 	Status    string
 }
@@ -40,6 +42,7 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (CreateP
 		arg.CreatedBy,
 		arg.Title,
 		arg.Body,
+		arg.BodyHtml,
 	)
 	var i CreatePostRow
 	err := row.Scan(
@@ -48,6 +51,7 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (CreateP
 		&i.CreatedBy,
 		&i.Title,
 		&i.Body,
+		&i.BodyHtml,
 		&i.CreatedAt,
 		&i.Status,
 	)
@@ -81,6 +85,7 @@ SELECT
     p.created_by,
     p.title,
     p.body,
+    p.body_html,
     p.created_at,
     p.status,
     u.username
@@ -95,6 +100,7 @@ type GetPostRow struct {
 	CreatedBy int64
 	Title     string
 	Body      string
+	BodyHtml  pgtype.Text
 	CreatedAt pgtype.Timestamptz
 	Status    string
 	Username  string
@@ -109,6 +115,7 @@ func (q *Queries) GetPost(ctx context.Context, postID int64) (GetPostRow, error)
 		&i.CreatedBy,
 		&i.Title,
 		&i.Body,
+		&i.BodyHtml,
 		&i.CreatedAt,
 		&i.Status,
 		&i.Username,
@@ -116,6 +123,150 @@ func (q *Queries) GetPost(ctx context.Context, postID int64) (GetPostRow, error)
 	return i, err
 }
 
+const listPostsByUser = `-- name: ListPostsByUser :many
+SELECT
+    p.post_id,
+    p.topic_id,
+    p.created_by,
+    p.title,
+    p.body,
+    p.body_html,
+    p.created_at,
+    p.status,
+    u.username
+FROM posts p
+JOIN users u ON p.created_by = u.user_id
+WHERE p.created_by = $1 AND (p.status = 'active' OR $2::boolean)
+ORDER BY p.created_at DESC, p.post_id DESC
+LIMIT $3 OFFSET $4
+`
+
+type ListPostsByUserParams struct {
+	UserID         int64
+	IncludeRemoved bool
+	PageLimit      int32
+	PageOffset     int32
+}
+
+type ListPostsByUserRow struct {
+	PostID    int64
+	TopicID   int64
+	CreatedBy int64
+	Title     string
+	Body      string
+	BodyHtml  pgtype.Text
+	CreatedAt pgtype.Timestamptz
+	Status    string
+	Username  string
+}
+
+func (q *Queries) ListPostsByUser(ctx context.Context, arg ListPostsByUserParams) ([]ListPostsByUserRow, error) {
+	rows, err := q.db.Query(ctx, listPostsByUser,
+		arg.UserID,
+		arg.IncludeRemoved,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPostsByUserRow
+	for rows.Next() {
+		var i ListPostsByUserRow
+		if err := rows.Scan(
+			&i.PostID,
+			&i.TopicID,
+			&i.CreatedBy,
+			&i.Title,
+			&i.Body,
+			&i.BodyHtml,
+			&i.CreatedAt,
+			&i.Status,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPostsByUserOldestFirst = `-- name: ListPostsByUserOldestFirst :many
+SELECT
+    p.post_id,
+    p.topic_id,
+    p.created_by,
+    p.title,
+    p.body,
+    p.body_html,
+    p.created_at,
+    p.status,
+    u.username
+FROM posts p
+JOIN users u ON p.created_by = u.user_id
+WHERE p.created_by = $1 AND (p.status = 'active' OR $2::boolean)
+ORDER BY p.created_at ASC, p.post_id ASC
+LIMIT $3 OFFSET $4
+`
+
+type ListPostsByUserOldestFirstParams struct {
+	UserID         int64
+	IncludeRemoved bool
+	PageLimit      int32
+	PageOffset     int32
+}
+
+type ListPostsByUserOldestFirstRow struct {
+	PostID    int64
+	TopicID   int64
+	CreatedBy int64
+	Title     string
+	Body      string
+	BodyHtml  pgtype.Text
+	CreatedAt pgtype.Timestamptz
+	Status    string
+	Username  string
+}
+
+func (q *Queries) ListPostsByUserOldestFirst(ctx context.Context, arg ListPostsByUserOldestFirstParams) ([]ListPostsByUserOldestFirstRow, error) {
+	rows, err := q.db.Query(ctx, listPostsByUserOldestFirst,
+		arg.UserID,
+		arg.IncludeRemoved,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPostsByUserOldestFirstRow
+	for rows.Next() {
+		var i ListPostsByUserOldestFirstRow
+		if err := rows.Scan(
+			&i.PostID,
+			&i.TopicID,
+			&i.CreatedBy,
+			&i.Title,
+			&i.Body,
+			&i.BodyHtml,
+			&i.CreatedAt,
+			&i.Status,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listPostsInTopic = `-- name: ListPostsInTopic :many
 SELECT
     p.post_id,
@@ -123,6 +274,7 @@ SELECT
     p.created_by,
     p.title,
     p.body,
+    p.body_html,
     p.created_at,
     p.status,
     u.username
@@ -138,6 +290,7 @@ type ListPostsInTopicRow struct {
 	CreatedBy int64
 	Title     string
 	Body      string
+	BodyHtml  pgtype.Text
 	CreatedAt pgtype.Timestamptz
 	Status    string
 	Username  string
@@ -158,6 +311,7 @@ func (q *Queries) ListPostsInTopic(ctx context.Context, topicID int64) ([]ListPo
 			&i.CreatedBy,
 			&i.Title,
 			&i.Body,
+			&i.BodyHtml,
 			&i.CreatedAt,
 			&i.Status,
 			&i.Username,
@@ -172,6 +326,37 @@ func (q *Queries) ListPostsInTopic(ctx context.Context, topicID int64) ([]ListPo
 	return items, nil
 }
 
+const moderatePost = `-- name: ModeratePost :one
+UPDATE posts
+SET status = 'removed', removed_at = NOW(), removed_by = $2
+WHERE post_id = $1 AND status = 'active'
+RETURNING post_id, topic_id, created_by, title
+`
+
+type ModeratePostParams struct {
+	PostID    int64
+	RemovedBy pgtype.Int8
+}
+
+type ModeratePostRow struct {
+	PostID    int64
+	TopicID   int64
+	CreatedBy int64
+	Title     string
+}
+
+func (q *Queries) ModeratePost(ctx context.Context, arg ModeratePostParams) (ModeratePostRow, error) {
+	row := q.db.QueryRow(ctx, moderatePost, arg.PostID, arg.RemovedBy)
+	var i ModeratePostRow
+	err := row.Scan(
+		&i.PostID,
+		&i.TopicID,
+		&i.CreatedBy,
+		&i.Title,
+	)
+	return i, err
+}
+
 const searchPostsGlobal = `-- name: SearchPostsGlobal :many
 SELECT
     p.post_id,
@@ -179,6 +364,7 @@ SELECT
     p.created_by,
     p.title,
     p.body,
+    p.body_html,
     p.created_at,
     p.status,
     u.username
@@ -187,22 +373,29 @@ JOIN users u ON p.created_by = u.user_id
 WHERE
     (p.title ILIKE '%' || $1 || '%' OR p.body ILIKE '%' || $1 || '%')
     AND p.status = 'active'
+    AND NOT EXISTS (SELECT 1 FROM topic_mutes m WHERE m.user_id = $2 AND m.topic_id = p.topic_id)
 ORDER BY p.created_at DESC
 `
 
+type SearchPostsGlobalParams struct {
+	Query    pgtype.Text
+	ViewerID int64
+}
+
 type SearchPostsGlobalRow struct {
 	PostID    int64
 	TopicID   int64
 	CreatedBy int64
 	Title     string
 	Body      string
+	BodyHtml  pgtype.Text
 	CreatedAt pgtype.Timestamptz
 	Status    string
 	Username  string
 }
 
-func (q *Queries) SearchPostsGlobal(ctx context.Context, dollar_1 pgtype.Text) ([]SearchPostsGlobalRow, error) {
-	rows, err := q.db.Query(ctx, searchPostsGlobal, dollar_1)
+func (q *Queries) SearchPostsGlobal(ctx context.Context, arg SearchPostsGlobalParams) ([]SearchPostsGlobalRow, error) {
+	rows, err := q.db.Query(ctx, searchPostsGlobal, arg.Query, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -216,6 +409,7 @@ func (q *Queries) SearchPostsGlobal(ctx context.Context, dollar_1 pgtype.Text) (
 			&i.CreatedBy,
 			&i.Title,
 			&i.Body,
+			&i.BodyHtml,
 			&i.CreatedAt,
 			&i.Status,
 			&i.Username,
@@ -237,6 +431,7 @@ SELECT
     p.created_by,
     p.title,
     p.body,
+    p.body_html,
     p.created_at,
     p.status,
     u.username
@@ -260,6 +455,7 @@ type SearchPostsInTopicRow struct {
 	CreatedBy int64
 	Title     string
 	Body      string
+	BodyHtml  pgtype.Text
 	CreatedAt pgtype.Timestamptz
 	Status    string
 	Username  string
@@ -280,6 +476,7 @@ func (q *Queries) SearchPostsInTopic(ctx context.Context, arg SearchPostsInTopic
 			&i.CreatedBy,
 			&i.Title,
 			&i.Body,
+			&i.BodyHtml,
 			&i.CreatedAt,
 			&i.Status,
 			&i.Username,
@@ -293,3 +490,57 @@ func (q *Queries) SearchPostsInTopic(ctx context.Context, arg SearchPostsInTopic
 	}
 	return items, nil
 }
+
+const updatePost = `-- name: UpdatePost :one
+UPDATE posts
+SET
+    title = COALESCE($1, title),
+    body = COALESCE($2, body),
+    body_html = COALESCE($3, body_html),
+    updated_at = NOW()
+WHERE post_id = $4 AND created_by = $5 AND status = 'active'
+RETURNING post_id, topic_id, created_by, title, body, body_html, created_at, updated_at, status
+`
+
+type UpdatePostParams struct {
+	Title    pgtype.Text
+	Body     pgtype.Text
+	BodyHtml pgtype.Text
+	PostID   int64
+	UserID   int64
+}
+
+type UpdatePostRow struct {
+	PostID    int64
+	TopicID   int64
+	CreatedBy int64
+	Title     string
+	Body      string
+	BodyHtml  pgtype.Text
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+	Status    string
+}
+
+func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) (UpdatePostRow, error) {
+	row := q.db.QueryRow(ctx, updatePost,
+		arg.Title,
+		arg.Body,
+		arg.BodyHtml,
+		arg.PostID,
+		arg.UserID,
+	)
+	var i UpdatePostRow
+	err := row.Scan(
+		&i.PostID,
+		&i.TopicID,
+		&i.CreatedBy,
+		&i.Title,
+		&i.Body,
+		&i.BodyHtml,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Status,
+	)
+	return i, err
+}
@@ -0,0 +1,64 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (uploaded_by, storage_key, filename, content_type, size_bytes)
+SELECT sqlc.arg('uploaded_by'), sqlc.arg('storage_key'), sqlc.arg('filename'), sqlc.arg('content_type'), sqlc.arg('size_bytes')::bigint
+WHERE (
+    SELECT COALESCE(SUM(size_bytes), 0) FROM attachments WHERE uploaded_by = sqlc.arg('uploaded_by')
+) + sqlc.arg('size_bytes')::bigint <= sqlc.arg('quota_bytes')::bigint
+RETURNING attachment_id, uploaded_by, storage_key, filename, content_type, size_bytes, post_id, comment_id, created_at;
+
+-- name: GetAttachment :one
+SELECT attachment_id, uploaded_by, storage_key, filename, content_type, size_bytes, post_id, comment_id, created_at
+FROM attachments
+WHERE attachment_id = $1;
+
+-- name: CountUnlinkedAttachments :one
+SELECT COUNT(*)
+FROM attachments
+WHERE attachment_id = ANY(sqlc.arg('attachment_ids')::bigint[])
+    AND uploaded_by = sqlc.arg('user_id')
+    AND post_id IS NULL AND comment_id IS NULL;
+
+-- name: LinkAttachmentsToPost :execrows
+UPDATE attachments
+SET post_id = sqlc.arg('post_id')
+WHERE attachment_id = ANY(sqlc.arg('attachment_ids')::bigint[])
+    AND uploaded_by = sqlc.arg('user_id')
+    AND post_id IS NULL AND comment_id IS NULL;
+
+-- name: LinkAttachmentsToComment :execrows
+UPDATE attachments
+SET comment_id = sqlc.arg('comment_id')
+WHERE attachment_id = ANY(sqlc.arg('attachment_ids')::bigint[])
+    AND uploaded_by = sqlc.arg('user_id')
+    AND post_id IS NULL AND comment_id IS NULL;
+
+-- name: ListPostAttachments :many
+SELECT attachment_id, uploaded_by, storage_key, filename, content_type, size_bytes, post_id, comment_id, created_at
+FROM attachments
+WHERE post_id = $1
+ORDER BY attachment_id;
+
+-- name: ListCommentAttachmentsByPost :many
+SELECT a.attachment_id, a.uploaded_by, a.storage_key, a.filename, a.content_type, a.size_bytes, a.post_id, a.comment_id, a.created_at
+FROM attachments a
+JOIN comments c ON a.comment_id = c.comment_id
+WHERE c.post_id = $1
+ORDER BY a.attachment_id;
+
+-- name: ListOrphanedAttachments :many
+SELECT attachment_id, uploaded_by, storage_key, filename, content_type, size_bytes, post_id, comment_id, created_at
+FROM attachments
+WHERE post_id IS NULL AND comment_id IS NULL AND created_at < sqlc.arg('created_before')
+ORDER BY created_at
+LIMIT sqlc.arg('page_limit');
+
+-- name: DeleteAttachment :exec
+DELETE FROM attachments
+WHERE attachment_id = $1;
+
+-- name: DeleteOrphanedAttachment :execrows
+DELETE FROM attachments
+WHERE attachment_id = $1 AND post_id IS NULL AND comment_id IS NULL;
+
+-- name: LockAttachmentQuota :exec
+SELECT user_id FROM users WHERE user_id = $1 FOR UPDATE;
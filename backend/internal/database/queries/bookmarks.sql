@@ -0,0 +1,88 @@
+-- name: BookmarkPost :one
+INSERT INTO bookmarks (user_id, post_id, folder_id, note, remind_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, post_id) WHERE post_id IS NOT NULL DO UPDATE
+SET folder_id = EXCLUDED.folder_id, note = EXCLUDED.note, remind_at = EXCLUDED.remind_at
+RETURNING bookmark_id, created_at;
+
+-- name: BookmarkComment :one
+INSERT INTO bookmarks (user_id, comment_id, folder_id, note, remind_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, comment_id) WHERE comment_id IS NOT NULL DO UPDATE
+SET folder_id = EXCLUDED.folder_id, note = EXCLUDED.note, remind_at = EXCLUDED.remind_at
+RETURNING bookmark_id, created_at;
+
+-- name: DeletePostBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: DeleteCommentBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1 AND comment_id = $2;
+
+-- name: ListBookmarks :many
+SELECT
+    b.bookmark_id,
+    p.post_id,
+    b.comment_id,
+    p.topic_id,
+    p.title,
+    CAST(COALESCE(c.body, p.body) AS TEXT) AS body,
+    u.username,
+    b.folder_id,
+    f.name AS folder_name,
+    b.note,
+    b.remind_at,
+    b.created_at
+FROM bookmarks b
+LEFT JOIN comments c ON c.comment_id = b.comment_id
+JOIN posts p ON p.post_id = COALESCE(b.post_id, c.post_id)
+JOIN users u ON u.user_id = COALESCE(c.commented_by, p.created_by)
+LEFT JOIN bookmark_folders f ON f.folder_id = b.folder_id
+WHERE b.user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('folder_id')::bigint IS NULL OR b.folder_id = sqlc.narg('folder_id'))
+  AND p.status = 'active' AND (c.comment_id IS NULL OR c.status = 'active')
+ORDER BY b.created_at DESC, b.bookmark_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: CreateBookmarkFolder :one
+INSERT INTO bookmark_folders (user_id, name)
+VALUES ($1, $2)
+RETURNING folder_id, name, created_at;
+
+-- name: GetBookmarkFolder :one
+SELECT folder_id, user_id, name, created_at
+FROM bookmark_folders
+WHERE folder_id = $1 AND user_id = $2;
+
+-- name: ListBookmarkFolders :many
+SELECT f.folder_id, f.name, f.created_at, COUNT(b.bookmark_id) AS bookmark_count
+FROM bookmark_folders f
+LEFT JOIN bookmarks b ON b.folder_id = f.folder_id
+WHERE f.user_id = $1
+GROUP BY f.folder_id
+ORDER BY f.name;
+
+-- name: DeleteBookmarkFolder :execrows
+DELETE FROM bookmark_folders
+WHERE folder_id = $1 AND user_id = $2;
+
+-- name: ClaimDueBookmarkReminders :many
+UPDATE bookmarks b
+SET remind_at = NULL
+FROM (
+    SELECT
+        bk.bookmark_id,
+        bk.remind_at,
+        COALESCE(bk.post_id, c.post_id) AS thread_post_id,
+        p.status <> 'removed' AND COALESCE(c.status, 'active') <> 'removed' AS available
+    FROM bookmarks bk
+    LEFT JOIN comments c ON c.comment_id = bk.comment_id
+    JOIN posts p ON p.post_id = COALESCE(bk.post_id, c.post_id)
+    WHERE bk.remind_at <= sqlc.arg('now')
+    ORDER BY bk.remind_at
+    LIMIT sqlc.arg('batch_size')
+    FOR UPDATE OF bk SKIP LOCKED
+) due
+WHERE b.bookmark_id = due.bookmark_id
+RETURNING b.bookmark_id, b.user_id, CAST(due.thread_post_id AS BIGINT) AS post_id, b.comment_id, b.note, due.remind_at, CAST(due.available AS BOOLEAN) AS available;
@@ -1,7 +1,7 @@
 -- name: CreateComment :one
-INSERT INTO comments (post_id, commented_by, parent_id, body)
-VALUES ($1, $2, $3, $4)
-RETURNING comment_id, post_id, commented_by, parent_id, body, created_at, status;
+INSERT INTO comments (post_id, commented_by, parent_id, body, body_html)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING comment_id, post_id, commented_by, parent_id, body, body_html, created_at, status;
 
 -- name: ListCommentsByPost :many
 SELECT
@@ -10,6 +10,7 @@ SELECT
     c.commented_by,
     c.parent_id,
     c.body,
+    c.body_html,
     c.created_at,
     c.edited_at,
     c.status,
@@ -20,18 +21,62 @@ WHERE c.post_id = $1
 ORDER BY c.created_at ASC;
 
 -- name: GetComment :one
-SELECT comment_id, post_id, commented_by, parent_id, body, created_at, edited_at, status
+SELECT comment_id, post_id, commented_by, parent_id, body, body_html, created_at, edited_at, status
 FROM comments
 WHERE comment_id = $1;
 
 -- name: UpdateComment :one
 UPDATE comments
-SET body = $2, edited_at = NOW()
-WHERE comment_id = $1 AND commented_by = $3
-    RETURNING comment_id, body, edited_at;
+SET body = $2, body_html = $4, edited_at = NOW()
+WHERE comment_id = $1 AND commented_by = $3 AND status = 'active'
+    RETURNING comment_id, body, body_html, edited_at;
 
 -- name: DeleteComment :one
 UPDATE comments
 SET status = 'removed', removed_at = NOW(), removed_by = $2
 WHERE comment_id = $1 AND commented_by = $3
 RETURNING comment_id;
+
+-- name: ModerateComment :one
+UPDATE comments
+SET status = 'removed', removed_at = NOW(), removed_by = $2
+WHERE comment_id = $1 AND status = 'active'
+RETURNING comment_id, post_id, commented_by;
+
+-- name: ListCommentsByUser :many
+SELECT
+    c.comment_id,
+    c.post_id,
+    c.commented_by,
+    c.parent_id,
+    c.body,
+    c.body_html,
+    c.created_at,
+    c.edited_at,
+    c.status,
+    p.title AS post_title
+FROM comments c
+JOIN posts p ON c.post_id = p.post_id
+WHERE c.commented_by = sqlc.arg('user_id')
+    AND ((c.status = 'active' AND p.status = 'active') OR sqlc.arg('include_removed')::boolean)
+ORDER BY c.created_at DESC, c.comment_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: ListCommentsByUserOldestFirst :many
+SELECT
+    c.comment_id,
+    c.post_id,
+    c.commented_by,
+    c.parent_id,
+    c.body,
+    c.body_html,
+    c.created_at,
+    c.edited_at,
+    c.status,
+    p.title AS post_title
+FROM comments c
+JOIN posts p ON c.post_id = p.post_id
+WHERE c.commented_by = sqlc.arg('user_id')
+    AND ((c.status = 'active' AND p.status = 'active') OR sqlc.arg('include_removed')::boolean)
+ORDER BY c.created_at ASC, c.comment_id ASC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
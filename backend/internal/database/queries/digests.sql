@@ -0,0 +1,73 @@
+-- name: GetDigestSubscription :one
+SELECT frequency, covered_until FROM digest_subscriptions
+WHERE user_id = $1;
+
+-- name: SetDigestSubscription :one
+INSERT INTO digest_subscriptions (user_id, frequency)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET frequency = EXCLUDED.frequency
+RETURNING frequency, covered_until;
+
+-- name: DeleteDigestSubscription :execrows
+DELETE FROM digest_subscriptions
+WHERE user_id = $1;
+
+-- name: ListDueDigestSubscriptions :many
+SELECT s.user_id FROM digest_subscriptions s
+JOIN users u ON u.user_id = s.user_id
+WHERE u.email_verified_at IS NOT NULL
+  AND s.covered_until <= sqlc.arg('until')::timestamptz - CASE s.frequency WHEN 'daily' THEN INTERVAL '1 day' ELSE INTERVAL '7 days' END
+ORDER BY s.user_id;
+
+-- name: GetDigestRecipient :one
+SELECT u.username, u.email, s.frequency, s.covered_until
+FROM digest_subscriptions s
+JOIN users u ON u.user_id = s.user_id
+WHERE s.user_id = $1 AND u.email_verified_at IS NOT NULL;
+
+-- name: ListDigestPosts :many
+SELECT p.post_id, p.topic_id, p.title, t.name AS topic_name, u.username AS author, COUNT(*) OVER () AS total_count
+FROM posts p
+JOIN topic_follows f ON f.topic_id = p.topic_id AND f.user_id = sqlc.arg('user_id')
+JOIN topics t ON t.topic_id = p.topic_id
+JOIN users u ON u.user_id = p.created_by
+WHERE p.created_at > sqlc.arg('since') AND p.created_at <= sqlc.arg('until')
+  AND p.status = 'active' AND t.status = 'active'
+  AND p.created_by <> sqlc.arg('user_id')
+  AND NOT EXISTS (
+    SELECT 1 FROM digests d
+    WHERE d.user_id = sqlc.arg('user_id') AND d.period_end > sqlc.arg('since') AND p.post_id = ANY(d.post_ids)
+  )
+ORDER BY p.created_at, p.post_id
+LIMIT sqlc.arg('max_items');
+
+-- name: ListDigestReplies :many
+SELECT c.comment_id, c.post_id, p.topic_id, c.body, p.title AS post_title, u.username AS replier_name, COUNT(*) OVER () AS total_count
+FROM comments c
+JOIN posts p ON p.post_id = c.post_id
+JOIN users u ON u.user_id = c.commented_by
+LEFT JOIN comments parent ON parent.comment_id = c.parent_id
+WHERE c.created_at > sqlc.arg('since') AND c.created_at <= sqlc.arg('until')
+  AND c.status = 'active' AND p.status = 'active'
+  AND COALESCE(parent.commented_by, p.created_by) = sqlc.arg('user_id')
+  AND c.commented_by <> sqlc.arg('user_id')
+  AND NOT EXISTS (
+    SELECT 1 FROM digests d
+    WHERE d.user_id = sqlc.arg('user_id') AND d.period_end > sqlc.arg('since') AND c.comment_id = ANY(d.comment_ids)
+  )
+ORDER BY c.created_at, c.comment_id
+LIMIT sqlc.arg('max_items');
+
+-- name: CreateDigest :execrows
+INSERT INTO digests (user_id, frequency, period_start, period_end, post_ids, comment_ids)
+VALUES (sqlc.arg('user_id'), sqlc.arg('frequency'), sqlc.arg('period_start'), sqlc.arg('period_end'), sqlc.arg('post_ids')::bigint[], sqlc.arg('comment_ids')::bigint[])
+ON CONFLICT (user_id, period_end) DO NOTHING;
+
+-- name: AdvanceDigestSubscription :exec
+UPDATE digest_subscriptions
+SET covered_until = sqlc.arg('covered_until')
+WHERE user_id = sqlc.arg('user_id') AND covered_until < sqlc.arg('covered_until');
+
+-- name: DeleteDigestsSentBefore :execrows
+DELETE FROM digests
+WHERE sent_at < $1;
@@ -0,0 +1,18 @@
+-- name: CreateEmailToken :exec
+INSERT INTO email_tokens (user_id, purpose, token_hash, email, expires_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ConsumeEmailToken :one
+UPDATE email_tokens
+SET used_at = NOW()
+WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+RETURNING user_id, email;
+
+-- name: ExpireEmailTokens :exec
+UPDATE email_tokens
+SET used_at = NOW()
+WHERE user_id = sqlc.arg('user_id') AND purpose = ANY(sqlc.arg('purposes')::text[]) AND used_at IS NULL;
+
+-- name: DeleteExpiredEmailTokens :execrows
+DELETE FROM email_tokens
+WHERE expires_at < $1;
@@ -0,0 +1,24 @@
+-- name: CreateEvent :one
+INSERT INTO events (channel, type, payload)
+VALUES ($1, $2, $3)
+RETURNING event_id;
+
+-- name: FetchEvent :one
+SELECT event_id, channel, type, payload, created_at
+FROM events
+WHERE event_id = $1;
+
+-- name: FetchEventsAfter :many
+SELECT event_id, channel, type, payload, created_at
+FROM events
+WHERE channel = ANY(sqlc.arg('channels')::text[]) AND event_id > sqlc.arg('after_id')
+ORDER BY event_id
+LIMIT sqlc.arg('page_limit');
+
+-- name: DeleteEventsBefore :execrows
+DELETE FROM events
+WHERE created_at < $1;
+
+-- name: FetchLatestEventID :one
+SELECT CAST(COALESCE(MAX(event_id), 0) AS BIGINT) AS latest_event_id
+FROM events;
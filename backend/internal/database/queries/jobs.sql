@@ -0,0 +1,65 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (kind, payload, unique_key, max_attempts, run_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (unique_key) WHERE status = 'queued' DO NOTHING
+RETURNING job_id;
+
+-- name: ClaimJob :one
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, locked_until = sqlc.arg('lease_until')
+WHERE job_id = (
+    SELECT job_id FROM jobs
+    WHERE kind = ANY(sqlc.arg('kinds')::text[])
+        AND ((status = 'queued' AND run_at <= NOW()) OR (status = 'running' AND locked_until < NOW()))
+    ORDER BY run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING job_id, kind, payload, attempts, max_attempts;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded', locked_until = NULL, finished_at = NOW()
+WHERE job_id = sqlc.arg('job_id') AND status = 'running' AND attempts = sqlc.arg('attempts');
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET
+    status = 'queued',
+    locked_until = NULL,
+    run_at = sqlc.arg('run_at'),
+    last_error = sqlc.arg('error'),
+    unique_key = CASE WHEN EXISTS (
+        SELECT 1 FROM jobs queued WHERE queued.unique_key = jobs.unique_key AND queued.status = 'queued'
+    ) THEN NULL ELSE unique_key END
+WHERE job_id = sqlc.arg('job_id') AND status = 'running' AND attempts = sqlc.arg('attempts');
+
+-- name: MarkJobDead :exec
+UPDATE jobs
+SET status = 'dead', locked_until = NULL, last_error = sqlc.arg('error'), finished_at = NOW()
+WHERE job_id = sqlc.arg('job_id') AND status = 'running' AND attempts = sqlc.arg('attempts');
+
+-- name: DeleteSucceededJobs :execrows
+DELETE FROM jobs
+WHERE status = 'succeeded' AND finished_at < $1;
+
+-- name: ListJobs :many
+SELECT job_id, kind, payload, unique_key, status, attempts, max_attempts, run_at, locked_until, last_error, created_at, finished_at
+FROM jobs
+WHERE sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status')::text
+ORDER BY created_at DESC, job_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: GetJob :one
+SELECT job_id, kind, payload, unique_key, status, attempts, max_attempts, run_at, locked_until, last_error, created_at, finished_at
+FROM jobs
+WHERE job_id = $1;
+
+-- name: RequeueDeadJob :one
+UPDATE jobs
+SET status = 'queued', attempts = 0, run_at = NOW(), finished_at = NULL
+WHERE job_id = $1 AND status = 'dead' AND NOT EXISTS (
+    SELECT 1 FROM jobs queued
+    WHERE queued.unique_key = jobs.unique_key AND queued.status = 'queued'
+)
+RETURNING job_id, kind, payload, unique_key, status, attempts, max_attempts, run_at, locked_until, last_error, created_at, finished_at;
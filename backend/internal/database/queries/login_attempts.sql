@@ -0,0 +1,38 @@
+-- name: CreateLoginAttempt :exec
+INSERT INTO login_attempts (username, user_id, ip_address, user_agent, success, failure_reason)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: ListLoginAttempts :many
+SELECT attempt_id, username, user_id, ip_address, user_agent, success, failure_reason, created_at
+FROM login_attempts
+WHERE
+    (sqlc.narg('username')::text IS NULL OR username = sqlc.narg('username'))
+    AND (sqlc.narg('ip_address')::text IS NULL OR ip_address = sqlc.narg('ip_address'))
+    AND (sqlc.narg('success')::boolean IS NULL OR success = sqlc.narg('success'))
+ORDER BY created_at DESC, attempt_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: GetLoginThrottle :one
+SELECT throttle_key, failure_count, last_failure_at, locked_until
+FROM login_throttles
+WHERE throttle_key = $1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (throttle_key, failure_count, last_failure_at)
+VALUES (sqlc.arg('throttle_key'), 1, NOW())
+ON CONFLICT (throttle_key) DO UPDATE
+SET failure_count = CASE
+        WHEN login_throttles.last_failure_at < sqlc.arg('window_start')::timestamptz THEN 1
+        ELSE login_throttles.failure_count + 1
+    END,
+    last_failure_at = NOW()
+RETURNING failure_count;
+
+-- name: LockLoginThrottle :exec
+UPDATE login_throttles
+SET locked_until = $2
+WHERE throttle_key = $1;
+
+-- name: DeleteLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE throttle_key = $1;
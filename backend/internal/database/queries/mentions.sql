@@ -0,0 +1,19 @@
+-- name: CreatePostMentions :many
+INSERT INTO mentions (user_id, mentioned_by, post_id)
+SELECT unnest(sqlc.arg('user_ids')::bigint[]), sqlc.arg('mentioned_by'), sqlc.arg('post_id')
+ON CONFLICT DO NOTHING
+RETURNING user_id;
+
+-- name: CreateCommentMentions :many
+INSERT INTO mentions (user_id, mentioned_by, comment_id)
+SELECT unnest(sqlc.arg('user_ids')::bigint[]), sqlc.arg('mentioned_by'), sqlc.arg('comment_id')
+ON CONFLICT DO NOTHING
+RETURNING user_id;
+
+-- name: DeletePostMentionsExcept :exec
+DELETE FROM mentions
+WHERE post_id = sqlc.arg('post_id') AND NOT (user_id = ANY(sqlc.arg('user_ids')::bigint[]));
+
+-- name: DeleteCommentMentionsExcept :exec
+DELETE FROM mentions
+WHERE comment_id = sqlc.arg('comment_id') AND NOT (user_id = ANY(sqlc.arg('user_ids')::bigint[]));
@@ -0,0 +1,50 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (user_id, type, payload)
+SELECT sqlc.arg('user_id')::bigint, sqlc.arg('type')::text, sqlc.arg('payload')::jsonb
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_preferences np
+    WHERE np.user_id = sqlc.arg('user_id') AND np.type = sqlc.arg('type') AND NOT np.enabled
+)
+RETURNING notification_id, created_at;
+
+-- name: ListNotifications :many
+SELECT notification_id, user_id, type, payload, read_at, created_at
+FROM notifications
+WHERE user_id = sqlc.arg('user_id') AND (read_at IS NULL OR NOT sqlc.arg('unread_only')::boolean)
+ORDER BY created_at DESC, notification_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = sqlc.arg('user_id') AND notification_id = ANY(sqlc.arg('notification_ids')::bigint[]) AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: ListNotificationPreferences :many
+SELECT type, enabled FROM notification_preferences
+WHERE user_id = $1;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled;
+
+-- name: CreatePostWatcherNotifications :many
+INSERT INTO notifications (user_id, type, payload)
+SELECT w.user_id, sqlc.arg('type')::text, sqlc.arg('payload')::jsonb
+FROM post_watches w
+WHERE w.post_id = sqlc.arg('post_id') AND w.level = 'all'
+  AND NOT w.user_id = ANY(sqlc.arg('exclude_user_ids')::bigint[])
+  AND NOT EXISTS (
+    SELECT 1 FROM notification_preferences np
+    WHERE np.user_id = w.user_id AND np.type = sqlc.arg('type') AND NOT np.enabled
+  )
+RETURNING user_id, notification_id, created_at;
@@ -0,0 +1,29 @@
+-- name: CreateOidcLoginState :exec
+INSERT INTO oidc_login_states (state, code_verifier, nonce, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: ConsumeOidcLoginState :one
+DELETE FROM oidc_login_states
+WHERE state = $1 AND expires_at > NOW()
+RETURNING code_verifier, nonce;
+
+-- name: DeleteExpiredOidcLoginStates :exec
+DELETE FROM oidc_login_states
+WHERE expires_at <= NOW();
+
+-- name: GetUserIdentity :one
+SELECT identity_id, user_id, issuer, subject, created_at
+FROM user_identities
+WHERE issuer = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (user_id, issuer, subject)
+VALUES ($1, $2, $3);
+
+-- name: UserHasIdentity :one
+SELECT EXISTS (SELECT 1 FROM user_identities WHERE user_id = $1);
+
+-- name: FindUserIDByVerifiedEmail :one
+SELECT user_id
+FROM users
+WHERE LOWER(email) = LOWER($1) AND email_verified_at IS NOT NULL;
@@ -0,0 +1,35 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox_events (type, payload)
+VALUES ($1, $2)
+RETURNING event_id;
+
+-- name: ClaimOutboxEvents :many
+UPDATE outbox_events
+SET attempts = attempts + 1, next_attempt_at = sqlc.arg('lease_until')
+WHERE event_id IN (
+    SELECT event_id FROM outbox_events
+    WHERE dispatched_at IS NULL AND next_attempt_at <= NOW()
+    ORDER BY event_id
+    LIMIT sqlc.arg('batch_size')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING event_id, type, payload, delivered_to, attempts;
+
+-- name: MarkOutboxEventDelivered :exec
+UPDATE outbox_events
+SET delivered_to = array_append(delivered_to, sqlc.arg('subscriber')::text)
+WHERE event_id = sqlc.arg('event_id') AND NOT sqlc.arg('subscriber')::text = ANY(delivered_to);
+
+-- name: FinishOutboxEvent :exec
+UPDATE outbox_events
+SET dispatched_at = NOW(), last_error = NULL
+WHERE event_id = $1;
+
+-- name: RetryOutboxEvent :exec
+UPDATE outbox_events
+SET next_attempt_at = sqlc.arg('next_attempt_at'), last_error = sqlc.arg('error')
+WHERE event_id = sqlc.arg('event_id');
+
+-- name: DeleteDispatchedOutboxEvents :execrows
+DELETE FROM outbox_events
+WHERE dispatched_at < $1;
@@ -0,0 +1,31 @@
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at, mfa_verified)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING token_id, user_id, name, token_prefix, scopes, expires_at, last_used_at, revoked_at, created_at;
+
+-- name: ListPersonalAccessTokens :many
+SELECT token_id, user_id, name, token_prefix, scopes, expires_at, last_used_at, revoked_at, created_at
+FROM personal_access_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC, token_id DESC;
+
+-- name: FetchPersonalAccessTokenByHash :one
+SELECT token_id, user_id, scopes, expires_at, revoked_at, mfa_verified
+FROM personal_access_tokens
+WHERE token_hash = $1;
+
+-- name: TouchPersonalAccessToken :exec
+UPDATE personal_access_tokens
+SET last_used_at = NOW()
+WHERE token_id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
+
+-- name: RevokePersonalAccessToken :one
+UPDATE personal_access_tokens
+SET revoked_at = NOW()
+WHERE token_id = $1 AND user_id = $2 AND revoked_at IS NULL
+RETURNING token_id;
+
+-- name: RevokeUserPersonalAccessTokens :exec
+UPDATE personal_access_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
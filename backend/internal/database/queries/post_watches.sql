@@ -0,0 +1,26 @@
+-- name: WatchPost :exec
+INSERT INTO post_watches (user_id, post_id, level)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE SET level = EXCLUDED.level;
+
+-- name: AutoWatchPost :exec
+INSERT INTO post_watches (user_id, post_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: UnwatchPost :execrows
+DELETE FROM post_watches
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: GetPostWatchLevel :one
+SELECT level FROM post_watches
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: ListWatchedPosts :many
+SELECT p.post_id, p.topic_id, p.title, p.created_at, p.created_by, u.username, w.level, w.created_at AS watched_at
+FROM post_watches w
+JOIN posts p ON p.post_id = w.post_id
+JOIN users u ON u.user_id = p.created_by
+WHERE w.user_id = sqlc.arg('user_id') AND p.status = 'active'
+ORDER BY w.created_at DESC, p.post_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
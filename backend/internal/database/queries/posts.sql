@@ -1,7 +1,7 @@
 -- name: CreatePost :one
-INSERT INTO posts (topic_id, created_by, title, body)
-VALUES ($1, $2, $3, $4)
-RETURNING post_id, topic_id, created_by, title, body, created_at, status;
+INSERT INTO posts (topic_id, created_by, title, body, body_html)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING post_id, topic_id, created_by, title, body, body_html, created_at, status;
 
 -- name: ListPostsInTopic :many
 SELECT
@@ -10,6 +10,7 @@ SELECT
     p.created_by,
     p.title,
     p.body,
+    p.body_html,
     p.created_at,
     p.status,
     u.username
@@ -25,6 +26,7 @@ SELECT
     p.created_by,
     p.title,
     p.body,
+    p.body_html,
     p.created_at,
     p.status,
     u.username
@@ -39,14 +41,16 @@ SELECT
     p.created_by,
     p.title,
     p.body,
+    p.body_html,
     p.created_at,
     p.status,
     u.username
 FROM posts p
 JOIN users u ON p.created_by = u.user_id
 WHERE
-    (p.title ILIKE '%' || $1 || '%' OR p.body ILIKE '%' || $1 || '%')
+    (p.title ILIKE '%' || sqlc.arg('query') || '%' OR p.body ILIKE '%' || sqlc.arg('query') || '%')
     AND p.status = 'active'
+    AND NOT EXISTS (SELECT 1 FROM topic_mutes m WHERE m.user_id = sqlc.arg('viewer_id') AND m.topic_id = p.topic_id)
 ORDER BY p.created_at DESC;
 
 -- name: SearchPostsInTopic :many
@@ -56,6 +60,7 @@ SELECT
     p.created_by,
     p.title,
     p.body,
+    p.body_html,
     p.created_at,
     p.status,
     u.username
@@ -72,3 +77,53 @@ UPDATE posts
 SET status = 'removed', removed_at = NOW(), removed_by = $2
 WHERE post_id = $1 AND created_by = $3
 RETURNING post_id;
+
+-- name: ModeratePost :one
+UPDATE posts
+SET status = 'removed', removed_at = NOW(), removed_by = $2
+WHERE post_id = $1 AND status = 'active'
+RETURNING post_id, topic_id, created_by, title;
+
+-- name: ListPostsByUser :many
+SELECT
+    p.post_id,
+    p.topic_id,
+    p.created_by,
+    p.title,
+    p.body,
+    p.body_html,
+    p.created_at,
+    p.status,
+    u.username
+FROM posts p
+JOIN users u ON p.created_by = u.user_id
+WHERE p.created_by = sqlc.arg('user_id') AND (p.status = 'active' OR sqlc.arg('include_removed')::boolean)
+ORDER BY p.created_at DESC, p.post_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: ListPostsByUserOldestFirst :many
+SELECT
+    p.post_id,
+    p.topic_id,
+    p.created_by,
+    p.title,
+    p.body,
+    p.body_html,
+    p.created_at,
+    p.status,
+    u.username
+FROM posts p
+JOIN users u ON p.created_by = u.user_id
+WHERE p.created_by = sqlc.arg('user_id') AND (p.status = 'active' OR sqlc.arg('include_removed')::boolean)
+ORDER BY p.created_at ASC, p.post_id ASC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: UpdatePost :one
+UPDATE posts
+SET
+    title = COALESCE(sqlc.narg('title'), title),
+    body = COALESCE(sqlc.narg('body'), body),
+    body_html = COALESCE(sqlc.narg('body_html'), body_html),
+    updated_at = NOW()
+WHERE post_id = sqlc.arg('post_id') AND created_by = sqlc.arg('user_id') AND status = 'active'
+RETURNING post_id, topic_id, created_by, title, body, body_html, created_at, updated_at, status;
@@ -0,0 +1,16 @@
+-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets AS b (bucket_key, tokens, allowed, updated_at)
+VALUES (@bucket_key, @capacity::float8 - 1, TRUE, clock_timestamp())
+ON CONFLICT (bucket_key) DO UPDATE
+SET tokens = CASE
+        WHEN LEAST(@capacity::float8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at)::float8 * @refill_per_second::float8) >= 1
+        THEN LEAST(@capacity::float8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at)::float8 * @refill_per_second::float8) - 1
+        ELSE LEAST(@capacity::float8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at)::float8 * @refill_per_second::float8)
+    END,
+    allowed = LEAST(@capacity::float8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at)::float8 * @refill_per_second::float8) >= 1,
+    updated_at = clock_timestamp()
+RETURNING tokens, allowed;
+
+-- name: DeleteStaleRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets
+WHERE updated_at < $1;
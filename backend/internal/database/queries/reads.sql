@@ -0,0 +1,56 @@
+-- name: MarkPostRead :exec
+INSERT INTO post_reads (user_id, post_id, last_read_comment_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET last_read_comment_id = GREATEST(post_reads.last_read_comment_id, EXCLUDED.last_read_comment_id),
+    read_at = NOW();
+
+-- name: GetLatestCommentID :one
+SELECT CAST(COALESCE(MAX(comment_id), 0) AS BIGINT) AS latest_comment_id
+FROM comments
+WHERE post_id = $1;
+
+-- name: RecordTopicVisit :exec
+INSERT INTO topic_visits (user_id, topic_id, seen_post_id)
+VALUES ($1, $2, (SELECT COALESCE(MAX(post_id), 0) FROM posts WHERE topic_id = $2))
+ON CONFLICT (user_id, topic_id) DO UPDATE
+SET visited_at = NOW(),
+    seen_post_id = GREATEST(topic_visits.seen_post_id, EXCLUDED.seen_post_id);
+
+-- name: MarkTopicRead :exec
+INSERT INTO topic_visits (user_id, topic_id, seen_post_id, read_comment_id)
+VALUES (
+    $1,
+    $2,
+    (SELECT COALESCE(MAX(post_id), 0) FROM posts WHERE topic_id = $2),
+    (SELECT COALESCE(MAX(comment_id), 0) FROM comments)
+)
+ON CONFLICT (user_id, topic_id) DO UPDATE
+SET visited_at = NOW(),
+    seen_post_id = GREATEST(topic_visits.seen_post_id, EXCLUDED.seen_post_id),
+    read_comment_id = GREATEST(topic_visits.read_comment_id, EXCLUDED.read_comment_id);
+
+-- name: ListUnreadCommentCounts :many
+SELECT p.post_id, unread.unread_count
+FROM posts p
+LEFT JOIN post_reads pr ON pr.user_id = sqlc.arg('user_id') AND pr.post_id = p.post_id
+LEFT JOIN topic_visits tv ON tv.user_id = sqlc.arg('user_id') AND tv.topic_id = p.topic_id
+CROSS JOIN LATERAL (
+    SELECT COUNT(*) AS unread_count
+    FROM comments c
+    WHERE c.post_id = p.post_id
+      AND c.comment_id > GREATEST(COALESCE(pr.last_read_comment_id, 0), COALESCE(tv.read_comment_id, 0))
+      AND c.status = 'active' AND c.commented_by <> sqlc.arg('user_id')
+) unread
+WHERE p.post_id = ANY(sqlc.arg('post_ids')::bigint[]) AND p.status = 'active' AND unread.unread_count > 0;
+
+-- name: ListTopicsWithNewPosts :many
+SELECT t.topic_id
+FROM topics t
+LEFT JOIN topic_visits tv ON tv.user_id = sqlc.arg('user_id') AND tv.topic_id = t.topic_id
+WHERE t.topic_id = ANY(sqlc.arg('topic_ids')::bigint[])
+  AND EXISTS (
+    SELECT 1 FROM posts p
+    WHERE p.topic_id = t.topic_id AND p.post_id > COALESCE(tv.seen_post_id, 0)
+      AND p.status = 'active' AND p.created_by <> sqlc.arg('user_id')
+  );
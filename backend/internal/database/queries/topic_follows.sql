@@ -0,0 +1,85 @@
+-- name: FollowTopic :exec
+INSERT INTO topic_follows (user_id, topic_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, topic_id) DO NOTHING;
+
+-- name: UnfollowTopic :execrows
+DELETE FROM topic_follows
+WHERE user_id = $1 AND topic_id = $2;
+
+-- name: MuteTopic :exec
+INSERT INTO topic_mutes (user_id, topic_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, topic_id) DO NOTHING;
+
+-- name: UnmuteTopic :execrows
+DELETE FROM topic_mutes
+WHERE user_id = $1 AND topic_id = $2;
+
+-- name: ListFollowedTopics :many
+SELECT t.topic_id, t.created_by, t.name, t.description, t.created_at, t.status, t.post_count, f.created_at AS followed_at
+FROM topic_follows f
+JOIN topics t ON t.topic_id = f.topic_id
+WHERE f.user_id = sqlc.arg('user_id') AND t.status = 'active'
+ORDER BY f.created_at DESC, t.topic_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: ListMutedTopics :many
+SELECT t.topic_id, t.created_by, t.name, t.description, t.created_at, t.status, t.post_count, m.created_at AS muted_at
+FROM topic_mutes m
+JOIN topics t ON t.topic_id = m.topic_id
+WHERE m.user_id = sqlc.arg('user_id') AND t.status = 'active'
+ORDER BY m.created_at DESC, t.topic_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: CountFollowedTopics :one
+SELECT COUNT(*) FROM topic_follows f
+JOIN topics t ON t.topic_id = f.topic_id
+WHERE f.user_id = $1 AND t.status = 'active';
+
+-- name: ListFeedPosts :many
+SELECT
+    p.post_id,
+    p.topic_id,
+    p.created_by,
+    p.title,
+    p.body,
+    p.body_html,
+    p.created_at,
+    p.status,
+    u.username,
+    t.name AS topic_name
+FROM posts p
+JOIN topic_follows f ON f.topic_id = p.topic_id AND f.user_id = sqlc.arg('user_id')
+JOIN topics t ON t.topic_id = p.topic_id
+JOIN users u ON p.created_by = u.user_id
+WHERE p.status = 'active' AND t.status = 'active'
+ORDER BY p.created_at DESC, p.post_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: ListPopularFeedPosts :many
+WITH popular AS (
+    SELECT t.topic_id, t.name
+    FROM topics t
+    WHERE t.status = 'active'
+      AND NOT EXISTS (SELECT 1 FROM topic_mutes m WHERE m.user_id = sqlc.arg('user_id') AND m.topic_id = t.topic_id)
+    ORDER BY t.post_count DESC, t.topic_id
+    LIMIT sqlc.arg('topic_limit')
+)
+SELECT
+    p.post_id,
+    p.topic_id,
+    p.created_by,
+    p.title,
+    p.body,
+    p.body_html,
+    p.created_at,
+    p.status,
+    u.username,
+    popular.name AS topic_name
+FROM posts p
+JOIN popular ON popular.topic_id = p.topic_id
+JOIN users u ON p.created_by = u.user_id
+WHERE p.status = 'active'
+ORDER BY p.created_at DESC, p.post_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
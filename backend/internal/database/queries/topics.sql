@@ -5,8 +5,9 @@ RETURNING topic_id, created_by, name, description, created_at, status, post_coun
 
 -- name: ListTopics :many
 SELECT topic_id, created_by, name, description, created_at, status, post_count
-FROM topics
+FROM topics t
 WHERE status = 'active'
+  AND NOT EXISTS (SELECT 1 FROM topic_mutes m WHERE m.user_id = $1 AND m.topic_id = t.topic_id)
 ORDER BY created_at DESC;
 
 -- name: GetTopic :one
@@ -16,21 +17,28 @@ WHERE topic_id = $1;
 
 -- name: SearchTopics :many
 SELECT topic_id, created_by, name, description, created_at, status, post_count
-FROM topics
+FROM topics t
 WHERE
-    (name ILIKE '%' || $1 || '%' OR description ILIKE '%' || $1 || '%')
+    (name ILIKE '%' || sqlc.arg('query') || '%' OR description ILIKE '%' || sqlc.arg('query') || '%')
     AND status = 'active'
+    AND NOT EXISTS (SELECT 1 FROM topic_mutes m WHERE m.user_id = sqlc.arg('viewer_id') AND m.topic_id = t.topic_id)
 ORDER BY created_at DESC;
 
--- name: IncrementPostCount :exec
+-- name: RecountTopicPosts :exec
 UPDATE topics
-SET post_count = post_count + 1
+SET post_count = (SELECT COUNT(*) FROM posts WHERE posts.topic_id = topics.topic_id AND posts.status = 'active')
 WHERE topic_id = $1;
 
--- name: DecrementPostCount :exec
+-- name: RecountAllTopicPosts :execrows
 UPDATE topics
-SET post_count = post_count - 1
-WHERE topic_id = $1;
+SET post_count = counts.actual
+FROM (
+    SELECT t.topic_id, COUNT(p.post_id) AS actual
+    FROM topics t
+    LEFT JOIN posts p ON p.topic_id = t.topic_id AND p.status = 'active'
+    GROUP BY t.topic_id
+) counts
+WHERE topics.topic_id = counts.topic_id AND topics.post_count <> counts.actual;
 
 -- name: DeleteTopic :one
 UPDATE topics
@@ -40,3 +48,17 @@ SET status = 'removed',
     name = name || '_deleted_' || CAST(EXTRACT(EPOCH FROM NOW()) AS TEXT)
 WHERE topic_id = $1 AND created_by = $3
 RETURNING topic_id;
+
+-- name: ListTopicsByUser :many
+SELECT topic_id, created_by, name, description, created_at, status, post_count
+FROM topics
+WHERE created_by = sqlc.arg('user_id') AND (status = 'active' OR sqlc.arg('include_removed')::boolean)
+ORDER BY created_at DESC, topic_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: ListTopicsByUserOldestFirst :many
+SELECT topic_id, created_by, name, description, created_at, status, post_count
+FROM topics
+WHERE created_by = sqlc.arg('user_id') AND (status = 'active' OR sqlc.arg('include_removed')::boolean)
+ORDER BY created_at ASC, topic_id ASC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
@@ -0,0 +1,43 @@
+-- name: FetchUserTOTP :one
+SELECT user_id, secret, enabled_at, last_used_step, created_at
+FROM user_totp
+WHERE user_id = $1;
+
+-- name: UpsertPendingUserTOTP :exec
+INSERT INTO user_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, enabled_at = NULL, last_used_step = 0, created_at = NOW()
+WHERE user_totp.enabled_at IS NULL;
+
+-- name: EnableUserTOTP :exec
+UPDATE user_totp
+SET enabled_at = NOW()
+WHERE user_id = $1;
+
+-- name: UseTOTPStep :execrows
+UPDATE user_totp
+SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO totp_recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: UseRecoveryCode :execrows
+UPDATE totp_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*)
+FROM totp_recovery_codes
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM totp_recovery_codes
+WHERE user_id = $1;
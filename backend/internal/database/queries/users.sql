@@ -3,12 +3,121 @@ INSERT INTO users (username, password_hash, bio)
 VALUES ($1, $2, $3)
 RETURNING user_id, username, bio, created_at;
 
--- name: GetUserByUsername :one
-SELECT user_id, username, password_hash, bio, created_at
+-- name: FetchUserByUsername :one
+SELECT user_id, username, password_hash, bio, created_at, role, display_name, profile_visibility, show_activity, avatar_updated_at, email, email_verified_at, sessions_revoked_at
 FROM users
 WHERE username = $1;
 
+-- name: GetUser :one
+SELECT user_id, username, password_hash, bio, created_at, role, display_name, profile_visibility, show_activity, avatar_updated_at, email, email_verified_at, sessions_revoked_at
+FROM users
+WHERE user_id = $1;
+
+-- name: GetUserProfile :one
+SELECT
+    u.user_id,
+    u.username,
+    u.display_name,
+    u.bio,
+    u.created_at,
+    u.role,
+    u.profile_visibility,
+    u.show_activity,
+    u.avatar_updated_at,
+    u.email,
+    u.email_verified_at,
+    (SELECT COUNT(*) FROM topics t WHERE t.created_by = u.user_id AND t.status = 'active') AS topic_count,
+    (SELECT COUNT(*) FROM posts p WHERE p.created_by = u.user_id AND p.status = 'active') AS post_count,
+    (SELECT COUNT(*) FROM comments c WHERE c.commented_by = u.user_id AND c.status = 'active') AS comment_count
+FROM users u
+WHERE u.username = $1;
+
+-- name: ListRecentUserActivity :many
+SELECT kind, item_id, post_id, title, created_at
+FROM (
+    SELECT 'topic'::TEXT AS kind, t.topic_id AS item_id, NULL::BIGINT AS post_id, t.name AS title, t.created_at
+    FROM topics t
+    WHERE t.created_by = sqlc.arg('user_id') AND t.status = 'active'
+    UNION ALL
+    SELECT 'post'::TEXT, p.post_id, p.post_id, p.title, p.created_at
+    FROM posts p
+    WHERE p.created_by = sqlc.arg('user_id') AND p.status = 'active'
+    UNION ALL
+    SELECT 'comment'::TEXT, c.comment_id, c.post_id, p.title, c.created_at
+    FROM comments c
+    JOIN posts p ON c.post_id = p.post_id
+    WHERE c.commented_by = sqlc.arg('user_id') AND c.status = 'active' AND p.status = 'active'
+) activity
+ORDER BY created_at DESC
+LIMIT sqlc.arg('page_limit');
+
 -- name: ListUsers :many
-SELECT user_id, username, bio, created_at
+SELECT user_id, username, display_name, role, profile_visibility, created_at
+FROM users
+WHERE sqlc.narg('query')::text IS NULL OR username ILIKE '%' || sqlc.narg('query') || '%' OR display_name ILIKE '%' || sqlc.narg('query') || '%'
+ORDER BY created_at DESC, user_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: SetUserAvatar :one
+UPDATE users
+SET avatar_updated_at = NOW()
+WHERE user_id = $1
+RETURNING avatar_updated_at;
+
+-- name: ClearUserAvatar :exec
+UPDATE users
+SET avatar_updated_at = NULL
+WHERE user_id = $1;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET
+    display_name = COALESCE(sqlc.narg('display_name'), display_name),
+    bio = COALESCE(sqlc.narg('bio'), bio),
+    profile_visibility = COALESCE(sqlc.narg('profile_visibility'), profile_visibility),
+    show_activity = COALESCE(sqlc.narg('show_activity'), show_activity)
+WHERE user_id = sqlc.arg('user_id')
+RETURNING user_id, username, display_name, bio, created_at, role, profile_visibility, show_activity, avatar_updated_at;
+
+-- name: UpdateUserRole :exec
+UPDATE users
+SET role = $2
+WHERE user_id = $1;
+
+-- name: GetUsersByUsernames :many
+SELECT user_id, username
+FROM users
+WHERE username = ANY(sqlc.arg('usernames')::text[]);
+
+-- name: SetUserEmail :one
+UPDATE users
+SET
+    email = sqlc.arg('email'),
+    email_verified_at = CASE WHEN LOWER(email) = LOWER(sqlc.arg('email')) THEN email_verified_at END -- Kept unless the address changes
+WHERE user_id = sqlc.arg('user_id')
+RETURNING email_verified_at;
+
+-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = NOW()
+WHERE user_id = $1 AND LOWER(email) = LOWER($2);
+
+-- name: GetUserByEmail :one
+SELECT user_id, username, email, email_verified_at
+FROM users
+WHERE LOWER(email) = LOWER($1);
+
+-- name: ResetUserPassword :exec
+UPDATE users
+SET password_hash = $2, sessions_revoked_at = NOW()
+WHERE user_id = $1;
+
+-- name: FetchUserSessionsRevokedAt :one
+SELECT sessions_revoked_at
+FROM users
+WHERE user_id = $1;
+
+-- name: FetchUserRole :one
+SELECT role
 FROM users
-ORDER BY created_at DESC;
\ No newline at end of file
+WHERE user_id = $1;
@@ -0,0 +1,62 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (url, secret, event_types, topic_id, created_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING webhook_id, url, secret, event_types, topic_id, created_by, created_at;
+
+-- name: ListWebhooks :many
+SELECT webhook_id, url, secret, event_types, topic_id, created_by, created_at
+FROM webhooks
+ORDER BY webhook_id;
+
+-- name: GetWebhook :one
+SELECT webhook_id, url, secret, event_types, topic_id, created_by, created_at
+FROM webhooks
+WHERE webhook_id = $1;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE webhook_id = $1;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+SELECT webhook_id, sqlc.arg('event_type')::text, sqlc.arg('payload')::jsonb
+FROM webhooks
+WHERE sqlc.arg('event_type')::text = ANY(event_types)
+    AND (topic_id IS NULL OR topic_id = sqlc.narg('topic_id')::bigint);
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1, next_attempt_at = sqlc.arg('lease_until')
+FROM webhooks w
+WHERE d.webhook_id = w.webhook_id AND d.delivery_id IN (
+    SELECT delivery_id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('batch_size')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING d.delivery_id, d.webhook_id, d.event_type, d.payload, d.attempts, d.created_at, w.url, w.secret;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET
+    status = sqlc.arg('status'),
+    next_attempt_at = COALESCE(sqlc.narg('next_attempt_at'), next_attempt_at),
+    last_status_code = sqlc.narg('status_code'),
+    last_error = sqlc.narg('error'),
+    delivered_at = CASE WHEN sqlc.arg('status') = 'succeeded' THEN NOW() ELSE delivered_at END
+WHERE delivery_id = sqlc.arg('delivery_id');
+
+-- name: ListWebhookDeliveries :many
+SELECT delivery_id, webhook_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at
+FROM webhook_deliveries
+WHERE webhook_id = sqlc.arg('webhook_id')
+ORDER BY created_at DESC, delivery_id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: RedeliverWebhookDelivery :one
+INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+SELECT webhook_id, event_type, payload
+FROM webhook_deliveries
+WHERE delivery_id = $1 AND webhook_id = $2
+RETURNING delivery_id, webhook_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at;
@@ -48,17 +48,6 @@ func (q *Queries) CreateTopic(ctx context.Context, arg CreateTopicParams) (Creat
 	return i, err
 }
 
-const decrementPostCount = `-- name: DecrementPostCount :exec
-UPDATE topics
-SET post_count = post_count - 1
-WHERE topic_id = $1
-`
-
-func (q *Queries) DecrementPostCount(ctx context.Context, topicID int64) error {
-	_, err := q.db.Exec(ctx, decrementPostCount, topicID)
-	return err
-}
-
 const deleteTopic = `-- name: DeleteTopic :one
 UPDATE topics
 SET status = 'removed',
@@ -113,21 +102,11 @@ func (q *Queries) GetTopic(ctx context.Context, topicID int64) (GetTopicRow, err
 	return i, err
 }
 
-const incrementPostCount = `-- name: IncrementPostCount :exec
-UPDATE topics
-SET post_count = post_count + 1
-WHERE topic_id = $1
-`
-
-func (q *Queries) IncrementPostCount(ctx context.Context, topicID int64) error {
-	_, err := q.db.Exec(ctx, incrementPostCount, topicID)
-	return err
-}
-
 const listTopics = `-- name: ListTopics :many
 SELECT topic_id, created_by, name, description, created_at, status, post_count
-FROM topics
+FROM topics t
 WHERE status = 'active'
+  AND NOT EXISTS (SELECT 1 FROM topic_mutes m WHERE m.user_id = $1 AND m.topic_id = t.topic_id)
 ORDER BY created_at DESC
 `
 
@@ -141,8 +120,8 @@ type ListTopicsRow struct {
 	PostCount   int64
 }
 
-func (q *Queries) ListTopics(ctx context.Context) ([]ListTopicsRow, error) {
-	rows, err := q.db.Query(ctx, listTopics)
+func (q *Queries) ListTopics(ctx context.Context, userID int64) ([]ListTopicsRow, error) {
+	rows, err := q.db.Query(ctx, listTopics, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -169,15 +148,168 @@ func (q *Queries) ListTopics(ctx context.Context) ([]ListTopicsRow, error) {
 	return items, nil
 }
 
-const searchTopics = `-- name: SearchTopics :many
+const listTopicsByUser = `-- name: ListTopicsByUser :many
+SELECT topic_id, created_by, name, description, created_at, status, post_count
+FROM topics
+WHERE created_by = $1 AND (status = 'active' OR $2::boolean)
+ORDER BY created_at DESC, topic_id DESC
+LIMIT $3 OFFSET $4
+`
+
+type ListTopicsByUserParams struct {
+	UserID         int64
+	IncludeRemoved bool
+	PageLimit      int32
+	PageOffset     int32
+}
+
+type ListTopicsByUserRow struct {
+	TopicID     int64
+	CreatedBy   int64
+	Name        string
+	Description string
+	CreatedAt   pgtype.Timestamptz
+	Status      string
+	PostCount   int64
+}
+
+func (q *Queries) ListTopicsByUser(ctx context.Context, arg ListTopicsByUserParams) ([]ListTopicsByUserRow, error) {
+	rows, err := q.db.Query(ctx, listTopicsByUser,
+		arg.UserID,
+		arg.IncludeRemoved,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTopicsByUserRow
+	for rows.Next() {
+		var i ListTopicsByUserRow
+		if err := rows.Scan(
+			&i.TopicID,
+			&i.CreatedBy,
+			&i.Name,
+			&i.Description,
+			&i.CreatedAt,
+			&i.Status,
+			&i.PostCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTopicsByUserOldestFirst = `-- name: ListTopicsByUserOldestFirst :many
 SELECT topic_id, created_by, name, description, created_at, status, post_count
 FROM topics
+WHERE created_by = $1 AND (status = 'active' OR $2::boolean)
+ORDER BY created_at ASC, topic_id ASC
+LIMIT $3 OFFSET $4
+`
+
+type ListTopicsByUserOldestFirstParams struct {
+	UserID         int64
+	IncludeRemoved bool
+	PageLimit      int32
+	PageOffset     int32
+}
+
+type ListTopicsByUserOldestFirstRow struct {
+	TopicID     int64
+	CreatedBy   int64
+	Name        string
+	Description string
+	CreatedAt   pgtype.Timestamptz
+	Status      string
+	PostCount   int64
+}
+
+func (q *Queries) ListTopicsByUserOldestFirst(ctx context.Context, arg ListTopicsByUserOldestFirstParams) ([]ListTopicsByUserOldestFirstRow, error) {
+	rows, err := q.db.Query(ctx, listTopicsByUserOldestFirst,
+		arg.UserID,
+		arg.IncludeRemoved,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTopicsByUserOldestFirstRow
+	for rows.Next() {
+		var i ListTopicsByUserOldestFirstRow
+		if err := rows.Scan(
+			&i.TopicID,
+			&i.CreatedBy,
+			&i.Name,
+			&i.Description,
+			&i.CreatedAt,
+			&i.Status,
+			&i.PostCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recountAllTopicPosts = `-- name: RecountAllTopicPosts :execrows
+UPDATE topics
+SET post_count = counts.actual
+FROM (
+    SELECT t.topic_id, COUNT(p.post_id) AS actual
+    FROM topics t
+    LEFT JOIN posts p ON p.topic_id = t.topic_id AND p.status = 'active'
+    GROUP BY t.topic_id
+) counts
+WHERE topics.topic_id = counts.topic_id AND topics.post_count <> counts.actual
+`
+
+func (q *Queries) RecountAllTopicPosts(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, recountAllTopicPosts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const recountTopicPosts = `-- name: RecountTopicPosts :exec
+UPDATE topics
+SET post_count = (SELECT COUNT(*) FROM posts WHERE posts.topic_id = topics.topic_id AND posts.status = 'active')
+WHERE topic_id = $1
+`
+
+func (q *Queries) RecountTopicPosts(ctx context.Context, topicID int64) error {
+	_, err := q.db.Exec(ctx, recountTopicPosts, topicID)
+	return err
+}
+
+const searchTopics = `-- name: SearchTopics :many
+SELECT topic_id, created_by, name, description, created_at, status, post_count
+FROM topics t
 WHERE
     (name ILIKE '%' || $1 || '%' OR description ILIKE '%' || $1 || '%')
     AND status = 'active'
+    AND NOT EXISTS (SELECT 1 FROM topic_mutes m WHERE m.user_id = $2 AND m.topic_id = t.topic_id)
 ORDER BY created_at DESC
 `
 
+type SearchTopicsParams struct {
+	Query    pgtype.Text
+	ViewerID int64
+}
+
 type SearchTopicsRow struct {
 	TopicID     int64
 	CreatedBy   int64
@@ -188,8 +320,8 @@ type SearchTopicsRow struct {
 	PostCount   int64
 }
 
-func (q *Queries) SearchTopics(ctx context.Context, dollar_1 pgtype.Text) ([]SearchTopicsRow, error) {
-	rows, err := q.db.Query(ctx, searchTopics, dollar_1)
+func (q *Queries) SearchTopics(ctx context.Context, arg SearchTopicsParams) ([]SearchTopicsRow, error) {
+	rows, err := q.db.Query(ctx, searchTopics, arg.Query, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// txBeginner is a DBTX that can start transactions, such as a pgxpool.Pool or a dbConnection.RoutedDB
+type txBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// InTx runs fn with queries bound to a new transaction on the primary, committing if fn returns nil
+// and rolling back otherwise
+func (q *Queries) InTx(ctx context.Context, fn func(tx *Queries) error) error {
+	beginner, ok := q.db.(txBeginner)
+	if !ok {
+		return errors.New("database connection does not support transactions")
+	}
+
+	tx, err := beginner.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background()) // No-op once committed
+
+	if err := fn(q.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -11,6 +11,17 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const clearUserAvatar = `-- name: ClearUserAvatar :exec
+UPDATE users
+SET avatar_updated_at = NULL
+WHERE user_id = $1
+`
+
+func (q *Queries) ClearUserAvatar(ctx context.Context, userID int64) error {
+	_, err := q.db.Exec(ctx, clearUserAvatar, userID)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (username, password_hash, bio)
 VALUES ($1, $2, $3)
@@ -42,14 +53,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateU
 	return i, err
 }
 
-const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT user_id, username, password_hash, bio, created_at
+const fetchUserByUsername = `-- name: FetchUserByUsername :one
+SELECT user_id, username, password_hash, bio, created_at, role, display_name, profile_visibility, show_activity, avatar_updated_at, email, email_verified_at, sessions_revoked_at
 FROM users
 WHERE username = $1
 `
 
-func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
-	row := q.db.QueryRow(ctx, getUserByUsername, username)
+func (q *Queries) FetchUserByUsername(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRow(ctx, fetchUserByUsername, username)
 	var i User
 	err := row.Scan(
 		&i.UserID,
@@ -57,25 +68,270 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.PasswordHash,
 		&i.Bio,
 		&i.CreatedAt,
+		&i.Role,
+		&i.DisplayName,
+		&i.ProfileVisibility,
+		&i.ShowActivity,
+		&i.AvatarUpdatedAt,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.SessionsRevokedAt,
 	)
 	return i, err
 }
 
-const listUsers = `-- name: ListUsers :many
-SELECT user_id, username, bio, created_at
+const fetchUserRole = `-- name: FetchUserRole :one
+SELECT role
+FROM users
+WHERE user_id = $1
+`
+
+func (q *Queries) FetchUserRole(ctx context.Context, userID int64) (string, error) {
+	row := q.db.QueryRow(ctx, fetchUserRole, userID)
+	var role string
+	err := row.Scan(&role)
+	return role, err
+}
+
+const fetchUserSessionsRevokedAt = `-- name: FetchUserSessionsRevokedAt :one
+SELECT sessions_revoked_at
+FROM users
+WHERE user_id = $1
+`
+
+func (q *Queries) FetchUserSessionsRevokedAt(ctx context.Context, userID int64) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, fetchUserSessionsRevokedAt, userID)
+	var sessions_revoked_at pgtype.Timestamptz
+	err := row.Scan(&sessions_revoked_at)
+	return sessions_revoked_at, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT user_id, username, password_hash, bio, created_at, role, display_name, profile_visibility, show_activity, avatar_updated_at, email, email_verified_at, sessions_revoked_at
+FROM users
+WHERE user_id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, userID int64) (User, error) {
+	row := q.db.QueryRow(ctx, getUser, userID)
+	var i User
+	err := row.Scan(
+		&i.UserID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.Bio,
+		&i.CreatedAt,
+		&i.Role,
+		&i.DisplayName,
+		&i.ProfileVisibility,
+		&i.ShowActivity,
+		&i.AvatarUpdatedAt,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.SessionsRevokedAt,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT user_id, username, email, email_verified_at
+FROM users
+WHERE LOWER(email) = LOWER($1)
+`
+
+type GetUserByEmailRow struct {
+	UserID          int64
+	Username        string
+	Email           pgtype.Text
+	EmailVerifiedAt pgtype.Timestamptz
+}
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i GetUserByEmailRow
+	err := row.Scan(
+		&i.UserID,
+		&i.Username,
+		&i.Email,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserProfile = `-- name: GetUserProfile :one
+SELECT
+    u.user_id,
+    u.username,
+    u.display_name,
+    u.bio,
+    u.created_at,
+    u.role,
+    u.profile_visibility,
+    u.show_activity,
+    u.avatar_updated_at,
+    u.email,
+    u.email_verified_at,
+    (SELECT COUNT(*) FROM topics t WHERE t.created_by = u.user_id AND t.status = 'active') AS topic_count,
+    (SELECT COUNT(*) FROM posts p WHERE p.created_by = u.user_id AND p.status = 'active') AS post_count,
+    (SELECT COUNT(*) FROM comments c WHERE c.commented_by = u.user_id AND c.status = 'active') AS comment_count
+FROM users u
+WHERE u.username = $1
+`
+
+type GetUserProfileRow struct {
+	UserID            int64
+	Username          string
+	DisplayName       string
+	Bio               string
+	CreatedAt         pgtype.Timestamptz
+	Role              string
+	ProfileVisibility string
+	ShowActivity      bool
+	AvatarUpdatedAt   pgtype.Timestamptz
+	Email             pgtype.Text
+	EmailVerifiedAt   pgtype.Timestamptz
+	TopicCount        int64
+	PostCount         int64
+	CommentCount      int64
+}
+
+func (q *Queries) GetUserProfile(ctx context.Context, username string) (GetUserProfileRow, error) {
+	row := q.db.QueryRow(ctx, getUserProfile, username)
+	var i GetUserProfileRow
+	err := row.Scan(
+		&i.UserID,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.CreatedAt,
+		&i.Role,
+		&i.ProfileVisibility,
+		&i.ShowActivity,
+		&i.AvatarUpdatedAt,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.TopicCount,
+		&i.PostCount,
+		&i.CommentCount,
+	)
+	return i, err
+}
+
+const getUsersByUsernames = `-- name: GetUsersByUsernames :many
+SELECT user_id, username
 FROM users
+WHERE username = ANY($1::text[])
+`
+
+type GetUsersByUsernamesRow struct {
+	UserID   int64
+	Username string
+}
+
+func (q *Queries) GetUsersByUsernames(ctx context.Context, usernames []string) ([]GetUsersByUsernamesRow, error) {
+	rows, err := q.db.Query(ctx, getUsersByUsernames, usernames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersByUsernamesRow
+	for rows.Next() {
+		var i GetUsersByUsernamesRow
+		if err := rows.Scan(&i.UserID, &i.Username); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRecentUserActivity = `-- name: ListRecentUserActivity :many
+SELECT kind, item_id, post_id, title, created_at
+FROM (
+    SELECT 'topic'::TEXT AS kind, t.topic_id AS item_id, NULL::BIGINT AS post_id, t.name AS title, t.created_at
+    FROM topics t
+    WHERE t.created_by = $1 AND t.status = 'active'
+    UNION ALL
+    SELECT 'post'::TEXT, p.post_id, p.post_id, p.title, p.created_at
+    FROM posts p
+    WHERE p.created_by = $1 AND p.status = 'active'
+    UNION ALL
+    SELECT 'comment'::TEXT, c.comment_id, c.post_id, p.title, c.created_at
+    FROM comments c
+    JOIN posts p ON c.post_id = p.post_id
+    WHERE c.commented_by = $1 AND c.status = 'active' AND p.status = 'active'
+) activity
 ORDER BY created_at DESC
+LIMIT $2
 `
 
-type ListUsersRow struct {
+type ListRecentUserActivityParams struct {
 	UserID    int64
-	Username  string
-	Bio       string
+	PageLimit int32
+}
+
+type ListRecentUserActivityRow struct {
+	Kind      string
+	ItemID    int64
+	PostID    pgtype.Int8
+	Title     string
 	CreatedAt pgtype.Timestamptz
 }
 
-func (q *Queries) ListUsers(ctx context.Context) ([]ListUsersRow, error) {
-	rows, err := q.db.Query(ctx, listUsers)
+func (q *Queries) ListRecentUserActivity(ctx context.Context, arg ListRecentUserActivityParams) ([]ListRecentUserActivityRow, error) {
+	rows, err := q.db.Query(ctx, listRecentUserActivity, arg.UserID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRecentUserActivityRow
+	for rows.Next() {
+		var i ListRecentUserActivityRow
+		if err := rows.Scan(
+			&i.Kind,
+			&i.ItemID,
+			&i.PostID,
+			&i.Title,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT user_id, username, display_name, role, profile_visibility, created_at
+FROM users
+WHERE $1::text IS NULL OR username ILIKE '%' || $1 || '%' OR display_name ILIKE '%' || $1 || '%'
+ORDER BY created_at DESC, user_id DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListUsersParams struct {
+	Query      pgtype.Text
+	PageLimit  int32
+	PageOffset int32
+}
+
+type ListUsersRow struct {
+	UserID            int64
+	Username          string
+	DisplayName       string
+	Role              string
+	ProfileVisibility string
+	CreatedAt         pgtype.Timestamptz
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUsersRow, error) {
+	rows, err := q.db.Query(ctx, listUsers, arg.Query, arg.PageLimit, arg.PageOffset)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +342,9 @@ func (q *Queries) ListUsers(ctx context.Context) ([]ListUsersRow, error) {
 		if err := rows.Scan(
 			&i.UserID,
 			&i.Username,
-			&i.Bio,
+			&i.DisplayName,
+			&i.Role,
+			&i.ProfileVisibility,
 			&i.CreatedAt,
 		); err != nil {
 			return nil, err
@@ -98,3 +356,143 @@ func (q *Queries) ListUsers(ctx context.Context) ([]ListUsersRow, error) {
 	}
 	return items, nil
 }
+
+const resetUserPassword = `-- name: ResetUserPassword :exec
+UPDATE users
+SET password_hash = $2, sessions_revoked_at = NOW()
+WHERE user_id = $1
+`
+
+type ResetUserPasswordParams struct {
+	UserID       int64
+	PasswordHash string
+}
+
+func (q *Queries) ResetUserPassword(ctx context.Context, arg ResetUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, resetUserPassword, arg.UserID, arg.PasswordHash)
+	return err
+}
+
+const setUserAvatar = `-- name: SetUserAvatar :one
+UPDATE users
+SET avatar_updated_at = NOW()
+WHERE user_id = $1
+RETURNING avatar_updated_at
+`
+
+func (q *Queries) SetUserAvatar(ctx context.Context, userID int64) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, setUserAvatar, userID)
+	var avatar_updated_at pgtype.Timestamptz
+	err := row.Scan(&avatar_updated_at)
+	return avatar_updated_at, err
+}
+
+const setUserEmail = `-- name: SetUserEmail :one
+UPDATE users
+SET
+    email = $1,
+    email_verified_at = CASE WHEN LOWER(email) = LOWER($1) THEN email_verified_at END -- Kept unless the address changes
+WHERE user_id = $2
+RETURNING email_verified_at
+`
+
+type SetUserEmailParams struct {
+	Email  string
+	UserID int64
+}
+
+func (q *Queries) SetUserEmail(ctx context.Context, arg SetUserEmailParams) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, setUserEmail, arg.Email, arg.UserID)
+	var email_verified_at pgtype.Timestamptz
+	err := row.Scan(&email_verified_at)
+	return email_verified_at, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET
+    display_name = COALESCE($1, display_name),
+    bio = COALESCE($2, bio),
+    profile_visibility = COALESCE($3, profile_visibility),
+    show_activity = COALESCE($4, show_activity)
+WHERE user_id = $5
+RETURNING user_id, username, display_name, bio, created_at, role, profile_visibility, show_activity, avatar_updated_at
+`
+
+type UpdateUserProfileParams struct {
+	DisplayName       pgtype.Text
+	Bio               pgtype.Text
+	ProfileVisibility pgtype.Text
+	ShowActivity      pgtype.Bool
+	UserID            int64
+}
+
+type UpdateUserProfileRow struct {
+	UserID            int64
+	Username          string
+	DisplayName       string
+	Bio               string
+	CreatedAt         pgtype.Timestamptz
+	Role              string
+	ProfileVisibility string
+	ShowActivity      bool
+	AvatarUpdatedAt   pgtype.Timestamptz
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (UpdateUserProfileRow, error) {
+	row := q.db.QueryRow(ctx, updateUserProfile,
+		arg.DisplayName,
+		arg.Bio,
+		arg.ProfileVisibility,
+		arg.ShowActivity,
+		arg.UserID,
+	)
+	var i UpdateUserProfileRow
+	err := row.Scan(
+		&i.UserID,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.CreatedAt,
+		&i.Role,
+		&i.ProfileVisibility,
+		&i.ShowActivity,
+		&i.AvatarUpdatedAt,
+	)
+	return i, err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :exec
+UPDATE users
+SET role = $2
+WHERE user_id = $1
+`
+
+type UpdateUserRoleParams struct {
+	UserID int64
+	Role   string
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) error {
+	_, err := q.db.Exec(ctx, updateUserRole, arg.UserID, arg.Role)
+	return err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = NOW()
+WHERE user_id = $1 AND LOWER(email) = LOWER($2)
+`
+
+type VerifyUserEmailParams struct {
+	UserID int64
+	Email  string
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (int64, error) {
+	result, err := q.db.Exec(ctx, verifyUserEmail, arg.UserID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -3,23 +3,26 @@ package dbConnection
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/DamienFooxx/CVWOForum/internal/config"
 	// Import to use pgx as the driver name
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // NewDB starts connection to the database
-func NewDB(databaseURL string) (*pgxpool.Pool, error) {
+func NewDB(databaseURL string, dbConfig config.DBConfig) (*pgxpool.Pool, error) {
 	// Parse configuration
-	config, err := pgxpool.ParseConfig(databaseURL)
+	poolConfig, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	applyPoolConfig(poolConfig, dbConfig)
 
 	// Create connection pool
 	ctx := context.Background() // Create context for connection to be root connection
-	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -28,8 +31,36 @@ func NewDB(databaseURL string) (*pgxpool.Pool, error) {
 	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second) // Ensures there is no infinite hang if ping fails.
 	defer cancel()
 	if err := pool.Ping(pingCtx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	return pool, nil
 }
+
+// applyPoolConfig overrides the pgxpool defaults with any values set in dbConfig
+func applyPoolConfig(poolConfig *pgxpool.Config, dbConfig config.DBConfig) {
+	if dbConfig.MaxConns > 0 {
+		poolConfig.MaxConns = dbConfig.MaxConns
+	}
+	if dbConfig.MinConns > 0 {
+		poolConfig.MinConns = dbConfig.MinConns
+	}
+	if dbConfig.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = dbConfig.MaxConnLifetime
+	}
+	if dbConfig.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = dbConfig.MaxConnIdleTime
+	}
+	if dbConfig.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = dbConfig.HealthCheckPeriod
+	}
+
+	// Sent as a startup parameter so every statement on every connection gets the default timeout.
+	// A DATABASE_URL that already sets statement_timeout takes precedence.
+	if dbConfig.StatementTimeout > 0 {
+		if _, ok := poolConfig.ConnConfig.RuntimeParams["statement_timeout"]; !ok {
+			poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(dbConfig.StatementTimeout.Milliseconds(), 10)
+		}
+	}
+}
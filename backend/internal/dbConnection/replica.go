@@ -0,0 +1,179 @@
+package dbConnection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// readQueryPrefixes are the sqlc query name prefixes that are safe to serve from a replica
+// Reads that must see the latest write, such as role, 2FA and credential checks, are named Fetch* to stay on the primary.
+var readQueryPrefixes = []string{"Get", "List", "Search", "Count"}
+
+// lockingClauses make a SELECT take row locks, which a read-only standby refuses
+var lockingClauses = []string{"FOR UPDATE", "FOR NO KEY UPDATE", "FOR SHARE", "FOR KEY SHARE"}
+
+// errNotStandby is reported when the replica is not replaying from the primary, such as after it was promoted
+var errNotStandby = errors.New("replica is not in recovery")
+
+// replicaLagQuery reports replication lag in seconds, 0 when the replica has replayed everything it received.
+// It is NULL on a server that is not a standby, which has no lag to report but is not a copy of the primary either.
+const replicaLagQuery = `
+SELECT CASE
+    WHEN NOT pg_is_in_recovery() THEN NULL
+    WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+    ELSE COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)
+END::float8`
+
+// RoutedDB satisfies database.DBTX, sending read-only sqlc queries to a replica
+// and everything else to the primary.
+type RoutedDB struct {
+	primary *pgxpool.Pool
+	replica *pgxpool.Pool
+	maxLag  time.Duration
+
+	replicaHealthy atomic.Bool
+	stop           context.CancelFunc
+}
+
+// NewRoutedDB wraps the primary and replica pools and starts monitoring replica lag.
+// The replica is only used while its lag stays at or below maxLag.
+func NewRoutedDB(primary, replica *pgxpool.Pool, maxLag, checkPeriod time.Duration) *RoutedDB {
+	ctx, cancel := context.WithCancel(context.Background())
+	db := &RoutedDB{
+		primary: primary,
+		replica: replica,
+		maxLag:  maxLag,
+		stop:    cancel,
+	}
+
+	// Check once up front so reads start on the replica straight away when it is healthy
+	db.checkReplica(ctx)
+	go db.monitorReplica(ctx, checkPeriod)
+
+	return db
+}
+
+// Primary returns the primary pool, used for transactions
+func (db *RoutedDB) Primary() *pgxpool.Pool {
+	return db.primary
+}
+
+// ReplicaHealthy reports whether read-only queries are currently sent to the replica
+func (db *RoutedDB) ReplicaHealthy() bool {
+	return db.replicaHealthy.Load()
+}
+
+// Close stops lag monitoring and closes both pools
+func (db *RoutedDB) Close() {
+	db.stop()
+	db.replica.Close()
+	db.primary.Close()
+}
+
+func (db *RoutedDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return db.primary.Exec(ctx, sql, args...)
+}
+
+func (db *RoutedDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return db.poolFor(sql).Query(ctx, sql, args...)
+}
+
+func (db *RoutedDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return db.poolFor(sql).QueryRow(ctx, sql, args...)
+}
+
+// Begin starts a transaction on the primary
+func (db *RoutedDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return db.primary.Begin(ctx)
+}
+
+// poolFor picks the replica for read-only queries while it is within the lag threshold
+func (db *RoutedDB) poolFor(sql string) *pgxpool.Pool {
+	if db.replicaHealthy.Load() && IsReadOnlyQuery(sql) {
+		return db.replica
+	}
+	return db.primary
+}
+
+// monitorReplica re-checks the replica lag every checkPeriod until ctx is cancelled
+func (db *RoutedDB) monitorReplica(ctx context.Context, checkPeriod time.Duration) {
+	ticker := time.NewTicker(checkPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			db.checkReplica(ctx)
+		}
+	}
+}
+
+// checkReplica measures the replica lag and flips reads back to the primary when it is too far behind
+func (db *RoutedDB) checkReplica(ctx context.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	var lagSeconds *float64
+	err := db.replica.QueryRow(checkCtx, replicaLagQuery).Scan(&lagSeconds)
+	if err == nil && lagSeconds == nil {
+		err = errNotStandby
+	}
+	var lag time.Duration
+	if lagSeconds != nil {
+		lag = time.Duration(*lagSeconds * float64(time.Second))
+	}
+	healthy := err == nil && lag <= db.maxLag
+
+	if wasHealthy := db.replicaHealthy.Swap(healthy); wasHealthy != healthy {
+		if healthy {
+			fmt.Printf("Read replica healthy (lag %s), routing reads to replica\n", lag)
+		} else if err != nil {
+			fmt.Printf("Read replica unavailable, routing reads to primary: %v\n", err)
+		} else {
+			fmt.Printf("Read replica lag %s exceeds %s, routing reads to primary\n", lag, db.maxLag)
+		}
+	}
+}
+
+// IsReadOnlyQuery reports whether sql is a sqlc generated Get/List/Search/Count SELECT without a locking clause.
+// sqlc prefixes every query with "-- name: QueryName :kind", anything else stays on the primary.
+func IsReadOnlyQuery(sql string) bool {
+	header, body, found := strings.Cut(sql, "\n")
+	if !found || !strings.HasPrefix(header, "-- name: ") {
+		return false
+	}
+	name := strings.TrimPrefix(header, "-- name: ")
+
+	hasReadPrefix := false
+	for _, prefix := range readQueryPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			hasReadPrefix = true
+			break
+		}
+	}
+	if !hasReadPrefix {
+		return false
+	}
+
+	// Collapse whitespace so a clause split across lines is still found
+	statement := strings.Join(strings.Fields(strings.ToUpper(body)), " ")
+	if !strings.HasPrefix(statement, "SELECT") {
+		return false
+	}
+	for _, clause := range lockingClauses {
+		if strings.Contains(statement, clause) {
+			return false
+		}
+	}
+	return true
+}
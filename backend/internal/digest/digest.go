@@ -0,0 +1,208 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/jobs"
+	"github.com/DamienFooxx/CVWOForum/internal/mail"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Frequencies a user can get digests at, stored in digest_subscriptions.frequency
+const (
+	Daily  = "daily"
+	Weekly = "weekly"
+)
+
+// maxExcerptLength caps how much of a reply is quoted in the email
+const maxExcerptLength = 200
+
+// Period is how much activity one digest at frequency covers
+func Period(frequency string) time.Duration {
+	if frequency == Daily {
+		return 24 * time.Hour
+	}
+	return 7 * 24 * time.Hour
+}
+
+// Sender builds digest emails of new posts in a user's followed topics and replies to their posts and comments.
+// Digests are sent by the digests.send job, queued for each subscriber once their period has passed.
+type Sender struct {
+	q        *database.Queries
+	queue    *jobs.Queue
+	cfg      config.DigestConfig
+	key      []byte
+	linkBase string // The frontend, which the email links to
+	apiURL   string // This API, which mail clients send one-click unsubscribes to
+}
+
+// NewSender returns a sender signing unsubscribe links with cfg.SigningKey, which config.Load requires
+func NewSender(q *database.Queries, queue *jobs.Queue, cfg config.DigestConfig, frontendURL, apiURL string) *Sender {
+	linkBase := strings.TrimRight(frontendURL, "/")
+	if linkBase == "" {
+		linkBase = "http://localhost:3000" // The default development frontend
+	}
+	return &Sender{q: q, queue: queue, cfg: cfg, key: []byte(cfg.SigningKey), linkBase: linkBase, apiURL: strings.TrimRight(apiURL, "/")}
+}
+
+// QueueDue queues a digest up to until for every subscriber whose last one ended a full period before it
+func (s *Sender) QueueDue(ctx context.Context, until time.Time) (int, error) {
+	userIDs, err := s.q.ListDueDigestSubscriptions(ctx, pgtype.Timestamptz{Time: until, Valid: true})
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, userID := range userIDs {
+		_, err := jobs.Enqueue(ctx, s.queue, jobs.SendDigest, jobs.DigestPayload{UserID: userID, Until: until},
+			jobs.UniqueKey(fmt.Sprintf("digest:%d", userID)))
+		if errors.Is(err, jobs.ErrDuplicate) {
+			continue
+		}
+		if err != nil {
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// Send emails userID what happened since their last digest, up to until. Periods without activity are skipped
+// without an email. Nothing is sent if the user unsubscribed since the job was queued, or the period was covered.
+func (s *Sender) Send(ctx context.Context, userID int64, until time.Time) error {
+	// The email is queued in the transaction that records the digest, so a failure or retry cannot send it twice
+	err := s.q.InTx(ctx, func(tx *database.Queries) error {
+		recipient, err := tx.GetDigestRecipient(ctx, userID)
+		if err != nil || !recipient.CoveredUntil.Time.Before(until) {
+			return err
+		}
+
+		// A subscriber who was unverified for a while gets one period, not everything since they subscribed
+		since := recipient.CoveredUntil.Time
+		if earliest := until.Add(-Period(recipient.Frequency)); since.Before(earliest) {
+			since = earliest
+		}
+
+		posts, err := tx.ListDigestPosts(ctx, database.ListDigestPostsParams{
+			UserID:   userID,
+			Since:    pgtype.Timestamptz{Time: since, Valid: true},
+			Until:    pgtype.Timestamptz{Time: until, Valid: true},
+			MaxItems: s.cfg.MaxItems,
+		})
+		if err != nil {
+			return err
+		}
+		replies, err := tx.ListDigestReplies(ctx, database.ListDigestRepliesParams{
+			UserID:   userID,
+			Since:    pgtype.Timestamptz{Time: since, Valid: true},
+			Until:    pgtype.Timestamptz{Time: until, Valid: true},
+			MaxItems: s.cfg.MaxItems,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(posts) > 0 || len(replies) > 0 {
+			postIDs := make([]int64, 0, len(posts))
+			for _, p := range posts {
+				postIDs = append(postIDs, p.PostID)
+			}
+			commentIDs := make([]int64, 0, len(replies))
+			for _, c := range replies {
+				commentIDs = append(commentIDs, c.CommentID)
+			}
+			// A concurrent run for the same period waits here and then records nothing, so it sends nothing either
+			recorded, err := tx.CreateDigest(ctx, database.CreateDigestParams{
+				UserID:      userID,
+				Frequency:   recipient.Frequency,
+				PeriodStart: pgtype.Timestamptz{Time: since, Valid: true},
+				PeriodEnd:   pgtype.Timestamptz{Time: until, Valid: true},
+				PostIds:     postIDs,
+				CommentIds:  commentIDs,
+			})
+			if err != nil {
+				return err
+			}
+			if recorded == 0 {
+				return nil
+			}
+			if err := s.enqueueEmail(ctx, tx, userID, recipient, posts, replies); err != nil {
+				return err
+			}
+		}
+
+		return tx.AdvanceDigestSubscription(ctx, database.AdvanceDigestSubscriptionParams{
+			CoveredUntil: pgtype.Timestamptz{Time: until, Valid: true},
+			UserID:       userID,
+		})
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// enqueueEmail renders the digest and queues it with tx to be sent with mail.SendEmail.
+// Anything past MaxItems is summed up as "and N more" rather than listed.
+func (s *Sender) enqueueEmail(ctx context.Context, tx *database.Queries, userID int64, recipient database.GetDigestRecipientRow, posts []database.ListDigestPostsRow, replies []database.ListDigestRepliesRow) error {
+	token := url.QueryEscape(s.UnsubscribeToken(userID))
+	unsubscribeURL := s.linkBase + "/unsubscribe?token=" + token
+	data := mail.DigestData{
+		Username:       recipient.Username,
+		Period:         recipient.Frequency,
+		UnsubscribeURL: unsubscribeURL,
+	}
+	if len(posts) > 0 {
+		data.MorePosts = int(posts[0].TotalCount) - len(posts)
+	}
+	for _, p := range posts {
+		data.Posts = append(data.Posts, mail.DigestPost{
+			Title:     p.Title,
+			TopicName: p.TopicName,
+			Author:    p.Author,
+			URL:       fmt.Sprintf("%s/topics/%d/posts/%d", s.linkBase, p.TopicID, p.PostID),
+		})
+	}
+	if len(replies) > 0 {
+		data.MoreReplies = int(replies[0].TotalCount) - len(replies)
+	}
+	for _, c := range replies {
+		data.Replies = append(data.Replies, mail.DigestReply{
+			PostTitle:   c.PostTitle,
+			ReplierName: c.ReplierName,
+			Excerpt:     excerpt(c.Body),
+			URL:         fmt.Sprintf("%s/topics/%d/posts/%d#comment-%d", s.linkBase, c.TopicID, c.PostID, c.CommentID),
+		})
+	}
+
+	msg, err := mail.Digest.Render(recipient.Email.String, data)
+	if err != nil {
+		return err
+	}
+	// Mail clients POST to the API directly for one-click unsubscribe (RFC 8058), the link in the body opens the frontend
+	msg.ListUnsubscribe = s.apiURL + "/digests/unsubscribe?token=" + token
+	_, err = jobs.EnqueueTx(ctx, s.queue, tx, mail.SendEmail, msg)
+	return err
+}
+
+// Purge deletes the records of digests sent before the retention period, returning how many were removed
+func (s *Sender) Purge(ctx context.Context, now time.Time) (int64, error) {
+	return s.q.DeleteDigestsSentBefore(ctx, pgtype.Timestamptz{Time: now.Add(-s.cfg.Retention), Valid: true})
+}
+
+// excerpt shortens a comment to a single line for the email
+func excerpt(body string) string {
+	text := strings.Join(strings.Fields(body), " ")
+	if runes := []rune(text); len(runes) > maxExcerptLength {
+		return strings.TrimSpace(string(runes[:maxExcerptLength])) + "…"
+	}
+	return text
+}
@@ -0,0 +1,42 @@
+package digest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe link")
+
+// UnsubscribeToken returns the token in a digest's unsubscribe link, which turns digests off for userID
+// without logging in. It does not expire, so links in old emails keep working.
+func (s *Sender) UnsubscribeToken(userID int64) string {
+	return strconv.FormatInt(userID, 10) + "." + s.sign(userID)
+}
+
+// ParseUnsubscribeToken checks the signature of an unsubscribe token and returns the user it is for
+func (s *Sender) ParseUnsubscribeToken(token string) (int64, error) {
+	id, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, ErrInvalidUnsubscribeToken
+	}
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidUnsubscribeToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(userID))) {
+		return 0, ErrInvalidUnsubscribeToken
+	}
+	return userID, nil
+}
+
+func (s *Sender) sign(userID int64) string {
+	// Prefixed so the MAC cannot be mistaken for one made with the same key elsewhere, such as a JWT
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "digest-unsubscribe:%d", userID)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,363 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/jobs"
+	"github.com/DamienFooxx/CVWOForum/internal/mail"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Purposes of the tokens in email_tokens
+const (
+	purposeVerifyEmail   = "verify_email"
+	purposePasswordReset = "password_reset"
+)
+
+// errEmailChanged is returned when a verification link is for an address the account no longer uses
+var errEmailChanged = errors.New("email changed since the link was sent")
+
+// AccountHandler verifies email addresses and resets forgotten passwords
+type AccountHandler struct {
+	q        *database.Queries
+	throttle *auth.LoginThrottle
+	mailer   *accountMailer
+}
+
+func NewAccountHandler(q *database.Queries, throttle *auth.LoginThrottle, queue *jobs.Queue, cfg config.AccountConfig, frontendURL string) *AccountHandler {
+	return &AccountHandler{q: q, throttle: throttle, mailer: newAccountMailer(q, queue, cfg, frontendURL)}
+}
+
+// accountMailer emails the single use links for verifying an address and resetting a password
+type accountMailer struct {
+	q        *database.Queries
+	queue    *jobs.Queue
+	cfg      config.AccountConfig
+	linkBase string // The frontend, which has the pages the links open
+}
+
+func newAccountMailer(q *database.Queries, queue *jobs.Queue, cfg config.AccountConfig, frontendURL string) *accountMailer {
+	linkBase := strings.TrimRight(frontendURL, "/")
+	if linkBase == "" {
+		linkBase = "http://localhost:3000" // The default development frontend
+	}
+	return &accountMailer{q: q, queue: queue, cfg: cfg, linkBase: linkBase}
+}
+
+// sendVerification emails a link that confirms the user owns the address
+func (m *accountMailer) sendVerification(ctx context.Context, userID int64, username, email string) error {
+	token, err := m.issueToken(ctx, userID, purposeVerifyEmail, email, m.cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	return mail.Enqueue(ctx, m.queue, mail.VerifyEmail, email, mail.VerifyEmailData{
+		Username:  username,
+		VerifyURL: m.linkBase + "/verify-email?token=" + url.QueryEscape(token),
+		ExpiresIn: formatTTL(m.cfg.EmailVerificationTTL),
+	})
+}
+
+// sendPasswordReset emails a link to choose a new password
+func (m *accountMailer) sendPasswordReset(ctx context.Context, userID int64, username, email string) error {
+	token, err := m.issueToken(ctx, userID, purposePasswordReset, email, m.cfg.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+	return mail.Enqueue(ctx, m.queue, mail.PasswordReset, email, mail.PasswordResetData{
+		Username:  username,
+		ResetURL:  m.linkBase + "/reset-password?token=" + url.QueryEscape(token),
+		ExpiresIn: formatTTL(m.cfg.PasswordResetTTL),
+	})
+}
+
+// issueToken stores the hash of a new token, the token itself only goes out in the email
+func (m *accountMailer) issueToken(ctx context.Context, userID int64, purpose, email string, ttl time.Duration) (string, error) {
+	token, hash, err := auth.GenerateEmailToken()
+	if err != nil {
+		return "", err
+	}
+	err = m.q.CreateEmailToken(ctx, database.CreateEmailTokenParams{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		Email:     email,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(ttl), Valid: true},
+	})
+	return token, err
+}
+
+// SetEmail PUT /users/me/email, changes the account's address and emails a link to verify it
+func (h *AccountHandler) SetEmail(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	type Request struct {
+		Email string `json:"email"`
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	email, err := parseEmail(req.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.q.GetUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var verifiedAt pgtype.Timestamptz
+	err = h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		var err error
+		verifiedAt, err = tx.SetUserEmail(r.Context(), database.SetUserEmailParams{Email: email, UserID: userID})
+		if err != nil {
+			return err
+		}
+		if verifiedAt.Valid {
+			return nil
+		}
+		// Links sent to the old address stop working
+		return tx.ExpireEmailTokens(r.Context(), database.ExpireEmailTokensParams{
+			UserID:   userID,
+			Purposes: []string{purposeVerifyEmail, purposePasswordReset},
+		})
+	})
+	if err != nil {
+		if isUniqueViolation(err, "idx_users_email") {
+			http.Error(w, "Email is already in use", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to set email: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Setting an unverified address again sends a new link
+	if !verifiedAt.Valid {
+		if err := h.mailer.sendVerification(r.Context(), userID, user.Username, email); err != nil {
+			http.Error(w, "Failed to send verification email: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"email":          email,
+		"email_verified": verifiedAt.Valid,
+	}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// VerifyEmail POST /auth/email/verify, confirms an address with the token from the emailed link
+func (h *AccountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	type Request struct {
+		Token string `json:"token"`
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	var email string
+	err := h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		token, err := tx.ConsumeEmailToken(r.Context(), database.ConsumeEmailTokenParams{
+			TokenHash: auth.HashEmailToken(req.Token),
+			Purpose:   purposeVerifyEmail,
+		})
+		if err != nil {
+			return err
+		}
+		email = token.Email
+
+		verified, err := tx.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{UserID: token.UserID, Email: token.Email})
+		if err != nil {
+			return err
+		}
+		if verified == 0 {
+			return errEmailChanged
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, errEmailChanged) {
+			http.Error(w, "The account's email address has changed since this link was sent", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to verify email: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"message": "Email verified", "email": email}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// ForgotPassword POST /auth/password/forgot, emails a reset link to the account with that verified address.
+// The response is the same whether or not there is one, so it cannot be used to find out who has an account.
+func (h *AccountHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	type Request struct {
+		Email string `json:"email"`
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	email, err := parseEmail(req.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Failures are only logged, an error response would give away that the account exists
+	user, err := h.q.GetUserByEmail(r.Context(), email)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		fmt.Printf("Failed to look up email for password reset: %v\n", err)
+	case !user.EmailVerifiedAt.Valid:
+		// Resetting through an unconfirmed address would hand the account to whoever owns it
+	default:
+		if err := h.mailer.sendPasswordReset(r.Context(), user.UserID, user.Username, user.Email.String); err != nil {
+			fmt.Printf("Failed to send password reset email to user %d: %v\n", user.UserID, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"message": "If an account has this verified email address, a link to reset its password has been sent",
+	}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// ResetPassword POST /auth/password/reset, sets a new password with the token from the emailed link
+// and logs out every existing session
+func (h *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	type Request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	// Checked before the token is used up
+	passwordHash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var userID int64
+	err = h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		token, err := tx.ConsumeEmailToken(r.Context(), database.ConsumeEmailTokenParams{
+			TokenHash: auth.HashEmailToken(req.Token),
+			Purpose:   purposePasswordReset,
+		})
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+
+		if err := tx.ResetUserPassword(r.Context(), database.ResetUserPasswordParams{UserID: userID, PasswordHash: passwordHash}); err != nil {
+			return err
+		}
+		// Tokens minted by whoever had the account stop working with its sessions
+		if err := tx.RevokeUserPersonalAccessTokens(r.Context(), userID); err != nil {
+			return err
+		}
+		// Other reset links sent before this one stop working too
+		return tx.ExpireEmailTokens(r.Context(), database.ExpireEmailTokensParams{
+			UserID:   userID,
+			Purposes: []string{purposePasswordReset},
+		})
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to reset password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The owner proved who they are, so a lockout from failed logins is lifted
+	if err := h.throttle.RecordSuccess(r.Context(), userID); err != nil {
+		fmt.Printf("Failed to reset login failures for user %d: %v\n", userID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"message": "Password reset, log in with the new password"}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// parseEmail checks value is a plain email address such as alice@example.com, without a display name
+func parseEmail(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", errors.New("Email is required")
+	}
+	addr, err := netmail.ParseAddress(value)
+	if err != nil || addr.Address != value {
+		return "", errors.New("Invalid email address")
+	}
+	return value, nil
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate in the unique index or constraint
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+}
+
+// formatTTL describes how long a link is valid, e.g. "24 hours" or "30 minutes"
+func formatTTL(ttl time.Duration) string {
+	unit, count := "minute", int64(ttl/time.Minute)
+	if ttl >= time.Hour && ttl%time.Hour == 0 {
+		unit, count = "hour", int64(ttl/time.Hour)
+	}
+	if count != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", count, unit)
+}
@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// activityQuery holds the parsed parameters shared by the /users/{userID}/... listings
+type activityQuery struct {
+	userID         int64
+	includeRemoved bool // Removed items are only listed for the author and moderators
+	oldestFirst    bool
+	limit          int32
+	offset         int32
+}
+
+// parseActivityQuery reads the user and ?sort=newest|oldest&limit=&offset=, writing an error and returning false on failure.
+// Listings follow the user's privacy settings, like their profile.
+func (h *ProfileHandler) parseActivityQuery(w http.ResponseWriter, r *http.Request) (activityQuery, bool) {
+	var query activityQuery
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return query, false
+	}
+
+	switch r.URL.Query().Get("sort") {
+	case "", "newest":
+	case "oldest":
+		query.oldestFirst = true
+	default:
+		http.Error(w, "sort must be newest or oldest", http.StatusBadRequest)
+		return query, false
+	}
+
+	query.limit, query.offset, err = parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return query, false
+	}
+
+	user, err := h.q.GetUser(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return query, false
+		}
+		http.Error(w, "Failed to get user: "+err.Error(), http.StatusInternalServerError)
+		return query, false
+	}
+
+	access, err := h.accessTo(r, user.UserID, user.ProfileVisibility, user.ShowActivity)
+	if err != nil {
+		http.Error(w, "Failed to get user: "+err.Error(), http.StatusInternalServerError)
+		return query, false
+	}
+	if !access.canSeeActivity {
+		http.Error(w, "This user's activity is private", http.StatusForbidden)
+		return query, false
+	}
+
+	query.userID = user.UserID
+	query.includeRemoved = access.fullAccess
+	return query, true
+}
+
+// ListUserPosts GET /users/{userID}/posts
+func (h *ProfileHandler) ListUserPosts(w http.ResponseWriter, r *http.Request) {
+	query, ok := h.parseActivityQuery(w, r)
+	if !ok {
+		return
+	}
+
+	posts, err := h.listPostsByUser(r, query)
+	if err != nil {
+		http.Error(w, "Failed to list posts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		PostID    int64  `json:"post_id"`
+		TopicID   int64  `json:"topic_id"`
+		Title     string `json:"title"`
+		Body      string `json:"body"`
+		BodyHTML  string `json:"body_html"`
+		CreatedAt string `json:"created_at"`
+		CreatedBy int64  `json:"created_by"`
+		Status    string `json:"status"`
+		Username  string `json:"username"`
+	}
+
+	response := []Response{}
+	for _, post := range posts {
+		response = append(response, Response{
+			PostID:    post.PostID,
+			TopicID:   post.TopicID,
+			Title:     post.Title,
+			Body:      post.Body,
+			BodyHTML:  renderedBody(post.Body, post.BodyHtml),
+			CreatedAt: post.CreatedAt.Time.Format(time.RFC3339),
+			CreatedBy: post.CreatedBy,
+			Status:    post.Status,
+			Username:  post.Username,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// ListUserComments GET /users/{userID}/comments
+func (h *ProfileHandler) ListUserComments(w http.ResponseWriter, r *http.Request) {
+	query, ok := h.parseActivityQuery(w, r)
+	if !ok {
+		return
+	}
+
+	comments, err := h.listCommentsByUser(r, query)
+	if err != nil {
+		http.Error(w, "Failed to list comments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		CommentID   int64   `json:"comment_id"`
+		PostID      int64   `json:"post_id"`
+		PostTitle   string  `json:"post_title"`
+		CommentedBy int64   `json:"commented_by"`
+		ParentID    *int64  `json:"parent_id"`
+		Body        string  `json:"body"`
+		BodyHTML    string  `json:"body_html"`
+		CreatedAt   string  `json:"created_at"`
+		EditedAt    *string `json:"edited_at"`
+		Status      string  `json:"status"`
+	}
+
+	response := []Response{}
+	for _, c := range comments {
+		var respParentID *int64
+		if c.ParentID.Valid {
+			respParentID = &c.ParentID.Int64
+		}
+		response = append(response, Response{
+			CommentID:   c.CommentID,
+			PostID:      c.PostID,
+			PostTitle:   c.PostTitle,
+			CommentedBy: c.CommentedBy,
+			ParentID:    respParentID,
+			Body:        c.Body,
+			BodyHTML:    renderedBody(c.Body, c.BodyHtml),
+			CreatedAt:   c.CreatedAt.Time.Format(time.RFC3339),
+			EditedAt:    formatOptionalTime(c.EditedAt),
+			Status:      c.Status,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// ListUserTopics GET /users/{userID}/topics
+func (h *ProfileHandler) ListUserTopics(w http.ResponseWriter, r *http.Request) {
+	query, ok := h.parseActivityQuery(w, r)
+	if !ok {
+		return
+	}
+
+	topics, err := h.listTopicsByUser(r, query)
+	if err != nil {
+		http.Error(w, "Failed to list topics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		TopicID     int64  `json:"topic_id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		CreatedBy   int64  `json:"created_by"`
+		CreatedAt   string `json:"created_at"`
+		Status      string `json:"status"`
+		PostCount   int64  `json:"post_count"`
+	}
+
+	response := []Response{}
+	for _, topic := range topics {
+		response = append(response, Response{
+			TopicID:     topic.TopicID,
+			Name:        topic.Name,
+			Description: topic.Description,
+			CreatedBy:   topic.CreatedBy,
+			CreatedAt:   topic.CreatedAt.Time.Format(time.RFC3339),
+			Status:      topic.Status,
+			PostCount:   topic.PostCount,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// listPostsByUser runs the query for the sort order. Each order has its own query so Postgres can read
+// the page straight off the (created_by, created_at) index instead of sorting all of the user's posts.
+func (h *ProfileHandler) listPostsByUser(r *http.Request, query activityQuery) ([]database.ListPostsByUserRow, error) {
+	params := database.ListPostsByUserParams{
+		UserID:         query.userID,
+		IncludeRemoved: query.includeRemoved,
+		PageLimit:      query.limit,
+		PageOffset:     query.offset,
+	}
+	if !query.oldestFirst {
+		return h.q.ListPostsByUser(r.Context(), params)
+	}
+
+	rows, err := h.q.ListPostsByUserOldestFirst(r.Context(), database.ListPostsByUserOldestFirstParams(params))
+	if err != nil {
+		return nil, err
+	}
+	posts := make([]database.ListPostsByUserRow, 0, len(rows))
+	for _, row := range rows {
+		posts = append(posts, database.ListPostsByUserRow(row))
+	}
+	return posts, nil
+}
+
+// listCommentsByUser runs the comments query for the sort order, like listPostsByUser
+func (h *ProfileHandler) listCommentsByUser(r *http.Request, query activityQuery) ([]database.ListCommentsByUserRow, error) {
+	params := database.ListCommentsByUserParams{
+		UserID:         query.userID,
+		IncludeRemoved: query.includeRemoved,
+		PageLimit:      query.limit,
+		PageOffset:     query.offset,
+	}
+	if !query.oldestFirst {
+		return h.q.ListCommentsByUser(r.Context(), params)
+	}
+
+	rows, err := h.q.ListCommentsByUserOldestFirst(r.Context(), database.ListCommentsByUserOldestFirstParams(params))
+	if err != nil {
+		return nil, err
+	}
+	comments := make([]database.ListCommentsByUserRow, 0, len(rows))
+	for _, row := range rows {
+		comments = append(comments, database.ListCommentsByUserRow(row))
+	}
+	return comments, nil
+}
+
+// listTopicsByUser runs the topics query for the sort order, like listPostsByUser
+func (h *ProfileHandler) listTopicsByUser(r *http.Request, query activityQuery) ([]database.ListTopicsByUserRow, error) {
+	params := database.ListTopicsByUserParams{
+		UserID:         query.userID,
+		IncludeRemoved: query.includeRemoved,
+		PageLimit:      query.limit,
+		PageOffset:     query.offset,
+	}
+	if !query.oldestFirst {
+		return h.q.ListTopicsByUser(r.Context(), params)
+	}
+
+	rows, err := h.q.ListTopicsByUserOldestFirst(r.Context(), database.ListTopicsByUserOldestFirstParams(params))
+	if err != nil {
+		return nil, err
+	}
+	topics := make([]database.ListTopicsByUserRow, 0, len(rows))
+	for _, row := range rows {
+		topics = append(topics, database.ListTopicsByUserRow(row))
+	}
+	return topics, nil
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type AdminHandler struct {
+	q *database.Queries
+}
+
+func NewAdminHandler(q *database.Queries) *AdminHandler {
+	return &AdminHandler{q: q}
+}
+
+// ListLoginAttempts GET /admin/login-attempts (?username=&ip=&success=&limit=&offset=)
+func (h *AdminHandler) ListLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Optional filters
+	query := r.URL.Query()
+	params := database.ListLoginAttemptsParams{
+		Username:   pgtype.Text{String: query.Get("username"), Valid: query.Get("username") != ""},
+		IpAddress:  pgtype.Text{String: query.Get("ip"), Valid: query.Get("ip") != ""},
+		PageLimit:  limit,
+		PageOffset: offset,
+	}
+	if successStr := query.Get("success"); successStr != "" {
+		success, err := strconv.ParseBool(successStr)
+		if err != nil {
+			http.Error(w, "success must be true or false", http.StatusBadRequest)
+			return
+		}
+		params.Success = pgtype.Bool{Bool: success, Valid: true}
+	}
+
+	attempts, err := h.q.ListLoginAttempts(r.Context(), params)
+	if err != nil {
+		http.Error(w, "Failed to list login attempts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		AttemptID     int64   `json:"attempt_id"`
+		Username      string  `json:"username"`
+		UserID        *int64  `json:"user_id"`
+		IPAddress     string  `json:"ip_address"`
+		UserAgent     string  `json:"user_agent"`
+		Success       bool    `json:"success"`
+		FailureReason *string `json:"failure_reason"`
+		CreatedAt     string  `json:"created_at"`
+	}
+
+	response := []Response{}
+	for _, a := range attempts {
+		var userID *int64
+		if a.UserID.Valid {
+			userID = &a.UserID.Int64
+		}
+		var failureReason *string
+		if a.FailureReason.Valid {
+			failureReason = &a.FailureReason.String
+		}
+		response = append(response, Response{
+			AttemptID:     a.AttemptID,
+			Username:      a.Username,
+			UserID:        userID,
+			IPAddress:     a.IpAddress,
+			UserAgent:     a.UserAgent,
+			Success:       a.Success,
+			FailureReason: failureReason,
+			CreatedAt:     a.CreatedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// ListUsers GET /users (?q=&limit=&offset=), the user directory
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Optional search on username and display name
+	search := r.URL.Query().Get("q")
+	users, err := h.q.ListUsers(r.Context(), database.ListUsersParams{
+		Query:      pgtype.Text{String: search, Valid: search != ""},
+		PageLimit:  limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		http.Error(w, "Failed to list users: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		UserID            int64  `json:"user_id"`
+		Username          string `json:"username"`
+		DisplayName       string `json:"display_name"`
+		Role              string `json:"role"`
+		ProfileVisibility string `json:"profile_visibility"`
+		CreatedAt         string `json:"created_at"`
+	}
+
+	response := []Response{}
+	for _, u := range users {
+		response = append(response, Response{
+			UserID:            u.UserID,
+			Username:          u.Username,
+			DisplayName:       u.DisplayName,
+			Role:              u.Role,
+			ProfileVisibility: u.ProfileVisibility,
+			CreatedAt:         u.CreatedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/attachment"
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxAttachmentsPerItem caps the attachments on a single post or comment
+const maxAttachmentsPerItem = 10
+
+type AttachmentHandler struct {
+	q      *database.Queries
+	store  storage.Storage
+	signer *attachment.Signer
+	cfg    config.UploadConfig
+}
+
+func NewAttachmentHandler(q *database.Queries, store storage.Storage, signer *attachment.Signer, cfg config.UploadConfig) *AttachmentHandler {
+	return &AttachmentHandler{q: q, store: store, signer: signer, cfg: cfg}
+}
+
+// attachmentResponse describes an attachment with a signed download URL
+type attachmentResponse struct {
+	AttachmentID int64  `json:"attachment_id"`
+	Filename     string `json:"filename"`
+	ContentType  string `json:"content_type"`
+	SizeBytes    int64  `json:"size_bytes"`
+	URL          string `json:"url"`
+}
+
+func newAttachmentResponse(signer *attachment.Signer, a database.Attachment) attachmentResponse {
+	return attachmentResponse{
+		AttachmentID: a.AttachmentID,
+		Filename:     a.Filename,
+		ContentType:  a.ContentType,
+		SizeBytes:    a.SizeBytes,
+		URL:          signer.URL(a.AttachmentID, time.Now()),
+	}
+}
+
+// UploadAttachment POST /attachments, the file is sent as the raw body (with ?filename=) or as the "file" field of a multipart form.
+// The returned attachment_id is then passed in attachment_ids when creating a post or comment.
+func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	data, filename, err := h.readUpload(w, r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("Attachments must be at most %d bytes", h.cfg.MaxAttachmentBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, "File is empty", http.StatusBadRequest)
+		return
+	}
+
+	contentType, err := attachment.DetectType(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, contentType, err = attachment.Sanitize(data, contentType)
+	if err != nil {
+		if errors.Is(err, attachment.ErrUnsupportedType) {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if errors.Is(err, attachment.ErrDimensions) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to process attachment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Random keys so stored files cannot be guessed or enumerated
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		http.Error(w, "Failed to store attachment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	key := fmt.Sprintf("attachments/%d/%s", userID, hex.EncodeToString(random))
+
+	// Record the attachment first, the insert is what enforces the quota. The user's uploads are serialised
+	// so concurrent inserts cannot each see room under the quota and together exceed it.
+	var a database.Attachment
+	err = h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		if err := tx.LockAttachmentQuota(r.Context(), userID); err != nil {
+			return err
+		}
+		a, err = tx.CreateAttachment(r.Context(), database.CreateAttachmentParams{
+			UploadedBy:  userID,
+			StorageKey:  key,
+			Filename:    attachment.CleanFilename(filename, contentType),
+			ContentType: contentType,
+			SizeBytes:   int64(len(data)),
+			QuotaBytes:  h.cfg.AttachmentQuotaBytes,
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, fmt.Sprintf("Attachment quota of %d bytes exceeded", h.cfg.AttachmentQuotaBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to create attachment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.store.Put(r.Context(), key, bytes.NewReader(data), contentType); err != nil {
+		if delErr := h.q.DeleteAttachment(r.Context(), a.AttachmentID); delErr != nil {
+			fmt.Printf("Failed to delete attachment %d: %v\n", a.AttachmentID, delErr)
+		}
+		http.Error(w, "Failed to store attachment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(newAttachmentResponse(h.signer, a)); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// readUpload returns the uploaded file and its client supplied name, capped at MaxAttachmentBytes
+func (h *AttachmentHandler) readUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxAttachmentBytes)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		data, err := io.ReadAll(r.Body)
+		return data, r.URL.Query().Get("filename"), err
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	return data, header.Filename, err
+}
+
+// DownloadAttachment GET /attachments/{attachmentID}?expires=&signature=, only reachable through a signed URL
+func (h *AttachmentHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	expiresAt, err := h.signer.Verify(attachmentID, r.URL.Query().Get("expires"), r.URL.Query().Get("signature"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	a, err := h.q.GetAttachment(r.Context(), attachmentID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get attachment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	file, err := h.store.Get(r.Context(), a.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get attachment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	// Images display inline, anything else is downloaded rather than rendered by the browser
+	disposition := "attachment"
+	if attachment.IsImage(a.ContentType) {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(time.Until(expiresAt).Seconds())))
+	if _, err := io.Copy(w, file); err != nil {
+		fmt.Printf("Error writing attachment: %v\n", err)
+	}
+}
+
+// checkAttachments validates the attachment_ids of a new post or comment, writing an error and returning false on failure.
+// Every attachment must have been uploaded by the user and not be used anywhere yet.
+func checkAttachments(w http.ResponseWriter, r *http.Request, q *database.Queries, userID int64, ids []int64) ([]int64, bool) {
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if len(ids) > maxAttachmentsPerItem {
+		http.Error(w, fmt.Sprintf("At most %d attachments are allowed", maxAttachmentsPerItem), http.StatusBadRequest)
+		return nil, false
+	}
+	if len(ids) == 0 {
+		return ids, true
+	}
+
+	count, err := q.CountUnlinkedAttachments(r.Context(), database.CountUnlinkedAttachmentsParams{
+		AttachmentIds: ids,
+		UserID:        userID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to check attachments: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if count != int64(len(ids)) {
+		http.Error(w, "Attachments must be your own uploads and not already used", http.StatusBadRequest)
+		return nil, false
+	}
+	return ids, true
+}
+
+// attachmentsByComment groups a post's comment attachments by comment ID
+func attachmentsByComment(signer *attachment.Signer, attachments []database.Attachment) map[int64][]attachmentResponse {
+	grouped := make(map[int64][]attachmentResponse)
+	for _, a := range attachments {
+		grouped[a.CommentID.Int64] = append(grouped[a.CommentID.Int64], newAttachmentResponse(signer, a))
+	}
+	return grouped
+}
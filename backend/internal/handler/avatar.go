@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/avatar"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type AvatarHandler struct {
+	q        *database.Queries
+	store    storage.Storage
+	maxBytes int64
+}
+
+func NewAvatarHandler(q *database.Queries, store storage.Storage, maxBytes int64) *AvatarHandler {
+	return &AvatarHandler{q: q, store: store, maxBytes: maxBytes}
+}
+
+// avatarKey is where one size of a user's avatar is stored
+func avatarKey(userID int64, size int) string {
+	return fmt.Sprintf("avatars/%d/%d.png", userID, size)
+}
+
+// avatarURL is the public URL of a user's avatar, versioned so browsers can cache it until it changes
+func avatarURL(userID int64, updatedAt pgtype.Timestamptz) string {
+	url := fmt.Sprintf("/users/%d/avatar", userID)
+	if updatedAt.Valid {
+		url += "?v=" + strconv.FormatInt(updatedAt.Time.Unix(), 10)
+	}
+	return url
+}
+
+// UploadAvatar PUT /users/me/avatar, the image is sent as the raw body or as the "avatar" field of a multipart form
+func (h *AvatarHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	data, declaredType, err := h.readUpload(w, r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("Avatar must be at most %d bytes", h.maxBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Trust the file's contents, not the client's label, but reject uploads where the two disagree
+	sniffedType, err := avatar.DetectType(data)
+	if err != nil || (declaredType != "" && declaredType != sniffedType) {
+		http.Error(w, "Avatar must be a PNG, JPEG or WebP image with a matching Content-Type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	images, err := avatar.Process(data)
+	if err != nil {
+		if errors.Is(err, avatar.ErrUnsupportedType) {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if errors.Is(err, avatar.ErrDimensions) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to process avatar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for size, image := range images {
+		if err := h.store.Put(r.Context(), avatarKey(userID, size), bytes.NewReader(image), "image/png"); err != nil {
+			http.Error(w, "Failed to store avatar: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	updatedAt, err := h.q.SetUserAvatar(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to update user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"avatar_url": avatarURL(userID, updatedAt)}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// readUpload returns the uploaded file and the content type the client gave it, capped at maxBytes
+func (h *AvatarHandler) readUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBytes)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		data, err := io.ReadAll(r.Body)
+		return data, mediaType, err
+	}
+
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	declaredType, _, _ := mime.ParseMediaType(header.Header.Get("Content-Type"))
+	return data, declaredType, err
+}
+
+// DeleteAvatar DELETE /users/me/avatar, going back to the generated identicon
+func (h *AvatarHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.q.ClearUserAvatar(r.Context(), userID); err != nil {
+		http.Error(w, "Failed to update user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.deleteFiles(r.Context(), userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"avatar_url": avatarURL(userID, pgtype.Timestamptz{})}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// deleteFiles removes every stored size, a leftover file is harmless so failures are only logged
+func (h *AvatarHandler) deleteFiles(ctx context.Context, userID int64) {
+	for _, size := range avatar.Sizes {
+		if err := h.store.Delete(ctx, avatarKey(userID, size)); err != nil {
+			fmt.Printf("Failed to delete avatar %s: %v\n", avatarKey(userID, size), err)
+		}
+	}
+}
+
+// GetAvatar GET /users/{userID}/avatar (?size=256|64)
+func (h *AvatarHandler) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	size := avatar.SizeLarge
+	if sizeStr := r.URL.Query().Get("size"); sizeStr != "" {
+		size, err = strconv.Atoi(sizeStr)
+		if err != nil || !slices.Contains(avatar.Sizes, size) {
+			http.Error(w, "size must be one of "+strings.Trim(fmt.Sprint(avatar.Sizes), "[]"), http.StatusBadRequest)
+			return
+		}
+	}
+
+	user, err := h.q.GetUser(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if user.AvatarUpdatedAt.Valid {
+		file, err := h.store.Get(r.Context(), avatarKey(userID, size))
+		if err == nil {
+			defer file.Close()
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Cache-Control", "public, max-age=86400")
+			if _, err := io.Copy(w, file); err != nil {
+				fmt.Printf("Error writing avatar: %v\n", err)
+			}
+			return
+		}
+		// Fall back to the identicon rather than showing a broken image
+		if !errors.Is(err, storage.ErrNotFound) {
+			fmt.Printf("Failed to read avatar %s: %v\n", avatarKey(userID, size), err)
+		}
+	}
+
+	image, err := avatar.Identicon(user.Username, size)
+	if err != nil {
+		http.Error(w, "Failed to generate avatar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if _, err := w.Write(image); err != nil {
+		fmt.Printf("Error writing avatar: %v\n", err)
+	}
+}
@@ -0,0 +1,435 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Limits on what users write about their bookmarks
+const (
+	maxBookmarkNoteLength   = 1000
+	maxBookmarkFolderLength = 50
+)
+
+type BookmarkHandler struct {
+	q *database.Queries
+}
+
+func NewBookmarkHandler(q *database.Queries) *BookmarkHandler {
+	return &BookmarkHandler{q: q}
+}
+
+// bookmarkRequest is the body of PUT /posts/{postID}/bookmark and PUT /comments/{commentID}/bookmark.
+// Saving a bookmark again replaces its folder, note and reminder.
+type bookmarkRequest struct {
+	FolderID *int64  `json:"folder_id"` // From POST /users/me/bookmark-folders, unfiled when missing
+	Note     string  `json:"note"`
+	RemindAt *string `json:"remind_at"` // RFC3339, e.g. three days from now for "remind me in 3 days"
+}
+
+type bookmarkResponse struct {
+	BookmarkID int64   `json:"bookmark_id"`
+	PostID     *int64  `json:"post_id"`
+	CommentID  *int64  `json:"comment_id"`
+	FolderID   *int64  `json:"folder_id"`
+	Note       string  `json:"note"`
+	RemindAt   *string `json:"remind_at"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+// BookmarkPost PUT /posts/{postID}/bookmark
+func (h *BookmarkHandler) BookmarkPost(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid postID", http.StatusBadRequest)
+		return
+	}
+	post, err := h.q.GetPost(r.Context(), postID)
+	if err != nil || post.Status == "removed" {
+		if err == nil || errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	folderID, note, remindAt, ok := h.parseBookmark(w, r, userID)
+	if !ok {
+		return
+	}
+
+	bookmark, err := h.q.BookmarkPost(r.Context(), database.BookmarkPostParams{
+		UserID:   userID,
+		PostID:   pgtype.Int8{Int64: postID, Valid: true},
+		FolderID: folderID,
+		Note:     note,
+		RemindAt: remindAt,
+	})
+	if err != nil {
+		http.Error(w, "Failed to bookmark post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBookmark(w, bookmarkResponse{
+		BookmarkID: bookmark.BookmarkID,
+		PostID:     &postID,
+		FolderID:   nullableID(folderID),
+		Note:       note,
+		RemindAt:   formatOptionalTime(remindAt),
+		CreatedAt:  bookmark.CreatedAt.Time.Format(time.RFC3339),
+	})
+}
+
+// UnbookmarkPost DELETE /posts/{postID}/bookmark
+func (h *BookmarkHandler) UnbookmarkPost(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid postID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.q.DeletePostBookmark(r.Context(), database.DeletePostBookmarkParams{
+		UserID: userID,
+		PostID: pgtype.Int8{Int64: postID, Valid: true},
+	}); err != nil {
+		http.Error(w, "Failed to remove bookmark: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BookmarkComment PUT /comments/{commentID}/bookmark
+func (h *BookmarkHandler) BookmarkComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid commentID", http.StatusBadRequest)
+		return
+	}
+	comment, err := h.q.GetComment(r.Context(), commentID)
+	if err != nil || comment.Status == "removed" {
+		if err == nil || errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get comment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	folderID, note, remindAt, ok := h.parseBookmark(w, r, userID)
+	if !ok {
+		return
+	}
+
+	bookmark, err := h.q.BookmarkComment(r.Context(), database.BookmarkCommentParams{
+		UserID:    userID,
+		CommentID: pgtype.Int8{Int64: commentID, Valid: true},
+		FolderID:  folderID,
+		Note:      note,
+		RemindAt:  remindAt,
+	})
+	if err != nil {
+		http.Error(w, "Failed to bookmark comment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBookmark(w, bookmarkResponse{
+		BookmarkID: bookmark.BookmarkID,
+		PostID:     &comment.PostID,
+		CommentID:  &commentID,
+		FolderID:   nullableID(folderID),
+		Note:       note,
+		RemindAt:   formatOptionalTime(remindAt),
+		CreatedAt:  bookmark.CreatedAt.Time.Format(time.RFC3339),
+	})
+}
+
+// UnbookmarkComment DELETE /comments/{commentID}/bookmark
+func (h *BookmarkHandler) UnbookmarkComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid commentID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.q.DeleteCommentBookmark(r.Context(), database.DeleteCommentBookmarkParams{
+		UserID:    userID,
+		CommentID: pgtype.Int8{Int64: commentID, Valid: true},
+	}); err != nil {
+		http.Error(w, "Failed to remove bookmark: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListBookmarks GET /users/me/bookmarks?folder_id=&limit=&offset=, newest first.
+// Bookmarks of removed posts and comments are left out.
+func (h *BookmarkHandler) ListBookmarks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var folderID pgtype.Int8
+	if raw := r.URL.Query().Get("folder_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid folder_id", http.StatusBadRequest)
+			return
+		}
+		folderID = pgtype.Int8{Int64: id, Valid: true}
+	}
+
+	bookmarks, err := h.q.ListBookmarks(r.Context(), database.ListBookmarksParams{
+		UserID:     userID,
+		FolderID:   folderID,
+		PageLimit:  limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		http.Error(w, "Failed to list bookmarks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		BookmarkID int64   `json:"bookmark_id"`
+		PostID     int64   `json:"post_id"`    // The post, or the post the comment is on
+		CommentID  *int64  `json:"comment_id"` // Only for comment bookmarks
+		TopicID    int64   `json:"topic_id"`
+		Title      string  `json:"title"`
+		Body       string  `json:"body"` // Of the comment for comment bookmarks
+		Username   string  `json:"username"`
+		FolderID   *int64  `json:"folder_id"`
+		FolderName *string `json:"folder_name"`
+		Note       string  `json:"note"`
+		RemindAt   *string `json:"remind_at"`
+		CreatedAt  string  `json:"created_at"`
+	}
+	response := []Response{}
+	for _, b := range bookmarks {
+		var folderName *string
+		if b.FolderName.Valid {
+			folderName = &b.FolderName.String
+		}
+		response = append(response, Response{
+			BookmarkID: b.BookmarkID,
+			PostID:     b.PostID,
+			CommentID:  nullableID(b.CommentID),
+			TopicID:    b.TopicID,
+			Title:      b.Title,
+			Body:       b.Body,
+			Username:   b.Username,
+			FolderID:   nullableID(b.FolderID),
+			FolderName: folderName,
+			Note:       b.Note,
+			RemindAt:   formatOptionalTime(b.RemindAt),
+			CreatedAt:  b.CreatedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// ListFolders GET /users/me/bookmark-folders, by name
+func (h *BookmarkHandler) ListFolders(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	folders, err := h.q.ListBookmarkFolders(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to list bookmark folders: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		FolderID      int64  `json:"folder_id"`
+		Name          string `json:"name"`
+		BookmarkCount int64  `json:"bookmark_count"`
+		CreatedAt     string `json:"created_at"`
+	}
+	response := []Response{}
+	for _, f := range folders {
+		response = append(response, Response{
+			FolderID:      f.FolderID,
+			Name:          f.Name,
+			BookmarkCount: f.BookmarkCount,
+			CreatedAt:     f.CreatedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// CreateFolder POST /users/me/bookmark-folders {"name": "Recipes"}
+func (h *BookmarkHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	type Request struct {
+		Name string `json:"name"`
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxBookmarkFolderLength {
+		http.Error(w, fmt.Sprintf("Name must be 1 to %d characters", maxBookmarkFolderLength), http.StatusBadRequest)
+		return
+	}
+
+	folder, err := h.q.CreateBookmarkFolder(r.Context(), database.CreateBookmarkFolderParams{UserID: userID, Name: name})
+	if err != nil {
+		if isUniqueViolation(err, "bookmark_folders_user_name_key") {
+			http.Error(w, "You already have a folder with this name", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create bookmark folder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		FolderID  int64  `json:"folder_id"`
+		Name      string `json:"name"`
+		CreatedAt string `json:"created_at"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(Response{
+		FolderID:  folder.FolderID,
+		Name:      folder.Name,
+		CreatedAt: folder.CreatedAt.Time.Format(time.RFC3339),
+	}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// DeleteFolder DELETE /users/me/bookmark-folders/{folderID}, its bookmarks are kept unfiled
+func (h *BookmarkHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	folderID, err := strconv.ParseInt(chi.URLParam(r, "folderID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid folderID", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.q.DeleteBookmarkFolder(r.Context(), database.DeleteBookmarkFolderParams{FolderID: folderID, UserID: userID})
+	if err != nil {
+		http.Error(w, "Failed to delete bookmark folder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "Folder not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseBookmark reads and checks a bookmarkRequest, writing an error if it is invalid
+func (h *BookmarkHandler) parseBookmark(w http.ResponseWriter, r *http.Request, userID int64) (folderID pgtype.Int8, note string, remindAt pgtype.Timestamptz, ok bool) {
+	var req bookmarkRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return folderID, "", remindAt, false
+		}
+	}
+
+	if utf8.RuneCountInString(req.Note) > maxBookmarkNoteLength {
+		http.Error(w, fmt.Sprintf("Note must be at most %d characters", maxBookmarkNoteLength), http.StatusBadRequest)
+		return folderID, "", remindAt, false
+	}
+
+	if req.FolderID != nil {
+		if _, err := h.q.GetBookmarkFolder(r.Context(), database.GetBookmarkFolderParams{FolderID: *req.FolderID, UserID: userID}); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "Folder not found", http.StatusBadRequest)
+				return folderID, "", remindAt, false
+			}
+			http.Error(w, "Failed to get bookmark folder: "+err.Error(), http.StatusInternalServerError)
+			return folderID, "", remindAt, false
+		}
+		folderID = pgtype.Int8{Int64: *req.FolderID, Valid: true}
+	}
+
+	if req.RemindAt != nil {
+		t, err := time.Parse(time.RFC3339, *req.RemindAt)
+		if err != nil {
+			http.Error(w, "remind_at must be an RFC3339 time", http.StatusBadRequest)
+			return folderID, "", remindAt, false
+		}
+		if !t.After(time.Now()) {
+			http.Error(w, "remind_at must be in the future", http.StatusBadRequest)
+			return folderID, "", remindAt, false
+		}
+		remindAt = pgtype.Timestamptz{Time: t, Valid: true}
+	}
+	return folderID, req.Note, remindAt, true
+}
+
+func writeBookmark(w http.ResponseWriter, bookmark bookmarkResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(bookmark); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// nullableID is the ID, nil when it is null
+func nullableID(id pgtype.Int8) *int64 {
+	if !id.Valid {
+		return nil
+	}
+	return &id.Int64
+}
@@ -1,25 +1,33 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/DamienFooxx/CVWOForum/internal/attachment"
 	"github.com/DamienFooxx/CVWOForum/internal/auth"
 	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/notification"
+	"github.com/DamienFooxx/CVWOForum/internal/outbox"
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type CommentHandler struct {
-	q *database.Queries
+	q        *database.Queries
+	signer   *attachment.Signer
+	notifier *notification.Notifier
+	events   *outbox.Dispatcher
 }
 
-func NewCommentHandler(q *database.Queries) *CommentHandler {
-	return &CommentHandler{q: q}
+func NewCommentHandler(q *database.Queries, signer *attachment.Signer, notifier *notification.Notifier, events *outbox.Dispatcher) *CommentHandler {
+	return &CommentHandler{q: q, signer: signer, notifier: notifier, events: events}
 }
 
 // CreateComment POST /posts/{postID}/comments
@@ -41,8 +49,9 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	type Request struct {
-		Body     string `json:"body"`
-		ParentID *int64 `json:"parent_id"` // Nullable, only for replies to comments
+		Body          string  `json:"body"`
+		ParentID      *int64  `json:"parent_id"`      // Nullable, only for replies to comments
+		AttachmentIDs []int64 `json:"attachment_ids"` // From POST /attachments
 	}
 
 	var req Request
@@ -56,6 +65,17 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	attachmentIDs, ok := checkAttachments(w, r, h.q, userID, req.AttachmentIDs)
+	if !ok {
+		return
+	}
+
+	mentioned, err := mentionedUsers(r.Context(), h.q, req.Body)
+	if err != nil {
+		http.Error(w, "Failed to resolve mentions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Handle ParentID
 	// pgtype.Int8 is used for it to be nullable in DB, int64 defaults to 0 which can cause issues
 	var parentID pgtype.Int8
@@ -65,27 +85,88 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		parentID = pgtype.Int8{Valid: false}
 	}
 
-	// Write to db
-	comment, err := h.q.CreateComment(r.Context(), database.CreateCommentParams{
-		PostID:      postID,
-		CommentedBy: userID,
-		ParentID:    parentID,
-		Body:        req.Body,
+	// Write the comment, its attachments and the event announcing it together, so followers only hear of complete comments
+	var comment database.CreateCommentRow
+	err = h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		var err error
+		comment, err = tx.CreateComment(r.Context(), database.CreateCommentParams{
+			PostID:      postID,
+			CommentedBy: userID,
+			ParentID:    parentID,
+			Body:        req.Body,
+			BodyHtml:    renderWithMentions(req.Body, mentioned),
+		})
+		if err != nil {
+			return err
+		}
+		if len(attachmentIDs) > 0 {
+			if _, err := tx.LinkAttachmentsToComment(r.Context(), database.LinkAttachmentsToCommentParams{
+				CommentID:     pgtype.Int8{Int64: comment.CommentID, Valid: true},
+				AttachmentIds: attachmentIDs,
+				UserID:        userID,
+			}); err != nil {
+				return fmt.Errorf("attach files: %w", err)
+			}
+		}
+
+		// Commenters follow the rest of the discussion, unless they already chose a level for it
+		if err := tx.AutoWatchPost(r.Context(), database.AutoWatchPostParams{UserID: userID, PostID: comment.PostID}); err != nil {
+			return fmt.Errorf("watch post: %w", err)
+		}
+
+		post, err := tx.GetPost(r.Context(), comment.PostID)
+		if err != nil {
+			return err
+		}
+		var eventParentID *int64
+		if comment.ParentID.Valid {
+			eventParentID = &comment.ParentID.Int64
+		}
+		return outbox.Write(r.Context(), tx, outbox.CommentCreated, outbox.CommentCreatedEvent{
+			CommentID:   comment.CommentID,
+			PostID:      comment.PostID,
+			TopicID:     post.TopicID,
+			ParentID:    eventParentID,
+			Body:        comment.Body,
+			CommentedBy: comment.CommentedBy,
+			CreatedAt:   comment.CreatedAt.Time.Format(time.RFC3339),
+		})
 	})
 	if err != nil {
-		http.Error(w, "Failed to create comment"+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to create comment: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.events.Wake()
+
+	recordMentions(r.Context(), h.q, h.notifier, userID, mentionTarget{postID: postID, commentID: comment.CommentID}, mentioned)
+	if recipient, err := h.replyRecipient(r.Context(), comment); err != nil {
+		fmt.Printf("Failed to find who comment %d replies to: %v\n", comment.CommentID, err)
+	} else {
+		h.notifyReply(r.Context(), comment, recipient, mentioned)
+		h.notifyWatchers(r.Context(), comment, recipient, mentioned)
+	}
+
+	attachments := []attachmentResponse{}
+	if len(attachmentIDs) > 0 {
+		linked, err := h.q.ListCommentAttachmentsByPost(r.Context(), postID)
+		if err != nil {
+			http.Error(w, "Failed to list attachments: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		attachments = attachmentsByComment(h.signer, linked)[comment.CommentID]
+	}
 
 	// Create Response
 	type Response struct {
-		CommentID   int64  `json:"comment_id"`
-		PostID      int64  `json:"post_id"`
-		CommentedBy int64  `json:"commented_by"`
-		ParentID    *int64 `json:"parent_id"`
-		Body        string `json:"body"`
-		CreatedAt   string `json:"created_at"`
-		Status      string `json:"status"`
+		CommentID   int64                `json:"comment_id"`
+		PostID      int64                `json:"post_id"`
+		CommentedBy int64                `json:"commented_by"`
+		ParentID    *int64               `json:"parent_id"`
+		Body        string               `json:"body"`
+		BodyHTML    string               `json:"body_html"`
+		CreatedAt   string               `json:"created_at"`
+		Status      string               `json:"status"`
+		Attachments []attachmentResponse `json:"attachments"`
 	}
 
 	var respParentID *int64
@@ -99,8 +180,10 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		CommentedBy: comment.CommentedBy,
 		ParentID:    respParentID,
 		Body:        comment.Body,
+		BodyHTML:    renderedBody(comment.Body, comment.BodyHtml),
 		CreatedAt:   comment.CreatedAt.Time.Format(time.RFC3339),
 		Status:      comment.Status,
+		Attachments: attachments,
 	}
 
 	// Return Response
@@ -111,6 +194,81 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// notifyReply lets recipient, the author of the parent comment or of the post for top level comments, know about a new comment.
+// Authors replying to themselves are not notified, mentioned authors only get the mention,
+// and authors who muted the post get nothing.
+func (h *CommentHandler) notifyReply(ctx context.Context, comment database.CreateCommentRow, recipient int64, mentioned []database.GetUsersByUsernamesRow) {
+	notificationType := notification.TypePostReply
+	payload := map[string]interface{}{
+		"post_id":    comment.PostID,
+		"comment_id": comment.CommentID,
+		"replied_by": comment.CommentedBy,
+	}
+	if comment.ParentID.Valid {
+		notificationType = notification.TypeCommentReply
+		payload["parent_id"] = comment.ParentID.Int64
+	}
+
+	if recipient == comment.CommentedBy {
+		return
+	}
+	for _, user := range mentioned {
+		if user.UserID == recipient {
+			return
+		}
+	}
+	level, err := h.q.GetPostWatchLevel(ctx, database.GetPostWatchLevelParams{UserID: recipient, PostID: comment.PostID})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fmt.Printf("Failed to get watch level of user %d on post %d: %v\n", recipient, comment.PostID, err)
+		return
+	}
+	if level == watchLevelMuted {
+		return
+	}
+	h.notifier.Notify(ctx, recipient, notificationType, payload)
+}
+
+// notifyWatchers lets everyone watching the post at the "all" level know about a new comment.
+// The commenter, the recipient of the reply and mentioned users are left out, as they hear about it already.
+func (h *CommentHandler) notifyWatchers(ctx context.Context, comment database.CreateCommentRow, recipient int64, mentioned []database.GetUsersByUsernamesRow) {
+	exclude := []int64{comment.CommentedBy, recipient}
+	for _, user := range mentioned {
+		exclude = append(exclude, user.UserID)
+	}
+	h.notifier.NotifyPostWatchers(ctx, comment.PostID, exclude, notification.TypeWatchedPost, map[string]interface{}{
+		"post_id":      comment.PostID,
+		"comment_id":   comment.CommentID,
+		"commented_by": comment.CommentedBy,
+	})
+}
+
+// replyRecipient is the author of the comment's parent, or of the post for top level comments
+func (h *CommentHandler) replyRecipient(ctx context.Context, comment database.CreateCommentRow) (int64, error) {
+	if comment.ParentID.Valid {
+		parent, err := h.q.GetComment(ctx, comment.ParentID.Int64)
+		if err != nil {
+			return 0, err
+		}
+		return parent.CommentedBy, nil
+	}
+	post, err := h.q.GetPost(ctx, comment.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return post.CreatedBy, nil
+}
+
+// writeCommentRemoved adds the removal of a comment to the outbox, filling in its kind and topic
+func writeCommentRemoved(ctx context.Context, tx *database.Queries, event outbox.ContentRemovedEvent) error {
+	post, err := tx.GetPost(ctx, event.PostID)
+	if err != nil {
+		return err
+	}
+	event.Kind = "comment"
+	event.TopicID = post.TopicID
+	return outbox.Write(ctx, tx, outbox.ContentRemoved, event)
+}
+
 // ListComments GET /posts/{postID}/comments
 func (h *CommentHandler) ListComments(w http.ResponseWriter, r *http.Request) {
 	// Get PostID
@@ -128,16 +286,39 @@ func (h *CommentHandler) ListComments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Logged in users have now read every comment
+	if viewerID, ok := r.Context().Value(auth.UserIDKey).(int64); ok && len(comments) > 0 {
+		var lastRead int64
+		for _, c := range comments {
+			lastRead = max(lastRead, c.CommentID)
+		}
+		if err := h.q.MarkPostRead(r.Context(), database.MarkPostReadParams{
+			UserID:            viewerID,
+			PostID:            postID,
+			LastReadCommentID: lastRead,
+		}); err != nil {
+			fmt.Printf("Failed to mark post %d read for user %d: %v\n", postID, viewerID, err)
+		}
+	}
+	attachments, err := h.q.ListCommentAttachmentsByPost(r.Context(), postID)
+	if err != nil {
+		http.Error(w, "Failed to list attachments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	attachmentsByID := attachmentsByComment(h.signer, attachments)
+
 	// Create Response
 	type Response struct {
-		CommentID   int64  `json:"comment_id"`
-		PostID      int64  `json:"post_id"`
-		CommentedBy int64  `json:"commented_by"`
-		ParentID    *int64 `json:"parent_id"`
-		Body        string `json:"body"`
-		CreatedAt   string `json:"created_at"`
-		Status      string `json:"status"`
-		Username    string `json:"username"`
+		CommentID   int64                `json:"comment_id"`
+		PostID      int64                `json:"post_id"`
+		CommentedBy int64                `json:"commented_by"`
+		ParentID    *int64               `json:"parent_id"`
+		Body        string               `json:"body"`
+		BodyHTML    string               `json:"body_html"`
+		CreatedAt   string               `json:"created_at"`
+		Status      string               `json:"status"`
+		Username    string               `json:"username"`
+		Attachments []attachmentResponse `json:"attachments"`
 	}
 
 	response := []Response{}
@@ -146,15 +327,22 @@ func (h *CommentHandler) ListComments(w http.ResponseWriter, r *http.Request) {
 		if c.ParentID.Valid {
 			respParentID = &c.ParentID.Int64
 		}
+		// Attachments of removed comments are no longer handed out
+		respAttachments := []attachmentResponse{}
+		if c.Status == "active" && attachmentsByID[c.CommentID] != nil {
+			respAttachments = attachmentsByID[c.CommentID]
+		}
 		response = append(response, Response{
 			CommentID:   c.CommentID,
 			PostID:      c.PostID,
 			CommentedBy: c.CommentedBy,
 			ParentID:    respParentID,
 			Body:        c.Body,
+			BodyHTML:    renderedBody(c.Body, c.BodyHtml),
 			CreatedAt:   c.CreatedAt.Time.Format(time.RFC3339),
 			Status:      c.Status,
 			Username:    c.Username,
+			Attachments: respAttachments,
 		})
 	}
 
@@ -166,6 +354,91 @@ func (h *CommentHandler) ListComments(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// UpdateComment PATCH /comments/{commentID}
+func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Comment ID", http.StatusBadRequest)
+		return
+	}
+
+	type Request struct {
+		Body string `json:"body"`
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Body == "" {
+		http.Error(w, "Body is required", http.StatusBadRequest)
+		return
+	}
+
+	comment, err := h.q.GetComment(r.Context(), commentID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get comment", http.StatusInternalServerError)
+		}
+		return
+	}
+	if comment.Status == "removed" {
+		http.Error(w, "Comment has been deleted", http.StatusBadRequest)
+		return
+	}
+
+	mentioned, err := mentionedUsers(r.Context(), h.q, req.Body)
+	if err != nil {
+		http.Error(w, "Failed to resolve mentions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := h.q.UpdateComment(r.Context(), database.UpdateCommentParams{
+		CommentID:   commentID,
+		Body:        req.Body,
+		CommentedBy: userID,
+		BodyHtml:    renderWithMentions(req.Body, mentioned),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Comment not found or you are not the creator", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Failed to update comment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Only newly mentioned users are notified of an edit
+	recordMentions(r.Context(), h.q, h.notifier, userID, mentionTarget{postID: comment.PostID, commentID: commentID}, mentioned)
+
+	type Response struct {
+		CommentID int64   `json:"comment_id"`
+		Body      string  `json:"body"`
+		BodyHTML  string  `json:"body_html"`
+		EditedAt  *string `json:"edited_at"`
+	}
+	resp := Response{
+		CommentID: updated.CommentID,
+		Body:      updated.Body,
+		BodyHTML:  renderedBody(updated.Body, updated.BodyHtml),
+		EditedAt:  formatOptionalTime(updated.EditedAt),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
 // DeleteComment DELETE /comments/{commentID}
 func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	// Authentication
@@ -200,10 +473,19 @@ func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete comment (soft delete)
-	_, err = h.q.DeleteComment(r.Context(), database.DeleteCommentParams{
-		CommentID:   commentID,
-		CommentedBy: userID,
-		RemovedBy:   pgtype.Int8{Int64: userID, Valid: true},
+	err = h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		if _, err := tx.DeleteComment(r.Context(), database.DeleteCommentParams{
+			CommentID:   commentID,
+			CommentedBy: userID,
+			RemovedBy:   pgtype.Int8{Int64: userID, Valid: true},
+		}); err != nil {
+			return err
+		}
+		return writeCommentRemoved(r.Context(), tx, outbox.ContentRemovedEvent{
+			PostID:    comment.PostID,
+			CommentID: commentID,
+			RemovedBy: userID,
+		})
 	})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -214,6 +496,8 @@ func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.events.Wake()
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Comment deleted successfully"})
 }
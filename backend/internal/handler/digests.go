@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/digest"
+	"github.com/jackc/pgx/v5"
+)
+
+// digestOff is the frequency reported for users without a digest subscription
+const digestOff = "off"
+
+type DigestHandler struct {
+	q       *database.Queries
+	digests *digest.Sender
+}
+
+func NewDigestHandler(q *database.Queries, digests *digest.Sender) *DigestHandler {
+	return &DigestHandler{q: q, digests: digests}
+}
+
+// GetSettings GET /users/me/digest
+func (h *DigestHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	frequency := digestOff
+	subscription, err := h.q.GetDigestSubscription(r.Context(), userID)
+	if err == nil {
+		frequency = subscription.Frequency
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Failed to get digest settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeSettings(w, r, userID, frequency)
+}
+
+// UpdateSettings PUT /users/me/digest, takes {"frequency": "daily" | "weekly" | "off"}
+func (h *DigestHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	type Request struct {
+		Frequency string `json:"frequency"`
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Frequency {
+	case digestOff:
+		if _, err := h.q.DeleteDigestSubscription(r.Context(), userID); err != nil {
+			http.Error(w, "Failed to update digest settings: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case digest.Daily, digest.Weekly:
+		// The first digest covers activity from now on
+		if _, err := h.q.SetDigestSubscription(r.Context(), database.SetDigestSubscriptionParams{
+			UserID:    userID,
+			Frequency: req.Frequency,
+		}); err != nil {
+			http.Error(w, "Failed to update digest settings: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Frequency must be daily, weekly or off", http.StatusBadRequest)
+		return
+	}
+
+	h.writeSettings(w, r, userID, req.Frequency)
+}
+
+// Unsubscribe POST /digests/unsubscribe, the unsubscribe link in digest emails that works without logging in.
+// Mail clients doing one-click unsubscribe send the token in the query with a form body, the frontend sends JSON.
+func (h *DigestHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	type Request struct {
+		Token string `json:"token"`
+	}
+	req := Request{Token: r.URL.Query().Get("token")}
+	if req.Token == "" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID, err := h.digests.ParseUnsubscribeToken(req.Token)
+	if err != nil {
+		http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+		return
+	}
+	// Unsubscribing twice is not an error, the link may be opened again
+	if _, err := h.q.DeleteDigestSubscription(r.Context(), userID); err != nil {
+		http.Error(w, "Failed to unsubscribe: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"message": "Unsubscribed from digest emails"}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// writeSettings responds with the user's digest frequency, and whether they have an address digests can go to
+func (h *DigestHandler) writeSettings(w http.ResponseWriter, r *http.Request, userID int64, frequency string) {
+	user, err := h.q.GetUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Frequency     string `json:"frequency"`
+		EmailVerified bool   `json:"email_verified"` // Digests are only sent to a verified address
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Response{Frequency: frequency, EmailVerified: user.EmailVerifiedAt.Valid}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/realtime"
+)
+
+const (
+	maxEventChannels = 20   // Topics and posts one stream may follow
+	replayPageSize   = 500  // Events loaded at a time when resuming
+	maxReplayEvents  = 1000 // Clients that missed more are told to resync instead
+	eventRetryMillis = 3000
+)
+
+type EventsHandler struct {
+	broker    *realtime.Broker
+	heartbeat time.Duration
+}
+
+func NewEventsHandler(broker *realtime.Broker, heartbeat time.Duration) *EventsHandler {
+	return &EventsHandler{broker: broker, heartbeat: heartbeat}
+}
+
+// parseEventChannels reads ?topic=&post=&notifications=true, writing an error and returning false on failure.
+// topic and post can be repeated, notifications need a logged in user.
+func parseEventChannels(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	query := r.URL.Query()
+
+	var channels []string
+	for _, param := range []struct {
+		name    string
+		channel func(int64) string
+	}{
+		{"topic", realtime.TopicChannel},
+		{"post", realtime.PostChannel},
+	} {
+		for _, value := range query[param.name] {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id < 1 {
+				http.Error(w, "Invalid "+param.name+" ID", http.StatusBadRequest)
+				return nil, false
+			}
+			channels = append(channels, param.channel(id))
+		}
+	}
+	if len(channels) > maxEventChannels {
+		http.Error(w, fmt.Sprintf("At most %d topics and posts can be followed at once", maxEventChannels), http.StatusBadRequest)
+		return nil, false
+	}
+
+	if query.Get("notifications") == "true" {
+		userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+		if !ok {
+			http.Error(w, "Log in to receive notifications", http.StatusUnauthorized)
+			return nil, false
+		}
+		if !auth.HasScope(r.Context(), auth.ScopeRead) {
+			http.Error(w, "Token is missing the "+auth.ScopeRead+" scope", http.StatusForbidden)
+			return nil, false
+		}
+		channels = append(channels, realtime.UserChannel(userID))
+	}
+
+	if len(channels) == 0 {
+		http.Error(w, "Subscribe to at least one topic, post or notifications", http.StatusBadRequest)
+		return nil, false
+	}
+	return channels, true
+}
+
+// StreamEvents GET /events, a Server-Sent Events stream of new posts, comments and notifications.
+// Reconnecting clients send Last-Event-ID (or ?last_event_id=) to receive the events they missed. Past
+// maxReplayEvents they get a resync event instead and should reload what they show.
+// Event IDs follow insert rather than commit order, so an event committing just after one with a higher ID
+// can be skipped by a replay from that ID. Events are written in short transactions, which keeps the window small.
+func (h *EventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	channels, ok := parseEventChannels(w, r)
+	if !ok {
+		return
+	}
+
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("last_event_id")
+	}
+	var lastEventID int64
+	if lastEventIDStr != "" {
+		parsed, err := strconv.ParseInt(lastEventIDStr, 10, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastEventID = parsed
+	}
+
+	// Subscribe before replaying so nothing published in between is lost
+	sub := h.broker.Subscribe(channels)
+	defer h.broker.Unsubscribe(sub)
+
+	var missed []realtime.Event
+	var resyncID int64 // Set when too much was missed to replay, the stream carries on from this event
+	if lastEventIDStr != "" {
+		afterID := lastEventID
+		for {
+			page, err := h.broker.Replay(r.Context(), channels, afterID, replayPageSize)
+			if err != nil {
+				http.Error(w, "Failed to load missed events: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			missed = append(missed, page...)
+			if len(missed) > maxReplayEvents {
+				missed = nil
+				if resyncID, err = h.broker.LatestEventID(r.Context()); err != nil {
+					http.Error(w, "Failed to load missed events: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				break
+			}
+			if len(page) < replayPageSize {
+				break
+			}
+			afterID = page[len(page)-1].ID
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop Nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetryMillis)
+	if resyncID != 0 {
+		// The ID moves the client's Last-Event-ID past what it missed, so it does not resync again on reconnect
+		fmt.Fprintf(w, "id: %d\nevent: resync\ndata: {}\n\n", resyncID)
+	}
+
+	// Live events can overlap with the replay
+	replayed := make(map[int64]bool, len(missed))
+	for _, event := range missed {
+		writeEvent(w, event)
+		replayed[event.ID] = true
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Dropped for falling behind, the client reconnects with Last-Event-ID
+				return
+			}
+			// Events up to the resync point are covered by the client reloading
+			if replayed[event.ID] || event.ID <= resyncID {
+				continue
+			}
+			writeEvent(w, event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes one event in the text/event-stream format
+func writeEvent(w http.ResponseWriter, event realtime.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+)
+
+// feedPopularTopics is how many of the busiest topics fill the feed of a user who follows none
+const feedPopularTopics = 5
+
+// Where the posts in a feed come from
+const (
+	feedSourceFollowing = "following"
+	feedSourcePopular   = "popular"
+)
+
+type FeedHandler struct {
+	q *database.Queries
+}
+
+func NewFeedHandler(q *database.Queries) *FeedHandler {
+	return &FeedHandler{q: q}
+}
+
+// GetFeed GET /feed?limit=&offset=, the newest posts in the user's followed topics.
+// Users who follow no topics get the newest posts in the most popular ones they have not muted.
+func (h *FeedHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	following, err := h.q.CountFollowedTopics(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to count followed topics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var posts []database.ListFeedPostsRow
+	source := feedSourceFollowing
+	if following > 0 {
+		posts, err = h.q.ListFeedPosts(r.Context(), database.ListFeedPostsParams{
+			UserID:     userID,
+			PageLimit:  limit,
+			PageOffset: offset,
+		})
+	} else {
+		source = feedSourcePopular
+		var popular []database.ListPopularFeedPostsRow
+		popular, err = h.q.ListPopularFeedPosts(r.Context(), database.ListPopularFeedPostsParams{
+			UserID:     userID,
+			TopicLimit: feedPopularTopics,
+			PageLimit:  limit,
+			PageOffset: offset,
+		})
+		for _, p := range popular {
+			posts = append(posts, database.ListFeedPostsRow(p))
+		}
+	}
+	if err != nil {
+		http.Error(w, "Failed to get feed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Post struct {
+		PostID    int64  `json:"post_id"`
+		TopicID   int64  `json:"topic_id"`
+		TopicName string `json:"topic_name"`
+		Title     string `json:"title"`
+		Body      string `json:"body"`
+		BodyHTML  string `json:"body_html"`
+		CreatedAt string `json:"created_at"`
+		CreatedBy int64  `json:"created_by"`
+		Status    string `json:"status"`
+		Username  string `json:"username"`
+	}
+	type Response struct {
+		Source string `json:"source"` // "following", or "popular" for users who follow no topics yet
+		Posts  []Post `json:"posts"`
+	}
+
+	response := Response{Source: source, Posts: []Post{}}
+	for _, p := range posts {
+		response.Posts = append(response.Posts, Post{
+			PostID:    p.PostID,
+			TopicID:   p.TopicID,
+			TopicName: p.TopicName,
+			Title:     p.Title,
+			Body:      p.Body,
+			BodyHTML:  renderedBody(p.Body, p.BodyHtml),
+			CreatedAt: p.CreatedAt.Time.Format(time.RFC3339),
+			CreatedBy: p.CreatedBy,
+			Status:    p.Status,
+			Username:  p.Username,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
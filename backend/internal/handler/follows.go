@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// FollowTopic PUT /topics/{topicID}/follow, following a muted topic unmutes it
+func (h *TopicHandler) FollowTopic(w http.ResponseWriter, r *http.Request) {
+	userID, topicID, ok := h.topicForUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		if _, err := tx.UnmuteTopic(r.Context(), database.UnmuteTopicParams{UserID: userID, TopicID: topicID}); err != nil {
+			return err
+		}
+		return tx.FollowTopic(r.Context(), database.FollowTopicParams{UserID: userID, TopicID: topicID})
+	})
+	if err != nil {
+		http.Error(w, "Failed to follow topic: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnfollowTopic DELETE /topics/{topicID}/follow
+func (h *TopicHandler) UnfollowTopic(w http.ResponseWriter, r *http.Request) {
+	userID, topicID, ok := h.topicForUser(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.q.UnfollowTopic(r.Context(), database.UnfollowTopicParams{UserID: userID, TopicID: topicID}); err != nil {
+		http.Error(w, "Failed to unfollow topic: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MuteTopic PUT /topics/{topicID}/mute, hiding the topic from the user's topic and post listings.
+// Muting a followed topic unfollows it.
+func (h *TopicHandler) MuteTopic(w http.ResponseWriter, r *http.Request) {
+	userID, topicID, ok := h.topicForUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		if _, err := tx.UnfollowTopic(r.Context(), database.UnfollowTopicParams{UserID: userID, TopicID: topicID}); err != nil {
+			return err
+		}
+		return tx.MuteTopic(r.Context(), database.MuteTopicParams{UserID: userID, TopicID: topicID})
+	})
+	if err != nil {
+		http.Error(w, "Failed to mute topic: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnmuteTopic DELETE /topics/{topicID}/mute
+func (h *TopicHandler) UnmuteTopic(w http.ResponseWriter, r *http.Request) {
+	userID, topicID, ok := h.topicForUser(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.q.UnmuteTopic(r.Context(), database.UnmuteTopicParams{UserID: userID, TopicID: topicID}); err != nil {
+		http.Error(w, "Failed to unmute topic: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFollowedTopics GET /users/me/followed-topics?limit=&offset=
+func (h *TopicHandler) ListFollowedTopics(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	topics, err := h.q.ListFollowedTopics(r.Context(), database.ListFollowedTopicsParams{
+		UserID:     userID,
+		PageLimit:  limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		http.Error(w, "Failed to list followed topics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := []subscribedTopicResponse{}
+	for _, t := range topics {
+		response = append(response, subscribedTopicResponse{
+			TopicID:     t.TopicID,
+			Name:        t.Name,
+			Description: t.Description,
+			CreatedAt:   t.CreatedAt.Time.Format(time.RFC3339),
+			Status:      t.Status,
+			PostCount:   t.PostCount,
+			CreatedBy:   t.CreatedBy,
+			Since:       t.FollowedAt.Time.Format(time.RFC3339),
+		})
+	}
+	writeSubscribedTopics(w, response)
+}
+
+// ListMutedTopics GET /users/me/muted-topics?limit=&offset=
+func (h *TopicHandler) ListMutedTopics(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	topics, err := h.q.ListMutedTopics(r.Context(), database.ListMutedTopicsParams{
+		UserID:     userID,
+		PageLimit:  limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		http.Error(w, "Failed to list muted topics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := []subscribedTopicResponse{}
+	for _, t := range topics {
+		response = append(response, subscribedTopicResponse{
+			TopicID:     t.TopicID,
+			Name:        t.Name,
+			Description: t.Description,
+			CreatedAt:   t.CreatedAt.Time.Format(time.RFC3339),
+			Status:      t.Status,
+			PostCount:   t.PostCount,
+			CreatedBy:   t.CreatedBy,
+			Since:       t.MutedAt.Time.Format(time.RFC3339),
+		})
+	}
+	writeSubscribedTopics(w, response)
+}
+
+// subscribedTopicResponse is a topic the user follows or has muted, and since when
+type subscribedTopicResponse struct {
+	TopicID     int64  `json:"topic_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+	Status      string `json:"status"`
+	PostCount   int64  `json:"post_count"`
+	CreatedBy   int64  `json:"created_by"`
+	Since       string `json:"since"`
+}
+
+func writeSubscribedTopics(w http.ResponseWriter, topics []subscribedTopicResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(topics); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// topicForUser reads the logged in user and the {topicID} they are changing, writing an error if either is missing
+func (h *TopicHandler) topicForUser(w http.ResponseWriter, r *http.Request) (userID, topicID int64, ok bool) {
+	userID, ok = r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+
+	topicID, err := strconv.ParseInt(chi.URLParam(r, "topicID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid topicID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	topic, err := h.q.GetTopic(r.Context(), topicID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Topic not found", http.StatusNotFound)
+			return 0, 0, false
+		}
+		http.Error(w, "Failed to get topic: "+err.Error(), http.StatusInternalServerError)
+		return 0, 0, false
+	}
+	if topic.Status == "removed" {
+		http.Error(w, "Topic not found", http.StatusNotFound)
+		return 0, 0, false
+	}
+	return userID, topicID, true
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type JobHandler struct {
+	q *database.Queries
+}
+
+func NewJobHandler(q *database.Queries) *JobHandler {
+	return &JobHandler{q: q}
+}
+
+// jobResponse is a background job and the outcome of its latest attempt
+type jobResponse struct {
+	JobID       int64           `json:"job_id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	UniqueKey   *string         `json:"unique_key"`
+	Status      string          `json:"status"` // queued, running, succeeded or dead
+	Attempts    int32           `json:"attempts"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       string          `json:"run_at"`
+	LastError   *string         `json:"last_error"`
+	CreatedAt   string          `json:"created_at"`
+	FinishedAt  *string         `json:"finished_at"`
+}
+
+func newJobResponse(job database.Job) jobResponse {
+	resp := jobResponse{
+		JobID:       job.JobID,
+		Kind:        job.Kind,
+		Payload:     job.Payload,
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt.Time.Format(time.RFC3339),
+		CreatedAt:   job.CreatedAt.Time.Format(time.RFC3339),
+		FinishedAt:  formatOptionalTime(job.FinishedAt),
+	}
+	if job.UniqueKey.Valid {
+		resp.UniqueKey = &job.UniqueKey.String
+	}
+	if job.LastError.Valid {
+		resp.LastError = &job.LastError.String
+	}
+	return resp
+}
+
+// ListJobs GET /admin/jobs?status=&limit=&offset=, newest first. status=dead lists the jobs that gave up.
+func (h *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var status pgtype.Text
+	if value := r.URL.Query().Get("status"); value != "" {
+		if value != "queued" && value != "running" && value != "succeeded" && value != "dead" {
+			http.Error(w, "status must be queued, running, succeeded or dead", http.StatusBadRequest)
+			return
+		}
+		status = pgtype.Text{String: value, Valid: true}
+	}
+
+	jobs, err := h.q.ListJobs(r.Context(), database.ListJobsParams{
+		Status:     status,
+		PageLimit:  limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		http.Error(w, "Failed to list jobs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := []jobResponse{}
+	for _, job := range jobs {
+		response = append(response, newJobResponse(job))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// RetryJob POST /admin/jobs/{jobID}/retry, queues a dead job again with a fresh set of attempts
+func (h *JobHandler) RetryJob(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "jobID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.q.RequeueDeadJob(r.Context(), jobID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Failed to retry job: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := h.q.GetJob(r.Context(), jobID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "Job not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to get job: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, "Only dead jobs without a queued copy can be retried", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(newJobResponse(job)); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
@@ -0,0 +1,14 @@
+package handler
+
+import (
+	"github.com/DamienFooxx/CVWOForum/internal/markdown"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// renderedBody returns the cached HTML of a body, rendering rows stored before rendering was added
+func renderedBody(body string, cached pgtype.Text) string {
+	if cached.Valid {
+		return cached.String
+	}
+	return markdown.Render(body)
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/markdown"
+	"github.com/DamienFooxx/CVWOForum/internal/notification"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxMentions caps how many users one post or comment can notify
+const maxMentions = 20
+
+// mentionTarget is where a body was written, commentID is 0 for posts
+type mentionTarget struct {
+	postID    int64
+	commentID int64
+}
+
+// mentionedUsers resolves the @mentions in body to existing users
+func mentionedUsers(ctx context.Context, q *database.Queries, body string) ([]database.GetUsersByUsernamesRow, error) {
+	usernames := markdown.Mentions(body)
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	if len(usernames) > maxMentions {
+		usernames = usernames[:maxMentions]
+	}
+	return q.GetUsersByUsernames(ctx, usernames)
+}
+
+// renderWithMentions renders body with links to the profiles of the mentioned users
+func renderWithMentions(body string, mentioned []database.GetUsersByUsernamesRow) pgtype.Text {
+	usernames := make([]string, 0, len(mentioned))
+	for _, user := range mentioned {
+		usernames = append(usernames, user.Username)
+	}
+	return pgtype.Text{String: markdown.Render(body, usernames...), Valid: true}
+}
+
+// recordMentions makes the stored mentions of a post or comment match its body and notifies users
+// mentioned for the first time. Mentions are a side effect of writing, so failures are only logged.
+func recordMentions(ctx context.Context, q *database.Queries, notifier *notification.Notifier, authorID int64, target mentionTarget, mentioned []database.GetUsersByUsernamesRow) {
+	userIDs := make([]int64, 0, len(mentioned))
+	for _, user := range mentioned {
+		userIDs = append(userIDs, user.UserID)
+	}
+
+	var added []int64
+	var err error
+	if target.commentID != 0 {
+		commentID := pgtype.Int8{Int64: target.commentID, Valid: true}
+		if err = q.DeleteCommentMentionsExcept(ctx, database.DeleteCommentMentionsExceptParams{CommentID: commentID, UserIds: userIDs}); err == nil {
+			added, err = q.CreateCommentMentions(ctx, database.CreateCommentMentionsParams{UserIds: userIDs, MentionedBy: authorID, CommentID: commentID})
+		}
+	} else {
+		postID := pgtype.Int8{Int64: target.postID, Valid: true}
+		if err = q.DeletePostMentionsExcept(ctx, database.DeletePostMentionsExceptParams{PostID: postID, UserIds: userIDs}); err == nil {
+			added, err = q.CreatePostMentions(ctx, database.CreatePostMentionsParams{UserIds: userIDs, MentionedBy: authorID, PostID: postID})
+		}
+	}
+	if err != nil {
+		fmt.Printf("Failed to record mentions for post %d comment %d: %v\n", target.postID, target.commentID, err)
+		return
+	}
+
+	payload := map[string]interface{}{
+		"post_id":      target.postID,
+		"mentioned_by": authorID,
+	}
+	if target.commentID != 0 {
+		payload["comment_id"] = target.commentID
+	}
+
+	for _, userID := range added {
+		// Mentioning yourself does not notify
+		if userID == authorID {
+			continue
+		}
+		notifier.Notify(ctx, userID, notification.TypeMention, payload)
+	}
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/jobs"
+	"github.com/DamienFooxx/CVWOForum/internal/notification"
+	"github.com/DamienFooxx/CVWOForum/internal/outbox"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxReasonLength bounds the reason a moderator gives for a removal
+const maxReasonLength = 500
+
+type ModerationHandler struct {
+	q        *database.Queries
+	notifier *notification.Notifier
+	jobs     *jobs.Queue
+	events   *outbox.Dispatcher
+}
+
+func NewModerationHandler(q *database.Queries, notifier *notification.Notifier, queue *jobs.Queue, events *outbox.Dispatcher) *ModerationHandler {
+	return &ModerationHandler{q: q, notifier: notifier, jobs: queue, events: events}
+}
+
+// parseReason reads the optional {"reason": "..."} body of a removal, writing an error and returning false on failure
+func parseReason(w http.ResponseWriter, r *http.Request) (string, bool) {
+	type Request struct {
+		Reason string `json:"reason"`
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return "", false
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if len(reason) > maxReasonLength {
+		http.Error(w, fmt.Sprintf("reason must be at most %d characters", maxReasonLength), http.StatusBadRequest)
+		return "", false
+	}
+	return reason, true
+}
+
+// RemovePost DELETE /moderation/posts/{postID}, removes any user's post and lets them know why
+func (h *ModerationHandler) RemovePost(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Post ID", http.StatusBadRequest)
+		return
+	}
+	reason, ok := parseReason(w, r)
+	if !ok {
+		return
+	}
+
+	var post database.ModeratePostRow
+	err = h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		var err error
+		post, err = tx.ModeratePost(r.Context(), database.ModeratePostParams{
+			PostID:    postID,
+			RemovedBy: pgtype.Int8{Int64: userID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		return outbox.Write(r.Context(), tx, outbox.ContentRemoved, outbox.ContentRemovedEvent{
+			Kind:        "post",
+			TopicID:     post.TopicID,
+			PostID:      post.PostID,
+			RemovedBy:   userID,
+			ByModerator: true,
+			Reason:      reason,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Post not found or already deleted", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to remove post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.events.Wake()
+
+	recountPosts(r, h.jobs, post.TopicID)
+
+	if post.CreatedBy != userID {
+		h.notifier.Notify(r.Context(), post.CreatedBy, notification.TypeModeration, map[string]interface{}{
+			"action":       "post_removed",
+			"post_id":      post.PostID,
+			"title":        post.Title,
+			"reason":       reason,
+			"moderator_id": userID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Post removed successfully"})
+}
+
+// RemoveComment DELETE /moderation/comments/{commentID}, removes any user's comment and lets them know why
+func (h *ModerationHandler) RemoveComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Comment ID", http.StatusBadRequest)
+		return
+	}
+	reason, ok := parseReason(w, r)
+	if !ok {
+		return
+	}
+
+	var comment database.ModerateCommentRow
+	err = h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		var err error
+		comment, err = tx.ModerateComment(r.Context(), database.ModerateCommentParams{
+			CommentID: commentID,
+			RemovedBy: pgtype.Int8{Int64: userID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		return writeCommentRemoved(r.Context(), tx, outbox.ContentRemovedEvent{
+			PostID:      comment.PostID,
+			CommentID:   comment.CommentID,
+			RemovedBy:   userID,
+			ByModerator: true,
+			Reason:      reason,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Comment not found or already deleted", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to remove comment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if comment.CommentedBy != userID {
+		h.notifier.Notify(r.Context(), comment.CommentedBy, notification.TypeModeration, map[string]interface{}{
+			"action":       "comment_removed",
+			"post_id":      comment.PostID,
+			"comment_id":   comment.CommentID,
+			"reason":       reason,
+			"moderator_id": userID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Comment removed successfully"})
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/notification"
+)
+
+// maxMarkRead caps how many notifications one POST /notifications/read can list
+const maxMarkRead = 100
+
+type NotificationHandler struct {
+	q *database.Queries
+}
+
+func NewNotificationHandler(q *database.Queries) *NotificationHandler {
+	return &NotificationHandler{q: q}
+}
+
+// ListNotifications GET /notifications?unread=true&limit=&offset=
+func (h *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	notifications, err := h.q.ListNotifications(r.Context(), database.ListNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		PageLimit:  limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		http.Error(w, "Failed to list notifications: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	unread, err := h.q.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to count notifications: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Notification struct {
+		NotificationID int64           `json:"notification_id"`
+		Type           string          `json:"type"`
+		Payload        json.RawMessage `json:"payload"`
+		ReadAt         *string         `json:"read_at"`
+		CreatedAt      string          `json:"created_at"`
+	}
+	type Response struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+	}
+
+	response := Response{Notifications: []Notification{}, UnreadCount: unread}
+	for _, n := range notifications {
+		response.Notifications = append(response.Notifications, Notification{
+			NotificationID: n.NotificationID,
+			Type:           n.Type,
+			Payload:        n.Payload,
+			ReadAt:         formatOptionalTime(n.ReadAt),
+			CreatedAt:      n.CreatedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// MarkRead POST /notifications/read, marks the listed notifications or all of them as read
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	type Request struct {
+		NotificationIDs []int64 `json:"notification_ids"`
+		All             bool    `json:"all"`
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.All == (len(req.NotificationIDs) > 0) {
+		http.Error(w, "Either notification_ids or all is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.NotificationIDs) > maxMarkRead {
+		http.Error(w, fmt.Sprintf("At most %d notifications can be marked read at once", maxMarkRead), http.StatusBadRequest)
+		return
+	}
+
+	// Other users' notifications are silently skipped
+	var marked int64
+	var err error
+	if req.All {
+		marked, err = h.q.MarkAllNotificationsRead(r.Context(), userID)
+	} else {
+		marked, err = h.q.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+			UserID:          userID,
+			NotificationIds: req.NotificationIDs,
+		})
+	}
+	if err != nil {
+		http.Error(w, "Failed to mark notifications read: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	unread, err := h.q.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to count notifications: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Marked      int64 `json:"marked"`
+		UnreadCount int64 `json:"unread_count"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Response{Marked: marked, UnreadCount: unread}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// GetPreferences GET /users/me/notification-preferences
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	h.writePreferences(w, r, userID)
+}
+
+// UpdatePreferences PUT /users/me/notification-preferences, takes a map of type to enabled e.g. {"post_reply": false}
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	for notificationType := range req {
+		if !slices.Contains(notification.Configurable, notificationType) {
+			http.Error(w, "Unknown notification type: "+notificationType, http.StatusBadRequest)
+			return
+		}
+	}
+
+	for notificationType, enabled := range req {
+		if err := h.q.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  userID,
+			Type:    notificationType,
+			Enabled: enabled,
+		}); err != nil {
+			http.Error(w, "Failed to update preferences: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.writePreferences(w, r, userID)
+}
+
+// writePreferences responds with whether each configurable type is enabled for the user
+func (h *NotificationHandler) writePreferences(w http.ResponseWriter, r *http.Request, userID int64) {
+	preferences, err := h.q.ListNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get preferences: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make(map[string]bool, len(notification.Configurable))
+	for _, notificationType := range notification.Configurable {
+		response[notificationType] = true
+	}
+	for _, p := range preferences {
+		response[p.Type] = p.Enabled
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
@@ -0,0 +1,385 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/oauth2"
+)
+
+/**
+OpenID Connect single sign-on, authorization code flow with PKCE
+/auth/oidc/login stores a state, nonce and PKCE verifier then redirects to the IdP
+/auth/oidc/callback exchanges the code, verifies the ID token, links or provisions the user and issues the usual forum JWT
+*/
+
+// oidcStateTTL is how long a user has to finish signing in at the IdP
+const oidcStateTTL = 10 * time.Minute
+
+// oidcStateCookie binds a login to the browser that started it, so a callback cannot be replayed in someone else's
+const oidcStateCookie = "oidc_state"
+
+type OIDCHandler struct {
+	q   *database.Queries
+	cfg config.OIDCConfig
+
+	mu       sync.Mutex
+	provider *oidc.Provider // Discovered on first use so the forum still starts while the IdP is down
+}
+
+func NewOIDCHandler(q *database.Queries, cfg config.OIDCConfig) *OIDCHandler {
+	return &OIDCHandler{q: q, cfg: cfg}
+}
+
+// oauthConfig returns the OAuth2 client config and provider, running discovery if it has not succeeded yet
+func (h *OIDCHandler) oauthConfig(ctx context.Context) (*oauth2.Config, *oidc.Provider, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.provider == nil {
+		provider, err := oidc.NewProvider(ctx, h.cfg.IssuerURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.provider = provider
+	}
+
+	return &oauth2.Config{
+		ClientID:     h.cfg.ClientID,
+		ClientSecret: h.cfg.ClientSecret,
+		RedirectURL:  h.cfg.RedirectURL,
+		Endpoint:     h.provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, h.cfg.Scopes...),
+	}, h.provider, nil
+}
+
+// randomString returns a URL safe random string for states and nonces
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Login GET /auth/oidc/login
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	oauthConfig, _, err := h.oauthConfig(r.Context())
+	if err != nil {
+		http.Error(w, "Identity provider unavailable: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	state, err := randomString()
+	if err != nil {
+		http.Error(w, "Failed to generate state", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomString()
+	if err != nil {
+		http.Error(w, "Failed to generate nonce", http.StatusInternalServerError)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	// Clear out abandoned logins while we are here
+	if err := h.q.DeleteExpiredOidcLoginStates(r.Context()); err != nil {
+		fmt.Printf("Failed to delete expired OIDC login states: %v\n", err)
+	}
+
+	err = h.q.CreateOidcLoginState(r.Context(), database.CreateOidcLoginStateParams{
+		State:        state,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    pgtype.Timestamptz{Time: time.Now().Add(oidcStateTTL), Valid: true},
+	})
+	if err != nil {
+		http.Error(w, "Failed to start login: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, h.stateCookie(state, int(oidcStateTTL/time.Second)))
+
+	authURL := oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback GET /auth/oidc/callback
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if idpError := query.Get("error"); idpError != "" {
+		http.Error(w, "Identity provider returned an error: "+idpError+" "+query.Get("error_description"), http.StatusUnauthorized)
+		return
+	}
+
+	code := query.Get("code")
+	state := query.Get("state")
+	if code == "" || state == "" {
+		http.Error(w, "code and state are required", http.StatusBadRequest)
+		return
+	}
+
+	// Only the browser that started the login may finish it
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, "Login was started in a different browser, please try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, h.stateCookie("", -1))
+
+	// Single use, so a replayed callback fails here
+	loginState, err := h.q.ConsumeOidcLoginState(r.Context(), state)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Unknown or expired login, please try again", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	oauthConfig, provider, err := h.oauthConfig(r.Context())
+	if err != nil {
+		http.Error(w, "Identity provider unavailable: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	oauthToken, err := oauthConfig.Exchange(r.Context(), code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		http.Error(w, "Failed to exchange code: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	rawIDToken, ok := oauthToken.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "Identity provider did not return an ID token", http.StatusUnauthorized)
+		return
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: h.cfg.ClientID}).Verify(r.Context(), rawIDToken)
+	if err != nil {
+		http.Error(w, "Invalid ID token: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if idToken.Nonce != loginState.Nonce {
+		http.Error(w, "Invalid ID token: nonce mismatch", http.StatusUnauthorized)
+		return
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		http.Error(w, "Invalid ID token claims: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.findOrProvisionUser(r.Context(), idToken.Issuer, idToken.Subject, claims)
+	if err != nil {
+		http.Error(w, "Failed to sign in: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The IdP is the source of truth for roles once a mapping is configured
+	if len(h.cfg.RoleMapping) > 0 {
+		role := h.roleFromGroups(claims)
+		if role != user.Role {
+			if err := h.q.UpdateUserRole(r.Context(), database.UpdateUserRoleParams{UserID: user.UserID, Role: role}); err != nil {
+				http.Error(w, "Failed to update role: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			user.Role = role
+		}
+	}
+
+	// Accounts with 2FA still need their second factor after signing in at the IdP
+	enabled, err := twoFactorEnabled(r.Context(), h.q, user.UserID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if enabled {
+		if h.cfg.PostLoginRedirect == "" {
+			writeMFAChallenge(w, user.UserID, user.Username)
+			return
+		}
+		mfaToken, err := auth.GenerateMFAToken(user.UserID)
+		if err != nil {
+			http.Error(w, "Failed to generate token: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fragment := url.Values{
+			"mfa_required": {"true"},
+			"mfa_token":    {mfaToken},
+			"username":     {user.Username},
+			"user_id":      {strconv.FormatInt(user.UserID, 10)},
+		}
+		http.Redirect(w, r, h.cfg.PostLoginRedirect+"#"+fragment.Encode(), http.StatusFound)
+		return
+	}
+
+	token, err := auth.GenerateToken(user.UserID)
+	if err != nil {
+		http.Error(w, "Failed to generate token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Browser flow, hand the token to the frontend in the fragment so it never reaches server logs
+	if h.cfg.PostLoginRedirect != "" {
+		fragment := url.Values{
+			"token":    {token},
+			"username": {user.Username},
+			"user_id":  {strconv.FormatInt(user.UserID, 10)},
+			"role":     {user.Role},
+		}
+		http.Redirect(w, r, h.cfg.PostLoginRedirect+"#"+fragment.Encode(), http.StatusFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":    token,
+		"username": user.Username,
+		"user_id":  user.UserID,
+		"role":     user.Role,
+	}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// stateCookie returns the login state cookie, a negative maxAge deletes it
+func (h *OIDCHandler) stateCookie(state string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.cfg.RedirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode, // Sent on the top level redirect back from the IdP
+	}
+}
+
+// findOrProvisionUser returns the forum user linked to the IdP account, linking or creating one on first login
+func (h *OIDCHandler) findOrProvisionUser(ctx context.Context, issuer, subject string, claims map[string]interface{}) (database.User, error) {
+	identity, err := h.q.GetUserIdentity(ctx, database.GetUserIdentityParams{Issuer: issuer, Subject: subject})
+	if err == nil {
+		return h.q.GetUser(ctx, identity.UserID)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	var user database.User
+	linked := false
+	// Only link on an email both sides have verified, a matching username proves nothing since /login creates accounts on demand
+	if email := verifiedEmailFromClaims(claims); h.cfg.LinkExistingUsers && email != "" {
+		userID, err := h.q.FindUserIDByVerifiedEmail(ctx, email)
+		switch {
+		case err == nil:
+			if user, err = h.q.GetUser(ctx, userID); err != nil {
+				return database.User{}, err
+			}
+			linked = true
+		case !errors.Is(err, pgx.ErrNoRows):
+			return database.User{}, err
+		}
+	}
+	if !linked {
+		user, err = h.provisionUser(ctx, usernameFromClaims(subject, claims))
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+
+	err = h.q.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		UserID:  user.UserID,
+		Issuer:  issuer,
+		Subject: subject,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+// provisionUser creates a user without a password, adding a numeric suffix if the username is taken
+func (h *OIDCHandler) provisionUser(ctx context.Context, username string) (database.User, error) {
+	for attempt := 1; attempt <= 10; attempt++ {
+		candidate := username
+		if attempt > 1 {
+			candidate = username + "-" + strconv.Itoa(attempt)
+		}
+
+		created, err := h.q.CreateUser(ctx, database.CreateUserParams{Username: candidate})
+		if err == nil {
+			return h.q.GetUser(ctx, created.UserID)
+		}
+		if !isUniqueViolation(err, "users_username_key") {
+			return database.User{}, err
+		}
+	}
+	return database.User{}, fmt.Errorf("could not find a free username based on %q", username)
+}
+
+// verifiedEmailFromClaims returns the email claim if the IdP says it has verified it
+func verifiedEmailFromClaims(claims map[string]interface{}) string {
+	email, _ := claims["email"].(string)
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		return ""
+	}
+	return email
+}
+
+// usernameFromClaims picks a forum username from the standard profile claims
+func usernameFromClaims(subject string, claims map[string]interface{}) string {
+	if preferred, ok := claims["preferred_username"].(string); ok && preferred != "" {
+		return preferred
+	}
+	if email, ok := claims["email"].(string); ok && email != "" {
+		local, _, _ := strings.Cut(email, "@")
+		return local
+	}
+	if len(subject) > 12 {
+		subject = subject[:12]
+	}
+	return "user-" + subject
+}
+
+// roleFromGroups maps the user's IdP groups to the most privileged forum role they grant
+func (h *OIDCHandler) roleFromGroups(claims map[string]interface{}) string {
+	var groups []string
+	switch value := claims[h.cfg.GroupsClaim].(type) {
+	case []interface{}:
+		for _, g := range value {
+			if group, ok := g.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	case string:
+		groups = strings.Fields(value)
+	}
+
+	rank := map[string]int{auth.RoleMember: 0, auth.RoleModerator: 1, auth.RoleAdmin: 2}
+	role := auth.RoleMember
+	for _, group := range groups {
+		if mapped, ok := h.cfg.RoleMapping[group]; ok && rank[mapped] > rank[role] {
+			role = mapped
+		}
+	}
+	return role
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// parsePagination reads ?limit= and ?offset=, defaulting to the first page of 20
+func parsePagination(r *http.Request) (limit int32, offset int32, err error) {
+	limit = defaultPageSize
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 32)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			return 0, 0, errors.New("limit must be between 1 and 100")
+		}
+		limit = int32(parsed)
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		parsed, err := strconv.ParseInt(offsetStr, 10, 32)
+		if err != nil || parsed < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+		offset = int32(parsed)
+	}
+
+	return limit, offset, nil
+}
@@ -8,19 +8,36 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/DamienFooxx/CVWOForum/internal/attachment"
 	"github.com/DamienFooxx/CVWOForum/internal/auth"
 	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/jobs"
+	"github.com/DamienFooxx/CVWOForum/internal/notification"
+	"github.com/DamienFooxx/CVWOForum/internal/outbox"
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type PostHandler struct {
-	q *database.Queries
+	q        *database.Queries
+	signer   *attachment.Signer
+	notifier *notification.Notifier
+	jobs     *jobs.Queue
+	events   *outbox.Dispatcher
 }
 
-func NewPostHandler(q *database.Queries) *PostHandler {
-	return &PostHandler{q: q}
+func NewPostHandler(q *database.Queries, signer *attachment.Signer, notifier *notification.Notifier, queue *jobs.Queue, events *outbox.Dispatcher) *PostHandler {
+	return &PostHandler{q: q, signer: signer, notifier: notifier, jobs: queue, events: events}
+}
+
+// recountPosts queues a recount of a topic's post_count, repeated changes to one topic share a single pending job
+func recountPosts(r *http.Request, queue *jobs.Queue, topicID int64) {
+	_, err := jobs.Enqueue(r.Context(), queue, jobs.RecountTopicPosts, jobs.TopicPayload{TopicID: topicID},
+		jobs.UniqueKey(fmt.Sprintf("topic-post-count:%d", topicID)))
+	if err != nil && !errors.Is(err, jobs.ErrDuplicate) {
+		fmt.Printf("Failed to queue post recount for topic %d: %v\n", topicID, err)
+	}
 }
 
 // CreatePost POST /topics/{topicID}/posts
@@ -42,8 +59,9 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	type Request struct {
-		Title string `json:"title"`
-		Body  string `json:"body"`
+		Title         string  `json:"title"`
+		Body          string  `json:"body"`
+		AttachmentIDs []int64 `json:"attachment_ids"` // From POST /attachments
 	}
 	var req Request
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -56,41 +74,93 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Write to db
-	post, err := h.q.CreatePost(r.Context(), database.CreatePostParams{
-		TopicID:   topicID,
-		CreatedBy: userID,
-		Title:     req.Title,
-		Body:      req.Body,
+	attachmentIDs, ok := checkAttachments(w, r, h.q, userID, req.AttachmentIDs)
+	if !ok {
+		return
+	}
+
+	mentioned, err := mentionedUsers(r.Context(), h.q, req.Body)
+	if err != nil {
+		http.Error(w, "Failed to resolve mentions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Write the post, its attachments and the event announcing it together, so followers only hear of complete posts
+	var post database.CreatePostRow
+	err = h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		var err error
+		post, err = tx.CreatePost(r.Context(), database.CreatePostParams{
+			TopicID:   topicID,
+			CreatedBy: userID,
+			Title:     req.Title,
+			Body:      req.Body,
+			BodyHtml:  renderWithMentions(req.Body, mentioned),
+		})
+		if err != nil {
+			return err
+		}
+		if len(attachmentIDs) > 0 {
+			if _, err := tx.LinkAttachmentsToPost(r.Context(), database.LinkAttachmentsToPostParams{
+				PostID:        pgtype.Int8{Int64: post.PostID, Valid: true},
+				AttachmentIds: attachmentIDs,
+				UserID:        userID,
+			}); err != nil {
+				return fmt.Errorf("attach files: %w", err)
+			}
+		}
+		// Authors watch their own posts
+		if err := tx.AutoWatchPost(r.Context(), database.AutoWatchPostParams{UserID: userID, PostID: post.PostID}); err != nil {
+			return fmt.Errorf("watch post: %w", err)
+		}
+		return outbox.Write(r.Context(), tx, outbox.PostCreated, outbox.PostCreatedEvent{
+			PostID:    post.PostID,
+			TopicID:   post.TopicID,
+			Title:     post.Title,
+			Body:      post.Body,
+			CreatedBy: post.CreatedBy,
+			CreatedAt: post.CreatedAt.Time.Format(time.RFC3339),
+		})
 	})
 	if err != nil {
-		http.Error(w, "Failed to create post"+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to create post: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.events.Wake()
+
+	recountPosts(r, h.jobs, topicID)
 
-	// Increment post count
-	if err := h.q.IncrementPostCount(r.Context(), topicID); err != nil {
-		fmt.Printf("Failed to increment post count for topic %d: %v\n", topicID, err)
+	recordMentions(r.Context(), h.q, h.notifier, userID, mentionTarget{postID: post.PostID}, mentioned)
+
+	attachments := []attachmentResponse{}
+	if len(attachmentIDs) > 0 {
+		if attachments, err = h.listAttachments(r, post.PostID); err != nil {
+			http.Error(w, "Failed to list attachments: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// Create Response
 	type Response struct {
-		PostID    int64  `json:"post_id"`
-		TopicID   int64  `json:"topic_id"`
-		Title     string `json:"title"`
-		Body      string `json:"body"`
-		CreatedAt string `json:"created_at"`
-		CreatedBy int64  `json:"created_by"`
-		Status    string `json:"status"`
+		PostID      int64                `json:"post_id"`
+		TopicID     int64                `json:"topic_id"`
+		Title       string               `json:"title"`
+		Body        string               `json:"body"`
+		BodyHTML    string               `json:"body_html"`
+		CreatedAt   string               `json:"created_at"`
+		CreatedBy   int64                `json:"created_by"`
+		Status      string               `json:"status"`
+		Attachments []attachmentResponse `json:"attachments"`
 	}
 	resp := Response{
-		PostID:    post.PostID,
-		TopicID:   post.TopicID,
-		Title:     post.Title,
-		Body:      post.Body,
-		CreatedAt: post.CreatedAt.Time.Format(time.RFC3339),
-		CreatedBy: post.CreatedBy,
-		Status:    post.Status,
+		PostID:      post.PostID,
+		TopicID:     post.TopicID,
+		Title:       post.Title,
+		Body:        post.Body,
+		BodyHTML:    renderedBody(post.Body, post.BodyHtml),
+		CreatedAt:   post.CreatedAt.Time.Format(time.RFC3339),
+		CreatedBy:   post.CreatedBy,
+		Status:      post.Status,
+		Attachments: attachments,
 	}
 
 	// Return Response
@@ -100,11 +170,15 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// SearchPostsGlobal GET /posts (?q=search)
+// SearchPostsGlobal GET /posts (?q=search), leaving out topics a logged in user has muted
 func (h *PostHandler) SearchPostsGlobal(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
+	viewerID, _ := r.Context().Value(auth.UserIDKey).(int64) // 0 when anonymous, who have nothing muted
 
-	posts, err := h.q.SearchPostsGlobal(r.Context(), pgtype.Text{String: query, Valid: true})
+	posts, err := h.q.SearchPostsGlobal(r.Context(), database.SearchPostsGlobalParams{
+		Query:    pgtype.Text{String: query, Valid: true},
+		ViewerID: viewerID,
+	})
 	if err != nil {
 		http.Error(w, "Failed to search posts: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -114,6 +188,7 @@ func (h *PostHandler) SearchPostsGlobal(w http.ResponseWriter, r *http.Request)
 		TopicID   int64  `json:"topic_id"`
 		Title     string `json:"title"`
 		Body      string `json:"body"`
+		BodyHTML  string `json:"body_html"`
 		CreatedAt string `json:"created_at"`
 		CreatedBy int64  `json:"created_by"`
 		Status    string `json:"status"`
@@ -128,6 +203,7 @@ func (h *PostHandler) SearchPostsGlobal(w http.ResponseWriter, r *http.Request)
 			TopicID:   post.TopicID,
 			Title:     post.Title,
 			Body:      post.Body,
+			BodyHTML:  renderedBody(post.Body, post.BodyHtml),
 			CreatedAt: post.CreatedAt.Time.Format(time.RFC3339),
 			CreatedBy: post.CreatedBy,
 			Status:    post.Status,
@@ -144,6 +220,7 @@ func (h *PostHandler) SearchPostsGlobal(w http.ResponseWriter, r *http.Request)
 // SearchPostsTopics GET /topics/{topicID}/posts
 func (h *PostHandler) SearchPostsTopics(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
+	viewerID, _ := r.Context().Value(auth.UserIDKey).(int64) // 0 when anonymous, who have no read positions
 	// Get TopicID
 	topicIDStr := chi.URLParam(r, "topicID") // Simple parsing of topicId from URL given by router
 	topicID, err := strconv.ParseInt(topicIDStr, 10, 64)
@@ -157,10 +234,13 @@ func (h *PostHandler) SearchPostsTopics(w http.ResponseWriter, r *http.Request)
 		TopicID   int64  `json:"topic_id"`
 		Title     string `json:"title"`
 		Body      string `json:"body"`
+		BodyHTML  string `json:"body_html"`
 		CreatedAt string `json:"created_at"`
 		CreatedBy int64  `json:"created_by"`
 		Status    string `json:"status"`
 		Username  string `json:"username"`
+		// Only for logged in users, comments by others after their read position in the post
+		UnreadCommentCount *int64 `json:"unread_comment_count,omitempty"`
 	}
 	response := []Response{}
 
@@ -180,6 +260,7 @@ func (h *PostHandler) SearchPostsTopics(w http.ResponseWriter, r *http.Request)
 				TopicID:   p.TopicID,
 				Title:     p.Title,
 				Body:      p.Body,
+				BodyHTML:  renderedBody(p.Body, p.BodyHtml),
 				CreatedAt: p.CreatedAt.Time.Format(time.RFC3339),
 				CreatedBy: p.CreatedBy,
 				Status:    p.Status,
@@ -199,6 +280,7 @@ func (h *PostHandler) SearchPostsTopics(w http.ResponseWriter, r *http.Request)
 				TopicID:   p.TopicID,
 				Title:     p.Title,
 				Body:      p.Body,
+				BodyHTML:  renderedBody(p.Body, p.BodyHtml),
 				CreatedAt: p.CreatedAt.Time.Format(time.RFC3339),
 				CreatedBy: p.CreatedBy,
 				Status:    p.Status,
@@ -207,6 +289,29 @@ func (h *PostHandler) SearchPostsTopics(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	if viewerID != 0 {
+		postIDs := make([]int64, len(response))
+		for i := range response {
+			postIDs[i] = response[i].PostID
+		}
+		unread, err := unreadCommentCounts(r.Context(), h.q, viewerID, postIDs)
+		if err != nil {
+			http.Error(w, "Failed to count unread comments: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i := range response {
+			count := unread[response[i].PostID]
+			response[i].UnreadCommentCount = &count
+		}
+	}
+
+	// Posts listed now are no longer new, a search only shows some of them so it does not count as a visit
+	if viewerID != 0 && query == "" {
+		if err := h.q.RecordTopicVisit(r.Context(), database.RecordTopicVisitParams{UserID: viewerID, TopicID: topicID}); err != nil {
+			fmt.Printf("Failed to record visit of user %d to topic %d: %v\n", viewerID, topicID, err)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		fmt.Printf("Error encoding JSON: %v\n", err)
@@ -233,25 +338,139 @@ func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type Response struct {
-		PostID    int64  `json:"post_id"`
-		TopicID   int64  `json:"topic_id"`
-		Title     string `json:"title"`
-		Body      string `json:"body"`
-		CreatedAt string `json:"created_at"`
-		CreatedBy int64  `json:"created_by"`
-		Status    string `json:"status"`
-		Username  string `json:"username"`
+		PostID      int64                `json:"post_id"`
+		TopicID     int64                `json:"topic_id"`
+		Title       string               `json:"title"`
+		Body        string               `json:"body"`
+		BodyHTML    string               `json:"body_html"`
+		CreatedAt   string               `json:"created_at"`
+		CreatedBy   int64                `json:"created_by"`
+		Status      string               `json:"status"`
+		Username    string               `json:"username"`
+		Attachments []attachmentResponse `json:"attachments"`
+	}
+
+	// Attachments of removed posts are no longer handed out
+	attachments := []attachmentResponse{}
+	if post.Status == "active" {
+		if attachments, err = h.listAttachments(r, post.PostID); err != nil {
+			http.Error(w, "Failed to list attachments: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := Response{
+		PostID:      post.PostID,
+		TopicID:     post.TopicID,
+		Title:       post.Title,
+		Body:        post.Body,
+		BodyHTML:    renderedBody(post.Body, post.BodyHtml),
+		CreatedAt:   post.CreatedAt.Time.Format(time.RFC3339),
+		CreatedBy:   post.CreatedBy,
+		Status:      post.Status,
+		Username:    post.Username,
+		Attachments: attachments,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// UpdatePost PATCH /posts/{postID}, only the fields sent are changed
+func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Post ID", http.StatusBadRequest)
+		return
+	}
+
+	type Request struct {
+		Title *string `json:"title"`
+		Body  *string `json:"body"`
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if (req.Title == nil && req.Body == nil) || (req.Title != nil && *req.Title == "") || (req.Body != nil && *req.Body == "") {
+		http.Error(w, "Title or body is required and cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	post, err := h.q.GetPost(r.Context(), postID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get post", http.StatusInternalServerError)
+		}
+		return
+	}
+	if post.Status == "removed" {
+		http.Error(w, "Post has been deleted", http.StatusBadRequest)
+		return
+	}
+
+	params := database.UpdatePostParams{PostID: postID, UserID: userID}
+	if req.Title != nil {
+		params.Title = pgtype.Text{String: *req.Title, Valid: true}
+	}
+	var mentioned []database.GetUsersByUsernamesRow
+	if req.Body != nil {
+		mentioned, err = mentionedUsers(r.Context(), h.q, *req.Body)
+		if err != nil {
+			http.Error(w, "Failed to resolve mentions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		params.Body = pgtype.Text{String: *req.Body, Valid: true}
+		params.BodyHtml = renderWithMentions(*req.Body, mentioned)
+	}
+
+	updated, err := h.q.UpdatePost(r.Context(), params)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Post not found or you are not the creator", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Failed to update post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Only newly mentioned users are notified of an edit
+	if req.Body != nil {
+		recordMentions(r.Context(), h.q, h.notifier, userID, mentionTarget{postID: postID}, mentioned)
 	}
 
+	type Response struct {
+		PostID    int64   `json:"post_id"`
+		TopicID   int64   `json:"topic_id"`
+		Title     string  `json:"title"`
+		Body      string  `json:"body"`
+		BodyHTML  string  `json:"body_html"`
+		CreatedAt string  `json:"created_at"`
+		UpdatedAt *string `json:"updated_at"`
+		CreatedBy int64   `json:"created_by"`
+		Status    string  `json:"status"`
+	}
 	resp := Response{
-		PostID:    post.PostID,
-		TopicID:   post.TopicID,
-		Title:     post.Title,
-		Body:      post.Body,
-		CreatedAt: post.CreatedAt.Time.Format(time.RFC3339),
-		CreatedBy: post.CreatedBy,
-		Status:    post.Status,
-		Username:  post.Username,
+		PostID:    updated.PostID,
+		TopicID:   updated.TopicID,
+		Title:     updated.Title,
+		Body:      updated.Body,
+		BodyHTML:  renderedBody(updated.Body, updated.BodyHtml),
+		CreatedAt: updated.CreatedAt.Time.Format(time.RFC3339),
+		UpdatedAt: formatOptionalTime(updated.UpdatedAt),
+		CreatedBy: updated.CreatedBy,
+		Status:    updated.Status,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -295,10 +514,20 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete post (soft delete)
-	_, err = h.q.DeletePost(r.Context(), database.DeletePostParams{
-		PostID:    postID,
-		RemovedBy: pgtype.Int8{Int64: userID, Valid: true},
-		CreatedBy: userID,
+	err = h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		if _, err := tx.DeletePost(r.Context(), database.DeletePostParams{
+			PostID:    postID,
+			RemovedBy: pgtype.Int8{Int64: userID, Valid: true},
+			CreatedBy: userID,
+		}); err != nil {
+			return err
+		}
+		return outbox.Write(r.Context(), tx, outbox.ContentRemoved, outbox.ContentRemovedEvent{
+			Kind:      "post",
+			TopicID:   post.TopicID,
+			PostID:    postID,
+			RemovedBy: userID,
+		})
 	})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -309,11 +538,23 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Decrement post count
-	if err := h.q.DecrementPostCount(r.Context(), post.TopicID); err != nil {
-		fmt.Printf("Failed to decrement post count for topic %d: %v\n", post.TopicID, err)
-	}
+	h.events.Wake()
+
+	recountPosts(r, h.jobs, post.TopicID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Post deleted successfully"})
 }
+
+// listAttachments returns a post's attachments with signed download URLs
+func (h *PostHandler) listAttachments(r *http.Request, postID int64) ([]attachmentResponse, error) {
+	attachments, err := h.q.ListPostAttachments(r.Context(), pgtype.Int8{Int64: postID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	response := []attachmentResponse{}
+	for _, a := range attachments {
+		response = append(response, newAttachmentResponse(h.signer, a))
+	}
+	return response, nil
+}
@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 1000
+	recentActivityLimit  = 10
+)
+
+// Profile visibility settings, stored in users.profile_visibility
+var profileVisibilities = []string{"public", "members", "private"}
+
+type ProfileHandler struct {
+	q *database.Queries
+}
+
+func NewProfileHandler(q *database.Queries) *ProfileHandler {
+	return &ProfileHandler{q: q}
+}
+
+// profileResponse is a user's profile, fields the viewer may not see are left out
+type profileResponse struct {
+	UserID            int64           `json:"user_id"`
+	Username          string          `json:"username"`
+	DisplayName       string          `json:"display_name"`
+	Role              string          `json:"role"`
+	ProfileVisibility string          `json:"profile_visibility"`
+	AvatarURL         string          `json:"avatar_url"`
+	Bio               *string         `json:"bio,omitempty"`
+	CreatedAt         *string         `json:"created_at,omitempty"`
+	ShowActivity      *bool           `json:"show_activity,omitempty"` // Only shown to the owner
+	Email             *string         `json:"email,omitempty"`         // Only shown to the owner
+	EmailVerified     *bool           `json:"email_verified,omitempty"`
+	Stats             *profileStats   `json:"stats,omitempty"`
+	RecentActivity    *[]activityItem `json:"recent_activity,omitempty"`
+}
+
+type profileStats struct {
+	TopicCount   int64 `json:"topic_count"`
+	PostCount    int64 `json:"post_count"`
+	CommentCount int64 `json:"comment_count"`
+}
+
+type activityItem struct {
+	Kind      string `json:"kind"` // topic, post or comment
+	ID        int64  `json:"id"`
+	PostID    *int64 `json:"post_id,omitempty"`
+	Title     string `json:"title"` // Topic name, post title, or the title of the post commented on
+	CreatedAt string `json:"created_at"`
+}
+
+// profileAccess is what the viewer of a request may see of another user
+type profileAccess struct {
+	isOwner        bool
+	fullAccess     bool // The owner and moderators see everything, including removed content
+	canSeeProfile  bool
+	canSeeActivity bool
+}
+
+// accessTo works out the viewer's access to a user from that user's privacy settings
+func (h *ProfileHandler) accessTo(r *http.Request, userID int64, visibility string, showActivity bool) (profileAccess, error) {
+	viewerID, loggedIn := r.Context().Value(auth.UserIDKey).(int64)
+
+	var access profileAccess
+	access.isOwner = loggedIn && viewerID == userID
+	access.fullAccess = access.isOwner
+	if loggedIn && !access.isOwner {
+		viewer, err := h.q.GetUser(r.Context(), viewerID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return access, err
+		}
+		access.fullAccess = viewer.Role == auth.RoleModerator || viewer.Role == auth.RoleAdmin
+	}
+	access.canSeeProfile = access.fullAccess || visibility == "public" || (visibility == "members" && loggedIn)
+	access.canSeeActivity = access.canSeeProfile && (access.fullAccess || showActivity)
+	return access, nil
+}
+
+// GetProfile GET /users/{username}
+func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	profile, err := h.q.GetUserProfile(r.Context(), username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	access, err := h.accessTo(r, profile.UserID, profile.ProfileVisibility, profile.ShowActivity)
+	if err != nil {
+		http.Error(w, "Failed to get user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := profileResponse{
+		UserID:            profile.UserID,
+		Username:          profile.Username,
+		DisplayName:       profile.DisplayName,
+		Role:              profile.Role,
+		ProfileVisibility: profile.ProfileVisibility,
+		AvatarURL:         avatarURL(profile.UserID, profile.AvatarUpdatedAt),
+	}
+	if access.canSeeProfile {
+		createdAt := profile.CreatedAt.Time.Format(time.RFC3339)
+		resp.Bio = &profile.Bio
+		resp.CreatedAt = &createdAt
+	}
+	if access.isOwner {
+		resp.ShowActivity = &profile.ShowActivity
+		if profile.Email.Valid {
+			verified := profile.EmailVerifiedAt.Valid
+			resp.Email = &profile.Email.String
+			resp.EmailVerified = &verified
+		}
+	}
+	if access.canSeeActivity {
+		resp.Stats = &profileStats{
+			TopicCount:   profile.TopicCount,
+			PostCount:    profile.PostCount,
+			CommentCount: profile.CommentCount,
+		}
+
+		activity, err := h.q.ListRecentUserActivity(r.Context(), database.ListRecentUserActivityParams{
+			UserID:    profile.UserID,
+			PageLimit: recentActivityLimit,
+		})
+		if err != nil {
+			http.Error(w, "Failed to get recent activity: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		items := []activityItem{}
+		for _, a := range activity {
+			var postID *int64
+			if a.PostID.Valid {
+				postID = &a.PostID.Int64
+			}
+			items = append(items, activityItem{
+				Kind:      a.Kind,
+				ID:        a.ItemID,
+				PostID:    postID,
+				Title:     a.Title,
+				CreatedAt: a.CreatedAt.Time.Format(time.RFC3339),
+			})
+		}
+		resp.RecentActivity = &items
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// UpdateProfile PATCH /users/me, only the fields sent are changed
+func (h *ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	type Request struct {
+		DisplayName       *string `json:"display_name"`
+		Bio               *string `json:"bio"`
+		ProfileVisibility *string `json:"profile_visibility"`
+		ShowActivity      *bool   `json:"show_activity"`
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	params := database.UpdateUserProfileParams{UserID: userID}
+	if req.DisplayName != nil {
+		displayName := strings.TrimSpace(*req.DisplayName)
+		if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+			http.Error(w, fmt.Sprintf("display_name must be at most %d characters", maxDisplayNameLength), http.StatusBadRequest)
+			return
+		}
+		params.DisplayName = pgtype.Text{String: displayName, Valid: true}
+	}
+	if req.Bio != nil {
+		if utf8.RuneCountInString(*req.Bio) > maxBioLength {
+			http.Error(w, fmt.Sprintf("bio must be at most %d characters", maxBioLength), http.StatusBadRequest)
+			return
+		}
+		params.Bio = pgtype.Text{String: *req.Bio, Valid: true}
+	}
+	if req.ProfileVisibility != nil {
+		if !slices.Contains(profileVisibilities, *req.ProfileVisibility) {
+			http.Error(w, "profile_visibility must be public, members or private", http.StatusBadRequest)
+			return
+		}
+		params.ProfileVisibility = pgtype.Text{String: *req.ProfileVisibility, Valid: true}
+	}
+	if req.ShowActivity != nil {
+		params.ShowActivity = pgtype.Bool{Bool: *req.ShowActivity, Valid: true}
+	}
+
+	user, err := h.q.UpdateUserProfile(r.Context(), params)
+	if err != nil {
+		http.Error(w, "Failed to update profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	createdAt := user.CreatedAt.Time.Format(time.RFC3339)
+	resp := profileResponse{
+		UserID:            user.UserID,
+		Username:          user.Username,
+		DisplayName:       user.DisplayName,
+		Role:              user.Role,
+		ProfileVisibility: user.ProfileVisibility,
+		AvatarURL:         avatarURL(user.UserID, user.AvatarUpdatedAt),
+		Bio:               &user.Bio,
+		CreatedAt:         &createdAt,
+		ShowActivity:      &user.ShowActivity,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+)
+
+// MarkTopicRead POST /topics/{topicID}/read, marking every post and comment in the topic as read.
+// Only the user's visit to the topic is updated, so this is as quick for large topics as for small ones.
+func (h *TopicHandler) MarkTopicRead(w http.ResponseWriter, r *http.Request) {
+	userID, topicID, ok := h.topicForUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.q.MarkTopicRead(r.Context(), database.MarkTopicReadParams{UserID: userID, TopicID: topicID}); err != nil {
+		http.Error(w, "Failed to mark topic read: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkPostRead PUT /posts/{postID}/read, with an optional {"last_read_comment_id": 1} defaulting to the latest comment.
+// The read position only moves forward.
+func (h *PostHandler) MarkPostRead(w http.ResponseWriter, r *http.Request) {
+	userID, postID, ok := h.postForUser(w, r)
+	if !ok {
+		return
+	}
+
+	type Request struct {
+		LastReadCommentID *int64 `json:"last_read_comment_id"`
+	}
+	var req Request
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var lastRead int64
+	if req.LastReadCommentID != nil {
+		lastRead = *req.LastReadCommentID
+	} else {
+		latest, err := h.q.GetLatestCommentID(r.Context(), postID)
+		if err != nil {
+			http.Error(w, "Failed to get latest comment: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lastRead = latest
+	}
+
+	if err := h.q.MarkPostRead(r.Context(), database.MarkPostReadParams{
+		UserID:            userID,
+		PostID:            postID,
+		LastReadCommentID: lastRead,
+	}); err != nil {
+		http.Error(w, "Failed to mark post read: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unreadCommentCounts maps each of postIDs with comments userID has not read to how many there are.
+// Only the comments after each read position are scanned, so the cost follows the listed posts and what is unread
+// rather than the size of the topic. The user's own comments are never unread.
+func unreadCommentCounts(ctx context.Context, q *database.Queries, userID int64, postIDs []int64) (map[int64]int64, error) {
+	rows, err := q.ListUnreadCommentCounts(ctx, database.ListUnreadCommentCountsParams{UserID: userID, PostIds: postIDs})
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		counts[row.PostID] = row.UnreadCount
+	}
+	return counts, nil
+}
+
+// topicsWithNewPosts is the set of topicIDs with posts by others since userID last visited them
+func topicsWithNewPosts(ctx context.Context, q *database.Queries, userID int64, topicIDs []int64) (map[int64]bool, error) {
+	ids, err := q.ListTopicsWithNewPosts(ctx, database.ListTopicsWithNewPostsParams{UserID: userID, TopicIds: topicIDs})
+	if err != nil {
+		return nil, err
+	}
+	hasNew := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		hasNew[id] = true
+	}
+	return hasNew, nil
+}
@@ -11,17 +11,19 @@ import (
 
 	"github.com/DamienFooxx/CVWOForum/internal/auth"
 	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/outbox"
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type TopicHandler struct {
-	q *database.Queries
+	q      *database.Queries
+	events *outbox.Dispatcher
 }
 
-func NewTopicHandler(q *database.Queries) *TopicHandler {
-	return &TopicHandler{q: q}
+func NewTopicHandler(q *database.Queries, events *outbox.Dispatcher) *TopicHandler {
+	return &TopicHandler{q: q, events: events}
 }
 
 // CreateTopic POST /topics
@@ -51,11 +53,25 @@ func (h *TopicHandler) CreateTopic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Write to database
-	topic, err := h.q.CreateTopic(r.Context(), database.CreateTopicParams{
-		CreatedBy:   userID,
-		Name:        req.Name,
-		Description: req.Description,
+	// Write to database, together with the event announcing it
+	var topic database.CreateTopicRow
+	err := h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		var err error
+		topic, err = tx.CreateTopic(r.Context(), database.CreateTopicParams{
+			CreatedBy:   userID,
+			Name:        req.Name,
+			Description: req.Description,
+		})
+		if err != nil {
+			return err
+		}
+		return outbox.Write(r.Context(), tx, outbox.TopicCreated, outbox.TopicCreatedEvent{
+			TopicID:     topic.TopicID,
+			Name:        topic.Name,
+			Description: topic.Description,
+			CreatedBy:   topic.CreatedBy,
+			CreatedAt:   topic.CreatedAt.Time.Format(time.RFC3339),
+		})
 	})
 	if err != nil {
 		if strings.Contains(err.Error(), "unique constraint") || strings.Contains(err.Error(), "duplicate key") {
@@ -66,6 +82,8 @@ func (h *TopicHandler) CreateTopic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.events.Wake()
+
 	// Response
 	type Response struct {
 		TopicID     int64  `json:"topic_id"`
@@ -95,9 +113,10 @@ func (h *TopicHandler) CreateTopic(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// SearchTopics GET /topics (handles ?q=search Fuzzy Search)
+// SearchTopics GET /topics (handles ?q=search Fuzzy Search), leaving out topics a logged in user has muted
 func (h *TopicHandler) SearchTopics(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
+	viewerID, _ := r.Context().Value(auth.UserIDKey).(int64) // 0 when anonymous, who have nothing muted
 
 	type Response struct {
 		TopicID     int64  `json:"topic_id"`
@@ -107,12 +126,16 @@ func (h *TopicHandler) SearchTopics(w http.ResponseWriter, r *http.Request) {
 		Status      string `json:"status"`
 		PostCount   int64  `json:"post_count"`
 		CreatedBy   int64  `json:"created_by"`
+		HasNewPosts *bool  `json:"has_new_posts,omitempty"` // Only for logged in users, since their last visit
 	}
 
 	response := []Response{}
 	if query != "" {
 		// Fuzzy Search for topics
-		topics, err := h.q.SearchTopics(r.Context(), pgtype.Text{String: query, Valid: true})
+		topics, err := h.q.SearchTopics(r.Context(), database.SearchTopicsParams{
+			Query:    pgtype.Text{String: query, Valid: true},
+			ViewerID: viewerID,
+		})
 		if err != nil {
 			http.Error(w, "Failed to search topics: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -130,7 +153,7 @@ func (h *TopicHandler) SearchTopics(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		// List All
-		topics, err := h.q.ListTopics(r.Context())
+		topics, err := h.q.ListTopics(r.Context(), viewerID)
 		if err != nil {
 			http.Error(w, "Failed to list topics: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -148,6 +171,22 @@ func (h *TopicHandler) SearchTopics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if viewerID != 0 && len(response) > 0 {
+		topicIDs := make([]int64, len(response))
+		for i, topic := range response {
+			topicIDs[i] = topic.TopicID
+		}
+		hasNew, err := topicsWithNewPosts(r.Context(), h.q, viewerID, topicIDs)
+		if err != nil {
+			http.Error(w, "Failed to check for new posts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i := range response {
+			hasNewPosts := hasNew[response[i].TopicID]
+			response[i].HasNewPosts = &hasNewPosts
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		fmt.Printf("Error encoding JSON: %v\n", err)
@@ -221,10 +260,19 @@ func (h *TopicHandler) DeleteTopic(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete topic (soft delete)
-	_, err = h.q.DeleteTopic(r.Context(), database.DeleteTopicParams{
-		TopicID:   topicID,
-		RemovedBy: pgtype.Int8{Int64: userID, Valid: true},
-		CreatedBy: userID,
+	err = h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		if _, err := tx.DeleteTopic(r.Context(), database.DeleteTopicParams{
+			TopicID:   topicID,
+			RemovedBy: pgtype.Int8{Int64: userID, Valid: true},
+			CreatedBy: userID,
+		}); err != nil {
+			return err
+		}
+		return outbox.Write(r.Context(), tx, outbox.ContentRemoved, outbox.ContentRemovedEvent{
+			Kind:      "topic",
+			TopicID:   topicID,
+			RemovedBy: userID,
+		})
 	})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -235,6 +283,8 @@ func (h *TopicHandler) DeleteTopic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.events.Wake()
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Topic deleted successfully"})
 }
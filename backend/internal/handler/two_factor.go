@@ -0,0 +1,379 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/jackc/pgx/v5"
+)
+
+/**
+TOTP enrollment under /users/me/2fa
+setup creates a pending secret, enable confirms it with a code and returns one-time recovery codes
+Logging in with 2FA enabled is handled by UserHandler.Login and UserHandler.CompleteLogin
+*/
+
+var (
+	errTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	errInvalidSecondFactor = errors.New("invalid code")
+)
+
+type TwoFactorHandler struct {
+	q      *database.Queries
+	issuer string // Name shown in authenticator apps
+}
+
+func NewTwoFactorHandler(q *database.Queries, issuer string) *TwoFactorHandler {
+	return &TwoFactorHandler{q: q, issuer: issuer}
+}
+
+// secondFactorRequest is the body of every request that needs a TOTP or recovery code
+type secondFactorRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// twoFactorEnabled reports whether the user has confirmed TOTP enrollment
+func twoFactorEnabled(ctx context.Context, q *database.Queries, userID int64) (bool, error) {
+	totp, err := q.FetchUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return totp.EnabledAt.Valid, nil
+}
+
+// verifySecondFactor checks a TOTP code, or failing that a recovery code, and uses it up
+func verifySecondFactor(ctx context.Context, q *database.Queries, userID int64, req secondFactorRequest) (bool, error) {
+	if req.Code != "" {
+		totp, err := q.FetchUserTOTP(ctx, userID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		step, ok := auth.ValidateTOTP(totp.Secret, req.Code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		// Only the first use of a code counts, so an observed code cannot be replayed
+		rows, err := q.UseTOTPStep(ctx, database.UseTOTPStepParams{UserID: userID, LastUsedStep: step})
+		if err != nil {
+			return false, err
+		}
+		return rows == 1, nil
+	}
+
+	if req.RecoveryCode != "" {
+		rows, err := q.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(req.RecoveryCode),
+		})
+		if err != nil {
+			return false, err
+		}
+		return rows == 1, nil
+	}
+
+	return false, nil
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and returns a fresh set.
+// Run it in a transaction so the old codes are kept if any new one fails to save.
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, userID int64) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		err := q.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// GetStatus GET /users/me/2fa
+func (h *TwoFactorHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	enabled, err := twoFactorEnabled(r.Context(), h.q, userID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	remaining, err := h.q.CountUnusedRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Enabled                bool  `json:"enabled"`
+		RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Response{Enabled: enabled, RecoveryCodesRemaining: remaining}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// Setup POST /users/me/2fa/setup
+func (h *TwoFactorHandler) Setup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.q.GetUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	enabled, err := twoFactorEnabled(r.Context(), h.q, userID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if enabled {
+		http.Error(w, "Two-factor authentication is already enabled, disable it first", http.StatusConflict)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+	// Replaces any earlier setup that was never confirmed
+	err = h.q.UpsertPendingUserTOTP(r.Context(), database.UpsertPendingUserTOTPParams{UserID: userID, Secret: secret})
+	if err != nil {
+		http.Error(w, "Failed to start setup: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"provisioning_uri"` // Render as a QR code for authenticator apps
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Response{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(h.issuer, user.Username, secret),
+	}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// Enable POST /users/me/2fa/enable
+func (h *TwoFactorHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req secondFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	// The pending secret is read in the transaction, which runs on the primary, so it is the one Setup just wrote.
+	// 2FA is only on once the recovery codes are saved with it.
+	var codes []string
+	err := h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		totp, err := tx.FetchUserTOTP(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		if totp.EnabledAt.Valid {
+			return errTwoFactorEnabled
+		}
+
+		verified, err := verifySecondFactor(r.Context(), tx, userID, secondFactorRequest{Code: req.Code})
+		if err != nil {
+			return err
+		}
+		if !verified {
+			return errInvalidSecondFactor
+		}
+
+		if err := tx.EnableUserTOTP(r.Context(), userID); err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(r.Context(), tx, userID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Start two-factor setup first", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, errTwoFactorEnabled) {
+			http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errInvalidSecondFactor) {
+			http.Error(w, "Invalid code", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Failed to enable two-factor authentication: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeRecoveryCodes(w, codes)
+}
+
+// RegenerateRecoveryCodes POST /users/me/2fa/recovery-codes
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req secondFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.checkSecondFactor(w, r, userID, secondFactorRequest{Code: req.Code}) {
+		return
+	}
+
+	var codes []string
+	err := h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		var err error
+		codes, err = replaceRecoveryCodes(r.Context(), tx, userID)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to create recovery codes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeRecoveryCodes(w, codes)
+}
+
+// Disable DELETE /users/me/2fa
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req secondFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if !h.checkSecondFactor(w, r, userID, req) {
+		return
+	}
+
+	err := h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		if err := tx.DeleteUserTOTP(r.Context(), userID); err != nil {
+			return err
+		}
+		return tx.DeleteRecoveryCodes(r.Context(), userID)
+	})
+	if err != nil {
+		http.Error(w, "Failed to disable two-factor authentication: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// checkSecondFactor writes an error and returns false unless 2FA is enabled and the code is valid
+func (h *TwoFactorHandler) checkSecondFactor(w http.ResponseWriter, r *http.Request, userID int64, req secondFactorRequest) bool {
+	enabled, err := twoFactorEnabled(r.Context(), h.q, userID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !enabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return false
+	}
+
+	verified, err := verifySecondFactor(r.Context(), h.q, userID, req)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !verified {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// writeRecoveryCodes returns newly issued recovery codes, the only time they are shown
+func writeRecoveryCodes(w http.ResponseWriter, codes []string) {
+	type Response struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Response{RecoveryCodes: codes}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// writeMFAChallenge tells the client to finish logging in at POST /login/2fa
+func writeMFAChallenge(w http.ResponseWriter, userID int64, username string) {
+	mfaToken, err := auth.GenerateMFAToken(userID)
+	if err != nil {
+		http.Error(w, "Failed to generate token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"mfa_required": true,
+		"mfa_token":    mfaToken,
+		"username":     username,
+		"user_id":      userID,
+	}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
@@ -4,22 +4,33 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/DamienFooxx/CVWOForum/internal/auth"
 	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/middleware"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 // UserHandler holds the database connection
 type UserHandler struct {
-	q *database.Queries
+	q          *database.Queries
+	throttle   *auth.LoginThrottle
+	trustProxy bool            // Whether client IPs can be read from X-Forwarded-For
+	accounts   *AccountHandler // Sends the verification email when signing up with an address
 }
 
 // NewUserHandler initializes the handler with the required database queries.
-func NewUserHandler(q *database.Queries) *UserHandler {
+func NewUserHandler(q *database.Queries, throttle *auth.LoginThrottle, trustProxy bool, accounts *AccountHandler) *UserHandler {
 	return &UserHandler{
-		q: q,
+		q:          q,
+		throttle:   throttle,
+		trustProxy: trustProxy,
+		accounts:   accounts,
 	}
 }
 
@@ -29,6 +40,8 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	type Request struct {
 		Username string `json:"username"`
 		Bio      string `json:"bio"`
+		Password string `json:"password"` // Optional, accounts without one log in by username only
+		Email    string `json:"email"`    // Optional, a link to verify it is emailed
 	}
 
 	// Parse json request body
@@ -38,16 +51,56 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	email := ""
+	if req.Email != "" {
+		var err error
+		if email, err = parseEmail(req.Email); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	passwordHash := ""
+	if req.Password != "" {
+		hash, err := auth.HashPassword(req.Password)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		passwordHash = hash
+	}
+
 	// Write to database
-	user, err := h.q.CreateUser(r.Context(), database.CreateUserParams{
-		Username: req.Username,
-		Bio:      req.Bio, // postgres defaults to '' if not provided
+	var user database.CreateUserRow
+	err := h.q.InTx(r.Context(), func(tx *database.Queries) error {
+		var err error
+		user, err = tx.CreateUser(r.Context(), database.CreateUserParams{
+			Username:     req.Username,
+			PasswordHash: passwordHash,
+			Bio:          req.Bio, // postgres defaults to '' if not provided
+		})
+		if err != nil || email == "" {
+			return err
+		}
+		_, err = tx.SetUserEmail(r.Context(), database.SetUserEmailParams{Email: email, UserID: user.UserID})
+		return err
 	})
 	if err != nil {
+		if isUniqueViolation(err, "idx_users_email") {
+			http.Error(w, "Email is already in use", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// The account is usable without a verified address, PUT /users/me/email sends a new link
+	if email != "" {
+		if err := h.accounts.mailer.sendVerification(r.Context(), user.UserID, user.Username, email); err != nil {
+			fmt.Printf("Failed to send verification email to user %d: %v\n", user.UserID, err)
+		}
+	}
+
 	// Return HTTP response
 	w.Header().Set("Content-Type", "application/json")
 	// Convert back to JSON
@@ -60,6 +113,7 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	type Request struct {
 		Username string `json:"username"`
+		Password string `json:"password"`
 	}
 
 	var req Request
@@ -73,17 +127,35 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := middleware.ClientIP(r, h.trustProxy)
+
 	// Find user by username
-	user, err := h.q.GetUserByUsername(r.Context(), req.Username)
+	user, err := h.q.FetchUserByUsername(r.Context(), req.Username)
 	var userId int64
+	role := auth.RoleMember
 
 	if err != nil {
 		// If user has not been created before, create them
 		if errors.Is(err, pgx.ErrNoRows) {
+			// Locked out IPs cannot create accounts either
+			if !h.checkLockout(w, r, req.Username, 0, ip) {
+				return
+			}
+
+			passwordHash := ""
+			if req.Password != "" {
+				passwordHash, err = auth.HashPassword(req.Password)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+
 			// Create User
 			newUser, err := h.q.CreateUser(r.Context(), database.CreateUserParams{
-				Username: req.Username,
-				Bio:      "",
+				Username:     req.Username,
+				PasswordHash: passwordHash,
+				Bio:          "",
 			})
 			if err != nil {
 				http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
@@ -95,8 +167,62 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	} else {
+		if !h.checkLockout(w, r, req.Username, user.UserID, ip) {
+			return
+		}
+
+		// A username alone is not a secret, accounts without a password cannot log in here
+		if user.PasswordHash == "" {
+			ssoOnly, err := h.q.UserHasIdentity(r.Context(), user.UserID)
+			if err != nil {
+				http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// Accounts created through single sign-on can only sign in at the IdP
+			if ssoOnly {
+				h.recordAttempt(r, req.Username, user.UserID, ip, "single_sign_on_only")
+				http.Error(w, "This account signs in with single sign-on", http.StatusUnauthorized)
+				return
+			}
+			// Others set one through a password reset to their verified email address
+			h.recordAttempt(r, req.Username, user.UserID, ip, "no_password")
+			http.Error(w, "This account has no password, set one with a password reset", http.StatusUnauthorized)
+			return
+		}
+
+		if !auth.CheckPassword(user.PasswordHash, req.Password) {
+			lockedFor, err := h.throttle.RecordFailure(r.Context(), user.UserID, ip)
+			if err != nil {
+				fmt.Printf("Failed to record login failure for user %d: %v\n", user.UserID, err)
+			}
+			h.recordAttempt(r, req.Username, user.UserID, ip, "invalid_password")
+
+			if lockedFor > 0 {
+				writeLockedOut(w, lockedFor)
+				return
+			}
+			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+			return
+		}
+
+		// The password alone is not enough once 2FA is enabled, finish at POST /login/2fa
+		enabled, err := twoFactorEnabled(r.Context(), h.q, user.UserID)
+		if err != nil {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if enabled {
+			writeMFAChallenge(w, user.UserID, user.Username)
+			return
+		}
+
+		if err := h.throttle.RecordSuccess(r.Context(), user.UserID); err != nil {
+			fmt.Printf("Failed to reset login failures for user %d: %v\n", user.UserID, err)
+		}
 		userId = user.UserID
+		role = user.Role
 	}
+	h.recordAttempt(r, req.Username, userId, ip, "")
 
 	// Generate token
 	token, err := auth.GenerateToken(userId)
@@ -111,7 +237,121 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		"token":    token,
 		"username": req.Username,
 		"user_id":  userId,
+		"role":     role,
 	}); err != nil {
 		fmt.Printf("Error encoding JSON: %v\n", err)
 	}
 }
+
+// CompleteLogin handles POST /login/2fa, exchanging the interim token and a TOTP or recovery code for a session
+func (h *UserHandler) CompleteLogin(w http.ResponseWriter, r *http.Request) {
+	type Request struct {
+		MFAToken string `json:"mfa_token"`
+		secondFactorRequest
+	}
+
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		http.Error(w, "code or recovery_code is required", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := auth.ValidateMFAToken(req.MFAToken)
+	if err != nil {
+		http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	user, err := h.q.GetUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get user: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	ip := middleware.ClientIP(r, h.trustProxy)
+	if !h.checkLockout(w, r, user.Username, user.UserID, ip) {
+		return
+	}
+
+	verified, err := verifySecondFactor(r.Context(), h.q, user.UserID, req.secondFactorRequest)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !verified {
+		// Wrong codes count towards the same lockout as wrong passwords
+		lockedFor, err := h.throttle.RecordFailure(r.Context(), user.UserID, ip)
+		if err != nil {
+			fmt.Printf("Failed to record login failure for user %d: %v\n", user.UserID, err)
+		}
+		h.recordAttempt(r, user.Username, user.UserID, ip, "invalid_second_factor")
+
+		if lockedFor > 0 {
+			writeLockedOut(w, lockedFor)
+			return
+		}
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.throttle.RecordSuccess(r.Context(), user.UserID); err != nil {
+		fmt.Printf("Failed to reset login failures for user %d: %v\n", user.UserID, err)
+	}
+	h.recordAttempt(r, user.Username, user.UserID, ip, "")
+
+	token, err := auth.GenerateVerifiedToken(user.UserID)
+	if err != nil {
+		http.Error(w, "Failed to generate token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":    token,
+		"username": user.Username,
+		"user_id":  user.UserID,
+		"role":     user.Role,
+	}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// checkLockout writes a 429 and returns false if the account or IP is locked out
+func (h *UserHandler) checkLockout(w http.ResponseWriter, r *http.Request, username string, userID int64, ip string) bool {
+	lockedFor, err := h.throttle.LockedFor(r.Context(), userID, ip)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if lockedFor <= 0 {
+		return true
+	}
+
+	h.recordAttempt(r, username, userID, ip, "locked")
+	writeLockedOut(w, lockedFor)
+	return false
+}
+
+// recordAttempt adds the attempt to the login audit trail, an empty failureReason means success
+func (h *UserHandler) recordAttempt(r *http.Request, username string, userID int64, ip string, failureReason string) {
+	err := h.q.CreateLoginAttempt(r.Context(), database.CreateLoginAttemptParams{
+		Username:      username,
+		UserID:        pgtype.Int8{Int64: userID, Valid: userID != 0},
+		IpAddress:     ip,
+		UserAgent:     r.UserAgent(),
+		Success:       failureReason == "",
+		FailureReason: pgtype.Text{String: failureReason, Valid: failureReason != ""},
+	})
+	if err != nil {
+		fmt.Printf("Failed to record login attempt for %s: %v\n", username, err)
+	}
+}
+
+// writeLockedOut responds with 429 and when to retry
+func writeLockedOut(w http.ResponseWriter, lockedFor time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedFor.Seconds()))))
+	http.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+}
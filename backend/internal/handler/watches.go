@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Watch levels, stored in post_watches.level
+const (
+	watchLevelAll    = "all"    // Every new comment on the post
+	watchLevelDirect = "direct" // Only replies to the user
+	watchLevelMuted  = "muted"  // Nothing, not even direct replies. Mentions are still delivered.
+)
+
+// WatchPost PUT /posts/{postID}/watch, with an optional {"level": "all"|"direct"|"muted"} defaulting to all
+func (h *PostHandler) WatchPost(w http.ResponseWriter, r *http.Request) {
+	userID, postID, ok := h.postForUser(w, r)
+	if !ok {
+		return
+	}
+
+	type Request struct {
+		Level string `json:"level"`
+	}
+	var req Request
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Level == "" {
+		req.Level = watchLevelAll
+	}
+	if req.Level != watchLevelAll && req.Level != watchLevelDirect && req.Level != watchLevelMuted {
+		http.Error(w, "Level must be all, direct or muted", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.q.WatchPost(r.Context(), database.WatchPostParams{UserID: userID, PostID: postID, Level: req.Level}); err != nil {
+		http.Error(w, "Failed to watch post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		PostID int64  `json:"post_id"`
+		Level  string `json:"level"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Response{PostID: postID, Level: req.Level}); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// UnwatchPost DELETE /posts/{postID}/watch. Replies to the user are still notified, as for posts they never watched.
+func (h *PostHandler) UnwatchPost(w http.ResponseWriter, r *http.Request) {
+	userID, postID, ok := h.postForUser(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.q.UnwatchPost(r.Context(), database.UnwatchPostParams{UserID: userID, PostID: postID}); err != nil {
+		http.Error(w, "Failed to unwatch post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWatchedPosts GET /users/me/watching?limit=&offset=, the posts the user watches or muted, most recent first
+func (h *PostHandler) ListWatchedPosts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	posts, err := h.q.ListWatchedPosts(r.Context(), database.ListWatchedPostsParams{
+		UserID:     userID,
+		PageLimit:  limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		http.Error(w, "Failed to list watched posts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		PostID    int64  `json:"post_id"`
+		TopicID   int64  `json:"topic_id"`
+		Title     string `json:"title"`
+		CreatedAt string `json:"created_at"`
+		CreatedBy int64  `json:"created_by"`
+		Username  string `json:"username"`
+		Level     string `json:"level"`
+		Since     string `json:"since"`
+	}
+	response := []Response{}
+	for _, p := range posts {
+		response = append(response, Response{
+			PostID:    p.PostID,
+			TopicID:   p.TopicID,
+			Title:     p.Title,
+			CreatedAt: p.CreatedAt.Time.Format(time.RFC3339),
+			CreatedBy: p.CreatedBy,
+			Username:  p.Username,
+			Level:     p.Level,
+			Since:     p.WatchedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// postForUser reads the logged in user and the {postID} they are changing, writing an error if either is missing
+func (h *PostHandler) postForUser(w http.ResponseWriter, r *http.Request) (userID, postID int64, ok bool) {
+	userID, ok = r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid postID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	post, err := h.q.GetPost(r.Context(), postID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return 0, 0, false
+		}
+		http.Error(w, "Failed to get post: "+err.Error(), http.StatusInternalServerError)
+		return 0, 0, false
+	}
+	if post.Status == "removed" {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return 0, 0, false
+	}
+	return userID, postID, true
+}
@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/webhook"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type WebhookHandler struct {
+	q *database.Queries
+}
+
+func NewWebhookHandler(q *database.Queries) *WebhookHandler {
+	return &WebhookHandler{q: q}
+}
+
+// webhookResponse is a webhook subscription, the secret is only included when it is created
+type webhookResponse struct {
+	WebhookID  int64    `json:"webhook_id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	TopicID    *int64   `json:"topic_id"`
+	CreatedAt  string   `json:"created_at"`
+	Secret     string   `json:"secret,omitempty"`
+}
+
+func newWebhookResponse(hook database.Webhook) webhookResponse {
+	var topicID *int64
+	if hook.TopicID.Valid {
+		topicID = &hook.TopicID.Int64
+	}
+	return webhookResponse{
+		WebhookID:  hook.WebhookID,
+		URL:        hook.Url,
+		EventTypes: hook.EventTypes,
+		TopicID:    topicID,
+		CreatedAt:  hook.CreatedAt.Time.Format(time.RFC3339),
+	}
+}
+
+// deliveryResponse is one entry of a webhook's delivery log
+type deliveryResponse struct {
+	DeliveryID     int64           `json:"delivery_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"` // pending, succeeded or failed
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *string         `json:"next_attempt_at"` // Only while pending
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	DeliveredAt    *string         `json:"delivered_at"`
+	CreatedAt      string          `json:"created_at"`
+}
+
+func newDeliveryResponse(delivery database.WebhookDelivery) deliveryResponse {
+	resp := deliveryResponse{
+		DeliveryID:  delivery.DeliveryID,
+		EventType:   delivery.EventType,
+		Payload:     delivery.Payload,
+		Status:      delivery.Status,
+		Attempts:    delivery.Attempts,
+		DeliveredAt: formatOptionalTime(delivery.DeliveredAt),
+		CreatedAt:   delivery.CreatedAt.Time.Format(time.RFC3339),
+	}
+	if delivery.Status == "pending" {
+		resp.NextAttemptAt = formatOptionalTime(delivery.NextAttemptAt)
+	}
+	if delivery.LastStatusCode.Valid {
+		resp.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.LastError.Valid {
+		resp.LastError = &delivery.LastError.String
+	}
+	return resp
+}
+
+// CreateWebhook POST /admin/webhooks
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	type Request struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+		TopicID    *int64   `json:"topic_id"` // Optional, only events in this topic
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	parsed, err := url.Parse(req.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		http.Error(w, "url must be an absolute http or https URL", http.StatusBadRequest)
+		return
+	}
+	if len(req.EventTypes) == 0 {
+		http.Error(w, "event_types is required", http.StatusBadRequest)
+		return
+	}
+	for _, eventType := range req.EventTypes {
+		if !slices.Contains(webhook.EventTypes, eventType) {
+			http.Error(w, "Unknown event type: "+eventType, http.StatusBadRequest)
+			return
+		}
+	}
+	slices.Sort(req.EventTypes)
+	req.EventTypes = slices.Compact(req.EventTypes)
+
+	var topicID pgtype.Int8
+	if req.TopicID != nil {
+		if _, err := h.q.GetTopic(r.Context(), *req.TopicID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "Topic not found", http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Failed to get topic: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		topicID = pgtype.Int8{Int64: *req.TopicID, Valid: true}
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+
+	hook, err := h.q.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		Url:        req.URL,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+		TopicID:    topicID,
+		CreatedBy:  pgtype.Int8{Int64: userID, Valid: true},
+	})
+	if err != nil {
+		http.Error(w, "Failed to create webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The secret is only shown now, it is needed to verify signatures
+	resp := newWebhookResponse(hook)
+	resp.Secret = hook.Secret
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// ListWebhooks GET /admin/webhooks
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.q.ListWebhooks(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list webhooks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := []webhookResponse{}
+	for _, hook := range hooks {
+		response = append(response, newWebhookResponse(hook))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// DeleteWebhook DELETE /admin/webhooks/{webhookID}, its pending deliveries are dropped
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.q.DeleteWebhook(r.Context(), webhookID)
+	if err != nil {
+		http.Error(w, "Failed to delete webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries GET /admin/webhooks/{webhookID}/deliveries?limit=&offset=
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.q.GetWebhook(r.Context(), webhookID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deliveries, err := h.q.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		WebhookID:  webhookID,
+		PageLimit:  limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		http.Error(w, "Failed to list deliveries: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := []deliveryResponse{}
+	for _, delivery := range deliveries {
+		response = append(response, newDeliveryResponse(delivery))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
+
+// Redeliver POST /admin/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver, queues a fresh copy of a delivery
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.q.RedeliverWebhookDelivery(r.Context(), database.RedeliverWebhookDeliveryParams{
+		DeliveryID: deliveryID,
+		WebhookID:  webhookID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Delivery not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to redeliver: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(newDeliveryResponse(delivery)); err != nil {
+		fmt.Printf("Error encoding JSON: %v\n", err)
+	}
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/realtime"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+const (
+	wsReadLimit    = 4096 // Client messages are small JSON commands
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+)
+
+type WebSocketHandler struct {
+	q              *database.Queries
+	hub            *realtime.PresenceHub
+	originPatterns []string
+}
+
+// NewWebSocketHandler accepts connections from the API's own origin and the frontend's
+func NewWebSocketHandler(q *database.Queries, hub *realtime.PresenceHub, frontendURL string) *WebSocketHandler {
+	var originPatterns []string
+	if parsed, err := url.Parse(frontendURL); err == nil && parsed.Host != "" {
+		originPatterns = append(originPatterns, parsed.Host)
+	}
+	return &WebSocketHandler{q: q, hub: hub, originPatterns: originPatterns}
+}
+
+// Connect GET /ws, a WebSocket for who is viewing and typing in post threads.
+// Clients send {"type": "join" | "leave" | "typing", "post_id": 1} and receive presence and typing messages.
+func (h *WebSocketHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.q.GetUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	client, err := h.hub.Connect(realtime.PresenceUser{UserID: user.UserID, Username: user.Username})
+	if err != nil {
+		if errors.Is(err, realtime.ErrTooManyConnections) {
+			http.Error(w, "Too many open connections, close another tab first", http.StatusTooManyRequests)
+			return
+		}
+		http.Error(w, "Failed to connect: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer h.hub.Disconnect(client)
+
+	// Accept writes its own error response
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: h.originPatterns})
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsReadLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	go h.writeLoop(ctx, cancel, conn, client)
+	h.readLoop(ctx, conn, client)
+}
+
+// readLoop handles client messages until the connection closes
+func (h *WebSocketHandler) readLoop(ctx context.Context, conn *websocket.Conn, client *realtime.Client) {
+	for {
+		var message struct {
+			Type   string `json:"type"`
+			PostID int64  `json:"post_id"`
+		}
+		if err := wsjson.Read(ctx, conn, &message); err != nil {
+			return
+		}
+
+		// Every join looks the post up and every change is broadcast to the room, so they are budgeted
+		if (message.Type == "join" || message.Type == "leave") && !h.hub.AllowMembershipChange(client, time.Now()) {
+			h.hub.Error(client, message.PostID, realtime.ErrTooManyChanges.Error())
+			continue
+		}
+
+		switch message.Type {
+		case "join":
+			post, err := h.q.GetPost(ctx, message.PostID)
+			if err != nil || post.Status != "active" {
+				h.hub.Error(client, message.PostID, "Post not found")
+				continue
+			}
+			if err := h.hub.Join(client, message.PostID); err != nil {
+				h.hub.Error(client, message.PostID, err.Error())
+			}
+		case "leave":
+			h.hub.Leave(client, message.PostID)
+		case "typing":
+			h.hub.Typing(client, message.PostID, time.Now())
+		default:
+			h.hub.Error(client, message.PostID, fmt.Sprintf("Unknown message type %q", message.Type))
+		}
+	}
+}
+
+// writeLoop is the only writer on the connection. The hub never blocks on it, a client that
+// cannot keep up is disconnected instead of holding up everyone else in its rooms.
+func (h *WebSocketHandler) writeLoop(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, client *realtime.Client) {
+	defer cancel()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-client.Done():
+			conn.Close(websocket.StatusPolicyViolation, "Too slow reading messages")
+			return
+		case message := <-client.Send():
+			writeCtx, cancelWrite := context.WithTimeout(ctx, wsWriteTimeout)
+			err := conn.Write(writeCtx, websocket.MessageText, message)
+			cancelWrite()
+			if err != nil {
+				return
+			}
+		case <-ping.C:
+			pingCtx, cancelPing := context.WithTimeout(ctx, wsWriteTimeout)
+			err := conn.Ping(pingCtx)
+			cancelPing()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	baseRetryDelay = 10 * time.Second
+	maxRetryDelay  = time.Hour
+	maxErrorLength = 500
+
+	// leaseGrace is how long past its timeout a job stays leased before another worker may take it over
+	leaseGrace = time.Minute
+)
+
+// ErrDuplicate is returned by Enqueue when a job with the same unique key is already waiting to run
+var ErrDuplicate = errors.New("a job with this unique key is already queued")
+
+// Kind names a type of job and the payload it carries, which is stored as JSON
+type Kind[T any] struct {
+	Name string
+}
+
+func NewKind[T any](name string) Kind[T] {
+	return Kind[T]{Name: name}
+}
+
+// handlerFunc runs one job given its raw payload
+type handlerFunc func(ctx context.Context, payload []byte) error
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job is moved to the dead state without being retried
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// Queue runs jobs stored in the jobs table. Jobs are claimed with SKIP LOCKED, so any number
+// of instances can work the same queue, and each only claims the kinds it has handlers for.
+type Queue struct {
+	q   *database.Queries
+	cfg config.JobsConfig
+
+	mu        sync.RWMutex
+	handlers  map[string]handlerFunc
+	schedules []schedule
+}
+
+func NewQueue(q *database.Queries, cfg config.JobsConfig) *Queue {
+	queue := &Queue{q: q, cfg: cfg, handlers: make(map[string]handlerFunc)}
+
+	// The queue cleans up after itself
+	Register(queue, PurgeJobs, func(ctx context.Context, _ struct{}) error {
+		cutoff := pgtype.Timestamptz{Time: time.Now().Add(-cfg.Retention), Valid: true}
+		_, err := q.DeleteSucceededJobs(ctx, cutoff)
+		return err
+	})
+	Schedule(queue, PurgeJobs, time.Hour, struct{}{})
+
+	return queue
+}
+
+// Register sets the handler for a kind of job, replacing any earlier one.
+// Handlers should be idempotent, a job can run more than once if its worker dies mid-run.
+func Register[T any](queue *Queue, kind Kind[T], fn func(ctx context.Context, payload T) error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	queue.handlers[kind.Name] = func(ctx context.Context, payload []byte) error {
+		var decoded T
+		if err := json.Unmarshal(payload, &decoded); err != nil {
+			return Permanent(fmt.Errorf("invalid %s payload: %w", kind.Name, err))
+		}
+		return fn(ctx, decoded)
+	}
+}
+
+// Option customises an enqueued job
+type Option func(*database.EnqueueJobParams)
+
+// UniqueKey drops the job if another with the same key is still waiting to run.
+// A running job does not count, it may have started before the change this job is for.
+func UniqueKey(key string) Option {
+	return func(p *database.EnqueueJobParams) {
+		p.UniqueKey = pgtype.Text{String: key, Valid: true}
+	}
+}
+
+// RunAt delays the job until t
+func RunAt(t time.Time) Option {
+	return func(p *database.EnqueueJobParams) {
+		p.RunAt = pgtype.Timestamptz{Time: t, Valid: true}
+	}
+}
+
+// MaxAttempts overrides how many times the job is tried before it is moved to the dead state
+func MaxAttempts(attempts int32) Option {
+	return func(p *database.EnqueueJobParams) {
+		p.MaxAttempts = max(attempts, 1)
+	}
+}
+
+// Enqueue stores a job to be run by a worker, returning its ID.
+// It fails with ErrDuplicate when a job with the same unique key is already queued.
+func Enqueue[T any](ctx context.Context, queue *Queue, kind Kind[T], payload T, opts ...Option) (int64, error) {
+	return EnqueueTx(ctx, queue, queue.q, kind, payload, opts...)
+}
+
+// EnqueueTx is Enqueue with queries bound to a transaction, so the job is only queued if the transaction commits
+func EnqueueTx[T any](ctx context.Context, queue *Queue, tx *database.Queries, kind Kind[T], payload T, opts ...Option) (int64, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	params := database.EnqueueJobParams{
+		Kind:        kind.Name,
+		Payload:     payloadJSON,
+		MaxAttempts: queue.cfg.MaxAttempts,
+		RunAt:       pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	jobID, err := tx.EnqueueJob(ctx, params)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrDuplicate
+	}
+	return jobID, err
+}
+
+// Run starts the configured number of workers and the scheduler, and returns once ctx is done
+func (queue *Queue) Run(ctx context.Context) {
+	go queue.runSchedules(ctx)
+
+	var wg sync.WaitGroup
+	for range queue.cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queue.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// work runs jobs back to back, waiting a poll interval whenever none are due
+func (queue *Queue) work(ctx context.Context) {
+	for {
+		ran, err := queue.RunNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			fmt.Printf("Failed to run job: %v\n", err)
+		}
+		if ran {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(queue.cfg.PollInterval):
+		}
+	}
+}
+
+// RunNext claims and runs one due job, reporting whether there was one.
+// The job's failure is recorded on it, the error is only for failing to claim or record it.
+func (queue *Queue) RunNext(ctx context.Context) (bool, error) {
+	queue.mu.RLock()
+	kinds := make([]string, 0, len(queue.handlers))
+	for kind := range queue.handlers {
+		kinds = append(kinds, kind)
+	}
+	queue.mu.RUnlock()
+
+	job, err := queue.q.ClaimJob(ctx, database.ClaimJobParams{
+		LeaseUntil: pgtype.Timestamptz{Time: time.Now().Add(queue.cfg.Timeout + leaseGrace), Valid: true},
+		Kinds:      kinds,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// A job whose workers keep dying mid-run is claimed again once its lease expires
+	var jobErr error
+	if job.Attempts > job.MaxAttempts {
+		jobErr = Permanent(errors.New("lease expired on the last attempt"))
+	} else {
+		jobErr = queue.run(ctx, job)
+	}
+	return true, queue.record(ctx, job, jobErr)
+}
+
+// run calls the job's handler within the job timeout, turning a panic into an error
+func (queue *Queue) run(ctx context.Context, job database.ClaimJobRow) (err error) {
+	queue.mu.RLock()
+	handler, ok := queue.handlers[job.Kind]
+	queue.mu.RUnlock()
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for %s jobs", job.Kind))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queue.cfg.Timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job.Payload)
+}
+
+// record stores the outcome of an attempt, scheduling a retry or moving the job to the dead state on failure
+func (queue *Queue) record(ctx context.Context, job database.ClaimJobRow, jobErr error) error {
+	if jobErr == nil {
+		return queue.q.CompleteJob(ctx, database.CompleteJobParams{JobID: job.JobID, Attempts: job.Attempts})
+	}
+
+	message := jobErr.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+	lastError := pgtype.Text{String: message, Valid: true}
+
+	var permanent permanentError
+	if errors.As(jobErr, &permanent) || job.Attempts >= job.MaxAttempts {
+		fmt.Printf("Job %d (%s) failed for good after %d attempts: %s\n", job.JobID, job.Kind, job.Attempts, message)
+		return queue.q.MarkJobDead(ctx, database.MarkJobDeadParams{Error: lastError, JobID: job.JobID, Attempts: job.Attempts})
+	}
+
+	// Gives up its unique key if a newer copy was queued while it ran
+	return queue.q.RetryJob(ctx, database.RetryJobParams{
+		RunAt:    pgtype.Timestamptz{Time: time.Now().Add(retryDelay(job.Attempts)), Valid: true},
+		Error:    lastError,
+		JobID:    job.JobID,
+		Attempts: job.Attempts,
+	})
+}
+
+// retryDelay doubles from baseRetryDelay with each failed attempt, up to maxRetryDelay
+func retryDelay(attempts int32) time.Duration {
+	delay := baseRetryDelay
+	for i := int32(1); i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
@@ -0,0 +1,29 @@
+package jobs
+
+import "time"
+
+// TopicPayload identifies the topic a job works on
+type TopicPayload struct {
+	TopicID int64 `json:"topic_id"`
+}
+
+// DigestPayload is the digest of one user covering activity up to Until
+type DigestPayload struct {
+	UserID int64     `json:"user_id"`
+	Until  time.Time `json:"until"`
+}
+
+// Jobs run by the forum
+var (
+	RecountTopicPosts        = NewKind[TopicPayload]("topic.recount_posts")
+	ReconcilePostCounts      = NewKind[struct{}]("topics.reconcile_post_counts")
+	PurgeOrphanedAttachments = NewKind[struct{}]("attachments.purge_orphans")
+	PruneEvents              = NewKind[struct{}]("events.prune")
+	PurgeOutbox              = NewKind[struct{}]("outbox.purge")
+	PurgeEmailTokens         = NewKind[struct{}]("email_tokens.purge")
+	QueueDigests             = NewKind[struct{}]("digests.queue")
+	SendDigest               = NewKind[DigestPayload]("digests.send")
+	PurgeDigests             = NewKind[struct{}]("digests.purge")
+	SendBookmarkReminders    = NewKind[struct{}]("bookmarks.send_reminders")
+	PurgeJobs                = NewKind[struct{}]("jobs.purge")
+)
@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxScheduleCheck is the longest the scheduler waits between queueing the next runs
+const maxScheduleCheck = time.Minute
+
+// schedule is a job queued again every interval
+type schedule struct {
+	kind    string
+	every   time.Duration
+	payload []byte
+}
+
+// Schedule runs a job every interval, at multiples of it since the Unix epoch, e.g. on the hour for time.Hour.
+// Every instance queues the next run under the same unique key, so each run happens once across all of them.
+// Call it before Run.
+func Schedule[T any](queue *Queue, kind Kind[T], every time.Duration, payload T) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		panic(fmt.Sprintf("jobs: invalid %s payload: %v", kind.Name, err))
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.schedules = append(queue.schedules, schedule{kind: kind.Name, every: every, payload: payloadJSON})
+}
+
+// runSchedules keeps the next run of every schedule queued until ctx is done
+func (queue *Queue) runSchedules(ctx context.Context) {
+	queue.mu.RLock()
+	schedules := queue.schedules
+	queue.mu.RUnlock()
+	if len(schedules) == 0 {
+		return
+	}
+
+	interval := maxScheduleCheck
+	for _, s := range schedules {
+		interval = min(interval, s.every)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	now := time.Now()
+	for {
+		for _, s := range schedules {
+			if err := queue.scheduleNext(ctx, s, now); err != nil && ctx.Err() == nil {
+				fmt.Printf("Failed to schedule %s job: %v\n", s.kind, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+	}
+}
+
+// scheduleNext queues the run after now, unless it or an earlier run is still pending
+func (queue *Queue) scheduleNext(ctx context.Context, s schedule, now time.Time) error {
+	_, err := queue.q.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        s.kind,
+		Payload:     s.payload,
+		UniqueKey:   pgtype.Text{String: "schedule:" + s.kind, Valid: true},
+		MaxAttempts: queue.cfg.MaxAttempts,
+		RunAt:       pgtype.Timestamptz{Time: now.Truncate(s.every).Add(s.every), Valid: true},
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes each email to an .eml file for development, open them with any mail client
+type FileMailer struct {
+	from string
+	dir  string
+}
+
+func NewFileMailer(from, dir string) *FileMailer {
+	return &FileMailer{from: from, dir: dir}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	_, _, body, err := encode(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	// Sorted by the time they were sent
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), randomID())
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0o644)
+}
+
+// LogMailer prints each email's text body instead of sending it, the default so development needs no setup
+type LogMailer struct {
+	from string
+}
+
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{from: from}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if _, _, _, err := encode(m.from, msg, time.Now()); err != nil {
+		return err
+	}
+	fmt.Printf("Email to %s: %s\n%s\n", msg.To, msg.Subject, msg.Text)
+	return nil
+}
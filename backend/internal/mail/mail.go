@@ -0,0 +1,145 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/jobs"
+)
+
+// ErrInvalidMessage is returned by Send for a message that no retry can deliver, e.g. a malformed address
+var ErrInvalidMessage = errors.New("invalid email message")
+
+// SendEmail is the background job that hands a rendered message to the mailer
+var SendEmail = jobs.NewKind[Message]("mail.send")
+
+// Message is a rendered email with a plain text body and an optional HTML alternative
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+
+	// ListUnsubscribe is a URL that opts the recipient out of this kind of email when POSTed to,
+	// sent as the List-Unsubscribe header with List-Unsubscribe-Post for one-click unsubscribe (RFC 8058)
+	ListUnsubscribe string `json:"list_unsubscribe,omitempty"`
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer selected by MAIL_DRIVER
+func New(cfg config.MailConfig) Mailer {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg.From, cfg.SMTP)
+	case "file":
+		return NewFileMailer(cfg.From, cfg.Dir)
+	default:
+		return NewLogMailer(cfg.From)
+	}
+}
+
+// Enqueue renders tmpl for one recipient and queues it to be sent by a background job,
+// so requests do not wait on the mail server and failed sends are retried.
+func Enqueue[T any](ctx context.Context, queue *jobs.Queue, tmpl Template[T], to string, data T) error {
+	msg, err := tmpl.Render(to, data)
+	if err != nil {
+		return err
+	}
+	_, err = jobs.Enqueue(ctx, queue, SendEmail, msg)
+	return err
+}
+
+// IsPermanent reports whether a send failed in a way retrying cannot fix,
+// an invalid message or a 5xx reply from the mail server
+func IsPermanent(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 500
+	}
+	return errors.Is(err, ErrInvalidMessage)
+}
+
+// encode builds the MIME message sent for msg, checking its addresses and headers
+func encode(from string, msg Message, now time.Time) (fromAddr, toAddr string, body []byte, err error) {
+	sender, err := netmail.ParseAddress(from)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%w: sender %q: %v", ErrInvalidMessage, from, err)
+	}
+	recipient, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%w: recipient %q: %v", ErrInvalidMessage, msg.To, err)
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return "", "", nil, fmt.Errorf("%w: subject contains a line break", ErrInvalidMessage)
+	}
+	if strings.ContainsAny(msg.ListUnsubscribe, "\r\n<> ") {
+		return "", "", nil, fmt.Errorf("%w: invalid unsubscribe URL", ErrInvalidMessage)
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	domain := sender.Address[strings.LastIndex(sender.Address, "@")+1:]
+	headers := []string{
+		"From: " + sender.String(),
+		"To: " + recipient.String(),
+		"Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date: " + now.Format(time.RFC1123Z),
+		"Message-ID: <" + randomID() + "@" + domain + ">",
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + writer.Boundary(),
+	}
+	if msg.ListUnsubscribe != "" {
+		headers = append(headers, "List-Unsubscribe: <"+msg.ListUnsubscribe+">", "List-Unsubscribe-Post: List-Unsubscribe=One-Click")
+	}
+	header := strings.Join(headers, "\r\n") + "\r\n\r\n"
+
+	parts := []struct{ contentType, content string }{{"text/plain", msg.Text}}
+	if msg.HTML != "" {
+		parts = append(parts, struct{ contentType, content string }{"text/html", msg.HTML})
+	}
+	for _, part := range parts {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return "", "", nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return "", "", nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return "", "", nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", "", nil, err
+	}
+
+	return sender.Address, recipient.Address, append([]byte(header), buf.Bytes()...), nil
+}
+
+// randomID returns a random hex string for Message-IDs and file names
+func randomID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
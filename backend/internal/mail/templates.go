@@ -0,0 +1,107 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// Emails sent by the forum
+var (
+	VerifyEmail   = NewTemplate[VerifyEmailData]("verify_email")
+	PasswordReset = NewTemplate[PasswordResetData]("password_reset")
+	Reply         = NewTemplate[ReplyData]("reply")
+	Digest        = NewTemplate[DigestData]("digest")
+)
+
+type VerifyEmailData struct {
+	Username  string
+	VerifyURL string
+	ExpiresIn string // e.g. "24 hours"
+}
+
+type PasswordResetData struct {
+	Username  string
+	ResetURL  string
+	ExpiresIn string
+}
+
+// ReplyData is a reply to one of the recipient's posts or comments
+type ReplyData struct {
+	Username    string
+	ReplierName string
+	PostTitle   string
+	Excerpt     string
+	ReplyURL    string
+	SettingsURL string // Where the recipient turns these emails off
+}
+
+// DigestData is the activity a member missed since their last digest
+type DigestData struct {
+	Username       string
+	Period         string // daily or weekly
+	Posts          []DigestPost
+	MorePosts      int // Posts past the digest's limit, mentioned as "and N more"
+	Replies        []DigestReply
+	MoreReplies    int
+	UnsubscribeURL string
+}
+
+// DigestPost is a new post in a followed topic
+type DigestPost struct {
+	Title     string
+	TopicName string
+	Author    string
+	URL       string
+}
+
+// DigestReply is a new comment on the recipient's content
+type DigestReply struct {
+	PostTitle   string
+	ReplierName string
+	Excerpt     string
+	URL         string
+}
+
+// Template renders one kind of email from templates/<name>.txt, which defines the subject and the
+// text body, and templates/<name>.html, the HTML body wrapped in templates/layout.html
+type Template[T any] struct {
+	Name string
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// NewTemplate parses the template's files, panicking if they are missing or invalid
+func NewTemplate[T any](name string) Template[T] {
+	return Template[T]{
+		Name: name,
+		text: texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/"+name+".txt")),
+		html: htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/layout.html", "templates/"+name+".html")),
+	}
+}
+
+// Render builds the email for one recipient
+func (t Template[T]) Render(to string, data T) (Message, error) {
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.text.ExecuteTemplate(&text, t.Name+".txt", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.Join(strings.Fields(subject.String()), " "), // Headers cannot span lines
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,66 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"slices"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+/**
+Markdown rendering
+Post and comment bodies are written in CommonMark with the GitHub extensions (tables, strikethrough,
+autolinks and task lists). They are rendered to HTML and then sanitized against an allowlist,
+so clients can insert body_html into the page as is.
+*/
+
+var (
+	// Raw HTML in the source is left out by goldmark, the policy below is the actual safety net
+	md = goldmark.New(goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+		&mentionExtension{},
+	))
+	policy = newPolicy()
+)
+
+// newPolicy allows the elements Markdown produces, scripts, styles, event handlers and unsafe URLs are stripped
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	// Language of fenced code blocks, for client side syntax highlighting
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	// Links to the profiles of mentioned users
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^mention$`)).OnElements("a")
+	// Task list checkboxes
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$`)).OnElements("input")
+	return p
+}
+
+// Render converts a Markdown body into sanitized HTML. Mentions of the users in mentioned
+// link to their profiles, other @names stay plain text.
+func Render(source string, mentioned ...string) string {
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+	if len(mentioned) > 0 {
+		walkMentions(doc, func(m *Mention) {
+			m.Linked = slices.Contains(mentioned, m.Username)
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, src, doc); err != nil {
+		// Only possible if writing to the buffer fails, fall back to the escaped text
+		return "<p>" + html.EscapeString(source) + "</p>"
+	}
+	return policy.Sanitize(buf.String())
+}
@@ -0,0 +1,128 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// maxUsernameLength bounds how far a mention is scanned
+const maxUsernameLength = 64
+
+// KindMention is the AST kind of an @username mention
+var KindMention = ast.NewNodeKind("Mention")
+
+// Mention is an @username in the text of a body. Code spans and blocks are never
+// parsed for inlines, so mentions inside code are left as plain text.
+type Mention struct {
+	ast.BaseInline
+	Username string
+	Linked   bool // Set for mentions of existing users, which render as a link to their profile
+}
+
+func (n *Mention) Kind() ast.NodeKind {
+	return KindMention
+}
+
+func (n *Mention) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Username": n.Username}, nil)
+}
+
+// isUsernameChar reports whether c may appear in a mentioned username
+func isUsernameChar(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c == '_' || c == '.' || c == '-'
+}
+
+type mentionParser struct{}
+
+func (p *mentionParser) Trigger() []byte {
+	return []byte{'@'}
+}
+
+func (p *mentionParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	// An @ in the middle of a word, such as an email address, is not a mention
+	if before := block.PrecendingCharacter(); before < 128 && isUsernameChar(byte(before)) {
+		return nil
+	}
+
+	line, _ := block.PeekLine()
+	end := 1
+	for end < len(line) && end <= maxUsernameLength && isUsernameChar(line[end]) {
+		end++
+	}
+	// Leave trailing punctuation, as in "thanks @alice."
+	for end > 1 && (line[end-1] == '.' || line[end-1] == '-') {
+		end--
+	}
+	if end == 1 {
+		return nil
+	}
+
+	block.Advance(end)
+	return &Mention{Username: string(line[1:end])}
+}
+
+type mentionRenderer struct{}
+
+func (r *mentionRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMention, r.render)
+}
+
+func (r *mentionRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*Mention)
+	name := html.EscapeString(n.Username)
+	if n.Linked {
+		_, _ = w.WriteString(`<a href="/users/` + url.PathEscape(n.Username) + `" class="mention">@` + name + `</a>`)
+	} else {
+		_, _ = w.WriteString("@" + name)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+type mentionExtension struct{}
+
+func (e *mentionExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&mentionParser{}, 500)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&mentionRenderer{}, 500)))
+}
+
+// walkMentions calls fn for every mention that is not part of a link's text
+func walkMentions(doc ast.Node, fn func(*Mention)) {
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.Link, *ast.AutoLink:
+			return ast.WalkSkipChildren, nil
+		case *Mention:
+			fn(n)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// Mentions returns the distinct usernames @mentioned in a body, in order of appearance.
+// Mentions in code or inside the text of a link do not count.
+func Mentions(source string) []string {
+	doc := md.Parser().Parse(text.NewReader([]byte(source)))
+
+	seen := make(map[string]bool)
+	var usernames []string
+	walkMentions(doc, func(m *Mention) {
+		if !seen[m.Username] {
+			seen[m.Username] = true
+			usernames = append(usernames, m.Username)
+		}
+	})
+	return usernames
+}
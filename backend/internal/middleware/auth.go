@@ -2,43 +2,189 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/jackc/pgx/v5"
 )
 
 /**
 Intercepts the HTTP request
 Checks for Authorisation
-Validates the Token
+Validates the Token, either a JWT from /login or a personal access token
 Extracts user_id
 Puts user_id into the request context for handlers
 */
 
-// AuthMiddleware verifies the JWT Token
-func AuthMiddleware(next http.Handler) http.Handler {
+// AuthMiddleware verifies the JWT Token or personal access token
+func AuthMiddleware(q *database.Queries) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				http.Error(w, "Authorization header required", http.StatusUnauthorized)
+				return
+			}
+
+			ctx, err := authenticate(r.Context(), q, authHeader)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// OptionalAuth identifies the user when an Authorization header is sent and lets anonymous requests through.
+// Handlers check for auth.UserIDKey to tell the two apart.
+func OptionalAuth(q *database.Queries) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// A bad token is still rejected rather than silently treated as anonymous
+			ctx, err := authenticate(r.Context(), q, authHeader)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// TokenFromQuery accepts the token as ?token= for clients that cannot set headers, such as browser WebSockets.
+// Must run before AuthMiddleware, an Authorization header takes precedence.
+func TokenFromQuery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		token := r.URL.Query().Get("token")
+		if token == "" || r.Header.Get("Authorization") != "" {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Header format: "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid Authorization header format", http.StatusUnauthorized)
-			return
-		}
-		// Extract token
-		tokenString := parts[1]
-		userID, err := auth.ValidateToken(tokenString)
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer "+token)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate validates the Authorization header and returns ctx with the user's identity
+func authenticate(ctx context.Context, q *database.Queries, authHeader string) (context.Context, error) {
+	// Header format: "Bearer <token>"
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, errors.New("Invalid Authorization header format")
+	}
+	// Extract token
+	tokenString := parts[1]
+
+	if auth.IsPersonalAccessToken(tokenString) {
+		pat, err := validatePersonalAccessToken(ctx, q, tokenString)
 		if err != nil {
-			http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
+			return nil, errors.New("Invalid token: " + err.Error())
+		}
+		ctx = context.WithValue(ctx, auth.UserIDKey, pat.UserID)
+		ctx = context.WithValue(ctx, auth.MFAKey, pat.MfaVerified)
+		return context.WithValue(ctx, auth.ScopesKey, pat.Scopes), nil
+	}
+
+	claims, err := auth.ParseSessionToken(tokenString)
+	if err != nil {
+		return nil, errors.New("Invalid token: " + err.Error())
+	}
+	if err := checkSessionRevoked(ctx, q, claims); err != nil {
+		return nil, errors.New("Invalid token: " + err.Error())
+	}
+	ctx = context.WithValue(ctx, auth.UserIDKey, claims.UserID)
+	return context.WithValue(ctx, auth.MFAKey, claims.MFA), nil
+}
+
+// checkSessionRevoked rejects sessions issued before the user's sessions were revoked, e.g. by a password reset.
+// The Fetch query reads from the primary, a lagging replica would let revoked sessions through.
+func checkSessionRevoked(ctx context.Context, q *database.Queries, claims *auth.Claims) error {
+	revokedAt, err := q.FetchUserSessionsRevokedAt(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errors.New("unknown user")
+		}
+		return err
+	}
+	// Tokens only record the second they were issued, so one issued in the same second as the revocation is kept
+	if revokedAt.Valid && claims.IssuedAt != nil && claims.IssuedAt.Time.Before(revokedAt.Time.Truncate(time.Second)) {
+		return errors.New("session has been revoked")
+	}
+	return nil
+}
+
+// validatePersonalAccessToken looks up the token on the primary and records that it was used
+func validatePersonalAccessToken(ctx context.Context, q *database.Queries, token string) (database.FetchPersonalAccessTokenByHashRow, error) {
+	pat, err := q.FetchPersonalAccessTokenByHash(ctx, auth.HashPersonalAccessToken(token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pat, errors.New("unknown token")
+		}
+		return pat, err
+	}
+	if pat.RevokedAt.Valid {
+		return pat, errors.New("token has been revoked")
+	}
+	if pat.ExpiresAt.Valid && pat.ExpiresAt.Time.Before(time.Now()) {
+		return pat, errors.New("token has expired")
+	}
+
+	if err := q.TouchPersonalAccessToken(ctx, pat.TokenID); err != nil {
+		fmt.Printf("Failed to update last used time of token %d: %v\n", pat.TokenID, err)
+	}
+
+	return pat, nil
+}
+
+// RequireScope rejects personal access tokens without scope, JWT sessions always pass
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.HasScope(r.Context(), scope) {
+				http.Error(w, "Token is missing the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAnyScope is RequireScope for routes that serve several scopes, any one of them is enough
+func RequireAnyScope(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, scope := range scopes {
+				if auth.HasScope(r.Context(), scope) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "Token needs one of the "+strings.Join(scopes, ", ")+" scopes", http.StatusForbidden)
+		})
+	}
+}
+
+// RequireSession only allows JWT sessions from /login, so tokens cannot be used to mint more tokens
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.IsSession(r.Context()) {
+			http.Error(w, "This action requires logging in, personal access tokens are not accepted", http.StatusForbidden)
 			return
 		}
-		ctx := context.WithValue(r.Context(), auth.UserIDKey, userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+/**
+Token bucket rate limiting
+Each policy allows Requests per Period, refilled continuously, with bursts of up to Requests
+Buckets are keyed by user ID when the request is authenticated, otherwise by client IP
+*/
+
+// RateLimitPolicy is a token bucket shared by a group of routes
+type RateLimitPolicy struct {
+	Name     string // Namespaces the buckets, e.g. "auth", "writes", "reads"
+	Requests int
+	Period   time.Duration
+}
+
+// refillPerSecond is how many tokens the bucket regains each second
+func (p RateLimitPolicy) refillPerSecond() float64 {
+	return float64(p.Requests) / p.Period.Seconds()
+}
+
+// RateLimitResult is the state of a bucket after taking a token from it
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining float64
+}
+
+// RateLimiter stores the token buckets
+type RateLimiter interface {
+	Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error)
+}
+
+// RateLimit limits requests with the given policy and sets the RateLimit-* headers.
+// trustProxy makes the client IP come from X-Forwarded-For, only enable it behind a reverse proxy.
+func RateLimit(limiter RateLimiter, policy RateLimitPolicy, trustProxy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := policy.Name + ":ip:" + ClientIP(r, trustProxy)
+			if userID, ok := r.Context().Value(auth.UserIDKey).(int64); ok {
+				key = policy.Name + ":user:" + strconv.FormatInt(userID, 10)
+			}
+
+			result, err := limiter.Take(r.Context(), key, policy)
+			if err != nil {
+				// Fail open so a rate limiter outage does not take the forum down with it
+				fmt.Printf("Rate limiter error for %s: %v\n", key, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Seconds until the bucket is full again
+			refill := policy.refillPerSecond()
+			reset := math.Ceil((float64(policy.Requests) - result.Remaining) / refill)
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(policy.Requests))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(math.Floor(result.Remaining))))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(int(reset)))
+
+			if !result.Allowed {
+				// Seconds until a whole token is available
+				retryAfter := math.Max(1, math.Ceil((1-result.Remaining)/refill))
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP returns the IP address of the client making the request
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		// Right most entry is the one our proxy appended, anything to its left came from the client and can be forged
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			last := forwarded[len(forwarded)-1]
+			if i := strings.LastIndex(last, ","); i >= 0 {
+				last = last[i+1:]
+			}
+			if client := strings.TrimSpace(last); client != "" {
+				return client
+			}
+		}
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return realIP
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// MemoryRateLimiter keeps buckets in process memory, suitable for a single server instance
+type MemoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	period    time.Duration
+}
+
+// memorySweepInterval is how often idle buckets are dropped from memory
+const memorySweepInterval = time.Minute
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *MemoryRateLimiter) Take(_ context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	capacity := float64(policy.Requests)
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: capacity, updatedAt: now, period: policy.Period}
+		l.buckets[key] = bucket
+	}
+
+	// Refill for the time since the last request, capped at capacity
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	bucket.tokens = math.Min(capacity, bucket.tokens+elapsed*policy.refillPerSecond())
+	bucket.updatedAt = now
+
+	if bucket.tokens < 1 {
+		return RateLimitResult{Allowed: false, Remaining: bucket.tokens}, nil
+	}
+	bucket.tokens--
+	return RateLimitResult{Allowed: true, Remaining: bucket.tokens}, nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < memorySweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.updatedAt) > bucket.period {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// PostgresRateLimiter keeps buckets in the rate_limit_buckets table so limits are shared across instances
+type PostgresRateLimiter struct {
+	q         *database.Queries
+	maxPeriod time.Duration
+}
+
+// NewPostgresRateLimiter creates the limiter, run Sweep to delete buckets idle for longer than maxPeriod
+func NewPostgresRateLimiter(q *database.Queries, maxPeriod time.Duration) *PostgresRateLimiter {
+	return &PostgresRateLimiter{q: q, maxPeriod: maxPeriod}
+}
+
+func (l *PostgresRateLimiter) Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	row, err := l.q.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		BucketKey:       key,
+		Capacity:        float64(policy.Requests),
+		RefillPerSecond: policy.refillPerSecond(),
+	})
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return RateLimitResult{Allowed: row.Allowed, Remaining: row.Tokens}, nil
+}
+
+// Sweep deletes idle buckets periodically until ctx is done
+func (l *PostgresRateLimiter) Sweep(ctx context.Context) {
+	ticker := time.NewTicker(memorySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// A bucket idle for a full period has refilled, so dropping it changes nothing
+			staleBefore := pgtype.Timestamptz{Time: time.Now().Add(-l.maxPeriod), Valid: true}
+			if _, err := l.q.DeleteStaleRateLimitBuckets(ctx, staleBefore); err != nil {
+				fmt.Printf("Failed to sweep rate limit buckets: %v\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/jackc/pgx/v5"
+)
+
+// RequireRole only lets users with one of the given roles through, must run after AuthMiddleware.
+// The role is looked up on the primary on every request so demotions take effect immediately.
+func RequireRole(q *database.Queries, roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+			if !ok {
+				http.Error(w, "User not authenticated", http.StatusUnauthorized)
+				return
+			}
+
+			role, err := q.FetchUserRole(r.Context(), userID)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					http.Error(w, "User not found", http.StatusUnauthorized)
+					return
+				}
+				http.Error(w, "Failed to get user", http.StatusInternalServerError)
+				return
+			}
+
+			if !slices.Contains(roles, role) {
+				http.Error(w, "Insufficient permissions", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), auth.RoleKey, role)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireTwoFactor enforces two-factor authentication for users whose role is in roles, must run after RequireRole.
+// The account must have TOTP enabled and sessions must have completed it at login.
+// Personal access tokens must have been created from a session that completed it.
+func RequireTwoFactor(q *database.Queries, roles []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value(auth.RoleKey).(string)
+			if !slices.Contains(roles, role) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+			if !ok {
+				http.Error(w, "User not authenticated", http.StatusUnauthorized)
+				return
+			}
+
+			totp, err := q.FetchUserTOTP(r.Context(), userID)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "Failed to get two-factor settings", http.StatusInternalServerError)
+				return
+			}
+			if err != nil || !totp.EnabledAt.Valid {
+				http.Error(w, "Two-factor authentication must be enabled for "+role+" accounts", http.StatusForbidden)
+				return
+			}
+			if !auth.IsMFAVerified(r.Context()) {
+				if auth.IsSession(r.Context()) {
+					http.Error(w, "Log in again with your two-factor code to continue", http.StatusForbidden)
+					return
+				}
+				http.Error(w, "Create a new token after logging in with your two-factor code", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
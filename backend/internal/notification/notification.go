@@ -0,0 +1,126 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/realtime"
+	"github.com/jackc/pgx/v5"
+)
+
+// Notification types, stored in notifications.type
+const (
+	TypeAccountLocked    = "account_locked" // Too many failed logins, see auth.LoginThrottle
+	TypeMention          = "mention"
+	TypePostReply        = "post_reply"        // A comment on one of the user's posts
+	TypeCommentReply     = "comment_reply"     // A reply to one of the user's comments
+	TypeModeration       = "moderation"        // A moderator removed the user's post or comment
+	TypeWatchedPost      = "watched_post"      // A new comment on a post the user watches
+	TypeBookmarkReminder = "bookmark_reminder" // A reminder the user set on a bookmark
+)
+
+// Configurable lists the types users can turn off. Account lockouts are security alerts and always delivered,
+// and bookmark reminders are only sent when the user asked for one.
+var Configurable = []string{TypeMention, TypePostReply, TypeCommentReply, TypeModeration, TypeWatchedPost}
+
+// Notifier creates in-app notifications, skipping types the recipient has turned off.
+// Each notification is also pushed to the recipient's event stream.
+type Notifier struct {
+	q      *database.Queries
+	broker *realtime.Broker
+}
+
+func NewNotifier(q *database.Queries, broker *realtime.Broker) *Notifier {
+	return &Notifier{q: q, broker: broker}
+}
+
+// Notify sends a notification of type typ to userID. Notifications are a side effect of
+// other actions, so failures are logged rather than returned.
+func (n *Notifier) Notify(ctx context.Context, userID int64, typ string, payload map[string]interface{}) {
+	created, err := n.Create(ctx, n.q, userID, typ, payload)
+	if err != nil {
+		fmt.Printf("Failed to send %s notification to user %d: %v\n", typ, userID, err)
+		return
+	}
+	if created != nil {
+		n.Publish(ctx, created)
+	}
+}
+
+// Created is a stored notification waiting to be pushed to its recipient with Publish
+type Created struct {
+	userID         int64
+	notificationID int64
+	typ            string
+	payloadJSON    []byte
+	createdAt      time.Time
+}
+
+// Create stores a notification with q, which may be bound to a transaction, and returns nil if the
+// recipient turned typ off. Publish it once the transaction commits, for actions where a lost
+// notification matters more than a failed one.
+func (n *Notifier) Create(ctx context.Context, q *database.Queries, userID int64, typ string, payload map[string]interface{}) (*Created, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := q.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  userID,
+		Type:    typ,
+		Payload: payloadJSON,
+	})
+	if err != nil {
+		// No row means the user turned this type off
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &Created{userID: userID, notificationID: row.NotificationID, typ: typ, payloadJSON: payloadJSON, createdAt: row.CreatedAt.Time}, nil
+}
+
+// Publish pushes a notification from Create to the recipient's event stream
+func (n *Notifier) Publish(ctx context.Context, c *Created) {
+	n.publish(ctx, c.userID, c.notificationID, c.typ, c.payloadJSON, c.createdAt)
+}
+
+// NotifyPostWatchers sends a notification of type typ to everyone watching postID at the "all" level,
+// except the users in exclude. The notifications are created in one statement, however many watchers there are.
+func (n *Notifier) NotifyPostWatchers(ctx context.Context, postID int64, exclude []int64, typ string, payload map[string]interface{}) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		fmt.Printf("Failed to encode %s notification for watchers of post %d: %v\n", typ, postID, err)
+		return
+	}
+
+	created, err := n.q.CreatePostWatcherNotifications(ctx, database.CreatePostWatcherNotificationsParams{
+		Type:           typ,
+		Payload:        payloadJSON,
+		PostID:         postID,
+		ExcludeUserIds: exclude,
+	})
+	if err != nil {
+		fmt.Printf("Failed to send %s notifications to watchers of post %d: %v\n", typ, postID, err)
+		return
+	}
+	for _, c := range created {
+		n.publish(ctx, c.UserID, c.NotificationID, typ, payloadJSON, c.CreatedAt.Time)
+	}
+}
+
+// publish pushes a stored notification to the recipient's event stream
+func (n *Notifier) publish(ctx context.Context, userID, notificationID int64, typ string, payloadJSON []byte, createdAt time.Time) {
+	if err := n.broker.Publish(ctx, realtime.UserChannel(userID), "notification", map[string]interface{}{
+		"notification_id": notificationID,
+		"type":            typ,
+		"payload":         json.RawMessage(payloadJSON),
+		"created_at":      createdAt.Format(time.RFC3339),
+	}); err != nil {
+		fmt.Printf("Failed to publish notification %d to user %d: %v\n", notificationID, userID, err)
+	}
+}
@@ -0,0 +1,47 @@
+package outbox
+
+// Domain events, named like the webhook event types they are published as
+var (
+	TopicCreated   = NewEventType[TopicCreatedEvent]("topic.created")
+	PostCreated    = NewEventType[PostCreatedEvent]("post.created")
+	CommentCreated = NewEventType[CommentCreatedEvent]("comment.created")
+	ContentRemoved = NewEventType[ContentRemovedEvent]("content.removed")
+)
+
+type TopicCreatedEvent struct {
+	TopicID     int64  `json:"topic_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CreatedBy   int64  `json:"created_by"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type PostCreatedEvent struct {
+	PostID    int64  `json:"post_id"`
+	TopicID   int64  `json:"topic_id"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	CreatedBy int64  `json:"created_by"`
+	CreatedAt string `json:"created_at"`
+}
+
+type CommentCreatedEvent struct {
+	CommentID   int64  `json:"comment_id"`
+	PostID      int64  `json:"post_id"`
+	TopicID     int64  `json:"topic_id"`
+	ParentID    *int64 `json:"parent_id"` // Nil for top level comments
+	Body        string `json:"body"`
+	CommentedBy int64  `json:"commented_by"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ContentRemovedEvent is a topic, post or comment being deleted by its author or removed by a moderator
+type ContentRemovedEvent struct {
+	Kind        string `json:"kind"` // topic, post or comment
+	TopicID     int64  `json:"topic_id"`
+	PostID      int64  `json:"post_id,omitempty"`
+	CommentID   int64  `json:"comment_id,omitempty"`
+	RemovedBy   int64  `json:"removed_by"`
+	ByModerator bool   `json:"by_moderator"`
+	Reason      string `json:"reason,omitempty"`
+}
@@ -0,0 +1,205 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	batchSize      = 50
+	leaseDuration  = 5 * time.Minute // An event whose dispatcher dies is picked up again after this long
+	baseRetryDelay = 5 * time.Second
+	maxRetryDelay  = time.Hour
+	maxErrorLength = 500
+)
+
+// EventType names a domain event and the payload it carries, which is stored as JSON
+type EventType[T any] struct {
+	Name string
+}
+
+func NewEventType[T any](name string) EventType[T] {
+	return EventType[T]{Name: name}
+}
+
+// Write stores an event in the outbox. Pass queries bound to the transaction making the change,
+// so the event is only dispatched if the change is committed.
+func Write[T any](ctx context.Context, tx *database.Queries, eventType EventType[T], event T) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = tx.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{Type: eventType.Name, Payload: payload})
+	return err
+}
+
+// subscriber handles one type of event under a name that is unique for that type
+type subscriber struct {
+	name   string
+	handle func(ctx context.Context, eventID int64, payload []byte) error
+}
+
+// Dispatcher delivers outbox events to the subscribers registered in this process, at least once.
+// Events are claimed with SKIP LOCKED, so with several instances each event is handled by one of them.
+// A subscriber that fails is retried with backoff, without repeating the subscribers that succeeded.
+type Dispatcher struct {
+	q   *database.Queries
+	cfg config.OutboxConfig
+
+	mu          sync.RWMutex
+	subscribers map[string][]subscriber
+	wake        chan struct{}
+}
+
+func NewDispatcher(q *database.Queries, cfg config.OutboxConfig) *Dispatcher {
+	return &Dispatcher{
+		q:           q,
+		cfg:         cfg,
+		subscribers: make(map[string][]subscriber),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Subscribe calls fn for every event of eventType. fn may see an event more than once, so it should
+// be idempotent, the event ID identifies repeats. Call it before Run.
+func Subscribe[T any](d *Dispatcher, name string, eventType EventType[T], fn func(ctx context.Context, eventID int64, event T) error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.subscribers[eventType.Name] = append(d.subscribers[eventType.Name], subscriber{
+		name: name,
+		handle: func(ctx context.Context, eventID int64, payload []byte) error {
+			var event T
+			if err := json.Unmarshal(payload, &event); err != nil {
+				// Retrying cannot fix a payload, so it is skipped
+				fmt.Printf("Skipping undecodable %s event %d for %s: %v\n", eventType.Name, eventID, name, err)
+				return nil
+			}
+			return fn(ctx, eventID, event)
+		},
+	})
+}
+
+// Wake dispatches pending events now instead of at the next poll, call it after committing an event
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run dispatches pending events whenever woken and every poll interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DispatchPending(ctx); err != nil && ctx.Err() == nil {
+			fmt.Printf("Failed to dispatch outbox events: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DispatchPending delivers every event that is due, returning how many were claimed
+func (d *Dispatcher) DispatchPending(ctx context.Context) (int, error) {
+	claimedTotal := 0
+	for {
+		claimed, err := d.q.ClaimOutboxEvents(ctx, database.ClaimOutboxEventsParams{
+			LeaseUntil: pgtype.Timestamptz{Time: time.Now().Add(leaseDuration), Valid: true},
+			BatchSize:  batchSize,
+		})
+		if err != nil {
+			return claimedTotal, err
+		}
+
+		for _, event := range claimed {
+			if err := d.dispatch(ctx, event); err != nil {
+				return claimedTotal, err
+			}
+		}
+		claimedTotal += len(claimed)
+
+		if len(claimed) < batchSize {
+			return claimedTotal, nil
+		}
+	}
+}
+
+// dispatch hands an event to the subscribers that have not handled it yet and records the outcome
+func (d *Dispatcher) dispatch(ctx context.Context, event database.ClaimOutboxEventsRow) error {
+	d.mu.RLock()
+	subscribers := d.subscribers[event.Type]
+	d.mu.RUnlock()
+
+	var failures []string
+	for _, sub := range subscribers {
+		if slices.Contains(event.DeliveredTo, sub.name) {
+			continue
+		}
+		if err := d.deliver(ctx, sub, event); err != nil {
+			failures = append(failures, sub.name+": "+err.Error())
+			continue
+		}
+		if err := d.q.MarkOutboxEventDelivered(ctx, database.MarkOutboxEventDeliveredParams{
+			Subscriber: sub.name,
+			EventID:    event.EventID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(failures) == 0 {
+		return d.q.FinishOutboxEvent(ctx, event.EventID)
+	}
+
+	message := strings.Join(failures, "; ")
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+	fmt.Printf("Outbox event %d (%s) failed on attempt %d: %s\n", event.EventID, event.Type, event.Attempts, message)
+	return d.q.RetryOutboxEvent(ctx, database.RetryOutboxEventParams{
+		NextAttemptAt: pgtype.Timestamptz{Time: time.Now().Add(retryDelay(event.Attempts)), Valid: true},
+		Error:         pgtype.Text{String: message, Valid: true},
+		EventID:       event.EventID,
+	})
+}
+
+// deliver calls one subscriber, turning a panic into an error
+func (d *Dispatcher) deliver(ctx context.Context, sub subscriber, event database.ClaimOutboxEventsRow) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return sub.handle(ctx, event.EventID, event.Payload)
+}
+
+// Purge deletes events dispatched before the retention period, returning how many were removed
+func (d *Dispatcher) Purge(ctx context.Context, now time.Time) (int64, error) {
+	return d.q.DeleteDispatchedOutboxEvents(ctx, pgtype.Timestamptz{Time: now.Add(-d.cfg.Retention), Valid: true})
+}
+
+// retryDelay doubles from baseRetryDelay with each failed attempt, up to maxRetryDelay
+func retryDelay(attempts int32) time.Duration {
+	delay := baseRetryDelay
+	for i := int32(1); i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
@@ -0,0 +1,235 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// listenChannel is the Postgres NOTIFY channel the events table trigger publishes on
+	listenChannel = "forum_events"
+
+	// subscriptionBuffer is how many events a slow client may fall behind before it is disconnected
+	subscriptionBuffer = 64
+
+	maxReconnectDelay = 30 * time.Second
+)
+
+// TopicChannel carries new posts in a topic
+func TopicChannel(topicID int64) string {
+	return fmt.Sprintf("topic:%d", topicID)
+}
+
+// PostChannel carries new comments on a post
+func PostChannel(postID int64) string {
+	return fmt.Sprintf("post:%d", postID)
+}
+
+// UserChannel carries a user's notifications, only the user may subscribe
+func UserChannel(userID int64) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// Event is a real-time update delivered to subscribers of its channel
+type Event struct {
+	ID      int64
+	Channel string
+	Type    string
+	Data    json.RawMessage
+}
+
+// Subscription receives the events of its channels until it is closed.
+// Events is closed when the subscriber falls too far behind and should resume with Last-Event-ID.
+type Subscription struct {
+	Events   chan Event
+	channels []string
+}
+
+// Broker fans events out to subscribers. Events are written to the events table and announced
+// with LISTEN/NOTIFY, so subscribers on every server instance receive them.
+type Broker struct {
+	q           *database.Queries
+	databaseURL string
+	retention   time.Duration
+
+	mu          sync.RWMutex
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+func NewBroker(q *database.Queries, databaseURL string, retention time.Duration) *Broker {
+	return &Broker{
+		q:           q,
+		databaseURL: databaseURL,
+		retention:   retention,
+		subscribers: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Publish stores an event for channel, it reaches subscribers on every instance through LISTEN/NOTIFY
+func (b *Broker) Publish(ctx context.Context, channel, eventType string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = b.q.CreateEvent(ctx, database.CreateEventParams{
+		Channel: channel,
+		Type:    eventType,
+		Payload: payloadJSON,
+	})
+	return err
+}
+
+// Subscribe starts delivering the events of channels, call Unsubscribe when done
+func (b *Broker) Subscribe(channels []string) *Subscription {
+	sub := &Subscription{Events: make(chan Event, subscriptionBuffer), channels: channels}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, channel := range channels {
+		if b.subscribers[channel] == nil {
+			b.subscribers[channel] = make(map[*Subscription]struct{})
+		}
+		b.subscribers[channel][sub] = struct{}{}
+	}
+	return sub
+}
+
+// Unsubscribe stops delivery to sub, it is safe to call more than once
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(sub)
+}
+
+// remove drops sub from every channel and closes it, the caller must hold mu
+func (b *Broker) remove(sub *Subscription) {
+	found := false
+	for _, channel := range sub.channels {
+		if _, ok := b.subscribers[channel][sub]; ok {
+			found = true
+			delete(b.subscribers[channel], sub)
+		}
+		if len(b.subscribers[channel]) == 0 {
+			delete(b.subscribers, channel)
+		}
+	}
+	if found {
+		close(sub.Events)
+	}
+}
+
+// Replay returns the stored events of channels after afterID, oldest first
+func (b *Broker) Replay(ctx context.Context, channels []string, afterID int64, limit int32) ([]Event, error) {
+	rows, err := b.q.FetchEventsAfter(ctx, database.FetchEventsAfterParams{
+		Channels:  channels,
+		AfterID:   afterID,
+		PageLimit: limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, Event{ID: row.EventID, Channel: row.Channel, Type: row.Type, Data: row.Payload})
+	}
+	return events, nil
+}
+
+// LatestEventID returns the ID of the newest stored event, 0 when there are none
+func (b *Broker) LatestEventID(ctx context.Context) (int64, error) {
+	return b.q.FetchLatestEventID(ctx)
+}
+
+// Run listens for new events until ctx is done, reconnecting with backoff when the connection drops
+func (b *Broker) Run(ctx context.Context) {
+	delay := time.Second
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("Event listener disconnected, reconnecting in %s: %v\n", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// listen holds a dedicated connection on LISTEN and dispatches each announced event
+func (b *Broker) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, b.databaseURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+listenChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		b.dispatch(ctx, notification.Payload)
+	}
+}
+
+// dispatch fetches an announced event and hands it to the subscribers of its channel
+func (b *Broker) dispatch(ctx context.Context, payload string) {
+	var announced struct {
+		EventID int64  `json:"event_id"`
+		Channel string `json:"channel"`
+	}
+	if err := json.Unmarshal([]byte(payload), &announced); err != nil {
+		fmt.Printf("Invalid event notification %q: %v\n", payload, err)
+		return
+	}
+
+	// Only load events someone on this instance is waiting for
+	b.mu.RLock()
+	subscribed := len(b.subscribers[announced.Channel]) > 0
+	b.mu.RUnlock()
+	if !subscribed {
+		return
+	}
+
+	row, err := b.q.FetchEvent(ctx, announced.EventID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Failed to fetch event %d: %v\n", announced.EventID, err)
+		}
+		return
+	}
+	event := Event{ID: row.EventID, Channel: row.Channel, Type: row.Type, Data: row.Payload}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers[event.Channel] {
+		select {
+		case sub.Events <- event:
+		default:
+			// Too far behind, the client reconnects and catches up from the events table
+			b.remove(sub)
+		}
+	}
+}
+
+// Prune deletes events past the retention period, returning how many were removed
+func (b *Broker) Prune(ctx context.Context, now time.Time) (int64, error) {
+	return b.q.DeleteEventsBefore(ctx, pgtype.Timestamptz{Time: now.Add(-b.retention), Valid: true})
+}
@@ -0,0 +1,267 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// clientBuffer is how many messages a connection may fall behind before it is dropped
+	clientBuffer = 32
+
+	// MaxRoomsPerClient bounds how many posts one connection can watch at once
+	MaxRoomsPerClient = 10
+
+	// A connection may join or leave rooms at most membershipBurst times per membershipWindow
+	membershipBurst  = 20
+	membershipWindow = 10 * time.Second
+
+	// presenceDelay collects the joins and leaves in a room into one presence broadcast
+	presenceDelay = 200 * time.Millisecond
+)
+
+var (
+	ErrTooManyConnections = errors.New("too many open connections")
+	ErrTooManyRooms       = fmt.Errorf("at most %d posts can be joined at once", MaxRoomsPerClient)
+	ErrTooManyChanges     = errors.New("joining and leaving posts too quickly, slow down")
+)
+
+// PresenceUser is someone viewing a post
+type PresenceUser struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// PresenceMessage is sent to WebSocket clients
+type PresenceMessage struct {
+	Type    string         `json:"type"` // presence, typing or error
+	PostID  int64          `json:"post_id,omitempty"`
+	Users   []PresenceUser `json:"users,omitempty"` // Everyone in the room, for presence
+	User    *PresenceUser  `json:"user,omitempty"`  // Who is typing
+	Message string         `json:"message,omitempty"`
+}
+
+// Client is one WebSocket connection. The hub queues messages on it without blocking,
+// and a connection that stops reading them is told to disconnect through Done.
+type Client struct {
+	User PresenceUser
+
+	send     chan []byte
+	done     chan struct{}
+	dropOnce sync.Once
+
+	// Guarded by the hub's mutex
+	rooms         map[int64]bool
+	lastTyping    map[int64]time.Time
+	windowStart   time.Time // Start of the current membership window
+	windowChanges int       // Joins and leaves within it
+}
+
+// Send delivers the encoded messages for the connection's write loop
+func (c *Client) Send() <-chan []byte {
+	return c.send
+}
+
+// Done is closed when the connection fell too far behind and must be closed
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// PresenceHub tracks who is viewing and typing in each post's room. Rooms only span
+// the connections of this server instance.
+type PresenceHub struct {
+	maxConnsPerUser int
+	typingInterval  time.Duration
+
+	mu      sync.Mutex
+	rooms   map[int64]map[*Client]struct{}
+	conns   map[int64]int  // Open connections per user
+	pending map[int64]bool // Rooms with a presence broadcast scheduled
+}
+
+func NewPresenceHub(maxConnsPerUser int, typingInterval time.Duration) *PresenceHub {
+	return &PresenceHub{
+		maxConnsPerUser: maxConnsPerUser,
+		typingInterval:  typingInterval,
+		rooms:           make(map[int64]map[*Client]struct{}),
+		conns:           make(map[int64]int),
+		pending:         make(map[int64]bool),
+	}
+}
+
+// Connect registers a new connection for user, failing with ErrTooManyConnections at the per-user cap
+func (h *PresenceHub) Connect(user PresenceUser) (*Client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conns[user.UserID] >= h.maxConnsPerUser {
+		return nil, ErrTooManyConnections
+	}
+	h.conns[user.UserID]++
+
+	return &Client{
+		User:       user,
+		send:       make(chan []byte, clientBuffer),
+		done:       make(chan struct{}),
+		rooms:      make(map[int64]bool),
+		lastTyping: make(map[int64]time.Time),
+	}, nil
+}
+
+// Disconnect removes the connection from its rooms and frees its slot
+func (h *PresenceHub) Disconnect(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for postID := range c.rooms {
+		h.leave(c, postID)
+	}
+	h.conns[c.User.UserID]--
+	if h.conns[c.User.UserID] <= 0 {
+		delete(h.conns, c.User.UserID)
+	}
+}
+
+// AllowMembershipChange counts a join or leave against the connection's budget, reporting false once it is spent.
+// Callers check it before doing any work for the message.
+func (h *PresenceHub) AllowMembershipChange(c *Client, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if now.Sub(c.windowStart) >= membershipWindow {
+		c.windowStart = now
+		c.windowChanges = 0
+	}
+	if c.windowChanges >= membershipBurst {
+		return false
+	}
+	c.windowChanges++
+	return true
+}
+
+// Join adds the connection to a post's room and sends everyone there the updated presence
+func (h *PresenceHub) Join(c *Client, postID int64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if c.rooms[postID] {
+		return nil
+	}
+	if len(c.rooms) >= MaxRoomsPerClient {
+		return ErrTooManyRooms
+	}
+
+	if h.rooms[postID] == nil {
+		h.rooms[postID] = make(map[*Client]struct{})
+	}
+	h.rooms[postID][c] = struct{}{}
+	c.rooms[postID] = true
+	h.schedulePresence(postID)
+	return nil
+}
+
+// Leave removes the connection from a post's room
+func (h *PresenceHub) Leave(c *Client, postID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leave(c, postID)
+}
+
+// leave is Leave for callers holding mu
+func (h *PresenceHub) leave(c *Client, postID int64) {
+	if !c.rooms[postID] {
+		return
+	}
+	delete(c.rooms, postID)
+	delete(c.lastTyping, postID)
+	delete(h.rooms[postID], c)
+	if len(h.rooms[postID]) == 0 {
+		delete(h.rooms, postID)
+		return
+	}
+	h.schedulePresence(postID)
+}
+
+// Typing tells the others in a room that the user is writing a reply.
+// Indicators arriving within the typing interval of the last relayed one are dropped.
+func (h *PresenceHub) Typing(c *Client, postID int64, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !c.rooms[postID] {
+		return
+	}
+	if last, ok := c.lastTyping[postID]; ok && now.Sub(last) < h.typingInterval {
+		return
+	}
+	c.lastTyping[postID] = now
+
+	// Other tabs of the same user are not told they are typing
+	user := c.User
+	message := encode(PresenceMessage{Type: "typing", PostID: postID, User: &user})
+	for other := range h.rooms[postID] {
+		if other.User.UserID != c.User.UserID {
+			h.enqueue(other, message)
+		}
+	}
+}
+
+// Error sends an error message to one connection
+func (h *PresenceHub) Error(c *Client, postID int64, message string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.enqueue(c, encode(PresenceMessage{Type: "error", PostID: postID, Message: message}))
+}
+
+// schedulePresence broadcasts the room's presence shortly, unless a broadcast is already due. The caller must hold mu.
+func (h *PresenceHub) schedulePresence(postID int64) {
+	if h.pending[postID] {
+		return
+	}
+	h.pending[postID] = true
+	time.AfterFunc(presenceDelay, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.pending, postID)
+		h.broadcastPresence(postID)
+	})
+}
+
+// broadcastPresence sends the room's distinct users to everyone in it, the caller must hold mu
+func (h *PresenceHub) broadcastPresence(postID int64) {
+	if len(h.rooms[postID]) == 0 {
+		return
+	}
+	seen := make(map[int64]bool)
+	users := []PresenceUser{}
+	for c := range h.rooms[postID] {
+		if !seen[c.User.UserID] {
+			seen[c.User.UserID] = true
+			users = append(users, c.User)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+
+	message := encode(PresenceMessage{Type: "presence", PostID: postID, Users: users})
+	for c := range h.rooms[postID] {
+		h.enqueue(c, message)
+	}
+}
+
+// enqueue queues a message without blocking, dropping connections whose buffer is full
+func (h *PresenceHub) enqueue(c *Client, message []byte) {
+	select {
+	case c.send <- message:
+	default:
+		c.dropOnce.Do(func() { close(c.done) })
+	}
+}
+
+func encode(message PresenceMessage) []byte {
+	encoded, _ := json.Marshal(message)
+	return encoded
+}
@@ -1,58 +1,454 @@
 package router
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/attachment"
+	"github.com/DamienFooxx/CVWOForum/internal/auth"
+	"github.com/DamienFooxx/CVWOForum/internal/config"
 	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/digest"
 	"github.com/DamienFooxx/CVWOForum/internal/handler"
+	"github.com/DamienFooxx/CVWOForum/internal/jobs"
+	"github.com/DamienFooxx/CVWOForum/internal/mail"
 	"github.com/DamienFooxx/CVWOForum/internal/middleware"
+	"github.com/DamienFooxx/CVWOForum/internal/notification"
+	"github.com/DamienFooxx/CVWOForum/internal/outbox"
+	"github.com/DamienFooxx/CVWOForum/internal/realtime"
+	"github.com/DamienFooxx/CVWOForum/internal/storage"
+	"github.com/DamienFooxx/CVWOForum/internal/webhook"
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
-// NewRouter initialises and returns new HTTP router
-func NewRouter(queries *database.Queries) *chi.Mux {
+// NewRouter initialises and returns new HTTP router, and the background workers it relies on.
+// The workers are not running yet, the caller starts them and stops them on shutdown.
+func NewRouter(cfg *config.Config, queries *database.Queries) (*chi.Mux, *Workers) {
 	// Create the router instance with r var name
 	r := chi.NewRouter()
+	workers := &Workers{}
 
 	// Use the CORS middleware
 	r.Use(middleware.CorsMiddleware())
 
+	// Uploaded files, and the signer for attachment download links
+	store := storage.New(cfg.Storage)
+	signer := attachment.NewSigner(cfg.Uploads.AttachmentSigningKey, cfg.Uploads.AttachmentURLTTL)
+
+	// Real-time events, fanned out to every instance through LISTEN/NOTIFY
+	broker := realtime.NewBroker(queries, cfg.DatabaseURL, cfg.Events.Retention)
+	workers.add(broker.Run)
+
+	// Outgoing webhooks, deliveries are queued in the database and sent in the background
+	webhooks := webhook.NewDispatcher(queries, cfg.Webhooks)
+	workers.add(webhooks.Run)
+
+	// Domain events, written in the same transaction as the change and handed to subscribers afterwards
+	events := outbox.NewDispatcher(queries, cfg.Outbox)
+	subscribeOutbox(events, broker, webhooks)
+	workers.add(events.Run)
+
+	// Background jobs, counters and purges run here rather than in request handlers
+	queue := jobs.NewQueue(queries, cfg.Jobs)
+	digests := digest.NewSender(queries, queue, cfg.Digests, cfg.FrontendURL, cfg.APIURL)
+	notifier := notification.NewNotifier(queries, broker)
+	registerJobs(queue, queries, broker, events, mail.New(cfg.Mail), digests, notifier, attachment.NewCleaner(queries, store, cfg.Uploads.AttachmentOrphanTTL))
+	workers.add(queue.Run)
+
 	// Initialise handlers
-	userHandler := handler.NewUserHandler(queries)
-	topicHandler := handler.NewTopicHandler(queries)
-	postHandler := handler.NewPostHandler(queries)
-	commentHandler := handler.NewCommentHandler(queries)
+	loginThrottle := auth.NewLoginThrottle(queries, cfg.Login)
+	accountHandler := handler.NewAccountHandler(queries, loginThrottle, queue, cfg.Accounts, cfg.FrontendURL)
+	digestHandler := handler.NewDigestHandler(queries, digests)
+	userHandler := handler.NewUserHandler(queries, loginThrottle, cfg.RateLimit.TrustProxyHeaders, accountHandler)
+	topicHandler := handler.NewTopicHandler(queries, events)
+	feedHandler := handler.NewFeedHandler(queries)
+	bookmarkHandler := handler.NewBookmarkHandler(queries)
+	postHandler := handler.NewPostHandler(queries, signer, notifier, queue, events)
+	commentHandler := handler.NewCommentHandler(queries, signer, notifier, events)
+	adminHandler := handler.NewAdminHandler(queries)
+	tokenHandler := handler.NewTokenHandler(queries)
+	twoFactorHandler := handler.NewTwoFactorHandler(queries, cfg.TwoFactor.Issuer)
+	profileHandler := handler.NewProfileHandler(queries)
+	avatarHandler := handler.NewAvatarHandler(queries, store, cfg.Uploads.MaxAvatarBytes)
+	attachmentHandler := handler.NewAttachmentHandler(queries, store, signer, cfg.Uploads)
+	notificationHandler := handler.NewNotificationHandler(queries)
+	moderationHandler := handler.NewModerationHandler(queries, notifier, queue, events)
+	webhookHandler := handler.NewWebhookHandler(queries)
+	jobHandler := handler.NewJobHandler(queries)
+	eventsHandler := handler.NewEventsHandler(broker, cfg.Events.Heartbeat)
+	presenceHub := realtime.NewPresenceHub(cfg.WebSocket.MaxConnsPerUser, cfg.WebSocket.TypingInterval)
+	webSocketHandler := handler.NewWebSocketHandler(queries, presenceHub, cfg.FrontendURL)
+
+	// Rate limits for each route group
+	authLimit, writeLimit, readLimit := newRateLimits(cfg.RateLimit, queries, workers)
 
 	// Register URLs
 	// Health
 	r.Get("/health", handler.Health)
 
 	// Users
-	r.Post("/users", userHandler.CreateUser)
-	r.Post("/login", userHandler.Login)
+	r.Group(func(r chi.Router) {
+		r.Use(authLimit)
+		r.Post("/users", userHandler.CreateUser)
+		r.Post("/login", userHandler.Login)
+		r.Post("/login/2fa", userHandler.CompleteLogin)
+
+		// Links emailed to verify an address or reset a forgotten password
+		r.Post("/auth/email/verify", accountHandler.VerifyEmail)
+		r.Post("/auth/password/forgot", accountHandler.ForgotPassword)
+		r.Post("/auth/password/reset", accountHandler.ResetPassword)
+		r.Post("/digests/unsubscribe", digestHandler.Unsubscribe)
+
+		// Single sign-on, only when an identity provider is configured
+		if cfg.OIDC.Enabled() {
+			oidcHandler := handler.NewOIDCHandler(queries, cfg.OIDC)
+			r.Get("/auth/oidc/login", oidcHandler.Login)
+			r.Get("/auth/oidc/callback", oidcHandler.Callback)
+		}
+	})
+
+	// Public Routes
+	r.Group(func(r chi.Router) {
+		r.Use(readLimit)
+
+		// Topics
+		r.Get("/topics/{topicID}", topicHandler.GetTopic)
+
+		// Posts
+		r.Get("/posts/{postID}", postHandler.GetPost)
+
+		// Avatars
+		r.Get("/users/{userID}/avatar", avatarHandler.GetAvatar)
+
+		// Attachments, through the signed URLs listed on posts and comments
+		r.Get("/attachments/{attachmentID}", attachmentHandler.DownloadAttachment)
+	})
+
+	// Public Routes that show more to logged in users, depending on privacy settings
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.OptionalAuth(queries))
+		r.Use(readLimit)
+		r.Get("/users/{username}", profileHandler.GetProfile)
+		r.Get("/users/{userID}/posts", profileHandler.ListUserPosts)
+		r.Get("/users/{userID}/comments", profileHandler.ListUserComments)
+		r.Get("/users/{userID}/topics", profileHandler.ListUserTopics)
+
+		// Sitewide listings, which leave out topics the user has muted
+		r.Get("/topics", topicHandler.SearchTopics) // Has Fuzzy Search
+		r.Get("/posts", postHandler.SearchPostsGlobal)
+
+		// Listings that also track what logged in users have read
+		r.Get("/topics/{topicID}/posts", postHandler.SearchPostsTopics)
+		r.Get("/posts/{postID}/comments", commentHandler.ListComments)
+
+		// Real-time updates, notifications need a login
+		r.Get("/events", eventsHandler.StreamEvents)
+	})
+
+	// Protected Routes, accept a JWT or a personal access token with the route's scope
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.AuthMiddleware(queries))
+		r.Use(writeLimit) // After auth so limits are per user
+
+		r.With(middleware.RequireScope(auth.ScopePost)).Post("/topics", topicHandler.CreateTopic)
+		r.With(middleware.RequireScope(auth.ScopePost)).Delete("/topics/{topicID}", topicHandler.DeleteTopic)
+
+		r.With(middleware.RequireScope(auth.ScopePost)).Post("/topics/{topicID}/posts", postHandler.CreatePost)
+		r.With(middleware.RequireScope(auth.ScopePost)).Patch("/posts/{postID}", postHandler.UpdatePost)
+		r.With(middleware.RequireScope(auth.ScopePost)).Delete("/posts/{postID}", postHandler.DeletePost)
+
+		r.With(middleware.RequireScope(auth.ScopeComment)).Post("/posts/{postID}/comments", commentHandler.CreateComment)
+		r.With(middleware.RequireScope(auth.ScopeComment)).Patch("/comments/{commentID}", commentHandler.UpdateComment)
+		r.With(middleware.RequireScope(auth.ScopeComment)).Delete("/comments/{commentID}", commentHandler.DeleteComment)
+
+		r.With(middleware.RequireAnyScope(auth.ScopePost, auth.ScopeComment)).Post("/attachments", attachmentHandler.UploadAttachment)
+
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/notifications", notificationHandler.ListNotifications)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Post("/notifications/read", notificationHandler.MarkRead)
+
+		// Followed topics make up the feed, muted ones are hidden from sitewide listings
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/feed", feedHandler.GetFeed)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Put("/topics/{topicID}/follow", topicHandler.FollowTopic)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Delete("/topics/{topicID}/follow", topicHandler.UnfollowTopic)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Put("/topics/{topicID}/mute", topicHandler.MuteTopic)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Delete("/topics/{topicID}/mute", topicHandler.UnmuteTopic)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/users/me/followed-topics", topicHandler.ListFollowedTopics)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/users/me/muted-topics", topicHandler.ListMutedTopics)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Put("/posts/{postID}/watch", postHandler.WatchPost)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Delete("/posts/{postID}/watch", postHandler.UnwatchPost)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/users/me/watching", postHandler.ListWatchedPosts)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Post("/topics/{topicID}/read", topicHandler.MarkTopicRead)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Put("/posts/{postID}/read", postHandler.MarkPostRead)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Put("/posts/{postID}/bookmark", bookmarkHandler.BookmarkPost)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Delete("/posts/{postID}/bookmark", bookmarkHandler.UnbookmarkPost)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Put("/comments/{commentID}/bookmark", bookmarkHandler.BookmarkComment)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Delete("/comments/{commentID}/bookmark", bookmarkHandler.UnbookmarkComment)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/users/me/bookmarks", bookmarkHandler.ListBookmarks)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/users/me/bookmark-folders", bookmarkHandler.ListFolders)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Post("/users/me/bookmark-folders", bookmarkHandler.CreateFolder)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Delete("/users/me/bookmark-folders/{folderID}", bookmarkHandler.DeleteFolder)
+	})
+
+	// Live thread presence, browsers cannot set headers on WebSockets so the token may come in the URL
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.TokenFromQuery)
+		r.Use(middleware.AuthMiddleware(queries))
+		r.Use(middleware.RequireScope(auth.ScopeRead))
+		r.Use(readLimit)
+		r.Get("/ws", webSocketHandler.Connect)
+	})
+
+	// Account settings, personal access tokens and two-factor, only manageable from a logged in session
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.AuthMiddleware(queries))
+		r.Use(middleware.RequireSession)
+		r.Use(writeLimit)
+		r.Post("/users/me/tokens", tokenHandler.CreateToken)
+		r.Get("/users/me/tokens", tokenHandler.ListTokens)
+		r.Delete("/users/me/tokens/{tokenID}", tokenHandler.RevokeToken)
+
+		r.Patch("/users/me", profileHandler.UpdateProfile)
+		r.Put("/users/me/email", accountHandler.SetEmail)
+		r.Put("/users/me/avatar", avatarHandler.UploadAvatar)
+		r.Delete("/users/me/avatar", avatarHandler.DeleteAvatar)
 
-	// Topics
-	r.Get("/topics", topicHandler.SearchTopics) // Has Fuzzy Search
-	r.Get("/topics/{topicID}", topicHandler.GetTopic)
+		r.Get("/users/me/notification-preferences", notificationHandler.GetPreferences)
+		r.Put("/users/me/notification-preferences", notificationHandler.UpdatePreferences)
+		r.Get("/users/me/digest", digestHandler.GetSettings)
+		r.Put("/users/me/digest", digestHandler.UpdateSettings)
 
-	// Posts
-	r.Get("/posts", postHandler.SearchPostsGlobal)
-	r.Get("/topics/{topicID}/posts", postHandler.SearchPostsTopics)
-	r.Get("/posts/{postID}", postHandler.GetPost)
+		r.Get("/users/me/2fa", twoFactorHandler.GetStatus)
+		r.Post("/users/me/2fa/setup", twoFactorHandler.Setup)
+		r.Post("/users/me/2fa/enable", twoFactorHandler.Enable)
+		r.Post("/users/me/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+		r.Delete("/users/me/2fa", twoFactorHandler.Disable)
+	})
 
-	// Comments
-	r.Get("/posts/{postID}/comments", commentHandler.ListComments)
+	// Moderator Routes
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.AuthMiddleware(queries))
+		r.Use(middleware.RequireScope(auth.ScopeModerate))
+		r.Use(middleware.RequireRole(queries, auth.RoleModerator, auth.RoleAdmin))
+		r.Use(middleware.RequireTwoFactor(queries, cfg.TwoFactor.RequiredRoles))
+		r.Use(writeLimit)
+		r.Delete("/moderation/posts/{postID}", moderationHandler.RemovePost)
+		r.Delete("/moderation/comments/{commentID}", moderationHandler.RemoveComment)
+	})
 
-	// Protected Routes
+	// Admin Routes
 	r.Group(func(r chi.Router) {
-		r.Use(middleware.AuthMiddleware)
-		r.Post("/topics", topicHandler.CreateTopic)
-		r.Delete("/topics/{topicID}", topicHandler.DeleteTopic)
+		r.Use(middleware.AuthMiddleware(queries))
+		r.Use(middleware.RequireScope(auth.ScopeModerate))
+		r.Use(middleware.RequireRole(queries, auth.RoleAdmin))
+		r.Use(middleware.RequireTwoFactor(queries, cfg.TwoFactor.RequiredRoles))
+		r.Use(readLimit)
+		r.Get("/admin/login-attempts", adminHandler.ListLoginAttempts)
+		r.Get("/users", adminHandler.ListUsers)
+
+		r.Post("/admin/webhooks", webhookHandler.CreateWebhook)
+		r.Get("/admin/webhooks", webhookHandler.ListWebhooks)
+		r.Delete("/admin/webhooks/{webhookID}", webhookHandler.DeleteWebhook)
+		r.Get("/admin/webhooks/{webhookID}/deliveries", webhookHandler.ListDeliveries)
+		r.Post("/admin/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", webhookHandler.Redeliver)
+
+		r.Get("/admin/jobs", jobHandler.ListJobs)
+		r.Post("/admin/jobs/{jobID}/retry", jobHandler.RetryJob)
+	})
+
+	return r, workers
+}
+
+// bookmarkReminderBatch is how many due bookmark reminders are claimed at a time
+const bookmarkReminderBatch = 100
+
+// registerJobs sets the handlers of the forum's background jobs and schedules the periodic ones
+func registerJobs(queue *jobs.Queue, queries *database.Queries, broker *realtime.Broker, events *outbox.Dispatcher, mailer mail.Mailer, digests *digest.Sender, notifier *notification.Notifier, cleaner *attachment.Cleaner) {
+	jobs.Register(queue, jobs.RecountTopicPosts, func(ctx context.Context, payload jobs.TopicPayload) error {
+		return queries.RecountTopicPosts(ctx, payload.TopicID)
+	})
+
+	// Catches counts that drifted, e.g. when a recount could not be queued
+	jobs.Register(queue, jobs.ReconcilePostCounts, func(ctx context.Context, _ struct{}) error {
+		fixed, err := queries.RecountAllTopicPosts(ctx)
+		if fixed > 0 {
+			fmt.Printf("Corrected the post count of %d topics\n", fixed)
+		}
+		return err
+	})
+	jobs.Schedule(queue, jobs.ReconcilePostCounts, 24*time.Hour, struct{}{})
+
+	// Uploads that were never added to a post or comment
+	jobs.Register(queue, jobs.PurgeOrphanedAttachments, func(ctx context.Context, _ struct{}) error {
+		_, err := cleaner.Sweep(ctx, time.Now())
+		return err
+	})
+	jobs.Schedule(queue, jobs.PurgeOrphanedAttachments, time.Hour, struct{}{})
 
-		r.Post("/topics/{topicID}/posts", postHandler.CreatePost)
-		r.Delete("/posts/{postID}", postHandler.DeletePost)
+	// Events past the Last-Event-ID resume window
+	jobs.Register(queue, jobs.PruneEvents, func(ctx context.Context, _ struct{}) error {
+		_, err := broker.Prune(ctx, time.Now())
+		return err
+	})
+	jobs.Schedule(queue, jobs.PruneEvents, time.Hour, struct{}{})
+
+	// Outbox events that every subscriber has handled
+	jobs.Register(queue, jobs.PurgeOutbox, func(ctx context.Context, _ struct{}) error {
+		_, err := events.Purge(ctx, time.Now())
+		return err
+	})
+	jobs.Schedule(queue, jobs.PurgeOutbox, time.Hour, struct{}{})
+
+	// Emailed account links past their expiry
+	jobs.Register(queue, jobs.PurgeEmailTokens, func(ctx context.Context, _ struct{}) error {
+		_, err := queries.DeleteExpiredEmailTokens(ctx, pgtype.Timestamptz{Time: time.Now(), Valid: true})
+		return err
+	})
+	jobs.Schedule(queue, jobs.PurgeEmailTokens, 24*time.Hour, struct{}{})
 
-		r.Post("/posts/{postID}/comments", commentHandler.CreateComment)
-		r.Delete("/comments/{commentID}", commentHandler.DeleteComment)
+	// Digest emails, checked hourly so each subscriber gets theirs a day or a week after the last
+	jobs.Register(queue, jobs.QueueDigests, func(ctx context.Context, _ struct{}) error {
+		_, err := digests.QueueDue(ctx, time.Now().Truncate(time.Hour))
+		return err
 	})
+	jobs.Schedule(queue, jobs.QueueDigests, time.Hour, struct{}{})
+	jobs.Register(queue, jobs.SendDigest, func(ctx context.Context, payload jobs.DigestPayload) error {
+		return digests.Send(ctx, payload.UserID, payload.Until)
+	})
+	jobs.Register(queue, jobs.PurgeDigests, func(ctx context.Context, _ struct{}) error {
+		_, err := digests.Purge(ctx, time.Now())
+		return err
+	})
+	jobs.Schedule(queue, jobs.PurgeDigests, 24*time.Hour, struct{}{})
+
+	// Bookmark reminders, each is cleared in the transaction that stores its notification so a failure leaves it due.
+	// Reminders for posts and comments removed since are cleared without a notification.
+	jobs.Register(queue, jobs.SendBookmarkReminders, func(ctx context.Context, _ struct{}) error {
+		for {
+			var claimed int
+			var created []*notification.Created
+			err := queries.InTx(ctx, func(tx *database.Queries) error {
+				due, err := tx.ClaimDueBookmarkReminders(ctx, database.ClaimDueBookmarkRemindersParams{
+					Now:       pgtype.Timestamptz{Time: time.Now(), Valid: true},
+					BatchSize: bookmarkReminderBatch,
+				})
+				if err != nil {
+					return err
+				}
+				claimed = len(due)
+				created = created[:0]
+				for _, b := range due {
+					if !b.Available {
+						continue
+					}
+					payload := map[string]interface{}{
+						"bookmark_id": b.BookmarkID,
+						"post_id":     b.PostID,
+						"note":        b.Note,
+					}
+					if b.CommentID.Valid {
+						payload["comment_id"] = b.CommentID.Int64
+					}
+					c, err := notifier.Create(ctx, tx, b.UserID, notification.TypeBookmarkReminder, payload)
+					if err != nil {
+						return err
+					}
+					if c != nil {
+						created = append(created, c)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, c := range created {
+				notifier.Publish(ctx, c)
+			}
+			if claimed < bookmarkReminderBatch {
+				return nil
+			}
+		}
+	})
+	jobs.Schedule(queue, jobs.SendBookmarkReminders, time.Minute, struct{}{})
+
+	// Emails queued with mail.Enqueue, rejected ones are not retried
+	jobs.Register(queue, mail.SendEmail, func(ctx context.Context, msg mail.Message) error {
+		if err := mailer.Send(ctx, msg); err != nil {
+			if mail.IsPermanent(err) {
+				return jobs.Permanent(err)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// subscribeOutbox hands the forum's domain events to outgoing webhooks and real-time clients
+func subscribeOutbox(events *outbox.Dispatcher, broker *realtime.Broker, webhooks *webhook.Dispatcher) {
+	// Webhooks get every event, filtered by topic
+	outbox.Subscribe(events, "webhooks", outbox.TopicCreated, func(ctx context.Context, _ int64, event outbox.TopicCreatedEvent) error {
+		return webhooks.Publish(ctx, webhook.EventTopicCreated, event.TopicID, event)
+	})
+	outbox.Subscribe(events, "webhooks", outbox.PostCreated, func(ctx context.Context, _ int64, event outbox.PostCreatedEvent) error {
+		return webhooks.Publish(ctx, webhook.EventPostCreated, event.TopicID, event)
+	})
+	outbox.Subscribe(events, "webhooks", outbox.CommentCreated, func(ctx context.Context, _ int64, event outbox.CommentCreatedEvent) error {
+		return webhooks.Publish(ctx, webhook.EventCommentCreated, event.TopicID, event)
+	})
+	outbox.Subscribe(events, "webhooks", outbox.ContentRemoved, func(ctx context.Context, _ int64, event outbox.ContentRemovedEvent) error {
+		return webhooks.Publish(ctx, webhook.EventContentRemoved, event.TopicID, event)
+	})
+
+	// Clients following a topic or post see new posts and comments live
+	outbox.Subscribe(events, "realtime", outbox.PostCreated, func(ctx context.Context, _ int64, event outbox.PostCreatedEvent) error {
+		return broker.Publish(ctx, realtime.TopicChannel(event.TopicID), "post_created", map[string]interface{}{
+			"post_id":    event.PostID,
+			"topic_id":   event.TopicID,
+			"title":      event.Title,
+			"created_by": event.CreatedBy,
+			"created_at": event.CreatedAt,
+		})
+	})
+	outbox.Subscribe(events, "realtime", outbox.CommentCreated, func(ctx context.Context, _ int64, event outbox.CommentCreatedEvent) error {
+		return broker.Publish(ctx, realtime.PostChannel(event.PostID), "comment_created", map[string]interface{}{
+			"comment_id":   event.CommentID,
+			"post_id":      event.PostID,
+			"parent_id":    event.ParentID,
+			"commented_by": event.CommentedBy,
+			"created_at":   event.CreatedAt,
+		})
+	})
+}
+
+// newRateLimits builds the auth, write and read rate limiting middleware.
+// They pass every request through when rate limiting is disabled.
+func newRateLimits(cfg config.RateLimitConfig, queries *database.Queries, workers *Workers) (authLimit, writeLimit, readLimit func(http.Handler) http.Handler) {
+	if !cfg.Enabled {
+		passthrough := func(next http.Handler) http.Handler { return next }
+		return passthrough, passthrough, passthrough
+	}
+
+	var limiter middleware.RateLimiter
+	if cfg.Backend == "postgres" {
+		// Buckets idle for the longest period have fully refilled and can be swept
+		maxPeriod := max(cfg.Auth.Period, cfg.Writes.Period, cfg.Reads.Period)
+		postgresLimiter := middleware.NewPostgresRateLimiter(queries, maxPeriod)
+		workers.add(postgresLimiter.Sweep)
+		limiter = postgresLimiter
+	} else {
+		limiter = middleware.NewMemoryRateLimiter()
+	}
+
+	policy := func(name string, rule config.RateLimitRule) func(http.Handler) http.Handler {
+		return middleware.RateLimit(limiter, middleware.RateLimitPolicy{
+			Name:     name,
+			Requests: rule.Requests,
+			Period:   rule.Period,
+		}, cfg.TrustProxyHeaders)
+	}
 
-	return r
+	return policy("auth", cfg.Auth), policy("writes", cfg.Writes), policy("reads", cfg.Reads)
 }
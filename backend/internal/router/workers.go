@@ -0,0 +1,36 @@
+package router
+
+import (
+	"context"
+	"sync"
+)
+
+// Workers are the background loops behind the routes, the event broker, the webhook and outbox
+// dispatchers, the job queue and the rate limit sweep
+type Workers struct {
+	runs []func(ctx context.Context)
+}
+
+func (w *Workers) add(run func(ctx context.Context)) {
+	w.runs = append(w.runs, run)
+}
+
+// Start runs every worker until ctx is done or stop is called. stop waits for them to return,
+// so in-flight jobs and deliveries finish before the server exits.
+func (w *Workers) Start(ctx context.Context) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	for _, run := range w.runs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(ctx)
+		}()
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+)
+
+// ErrNotFound is returned by Get when no object is stored under the key
+var ErrNotFound = errors.New("object not found")
+
+// Storage stores uploaded files as blobs under slash separated keys such as "avatars/1/256.png"
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error // Deleting a missing key is not an error
+}
+
+// New returns the backend selected by STORAGE_BACKEND
+func New(cfg config.StorageConfig) Storage {
+	if cfg.Backend == "s3" {
+		return NewS3Storage(cfg.S3)
+	}
+	return NewLocalStorage(cfg.LocalDir)
+}
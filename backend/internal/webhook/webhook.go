@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Event types a webhook can subscribe to
+const (
+	EventTopicCreated   = "topic.created"
+	EventPostCreated    = "post.created"
+	EventCommentCreated = "comment.created"
+	EventContentRemoved = "content.removed"
+)
+
+var EventTypes = []string{EventTopicCreated, EventPostCreated, EventCommentCreated, EventContentRemoved}
+
+const (
+	batchSize      = 10
+	baseRetryDelay = 30 * time.Second
+	maxRetryDelay  = 6 * time.Hour
+	maxErrorLength = 500
+	leaseGrace     = time.Minute // Slack on top of the send timeouts for recording the outcomes
+)
+
+// Dispatcher queues webhook deliveries and sends them, retrying failures with exponential backoff.
+// Deliveries are claimed with SKIP LOCKED, so any number of instances can run it.
+type Dispatcher struct {
+	q      *database.Queries
+	cfg    config.WebhookConfig
+	client *http.Client
+}
+
+func NewDispatcher(q *database.Queries, cfg config.WebhookConfig) *Dispatcher {
+	return &Dispatcher{
+		q:   q,
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// A redirect is reported as a failed delivery rather than followed
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Publish queues a delivery of the event to every webhook subscribed to it. topicID is 0 for events outside a topic.
+func (d *Dispatcher) Publish(ctx context.Context, eventType string, topicID int64, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = d.q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventType: eventType,
+		Payload:   payload,
+		TopicID:   pgtype.Int8{Int64: topicID, Valid: topicID != 0},
+	})
+	return err
+}
+
+// Run sends due deliveries every poll interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.DeliverDue(ctx); err != nil {
+				fmt.Printf("Failed to deliver webhooks: %v\n", err)
+			}
+		}
+	}
+}
+
+// DeliverDue sends every delivery that is due, returning how many were attempted
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	attempted := 0
+	for {
+		// Claimed deliveries are leased, another instance retries them if this one dies mid-send.
+		// The batch is sent one by one, so the lease must outlast every delivery in it timing out.
+		leaseUntil := time.Now().Add(batchSize*d.cfg.Timeout + leaseGrace)
+		claimed, err := d.q.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+			LeaseUntil: pgtype.Timestamptz{Time: leaseUntil, Valid: true},
+			BatchSize:  batchSize,
+		})
+		if err != nil {
+			return attempted, err
+		}
+
+		for _, delivery := range claimed {
+			d.deliver(ctx, delivery)
+		}
+		attempted += len(claimed)
+
+		if len(claimed) < batchSize {
+			return attempted, nil
+		}
+	}
+}
+
+// deliver sends one delivery and records the outcome
+func (d *Dispatcher) deliver(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"delivery_id": delivery.DeliveryID,
+		"event":       delivery.EventType,
+		"created_at":  delivery.CreatedAt.Time.Format(time.RFC3339),
+		"data":        json.RawMessage(delivery.Payload),
+	})
+
+	statusCode, err := d.send(ctx, delivery, body)
+
+	params := database.RecordWebhookAttemptParams{DeliveryID: delivery.DeliveryID, Status: "succeeded"}
+	if statusCode != 0 {
+		params.StatusCode = pgtype.Int4{Int32: int32(statusCode), Valid: true}
+	}
+	if err != nil {
+		message := err.Error()
+		if len(message) > maxErrorLength {
+			message = message[:maxErrorLength]
+		}
+		params.Error = pgtype.Text{String: message, Valid: true}
+
+		if delivery.Attempts >= d.cfg.MaxAttempts {
+			params.Status = "failed"
+		} else {
+			params.Status = "pending"
+			params.NextAttemptAt = pgtype.Timestamptz{Time: time.Now().Add(retryDelay(delivery.Attempts)), Valid: true}
+		}
+	}
+
+	if err := d.q.RecordWebhookAttempt(ctx, params); err != nil {
+		fmt.Printf("Failed to record attempt of webhook delivery %d: %v\n", delivery.DeliveryID, err)
+	}
+}
+
+// send POSTs the signed body, any response other than 2xx is an error
+func (d *Dispatcher) send(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CVWOForum-Webhooks")
+	req.Header.Set("X-Forum-Event", delivery.EventType)
+	req.Header.Set("X-Forum-Delivery", fmt.Sprintf("%d", delivery.DeliveryID))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Lets the connection be reused
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay doubles from baseRetryDelay with each failed attempt, up to maxRetryDelay
+func retryDelay(attempts int32) time.Duration {
+	delay := baseRetryDelay
+	for i := int32(1); i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
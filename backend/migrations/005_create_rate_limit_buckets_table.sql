@@ -0,0 +1,13 @@
+-- +goose Up
+-- Token buckets for the Postgres rate limiter backend, shared by every server instance
+CREATE TABLE rate_limit_buckets (
+    bucket_key TEXT PRIMARY KEY, -- "<policy>:<user or ip>"
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL, -- Whether the last request against this bucket was let through
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW() -- Full precision, refills are computed from it
+);
+
+CREATE INDEX idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at); -- For sweeping idle buckets
+
+-- +goose Down
+DROP TABLE rate_limit_buckets;
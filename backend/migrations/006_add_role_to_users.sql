@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('member', 'moderator', 'admin'));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- Audit trail of every login attempt
+CREATE TABLE login_attempts (
+    attempt_id BIGSERIAL PRIMARY KEY,
+    username TEXT NOT NULL, -- As submitted, the account may not exist
+    user_id BIGINT REFERENCES users(user_id) ON DELETE SET NULL,
+    ip_address TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    failure_reason TEXT, -- 'invalid_password' or 'locked'
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_attempts_created_at ON login_attempts(created_at DESC);
+CREATE INDEX idx_login_attempts_username_created_at ON login_attempts(username, created_at DESC);
+CREATE INDEX idx_login_attempts_ip_created_at ON login_attempts(ip_address, created_at DESC);
+
+-- Consecutive failures per account and per IP, used for backoff and lockouts
+CREATE TABLE login_throttles (
+    throttle_key TEXT PRIMARY KEY, -- "account:<user_id>" or "ip:<address>"
+    failure_count INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP(0) WITH TIME ZONE
+);
+
+-- +goose Down
+DROP TABLE login_throttles;
+DROP TABLE login_attempts;
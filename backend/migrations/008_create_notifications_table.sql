@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE notifications (
+    notification_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE, -- Recipient
+    type TEXT NOT NULL CHECK (type IN ('account_locked')),
+    payload JSONB NOT NULL DEFAULT '{}', -- Type specific details
+    read_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_notifications_user_created_at ON notifications(user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE notifications;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE personal_access_tokens (
+    token_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE, -- SHA-256 of the token, the token itself is only shown once
+    token_prefix TEXT NOT NULL, -- Start of the token so users can tell them apart
+    scopes TEXT[] NOT NULL CHECK (scopes <@ ARRAY['read', 'post', 'comment', 'moderate']),
+    expires_at TIMESTAMP(0) WITH TIME ZONE, -- Null never expires
+    last_used_at TIMESTAMP(0) WITH TIME ZONE,
+    revoked_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_personal_access_tokens_user_created_at ON personal_access_tokens(user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE personal_access_tokens;
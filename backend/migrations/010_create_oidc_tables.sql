@@ -0,0 +1,24 @@
+-- +goose Up
+-- Links forum users to accounts at an OpenID Connect identity provider
+CREATE TABLE user_identities (
+    identity_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL, -- The IdP's stable "sub" claim
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+-- In flight authorization requests, consumed by the callback
+CREATE TABLE oidc_login_states (
+    state TEXT PRIMARY KEY,
+    code_verifier TEXT NOT NULL, -- PKCE verifier, never leaves the server
+    nonce TEXT NOT NULL,
+    expires_at TIMESTAMP(0) WITH TIME ZONE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oidc_login_states;
+DROP TABLE user_identities;
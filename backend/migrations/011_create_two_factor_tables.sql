@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE user_totp (
+    user_id BIGINT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    secret TEXT NOT NULL, -- Base32 TOTP secret
+    enabled_at TIMESTAMP(0) WITH TIME ZONE, -- Null until the user has confirmed a code from their app
+    last_used_step BIGINT NOT NULL DEFAULT 0, -- Time step of the last accepted code, so codes cannot be replayed
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE totp_recovery_codes (
+    code_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL, -- SHA-256 of the code, the code itself is only shown once
+    used_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_totp_recovery_codes_user_id ON totp_recovery_codes(user_id);
+
+-- +goose Down
+DROP TABLE totp_recovery_codes;
+DROP TABLE user_totp;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+    -- public: everyone, members: logged in users, private: only the user and moderators
+    ADD COLUMN profile_visibility TEXT NOT NULL DEFAULT 'public' CHECK (profile_visibility IN ('public', 'members', 'private')),
+    ADD COLUMN show_activity BOOLEAN NOT NULL DEFAULT TRUE; -- Whether others see the user's counts and recent activity
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN display_name,
+    DROP COLUMN profile_visibility,
+    DROP COLUMN show_activity;
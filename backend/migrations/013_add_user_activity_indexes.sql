@@ -0,0 +1,10 @@
+-- +goose Up
+-- Indexes for listing everything a user has created, newest first
+CREATE INDEX idx_topics_created_by_created_at ON topics(created_by, created_at DESC);
+CREATE INDEX idx_posts_created_by_created_at ON posts(created_by, created_at DESC);
+CREATE INDEX idx_comments_commented_by_created_at ON comments(commented_by, created_at DESC);
+
+-- +goose Down
+DROP INDEX idx_comments_commented_by_created_at;
+DROP INDEX idx_posts_created_by_created_at;
+DROP INDEX idx_topics_created_by_created_at;
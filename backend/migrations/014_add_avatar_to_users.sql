@@ -0,0 +1,6 @@
+-- +goose Up
+-- Null while the user has no uploaded avatar and is shown an identicon instead
+ALTER TABLE users ADD COLUMN avatar_updated_at TIMESTAMP(0) WITH TIME ZONE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN avatar_updated_at;
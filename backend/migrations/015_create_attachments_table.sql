@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE attachments (
+    attachment_id BIGSERIAL PRIMARY KEY,
+    uploaded_by BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    storage_key TEXT NOT NULL UNIQUE,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL, -- Sniffed from the file, not taken from the client
+    size_bytes BIGINT NOT NULL,
+    -- Both null until the attachment is used, unused uploads are cleaned up after a while
+    post_id BIGINT REFERENCES posts(post_id) ON DELETE CASCADE,
+    comment_id BIGINT REFERENCES comments(comment_id) ON DELETE CASCADE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (post_id IS NULL OR comment_id IS NULL)
+);
+
+CREATE INDEX idx_attachments_uploaded_by ON attachments(uploaded_by);
+CREATE INDEX idx_attachments_post_id ON attachments(post_id);
+CREATE INDEX idx_attachments_comment_id ON attachments(comment_id);
+CREATE INDEX idx_attachments_orphaned ON attachments(created_at) WHERE post_id IS NULL AND comment_id IS NULL;
+
+-- +goose Down
+DROP TABLE attachments;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Rendered and sanitized Markdown, cached next to the source.
+-- Null for rows written before rendering was added, these are rendered when read.
+ALTER TABLE posts ADD COLUMN body_html TEXT;
+ALTER TABLE comments ADD COLUMN body_html TEXT;
+
+-- +goose Down
+ALTER TABLE comments DROP COLUMN body_html;
+ALTER TABLE posts DROP COLUMN body_html;
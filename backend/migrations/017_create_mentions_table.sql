@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE mentions (
+    mention_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE, -- Mentioned user
+    mentioned_by BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    -- Exactly one of post_id and comment_id is set, depending on where the mention was written
+    post_id BIGINT REFERENCES posts(post_id) ON DELETE CASCADE,
+    comment_id BIGINT REFERENCES comments(comment_id) ON DELETE CASCADE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK ((post_id IS NULL) <> (comment_id IS NULL))
+);
+
+-- A user is mentioned at most once per post or comment, however often their name appears
+CREATE UNIQUE INDEX idx_mentions_post_user ON mentions(post_id, user_id) WHERE post_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_mentions_comment_user ON mentions(comment_id, user_id) WHERE comment_id IS NOT NULL;
+CREATE INDEX idx_mentions_user_created_at ON mentions(user_id, created_at DESC);
+
+ALTER TABLE notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check CHECK (type IN ('account_locked', 'mention'));
+
+-- +goose Down
+DELETE FROM notifications WHERE type = 'mention';
+ALTER TABLE notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check CHECK (type IN ('account_locked'));
+
+DROP TABLE mentions;
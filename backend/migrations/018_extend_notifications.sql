@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check
+    CHECK (type IN ('account_locked', 'mention', 'post_reply', 'comment_reply', 'moderation'));
+
+CREATE INDEX idx_notifications_user_unread ON notifications(user_id) WHERE read_at IS NULL; -- For unread counts
+
+-- Types a user has turned off, anything without a row is delivered
+CREATE TABLE notification_preferences (
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, type)
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP INDEX idx_notifications_user_unread;
+
+DELETE FROM notifications WHERE type IN ('post_reply', 'comment_reply', 'moderation');
+ALTER TABLE notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check CHECK (type IN ('account_locked', 'mention'));
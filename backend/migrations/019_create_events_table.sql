@@ -0,0 +1,30 @@
+-- +goose Up
+-- Real-time events, kept for a while so clients can resume with Last-Event-ID
+CREATE TABLE events (
+    event_id BIGSERIAL PRIMARY KEY,
+    channel TEXT NOT NULL, -- topic:{id}, post:{id} or user:{id}
+    type TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_events_channel_event_id ON events(channel, event_id);
+CREATE INDEX idx_events_created_at ON events(created_at); -- For pruning
+
+-- Every server instance LISTENs on forum_events and fetches the events its clients subscribe to
+-- +goose StatementBegin
+CREATE FUNCTION notify_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('forum_events', json_build_object('event_id', NEW.event_id, 'channel', NEW.channel)::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER events_notify AFTER INSERT ON events
+    FOR EACH ROW EXECUTE FUNCTION notify_event();
+
+-- +goose Down
+DROP TRIGGER events_notify ON events;
+DROP FUNCTION notify_event();
+DROP TABLE events;
@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE webhooks (
+    webhook_id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL, -- HMAC-SHA256 key for signing deliveries, shown to the admin once
+    event_types TEXT[] NOT NULL,
+    topic_id BIGINT REFERENCES topics(topic_id) ON DELETE CASCADE, -- Only events in this topic when set
+    created_by BIGINT REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Both the delivery queue and the log of every attempt's outcome
+CREATE TABLE webhook_deliveries (
+    delivery_id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(webhook_id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Also the lease of a claimed delivery
+    last_status_code INT,
+    last_error TEXT,
+    delivered_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_webhook_created_at ON webhook_deliveries(webhook_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
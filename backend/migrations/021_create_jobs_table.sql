@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE jobs (
+    job_id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    unique_key TEXT, -- At most one queued job per key, running ones do not count so later work is not lost
+    status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'dead')),
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP WITH TIME ZONE, -- Lease of a running job, another worker takes it over once expired
+    last_error TEXT,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX idx_jobs_unique_key ON jobs(unique_key) WHERE status = 'queued';
+CREATE INDEX idx_jobs_due ON jobs(run_at) WHERE status = 'queued';
+CREATE INDEX idx_jobs_leased ON jobs(locked_until) WHERE status = 'running';
+CREATE INDEX idx_jobs_status_created_at ON jobs(status, created_at DESC);
+
+-- +goose Down
+DROP TABLE jobs;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Domain events written in the same transaction as the change they describe
+CREATE TABLE outbox_events (
+    event_id BIGSERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    delivered_to TEXT[] NOT NULL DEFAULT '{}', -- Subscribers that have handled the event, skipped on retries
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Also the lease of a claimed event
+    last_error TEXT,
+    dispatched_at TIMESTAMP(0) WITH TIME ZONE, -- Set once every subscriber has handled it
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_outbox_events_pending ON outbox_events(next_attempt_at) WHERE dispatched_at IS NULL;
+CREATE INDEX idx_outbox_events_dispatched_at ON outbox_events(dispatched_at) WHERE dispatched_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE outbox_events;
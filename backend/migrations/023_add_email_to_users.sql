@@ -0,0 +1,29 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email TEXT; -- Null until the user adds one
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP(0) WITH TIME ZONE; -- Cleared whenever the email changes
+ALTER TABLE users ADD COLUMN sessions_revoked_at TIMESTAMP WITH TIME ZONE; -- Sessions issued before this are rejected
+
+-- The same address cannot be used twice, however it is capitalised
+CREATE UNIQUE INDEX idx_users_email ON users(LOWER(email));
+
+-- Single use links sent by email to verify an address or reset a password
+CREATE TABLE email_tokens (
+    token_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL CHECK (purpose IN ('verify_email', 'password_reset')),
+    token_hash TEXT NOT NULL UNIQUE, -- SHA-256 of the token, the token itself is only in the email
+    email TEXT NOT NULL, -- The address the token was sent to
+    expires_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_email_tokens_user_purpose ON email_tokens(user_id, purpose) WHERE used_at IS NULL;
+CREATE INDEX idx_email_tokens_expires_at ON email_tokens(expires_at); -- For purging
+
+-- +goose Down
+DROP TABLE email_tokens;
+DROP INDEX idx_users_email;
+ALTER TABLE users DROP COLUMN sessions_revoked_at;
+ALTER TABLE users DROP COLUMN email_verified_at;
+ALTER TABLE users DROP COLUMN email;
@@ -0,0 +1,40 @@
+-- +goose Up
+-- Topics a user follows, their new posts go in the user's digest
+CREATE TABLE topic_follows (
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    topic_id BIGINT NOT NULL REFERENCES topics(topic_id) ON DELETE CASCADE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, topic_id)
+);
+
+CREATE INDEX idx_topic_follows_topic_id ON topic_follows(topic_id);
+
+-- Users who get digest emails, anyone without a row gets none
+CREATE TABLE digest_subscriptions (
+    user_id BIGINT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    frequency TEXT NOT NULL CHECK (frequency IN ('daily', 'weekly')),
+    covered_until TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(), -- The next digest starts here
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_digest_subscriptions_covered_until ON digest_subscriptions(covered_until);
+
+-- Digests that were sent and what was in them, so nothing is sent twice
+CREATE TABLE digests (
+    digest_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    frequency TEXT NOT NULL,
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    post_ids BIGINT[] NOT NULL DEFAULT '{}',
+    comment_ids BIGINT[] NOT NULL DEFAULT '{}',
+    sent_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, period_end)
+);
+
+CREATE INDEX idx_digests_sent_at ON digests(sent_at); -- For purging
+
+-- +goose Down
+DROP TABLE digests;
+DROP TABLE digest_subscriptions;
+DROP TABLE topic_follows;
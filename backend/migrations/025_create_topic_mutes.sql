@@ -0,0 +1,11 @@
+-- +goose Up
+-- Topics a user has muted, hidden from their topic and post listings. A topic is followed or muted, not both.
+CREATE TABLE topic_mutes (
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    topic_id BIGINT NOT NULL REFERENCES topics(topic_id) ON DELETE CASCADE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, topic_id)
+);
+
+-- +goose Down
+DROP TABLE topic_mutes;
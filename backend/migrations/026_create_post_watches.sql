@@ -0,0 +1,25 @@
+-- +goose Up
+-- Posts a user watches, and how much of the discussion they are notified about
+CREATE TABLE post_watches (
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    post_id BIGINT NOT NULL REFERENCES posts(post_id) ON DELETE CASCADE,
+    -- all: every new comment, direct: only replies to the user, muted: nothing, not even direct replies
+    level TEXT NOT NULL DEFAULT 'all' CHECK (level IN ('all', 'direct', 'muted')),
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX idx_post_watches_post_id ON post_watches(post_id) WHERE level = 'all'; -- For notifying watchers of new comments
+CREATE INDEX idx_post_watches_user_created_at ON post_watches(user_id, created_at DESC);
+
+ALTER TABLE notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check
+    CHECK (type IN ('account_locked', 'mention', 'post_reply', 'comment_reply', 'moderation', 'watched_post'));
+
+-- +goose Down
+DELETE FROM notifications WHERE type = 'watched_post';
+ALTER TABLE notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check
+    CHECK (type IN ('account_locked', 'mention', 'post_reply', 'comment_reply', 'moderation'));
+
+DROP TABLE post_watches;
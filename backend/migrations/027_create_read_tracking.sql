@@ -0,0 +1,29 @@
+-- +goose Up
+-- How far into each post's comments a user has read
+CREATE TABLE post_reads (
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    post_id BIGINT NOT NULL REFERENCES posts(post_id) ON DELETE CASCADE,
+    last_read_comment_id BIGINT NOT NULL,
+    read_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, post_id)
+);
+
+-- A user's last visit to each topic. Positions are IDs rather than times, as created_at is only precise to the second.
+CREATE TABLE topic_visits (
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    topic_id BIGINT NOT NULL REFERENCES topics(topic_id) ON DELETE CASCADE,
+    visited_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    seen_post_id BIGINT NOT NULL DEFAULT 0, -- Posts after this one are new
+    -- Comments up to this ID count as read on every post in the topic, so marking a topic read is one row however large it is
+    read_comment_id BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, topic_id)
+);
+
+CREATE INDEX idx_posts_topic_post_id ON posts(topic_id, post_id); -- For finding posts after a visit
+CREATE INDEX idx_comments_post_comment_id ON comments(post_id, comment_id); -- For counting comments after a read position
+
+-- +goose Down
+DROP INDEX idx_comments_post_comment_id;
+DROP INDEX idx_posts_topic_post_id;
+DROP TABLE topic_visits;
+DROP TABLE post_reads;
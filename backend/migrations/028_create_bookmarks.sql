@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE bookmark_folders (
+    folder_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT bookmark_folders_user_name_key UNIQUE (user_id, name)
+);
+
+-- A saved post or comment, exactly one of the two
+CREATE TABLE bookmarks (
+    bookmark_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    post_id BIGINT REFERENCES posts(post_id) ON DELETE CASCADE,
+    comment_id BIGINT REFERENCES comments(comment_id) ON DELETE CASCADE,
+    folder_id BIGINT REFERENCES bookmark_folders(folder_id) ON DELETE SET NULL, -- Deleting a folder keeps its bookmarks
+    note TEXT NOT NULL DEFAULT '',
+    remind_at TIMESTAMP(0) WITH TIME ZONE, -- Cleared once the reminder is sent
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK ((post_id IS NULL) <> (comment_id IS NULL))
+);
+
+CREATE UNIQUE INDEX idx_bookmarks_user_post_id ON bookmarks(user_id, post_id) WHERE post_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_bookmarks_user_comment_id ON bookmarks(user_id, comment_id) WHERE comment_id IS NOT NULL;
+CREATE INDEX idx_bookmarks_user_created_at ON bookmarks(user_id, created_at DESC);
+CREATE INDEX idx_bookmarks_remind_at ON bookmarks(remind_at) WHERE remind_at IS NOT NULL; -- For finding due reminders
+
+ALTER TABLE notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check
+    CHECK (type IN ('account_locked', 'mention', 'post_reply', 'comment_reply', 'moderation', 'watched_post', 'bookmark_reminder'));
+
+-- +goose Down
+DELETE FROM notifications WHERE type = 'bookmark_reminder';
+ALTER TABLE notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check
+    CHECK (type IN ('account_locked', 'mention', 'post_reply', 'comment_reply', 'moderation', 'watched_post'));
+
+DROP TABLE bookmarks;
+DROP TABLE bookmark_folders;
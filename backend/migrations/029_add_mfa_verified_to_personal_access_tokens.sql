@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE personal_access_tokens
+    ADD COLUMN mfa_verified BOOLEAN NOT NULL DEFAULT FALSE; -- Created from a session that completed two-factor authentication
+
+-- +goose Down
+ALTER TABLE personal_access_tokens DROP COLUMN mfa_verified;
@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// emailTokenPattern finds the token in the link of a verification or reset email
+var emailTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+func TestAccountEmails(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+	server := SharedSMTP(t)
+	ctx := context.Background()
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	login := func(username, password string) *httptest.ResponseRecorder {
+		return do("POST", "/login", "", map[string]string{"username": username, "password": password})
+	}
+	// latestToken waits for the n-th email to an address and returns the token in its link
+	latestToken := func(t *testing.T, address string, n int) (string, string) {
+		var emails []ReceivedEmail
+		assert.Eventually(t, func() bool {
+			emails = server.To(address)
+			return len(emails) >= n
+		}, 5*time.Second, 20*time.Millisecond)
+		if len(emails) < n {
+			return "", ""
+		}
+		headers, text, _, err := emailParts(emails[n-1])
+		assert.NoError(t, err)
+		match := emailTokenPattern.FindStringSubmatch(text)
+		if !assert.NotNil(t, match, text) {
+			return "", ""
+		}
+		return headers.Get("Subject"), match[1]
+	}
+	profileOf := func(username, token string) map[string]interface{} {
+		return decode(do("GET", "/users/"+username, token, nil))
+	}
+
+	// Test Case 1: Signing up with an email sends a verification link
+	t.Run("Sign Up With Email", func(t *testing.T) {
+		w := do("POST", "/users", "", map[string]string{"username": "account_alice", "password": "correct horse", "email": "Alice@Example.com"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		subject, token := latestToken(t, "Alice@Example.com", 1)
+		assert.Equal(t, "Verify your email address", subject)
+		assert.NotEmpty(t, token)
+
+		// Addresses are unique however they are capitalised
+		w = do("POST", "/users", "", map[string]string{"username": "account_copycat", "email": "alice@example.com"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = do("POST", "/users", "", map[string]string{"username": "account_bad", "email": "Alice <alice@example.com>"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		session := decode(login("account_alice", "correct horse"))["token"].(string)
+		profile := profileOf("account_alice", session)
+		assert.Equal(t, "Alice@Example.com", profile["email"])
+		assert.Equal(t, false, profile["email_verified"])
+
+		// Only the owner sees their address
+		assert.Nil(t, profileOf("account_alice", "")["email"])
+	})
+
+	// Test Case 2: Verification links work once
+	t.Run("Verify Email", func(t *testing.T) {
+		_, token := latestToken(t, "Alice@Example.com", 1)
+
+		w := do("POST", "/auth/email/verify", "", map[string]string{"token": "not-a-real-token"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do("POST", "/auth/email/verify", "", map[string]string{"token": token})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Alice@Example.com", decode(w)["email"])
+
+		w = do("POST", "/auth/email/verify", "", map[string]string{"token": token})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		session := decode(login("account_alice", "correct horse"))["token"].(string)
+		assert.Equal(t, true, profileOf("account_alice", session)["email_verified"])
+	})
+
+	// Test Case 3: Changing the address needs a new verification, and old links stop working
+	t.Run("Change Email", func(t *testing.T) {
+		bob := decode(login("account_bob", ""))["token"].(string)
+
+		w := do("PUT", "/users/me/email", bob, map[string]string{"email": "bob.old@example.com"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, false, decode(w)["email_verified"])
+		_, oldToken := latestToken(t, "bob.old@example.com", 1)
+
+		w = do("PUT", "/users/me/email", bob, map[string]string{"email": "alice@example.com"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = do("PUT", "/users/me/email", bob, map[string]string{"email": "bob@example.com"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		_, newToken := latestToken(t, "bob@example.com", 1)
+
+		w = do("POST", "/auth/email/verify", "", map[string]string{"token": oldToken})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = do("POST", "/auth/email/verify", "", map[string]string{"token": newToken})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Setting the verified address again does not send another link
+		w = do("PUT", "/users/me/email", bob, map[string]string{"email": "BOB@example.com"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, true, decode(w)["email_verified"])
+	})
+
+	// Test Case 4: Forgot password answers the same way whether or not the account exists
+	t.Run("Forgot Password", func(t *testing.T) {
+		w := do("POST", "/users", "", map[string]string{"username": "account_carol", "email": "carol@example.com"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var messages []string
+		for _, email := range []string{"nobody@example.com", "carol@example.com", "alice@EXAMPLE.com"} {
+			w := do("POST", "/auth/password/forgot", "", map[string]string{"email": email})
+			assert.Equal(t, http.StatusAccepted, w.Code)
+			messages = append(messages, decode(w)["message"].(string))
+		}
+		assert.Equal(t, messages[0], messages[1])
+		assert.Equal(t, messages[0], messages[2])
+
+		// Only the verified address gets a reset link
+		subject, _ := latestToken(t, "Alice@Example.com", 2)
+		assert.Equal(t, "Reset your password", subject)
+		var resetTokens int
+		err := dbConn.QueryRow(ctx, "SELECT COUNT(*) FROM email_tokens WHERE purpose = 'password_reset'").Scan(&resetTokens)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, resetTokens)
+	})
+
+	// Test Case 5: Resetting the password logs out existing sessions and revokes personal access tokens
+	t.Run("Reset Password", func(t *testing.T) {
+		_, token := latestToken(t, "Alice@Example.com", 2)
+		oldSession := decode(login("account_alice", "correct horse"))["token"].(string)
+		w := do("GET", "/users/me/tokens", oldSession, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = do("POST", "/users/me/tokens", oldSession, map[string]interface{}{"name": "bot", "scopes": []string{"read"}})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		personalToken, _ := decode(w)["token"].(string)
+		assert.Equal(t, http.StatusOK, do("GET", "/notifications", personalToken, nil).Code)
+
+		// Sessions are revoked by the second they were issued in
+		time.Sleep(time.Second)
+
+		w = do("POST", "/auth/password/reset", "", map[string]string{"token": token, "password": "short"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do("POST", "/auth/password/reset", "", map[string]string{"token": token, "password": "battery staple"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = do("GET", "/users/me/tokens", oldSession, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, http.StatusUnauthorized, do("GET", "/notifications", personalToken, nil).Code)
+
+		assert.Equal(t, http.StatusUnauthorized, login("account_alice", "correct horse").Code)
+		w = login("account_alice", "battery staple")
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = do("GET", "/users/me/tokens", decode(w)["token"].(string), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// The link only works once
+		w = do("POST", "/auth/password/reset", "", map[string]string{"token": token, "password": "another password"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test Case 6: Expired links are rejected
+	t.Run("Expired Link", func(t *testing.T) {
+		w := do("POST", "/auth/password/forgot", "", map[string]string{"email": "bob@example.com"})
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		_, token := latestToken(t, "bob@example.com", 2)
+
+		_, err := dbConn.Exec(ctx, "UPDATE email_tokens SET expires_at = NOW() - INTERVAL '1 minute' WHERE purpose = 'password_reset' AND used_at IS NULL")
+		assert.NoError(t, err)
+		w = do("POST", "/auth/password/reset", "", map[string]string{"token": token, "password": "battery staple"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
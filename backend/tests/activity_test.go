@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserActivity(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username string) (string, int64) {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": username}).Body.Bytes(), &resp)
+		return resp["token"].(string), int64(resp["user_id"].(float64))
+	}
+	list := func(url, token string) []map[string]interface{} {
+		w := do("GET", url, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var items []map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &items)
+		return items
+	}
+
+	author, authorID := login("activity_author")
+	other, _ := login("activity_other")
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(do("POST", "/topics", author, map[string]string{"name": "activityTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	topicID := int64(resp["topic_id"].(float64))
+
+	var postIDs []int64
+	for i := 1; i <= 3; i++ {
+		_ = json.Unmarshal(do("POST", fmt.Sprintf("/topics/%d/posts", topicID), author, map[string]string{"title": fmt.Sprintf("post%d", i), "body": "Body"}).Body.Bytes(), &resp)
+		postIDs = append(postIDs, int64(resp["post_id"].(float64)))
+		// Timestamps are stored to the second
+		time.Sleep(1 * time.Second)
+	}
+	do("POST", fmt.Sprintf("/posts/%d/comments", postIDs[0]), author, map[string]string{"body": "a comment"})
+	do("DELETE", fmt.Sprintf("/posts/%d", postIDs[1]), author, nil)
+
+	postsURL := fmt.Sprintf("/users/%d/posts", authorID)
+
+	// Test Case 1: Removed posts are hidden from the public, newest first
+	t.Run("Public Posts", func(t *testing.T) {
+		posts := list(postsURL, "")
+		assert.Len(t, posts, 2)
+		assert.Equal(t, "post3", posts[0]["title"])
+		assert.Equal(t, "post1", posts[1]["title"])
+
+		posts = list(postsURL, other)
+		assert.Len(t, posts, 2)
+	})
+
+	// Test Case 2: The author sees their removed posts
+	t.Run("Author Sees Removed", func(t *testing.T) {
+		posts := list(postsURL, author)
+		assert.Len(t, posts, 3)
+		assert.Equal(t, "removed", posts[1]["status"])
+	})
+
+	// Test Case 3: Sorting and pagination
+	t.Run("Sort And Paginate", func(t *testing.T) {
+		posts := list(postsURL+"?sort=oldest&limit=1&offset=1", author)
+		assert.Len(t, posts, 1)
+		assert.Equal(t, "post2", posts[0]["title"])
+
+		w := do("GET", postsURL+"?sort=popular", "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test Case 4: Comments and topics
+	t.Run("Comments And Topics", func(t *testing.T) {
+		comments := list(fmt.Sprintf("/users/%d/comments", authorID), "")
+		assert.Len(t, comments, 1)
+		assert.Equal(t, "post1", comments[0]["post_title"])
+
+		topics := list(fmt.Sprintf("/users/%d/topics", authorID), "")
+		assert.Len(t, topics, 1)
+		assert.Equal(t, "activityTopic", topics[0]["name"])
+	})
+
+	// Test Case 5: Moderators see removed posts
+	t.Run("Moderator Sees Removed", func(t *testing.T) {
+		_, err := dbConn.Exec(context.Background(), "UPDATE users SET role = 'moderator' WHERE username = 'activity_other'")
+		assert.NoError(t, err)
+
+		posts := list(postsURL, other)
+		assert.Len(t, posts, 3)
+	})
+
+	// Test Case 6: Hidden activity and unknown users
+	t.Run("Privacy And Not Found", func(t *testing.T) {
+		do("PATCH", "/users/me", author, map[string]bool{"show_activity": false})
+
+		w := do("GET", postsURL, "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("GET", "/users/999999999/posts", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
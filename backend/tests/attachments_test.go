@@ -0,0 +1,319 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/attachment"
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// testJPEGWithEXIF encodes a width x height JPEG carrying an EXIF orientation tag and some private text
+func testJPEGWithEXIF(t *testing.T, width, height int, orientation uint16, private string) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+
+	// Big endian TIFF header with one IFD entry holding the orientation
+	var tiff bytes.Buffer
+	tiff.WriteString("MM\x00\x2a")
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(8))
+	_ = binary.Write(&tiff, binary.BigEndian, uint16(1))
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(1))
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(0))
+	tiff.WriteString(private)
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	// Insert the APP1 segment straight after the start of image marker
+	data := buf.Bytes()
+	return append(append([]byte{0xFF, 0xD8}, segment...), data[2:]...)
+}
+
+func TestAttachments(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.RateLimit.Enabled = false
+	cfg.Storage.LocalDir = t.TempDir()
+	r := StartRouter(t, cfg, dbConn)
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	upload := func(token, filename string, data []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/attachments?filename="+filename, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	uploadID := func(token, filename string, data []byte) int64 {
+		w := upload(token, filename, data)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return int64(resp["attachment_id"].(float64))
+	}
+	login := func(username string) string {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": username}).Body.Bytes(), &resp)
+		return resp["token"].(string)
+	}
+
+	token := login("attach_user")
+	other := login("attach_other")
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(do("POST", "/topics", token, map[string]string{"name": "attachTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	topicID := int64(resp["topic_id"].(float64))
+
+	// Test Case 1: Uploads are sniffed, not trusted
+	t.Run("Upload And Sniff", func(t *testing.T) {
+		w := upload(token, "notes.html", []byte("just some plain notes"))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, "text/plain", resp["content_type"])
+		assert.Equal(t, "notes.txt", resp["filename"])
+		assert.True(t, strings.HasPrefix(resp["url"].(string), "/attachments/"))
+
+		// Text files are downloaded, never rendered
+		w = do("GET", resp["url"].(string), "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "just some plain notes", w.Body.String())
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+		assert.True(t, strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment"))
+
+		w = upload(token, "page.txt", []byte("<html><script>alert(1)</script></html>"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+		w = upload(token, "empty.txt", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test Case 2: Multipart uploads
+	t.Run("Upload Multipart", func(t *testing.T) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		part, _ := mw.CreateFormFile("file", "../../diagram.png")
+		_, _ = part.Write(testPNG(t, 40, 40))
+		_ = mw.Close()
+
+		req := httptest.NewRequest("POST", "/attachments", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, "diagram.png", resp["filename"])
+		assert.Equal(t, "image/png", resp["content_type"])
+	})
+
+	// Test Case 3: EXIF data is stripped and the orientation applied
+	t.Run("Strip EXIF", func(t *testing.T) {
+		w := upload(token, "photo.jpg", testJPEGWithEXIF(t, 40, 20, 6, "GPS 1.3521N 103.8198E"))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+
+		w = do("GET", resp["url"].(string), "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(w.Header().Get("Content-Disposition"), "inline"))
+		assert.NotContains(t, w.Body.String(), "GPS")
+		assert.NotContains(t, w.Body.String(), "Exif")
+
+		// Rotated 90 degrees, so the width and height swap
+		img, err := jpeg.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+		assert.NoError(t, err)
+		assert.Equal(t, 20, img.Width)
+		assert.Equal(t, 40, img.Height)
+	})
+
+	// Test Case 4: Attaching to posts and comments
+	t.Run("Attach To Post And Comment", func(t *testing.T) {
+		first := uploadID(token, "a.txt", []byte("first file"))
+		second := uploadID(token, "b.txt", []byte("second file"))
+
+		w := do("POST", fmt.Sprintf("/topics/%d/posts", topicID), token, map[string]interface{}{
+			"title": "With files", "body": "See attached", "attachment_ids": []int64{first, second},
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		postID := int64(resp["post_id"].(float64))
+		assert.Len(t, resp["attachments"], 2)
+
+		_ = json.Unmarshal(do("GET", fmt.Sprintf("/posts/%d", postID), "", nil).Body.Bytes(), &resp)
+		attachments := resp["attachments"].([]interface{})
+		assert.Len(t, attachments, 2)
+		assert.Equal(t, "a.txt", attachments[0].(map[string]interface{})["filename"])
+
+		// An attachment can only be used once
+		w = do("POST", fmt.Sprintf("/topics/%d/posts", topicID), token, map[string]interface{}{
+			"title": "Again", "body": "Body", "attachment_ids": []int64{first},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// Nor by someone else
+		third := uploadID(token, "c.txt", []byte("third file"))
+		w = do("POST", fmt.Sprintf("/posts/%d/comments", postID), other, map[string]interface{}{
+			"body": "Stolen", "attachment_ids": []int64{third},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do("POST", fmt.Sprintf("/posts/%d/comments", postID), token, map[string]interface{}{
+			"body": "One more", "attachment_ids": []int64{third},
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var comments []map[string]interface{}
+		_ = json.Unmarshal(do("GET", fmt.Sprintf("/posts/%d/comments", postID), "", nil).Body.Bytes(), &comments)
+		assert.Len(t, comments, 1)
+		assert.Len(t, comments[0]["attachments"], 1)
+	})
+
+	// Test Case 5: Download links must be signed and unexpired
+	t.Run("Signed URLs", func(t *testing.T) {
+		id := uploadID(token, "d.txt", []byte("signed"))
+
+		w := do("GET", fmt.Sprintf("/attachments/%d", id), "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// A signature for one attachment does not open another
+		url := attachment.NewSigner("secret", time.Hour).URL(id, time.Now())
+		w = do("GET", strings.Replace(url, fmt.Sprintf("/attachments/%d", id), fmt.Sprintf("/attachments/%d", id+1), 1), "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("GET", url, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		expired := attachment.NewSigner("secret", -time.Minute).URL(id, time.Now())
+		w = do("GET", expired, "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "expired")
+	})
+
+	// Test Case 6: Orphaned uploads are cleaned up, attached ones are kept
+	t.Run("Cleanup", func(t *testing.T) {
+		orphan := uploadID(token, "orphan.txt", []byte("never used"))
+		_, err := dbConn.Exec(context.Background(), "UPDATE attachments SET created_at = NOW() - INTERVAL '2 days'")
+		assert.NoError(t, err)
+
+		q := database.New(dbConn)
+		orphanRow, err := q.GetAttachment(context.Background(), orphan)
+		assert.NoError(t, err)
+
+		cleaner := attachment.NewCleaner(q, storage.NewLocalStorage(cfg.Storage.LocalDir), 24*time.Hour)
+		deleted, err := cleaner.Sweep(context.Background(), time.Now())
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, deleted, 1)
+
+		_, err = q.GetAttachment(context.Background(), orphan)
+		assert.Error(t, err)
+		_, err = os.Stat(filepath.Join(cfg.Storage.LocalDir, filepath.FromSlash(orphanRow.StorageKey)))
+		assert.True(t, os.IsNotExist(err))
+
+		var remaining int
+		_ = dbConn.QueryRow(context.Background(), "SELECT COUNT(*) FROM attachments").Scan(&remaining)
+		assert.Equal(t, 3, remaining)
+	})
+
+	// Test Case 7: Size limits and quotas
+	t.Run("Limits", func(t *testing.T) {
+		limited := *cfg
+		limited.Uploads.MaxAttachmentBytes = 64
+		limited.Uploads.AttachmentQuotaBytes = 100
+		lr := StartRouter(t, &limited, dbConn)
+		send := func(data []byte) int {
+			req := httptest.NewRequest("POST", "/attachments", bytes.NewReader(data))
+			req.Header.Set("Authorization", "Bearer "+other)
+			w := httptest.NewRecorder()
+			lr.ServeHTTP(w, req)
+			_, _ = io.Copy(io.Discard, w.Body)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, send([]byte(strings.Repeat("a", 65))))
+		assert.Equal(t, http.StatusCreated, send([]byte(strings.Repeat("a", 60))))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, send([]byte(strings.Repeat("a", 60))))
+	})
+
+	// Test Case 8: Parallel uploads cannot together exceed the quota
+	t.Run("Concurrent Quota", func(t *testing.T) {
+		limited := *cfg
+		limited.Uploads.AttachmentQuotaBytes = 100
+		lr := StartRouter(t, &limited, dbConn)
+		racer := login("attach_racer")
+
+		var wg sync.WaitGroup
+		codes := make([]int, 8)
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				req := httptest.NewRequest("POST", "/attachments", bytes.NewReader([]byte(strings.Repeat("a", 60))))
+				req.Header.Set("Authorization", "Bearer "+racer)
+				w := httptest.NewRecorder()
+				lr.ServeHTTP(w, req)
+				codes[i] = w.Code
+			}(i)
+		}
+		wg.Wait()
+
+		created := 0
+		for _, code := range codes {
+			if code == http.StatusCreated {
+				created++
+			} else {
+				assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+			}
+		}
+		assert.Equal(t, 1, created)
+	})
+}
@@ -8,8 +8,6 @@ import (
 	"os"
 	"testing"
 
-	"github.com/DamienFooxx/CVWOForum/internal/database"
-	"github.com/DamienFooxx/CVWOForum/internal/router"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,8 +22,7 @@ func TestLogin(t *testing.T) {
 	dbConn := SetupDB(t)
 	defer dbConn.Close()
 
-	queries := database.New(dbConn)
-	r := router.NewRouter(queries)
+	r := SetupRouter(t, dbConn)
 
 	// Clear DB
 	ClearDB(t, dbConn)
@@ -33,7 +30,8 @@ func TestLogin(t *testing.T) {
 	// Test Case 1: Login New User
 	t.Run("Login New User", func(t *testing.T) {
 		payload := []byte(`{
-			"username": "testuser"
+			"username": "testuser",
+			"password": "password"
 		}`)
 		req, err := http.NewRequest("POST", "/login", bytes.NewBuffer(payload))
 		if err != nil {
@@ -57,7 +55,8 @@ func TestLogin(t *testing.T) {
 	// Test Case 2: Login Existing User
 	t.Run("Login Existing User", func(t *testing.T) {
 		payload := []byte(`{
-			"username": "testuser"
+			"username": "testuser",
+			"password": "password"
 		}`)
 		req, err := http.NewRequest("POST", "/login", bytes.NewBuffer(payload))
 		if err != nil {
@@ -95,4 +94,20 @@ func TestLogin(t *testing.T) {
 		// Check if bad request was sent back
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
+
+	// Test Case 4: Accounts without a password only get the session from their first login
+	t.Run("Login Passwordless Account Refused", func(t *testing.T) {
+		login := func() *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(`{"username": "nopassword"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		assert.Equal(t, http.StatusOK, login().Code)
+		w := login()
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.NotContains(t, w.Body.String(), "token")
+	})
 }
@@ -0,0 +1,226 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPNG encodes a solid width x height PNG
+func testPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 50, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestAvatars(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Helpers
+	upload := func(token, contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/users/me/avatar", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	var resp map[string]interface{}
+	body, _ := json.Marshal(map[string]string{"username": "avatar_user"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/login", bytes.NewReader(body)))
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	token := resp["token"].(string)
+	userID := int64(resp["user_id"].(float64))
+	avatarURL := fmt.Sprintf("/users/%d/avatar", userID)
+
+	// Test Case 1: Users without an upload get a deterministic identicon
+	t.Run("Identicon Fallback", func(t *testing.T) {
+		first := get(avatarURL)
+		assert.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, "image/png", first.Header().Get("Content-Type"))
+		assert.Equal(t, "nosniff", first.Header().Get("X-Content-Type-Options"))
+
+		second := get(avatarURL)
+		assert.Equal(t, first.Body.Bytes(), second.Body.Bytes())
+
+		cfg, err := png.DecodeConfig(bytes.NewReader(get(avatarURL + "?size=64").Body.Bytes()))
+		assert.NoError(t, err)
+		assert.Equal(t, 64, cfg.Width)
+
+		assert.Equal(t, http.StatusBadRequest, get(avatarURL+"?size=100").Code)
+		assert.Equal(t, http.StatusNotFound, get("/users/999999999/avatar").Code)
+	})
+
+	// Test Case 2: Upload as a raw body, resized and served at every size
+	t.Run("Upload Raw", func(t *testing.T) {
+		w := upload(token, "image/png", testPNG(t, 300, 200))
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.True(t, strings.HasPrefix(resp["avatar_url"].(string), avatarURL+"?v="))
+
+		w = get(avatarURL)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "public, max-age=86400", w.Header().Get("Cache-Control"))
+		cfg, err := png.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+		assert.NoError(t, err)
+		assert.Equal(t, 256, cfg.Width)
+		assert.Equal(t, 256, cfg.Height)
+
+		// The profile links to the new avatar
+		_ = json.Unmarshal(get("/users/avatar_user").Body.Bytes(), &resp)
+		assert.True(t, strings.HasPrefix(resp["avatar_url"].(string), avatarURL+"?v="))
+	})
+
+	// Test Case 3: Upload as a multipart form
+	t.Run("Upload Multipart", func(t *testing.T) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		header := make(map[string][]string)
+		header["Content-Disposition"] = []string{`form-data; name="avatar"; filename="me.png"`}
+		header["Content-Type"] = []string{"image/png"}
+		part, _ := mw.CreatePart(header)
+		_, _ = part.Write(testPNG(t, 64, 64))
+		_ = mw.Close()
+
+		w := upload(token, mw.FormDataContentType(), buf.Bytes())
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	// Test Case 4: Rejected uploads
+	t.Run("Invalid Uploads", func(t *testing.T) {
+		// Not an image
+		w := upload(token, "image/png", []byte("<html><script>alert(1)</script></html>"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+		// Content type does not match the contents
+		w = upload(token, "image/jpeg", testPNG(t, 64, 64))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+		// Too small
+		w = upload(token, "image/png", testPNG(t, 8, 8))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// Not logged in
+		req := httptest.NewRequest("PUT", "/users/me/avatar", bytes.NewReader(testPNG(t, 64, 64)))
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	// Test Case 5: Deleting goes back to the identicon
+	t.Run("Delete", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/users/me/avatar", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = get(avatarURL)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "public, max-age=3600", w.Header().Get("Cache-Control"))
+	})
+}
+
+func TestS3Storage(t *testing.T) {
+	// A fake S3 endpoint keeping objects in memory
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") || r.Header.Get("X-Amz-Date") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(data)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	store := storage.New(config.StorageConfig{
+		Backend: "s3",
+		S3: config.S3Config{
+			Endpoint:        server.URL,
+			Region:          "us-east-1",
+			Bucket:          "forum",
+			AccessKeyID:     "key",
+			SecretAccessKey: "secret",
+			PathStyle:       true,
+		},
+	})
+	ctx := context.Background()
+
+	// Test Case 1: Objects round trip under the bucket path
+	t.Run("Put And Get", func(t *testing.T) {
+		err := store.Put(ctx, "avatars/1/256.png", strings.NewReader("data"), "image/png")
+		assert.NoError(t, err)
+		assert.Contains(t, objects, "/forum/avatars/1/256.png")
+
+		file, err := store.Get(ctx, "avatars/1/256.png")
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(file)
+		_ = file.Close()
+		assert.Equal(t, "data", string(data))
+	})
+
+	// Test Case 2: Missing and deleted objects
+	t.Run("Not Found And Delete", func(t *testing.T) {
+		_, err := store.Get(ctx, "avatars/2/256.png")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+
+		assert.NoError(t, store.Delete(ctx, "avatars/1/256.png"))
+		_, err = store.Get(ctx, "avatars/1/256.png")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
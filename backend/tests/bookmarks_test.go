@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/jobs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookmarks(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+	ctx := context.Background()
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username string) (string, int64) {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": username}).Body.Bytes(), &resp)
+		return resp["token"].(string), int64(resp["user_id"].(float64))
+	}
+	list := func(token, query string) []map[string]interface{} {
+		w := do("GET", "/users/me/bookmarks"+query, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var bookmarks []map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &bookmarks)
+		return bookmarks
+	}
+
+	author, _ := login("bookmark_author")
+	reader, readerID := login("bookmark_reader")
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(do("POST", "/topics", author, map[string]string{"name": "bookmarkTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	topicID := int64(resp["topic_id"].(float64))
+	_ = json.Unmarshal(do("POST", fmt.Sprintf("/topics/%d/posts", topicID), author, map[string]string{"title": "Saved", "body": "Post body"}).Body.Bytes(), &resp)
+	postID := int64(resp["post_id"].(float64))
+	_ = json.Unmarshal(do("POST", fmt.Sprintf("/posts/%d/comments", postID), author, map[string]string{"body": "Comment body"}).Body.Bytes(), &resp)
+	commentID := int64(resp["comment_id"].(float64))
+
+	// Test Case 1: Posts and comments can be saved with a note, once each
+	t.Run("Bookmark Posts And Comments", func(t *testing.T) {
+		w := do("PUT", fmt.Sprintf("/posts/%d/bookmark", postID), reader, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = do("PUT", fmt.Sprintf("/comments/%d/bookmark", commentID), reader, map[string]string{"note": "Good point"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, float64(postID), resp["post_id"])
+
+		// Saving again updates the bookmark
+		w = do("PUT", fmt.Sprintf("/posts/%d/bookmark", postID), reader, map[string]string{"note": "Read later"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		bookmarks := list(reader, "")
+		if assert.Len(t, bookmarks, 2) {
+			assert.Equal(t, float64(commentID), bookmarks[0]["comment_id"])
+			assert.Equal(t, "Comment body", bookmarks[0]["body"])
+			assert.Equal(t, "Saved", bookmarks[0]["title"])
+			assert.Nil(t, bookmarks[1]["comment_id"])
+			assert.Equal(t, "Read later", bookmarks[1]["note"])
+		}
+		assert.Len(t, list(reader, "?limit=1"), 1)
+		assert.Empty(t, list(author, ""))
+
+		w = do("PUT", "/posts/999999/bookmark", reader, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = do("PUT", fmt.Sprintf("/posts/%d/bookmark", postID), "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = do("DELETE", fmt.Sprintf("/comments/%d/bookmark", commentID), reader, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Len(t, list(reader, ""), 1)
+	})
+
+	// Test Case 2: Bookmarks can be filed in the user's own folders
+	t.Run("Folders", func(t *testing.T) {
+		w := do("POST", "/users/me/bookmark-folders", reader, map[string]string{"name": "Recipes"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		folderID := int64(resp["folder_id"].(float64))
+		w = do("POST", "/users/me/bookmark-folders", reader, map[string]string{"name": "Recipes"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		// Folders belong to one user
+		w = do("PUT", fmt.Sprintf("/posts/%d/bookmark", postID), author, map[string]int64{"folder_id": folderID})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		do("PUT", fmt.Sprintf("/comments/%d/bookmark", commentID), reader, nil)
+		w = do("PUT", fmt.Sprintf("/posts/%d/bookmark", postID), reader, map[string]int64{"folder_id": folderID})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		filed := list(reader, fmt.Sprintf("?folder_id=%d", folderID))
+		if assert.Len(t, filed, 1) {
+			assert.Equal(t, float64(postID), filed[0]["post_id"])
+			assert.Equal(t, "Recipes", filed[0]["folder_name"])
+		}
+		w = do("GET", "/users/me/bookmark-folders", reader, nil)
+		var folders []map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &folders)
+		if assert.Len(t, folders, 1) {
+			assert.Equal(t, float64(1), folders[0]["bookmark_count"])
+		}
+
+		// Deleting the folder keeps its bookmarks
+		w = do("DELETE", fmt.Sprintf("/users/me/bookmark-folders/%d", folderID), reader, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		for _, b := range list(reader, "") {
+			assert.Nil(t, b["folder_id"])
+		}
+		w = do("DELETE", fmt.Sprintf("/users/me/bookmark-folders/%d", folderID), reader, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	// Test Case 3: Reminders arrive as notifications once they are due
+	t.Run("Reminders", func(t *testing.T) {
+		w := do("PUT", fmt.Sprintf("/posts/%d/bookmark", postID), reader, map[string]string{"remind_at": time.Now().Add(-time.Hour).Format(time.RFC3339)})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = do("PUT", fmt.Sprintf("/posts/%d/bookmark", postID), reader, map[string]string{"remind_at": "in 3 days"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		remindAt := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+		w = do("PUT", fmt.Sprintf("/posts/%d/bookmark", postID), reader, map[string]string{"note": "Try this", "remind_at": remindAt.Format(time.RFC3339)})
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, remindAt.Format(time.RFC3339), resp["remind_at"])
+
+		// Three days pass
+		_, err := dbConn.Exec(ctx, "UPDATE bookmarks SET remind_at = NOW() - INTERVAL '1 minute' WHERE post_id = $1", postID)
+		assert.NoError(t, err)
+		queue := jobs.NewQueue(database.New(dbConn), config.JobsConfig{MaxAttempts: 3})
+		_, err = jobs.Enqueue(ctx, queue, jobs.SendBookmarkReminders, struct{}{})
+		assert.NoError(t, err)
+
+		var payload map[string]interface{}
+		assert.Eventually(t, func() bool {
+			err := dbConn.QueryRow(ctx, "SELECT payload FROM notifications WHERE user_id = $1 AND type = 'bookmark_reminder'", readerID).Scan(&payload)
+			return err == nil
+		}, 5*time.Second, 50*time.Millisecond)
+		assert.Equal(t, float64(postID), payload["post_id"])
+		assert.Equal(t, "Try this", payload["note"])
+
+		// Each reminder is sent once
+		for _, b := range list(reader, "") {
+			assert.Nil(t, b["remind_at"])
+		}
+	})
+
+	// Test Case 4: Reminders for removed comments are cleared without a notification
+	t.Run("Removed Reminders Skipped", func(t *testing.T) {
+		w := do("PUT", fmt.Sprintf("/comments/%d/bookmark", commentID), reader, map[string]string{"remind_at": time.Now().Add(time.Hour).Format(time.RFC3339)})
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = do("DELETE", fmt.Sprintf("/comments/%d", commentID), author, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		_, err := dbConn.Exec(ctx, "UPDATE bookmarks SET remind_at = NOW() - INTERVAL '1 minute' WHERE comment_id = $1", commentID)
+		assert.NoError(t, err)
+		queue := jobs.NewQueue(database.New(dbConn), config.JobsConfig{MaxAttempts: 3})
+		_, err = jobs.Enqueue(ctx, queue, jobs.SendBookmarkReminders, struct{}{})
+		assert.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			var cleared bool
+			err := dbConn.QueryRow(ctx, "SELECT remind_at IS NULL FROM bookmarks WHERE comment_id = $1", commentID).Scan(&cleared)
+			return err == nil && cleared
+		}, 5*time.Second, 50*time.Millisecond)
+
+		var count int
+		err = dbConn.QueryRow(ctx, "SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND type = 'bookmark_reminder'", readerID).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
@@ -8,8 +8,6 @@ import (
 	"net/http/httptest"
 	"testing"
 
-	"github.com/DamienFooxx/CVWOForum/internal/database"
-	"github.com/DamienFooxx/CVWOForum/internal/router"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,8 +16,7 @@ func TestComments(t *testing.T) {
 	defer dbConn.Close()
 	ClearDB(t, dbConn)
 
-	queries := database.New(dbConn)
-	r := router.NewRouter(queries)
+	r := SetupRouter(t, dbConn)
 
 	// Helpers
 	getToken := func(username string) string {
@@ -202,7 +199,14 @@ func TestComments(t *testing.T) {
 		wReg := httptest.NewRecorder()
 		r.ServeHTTP(wReg, reqReg)
 
-		token2 := getToken("user2_comment")
+		// user2 registered with a password, so it is needed to log in
+		reqLogin, _ := http.NewRequest("POST", "/login", bytes.NewBufferString(`{"username": "user2_comment", "password": "password"}`))
+		reqLogin.Header.Set("Content-Type", "application/json")
+		wLogin := httptest.NewRecorder()
+		r.ServeHTTP(wLogin, reqLogin)
+		var loginResp map[string]interface{}
+		_ = json.Unmarshal(wLogin.Body.Bytes(), &loginResp)
+		token2, _ := loginResp["token"].(string)
 
 		// Try to delete user1's comment with user2's token
 		url := fmt.Sprintf("/comments/%d", commentID)
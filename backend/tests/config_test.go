@@ -0,0 +1,38 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+
+	// Test Case 1: The replica check period must be positive, a zero ticker would panic the monitor
+	t.Run("Replica Check Period", func(t *testing.T) {
+		tests := []struct {
+			value string
+			valid bool
+		}{
+			{"0", false},
+			{"0s", false},
+			{"-1s", false},
+			{"500ms", true},
+		}
+		for _, tt := range tests {
+			t.Run(tt.value, func(t *testing.T) {
+				t.Setenv("DB_REPLICA_CHECK_PERIOD", tt.value)
+				cfg, err := config.Load()
+				if !tt.valid {
+					assert.ErrorContains(t, err, "DB_REPLICA_CHECK_PERIOD")
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, 500*time.Millisecond, cfg.DB.ReplicaCheckPeriod)
+			})
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/digest"
+	"github.com/DamienFooxx/CVWOForum/internal/jobs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigests(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	// The router's workers build and send the queued digests, to the shared fake server
+	r := SetupRouter(t, dbConn)
+	server := SharedSMTP(t)
+	ctx := context.Background()
+	queries := database.New(dbConn)
+	sender := digest.NewSender(queries, jobs.NewQueue(queries, config.JobsConfig{MaxAttempts: 3}), config.DigestConfig{
+		MaxItems:   20,
+		Retention:  30 * 24 * time.Hour,
+		SigningKey: "secret", // The router's key, which defaults to JWT_SECRET
+	}, "https://forum.test", "https://api.forum.test")
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	login := func(username string) (string, int64) {
+		resp := decode(do("POST", "/login", "", map[string]string{"username": username}))
+		return resp["token"].(string), int64(resp["user_id"].(float64))
+	}
+	exec := func(sql string, args ...interface{}) {
+		_, err := dbConn.Exec(ctx, sql, args...)
+		assert.NoError(t, err)
+	}
+	count := func(sql string, args ...interface{}) int {
+		var n int
+		assert.NoError(t, dbConn.QueryRow(ctx, sql, args...).Scan(&n))
+		return n
+	}
+	digestEmailsTo := func(email string) int {
+		return count("SELECT COUNT(*) FROM jobs WHERE kind = 'mail.send' AND payload->>'to' = $1", email)
+	}
+
+	alice, aliceID := login("digest_alice")
+	bob, _ := login("digest_bob")
+	_, carolID := login("digest_carol")
+	exec("UPDATE users SET email = 'digest.alice@example.com', email_verified_at = NOW() WHERE user_id = $1", aliceID)
+
+	w := do("POST", "/topics", bob, map[string]string{"name": "digestFollowed", "description": "Desc"})
+	followedID := int64(decode(w)["topic_id"].(float64))
+	w = do("POST", "/topics", bob, map[string]string{"name": "digestOther", "description": "Desc"})
+	otherID := int64(decode(w)["topic_id"].(float64))
+	exec("INSERT INTO topic_follows (user_id, topic_id) VALUES ($1, $2)", aliceID, followedID)
+
+	// Test Case 1: Members choose daily, weekly or no digests
+	t.Run("Settings", func(t *testing.T) {
+		w := do("GET", "/users/me/digest", alice, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "off", decode(w)["frequency"])
+		assert.Equal(t, true, decode(w)["email_verified"])
+
+		w = do("PUT", "/users/me/digest", alice, map[string]string{"frequency": "hourly"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do("PUT", "/users/me/digest", alice, map[string]string{"frequency": "weekly"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "weekly", decode(w)["frequency"])
+		w = do("PUT", "/users/me/digest", alice, map[string]string{"frequency": "daily"})
+		assert.Equal(t, "daily", decode(w)["frequency"])
+		assert.Equal(t, "daily", decode(do("GET", "/users/me/digest", alice, nil))["frequency"])
+	})
+
+	// Test Case 2: A due digest lists new posts in followed topics and replies to the member
+	t.Run("Send Digest", func(t *testing.T) {
+		// Both subscriptions started two days ago, but only verified addresses get digests
+		exec("INSERT INTO digest_subscriptions (user_id, frequency) VALUES ($1, 'daily')", carolID)
+		exec("UPDATE digest_subscriptions SET covered_until = NOW() - INTERVAL '2 days'")
+
+		w := do("POST", fmt.Sprintf("/topics/%d/posts", followedID), bob, map[string]string{"title": "Followed post", "body": "Body"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		do("POST", fmt.Sprintf("/topics/%d/posts", otherID), bob, map[string]string{"title": "Unfollowed post", "body": "Body"})
+		w = do("POST", fmt.Sprintf("/topics/%d/posts", followedID), alice, map[string]string{"title": "Alice's post", "body": "Body"})
+		alicePostID := int64(decode(w)["post_id"].(float64))
+		do("POST", fmt.Sprintf("/posts/%d/comments", alicePostID), bob, map[string]string{"body": "Nice\n\npost"})
+		do("POST", fmt.Sprintf("/posts/%d/comments", alicePostID), alice, map[string]string{"body": "Talking to myself"})
+
+		until := time.Now().Add(time.Minute)
+		queued, err := sender.QueueDue(ctx, until)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, queued)
+
+		email := server.WaitFor(t, "digest.alice@example.com")
+		headers, text, html, err := emailParts(email)
+		assert.NoError(t, err)
+		assert.Equal(t, "Your daily forum digest", headers.Get("Subject"))
+		assert.Contains(t, text, "- Followed post in digestFollowed by digest_bob")
+		assert.Contains(t, text, fmt.Sprintf("/topics/%d/posts/", followedID))
+		assert.Contains(t, text, `- digest_bob in "Alice's post": Nice post`)
+		assert.NotContains(t, text, "Unfollowed post")
+		assert.NotContains(t, text, "Alice's post in")
+		assert.NotContains(t, text, "Talking to myself")
+		assert.Contains(t, html, "Followed post")
+		assert.Contains(t, headers.Get("List-Unsubscribe"), "/digests/unsubscribe?token=")
+		assert.Equal(t, "List-Unsubscribe=One-Click", headers.Get("List-Unsubscribe-Post"))
+
+		// The digest is recorded and the next one starts where it ended
+		assert.Eventually(t, func() bool {
+			return count("SELECT COUNT(*) FROM digests WHERE user_id = $1 AND cardinality(post_ids) = 1 AND cardinality(comment_ids) = 1", aliceID) == 1
+		}, 5*time.Second, 50*time.Millisecond)
+		queued, err = sender.QueueDue(ctx, until)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, queued)
+	})
+
+	// Test Case 3: Nothing is sent twice, even if a period is covered again
+	t.Run("No Duplicates", func(t *testing.T) {
+		before := digestEmailsTo("digest.alice@example.com")
+		assert.Equal(t, 1, before)
+
+		exec("UPDATE digest_subscriptions SET covered_until = NOW() - INTERVAL '2 days' WHERE user_id = $1", aliceID)
+		assert.NoError(t, sender.Send(ctx, aliceID, time.Now().Add(2*time.Minute)))
+		assert.Equal(t, before, digestEmailsTo("digest.alice@example.com"))
+
+		// Quiet periods send nothing, but still move the subscription on
+		assert.Equal(t, 1, count("SELECT COUNT(*) FROM digests WHERE user_id = $1", aliceID))
+		assert.Equal(t, 1, count("SELECT COUNT(*) FROM digest_subscriptions WHERE user_id = $1 AND covered_until > NOW()", aliceID))
+	})
+
+	// Test Case 4: The link in the email unsubscribes without logging in
+	t.Run("Unsubscribe", func(t *testing.T) {
+		email := server.WaitFor(t, "digest.alice@example.com")
+		headers, _, _, err := emailParts(email)
+		assert.NoError(t, err)
+		link, err := url.Parse(strings.Trim(headers.Get("List-Unsubscribe"), "<>"))
+		assert.NoError(t, err)
+		token := link.Query().Get("token")
+
+		// Tokens are signed, changing the user they are for breaks them
+		_, signature, _ := strings.Cut(token, ".")
+		w := do("POST", "/digests/unsubscribe", "", map[string]string{"token": fmt.Sprintf("%d.%s", carolID, signature)})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 1, count("SELECT COUNT(*) FROM digest_subscriptions WHERE user_id = $1", carolID))
+
+		// One-click unsubscribe as a mail client sends it, to the header's URL with a form body
+		req := httptest.NewRequest("POST", link.RequestURI(), strings.NewReader("List-Unsubscribe=One-Click"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "off", decode(do("GET", "/users/me/digest", alice, nil))["frequency"])
+
+		// Opening the link again still succeeds, as does the frontend's JSON request
+		w = do("POST", "/digests/unsubscribe", "", map[string]string{"token": token})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	// Test Case 5: Posts past the item limit are counted rather than dropped without a word
+	t.Run("More Items", func(t *testing.T) {
+		exec("UPDATE users SET email = 'digest.carol@example.com', email_verified_at = NOW() WHERE user_id = $1", carolID)
+		exec("INSERT INTO topic_follows (user_id, topic_id) VALUES ($1, $2)", carolID, followedID)
+		limited := digest.NewSender(queries, jobs.NewQueue(queries, config.JobsConfig{MaxAttempts: 3}), config.DigestConfig{
+			MaxItems:   1,
+			Retention:  30 * 24 * time.Hour,
+			SigningKey: "secret",
+		}, "https://forum.test", "https://api.forum.test")
+
+		// Followed post and Alice's post
+		assert.NoError(t, limited.Send(ctx, carolID, time.Now().Add(3*time.Minute)))
+		email := server.WaitFor(t, "digest.carol@example.com")
+		_, text, html, err := emailParts(email)
+		assert.NoError(t, err)
+		assert.Contains(t, text, "- Followed post in digestFollowed by digest_bob")
+		assert.Contains(t, text, "- and 1 more")
+		assert.Contains(t, html, "and 1 more")
+	})
+}
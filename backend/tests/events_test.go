@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/realtime"
+	"github.com/stretchr/testify/assert"
+)
+
+// sseEvent is one event read from a text/event-stream response
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  map[string]interface{}
+}
+
+func TestEvents(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+	server := httptest.NewServer(r) // Streams need a real connection
+	defer server.Close()
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username string) string {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": username}).Body.Bytes(), &resp)
+		return resp["token"].(string)
+	}
+	// stream opens /events and sends each event it reads on the returned channel
+	stream := func(t *testing.T, query, token, lastEventID string) (<-chan sseEvent, int, func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events"+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			cancel()
+			t.Fatalf("Failed to open stream: %v", err)
+		}
+
+		events := make(chan sseEvent, 16)
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			close(events)
+			return events, resp.StatusCode, cancel
+		}
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		go func() {
+			defer resp.Body.Close()
+			defer close(events)
+			scanner := bufio.NewScanner(resp.Body)
+			var event sseEvent
+			for scanner.Scan() {
+				line := scanner.Text()
+				switch {
+				case line == "":
+					if event.Event != "" {
+						events <- event
+					}
+					event = sseEvent{}
+				case strings.HasPrefix(line, "id: "):
+					event.ID = strings.TrimPrefix(line, "id: ")
+				case strings.HasPrefix(line, "event: "):
+					event.Event = strings.TrimPrefix(line, "event: ")
+				case strings.HasPrefix(line, "data: "):
+					_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.Data)
+				}
+			}
+		}()
+		return events, resp.StatusCode, cancel
+	}
+	// await repeats action until an event arrives, as the broker may still be connecting to LISTEN
+	await := func(t *testing.T, events <-chan sseEvent, action func()) (sseEvent, bool) {
+		for attempt := 0; attempt < 5; attempt++ {
+			action()
+			select {
+			case event := <-events:
+				return event, true
+			case <-time.After(time.Second):
+			}
+		}
+		t.Error("No event received")
+		return sseEvent{}, false
+	}
+
+	author := login("events_author")
+	commenter := login("events_commenter")
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(do("POST", "/topics", author, map[string]string{"name": "eventsTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	topicID := int64(resp["topic_id"].(float64))
+
+	var postID int64
+	var firstEventID string
+
+	// Test Case 1: Anonymous clients following a topic see new posts
+	t.Run("Topic Stream", func(t *testing.T) {
+		events, status, cancel := stream(t, fmt.Sprintf("?topic=%d", topicID), "", "")
+		defer cancel()
+		assert.Equal(t, http.StatusOK, status)
+
+		event, ok := await(t, events, func() {
+			_ = json.Unmarshal(do("POST", fmt.Sprintf("/topics/%d/posts", topicID), author, map[string]string{"title": "Live", "body": "Body"}).Body.Bytes(), &resp)
+			postID = int64(resp["post_id"].(float64))
+		})
+		if ok {
+			assert.Equal(t, "post_created", event.Event)
+			assert.Equal(t, float64(topicID), event.Data["topic_id"])
+			assert.NotEmpty(t, event.ID)
+			firstEventID = event.ID
+		}
+	})
+
+	// Test Case 2: Clients following a post see new comments
+	t.Run("Post Stream", func(t *testing.T) {
+		events, _, cancel := stream(t, fmt.Sprintf("?post=%d", postID), "", "")
+		defer cancel()
+
+		event, ok := await(t, events, func() {
+			do("POST", fmt.Sprintf("/posts/%d/comments", postID), commenter, map[string]string{"body": "Live comment"})
+		})
+		if ok {
+			assert.Equal(t, "comment_created", event.Event)
+			assert.Equal(t, float64(postID), event.Data["post_id"])
+		}
+	})
+
+	// Test Case 3: Notifications are only streamed to their recipient
+	t.Run("Notification Stream", func(t *testing.T) {
+		_, status, cancel := stream(t, "?notifications=true", "", "")
+		cancel()
+		assert.Equal(t, http.StatusUnauthorized, status)
+
+		events, status, cancel := stream(t, "?notifications=true", author, "")
+		defer cancel()
+		assert.Equal(t, http.StatusOK, status)
+
+		event, ok := await(t, events, func() {
+			do("POST", fmt.Sprintf("/posts/%d/comments", postID), commenter, map[string]string{"body": "Notify the author"})
+		})
+		if ok {
+			assert.Equal(t, "notification", event.Event)
+			assert.Equal(t, "post_reply", event.Data["type"])
+		}
+	})
+
+	// Test Case 4: Reconnecting with Last-Event-ID replays missed events
+	t.Run("Resume", func(t *testing.T) {
+		do("POST", fmt.Sprintf("/topics/%d/posts", topicID), author, map[string]string{"title": "Missed", "body": "Body"})
+
+		events, _, cancel := stream(t, fmt.Sprintf("?topic=%d", topicID), "", firstEventID)
+		defer cancel()
+
+		select {
+		case event := <-events:
+			assert.Equal(t, "post_created", event.Event)
+			assert.NotEqual(t, firstEventID, event.ID)
+		case <-time.After(5 * time.Second):
+			t.Error("Missed events were not replayed")
+		}
+	})
+
+	// Test Case 5: Clients that missed too much to replay are told to resync
+	t.Run("Resync", func(t *testing.T) {
+		_, err := dbConn.Exec(context.Background(),
+			"INSERT INTO events (channel, type, payload) SELECT $1, 'post_created', '{}' FROM generate_series(1, 1500)",
+			realtime.TopicChannel(topicID))
+		assert.NoError(t, err)
+
+		events, _, cancel := stream(t, fmt.Sprintf("?topic=%d", topicID), "", "0")
+		defer cancel()
+
+		select {
+		case event := <-events:
+			assert.Equal(t, "resync", event.Event)
+			assert.NotEmpty(t, event.ID)
+		case <-time.After(5 * time.Second):
+			t.Error("No resync event")
+		}
+	})
+
+	// Test Case 6: Invalid subscriptions
+	t.Run("Invalid Subscriptions", func(t *testing.T) {
+		_, status, cancel := stream(t, "", "", "")
+		cancel()
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		_, status, cancel = stream(t, "?topic=abc", "", "")
+		cancel()
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
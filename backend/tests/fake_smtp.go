@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ReceivedEmail is a message accepted by the fake SMTP server
+type ReceivedEmail struct {
+	From string
+	To   []string
+	Auth string // user:password sent with AUTH PLAIN, empty without
+	Data []byte
+}
+
+// FakeSMTPServer is an in-process SMTP server that keeps what it is sent.
+// Recipients containing "rejected" get a permanent 550, "busy" a temporary 451.
+type FakeSMTPServer struct {
+	Host string
+	Port int
+
+	listener net.Listener
+	mu       sync.Mutex
+	received []ReceivedEmail
+}
+
+var (
+	sharedSMTP     *FakeSMTPServer
+	sharedSMTPOnce sync.Once
+)
+
+// SharedSMTP returns the fake server every test router sends email to. It runs for the whole test binary,
+// since the job workers of earlier tests' routers keep running and may be the ones that send an email.
+func SharedSMTP(t *testing.T) *FakeSMTPServer {
+	sharedSMTPOnce.Do(func() {
+		sharedSMTP = StartFakeSMTP(t)
+	})
+	return sharedSMTP
+}
+
+// StartFakeSMTP listens on a random local port, stop it with Close
+func StartFakeSMTP(t *testing.T) *FakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start fake SMTP server: %v", err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	s := &FakeSMTPServer{Host: "127.0.0.1", Port: addr.Port, listener: listener}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *FakeSMTPServer) Close() {
+	_ = s.listener.Close()
+}
+
+// To returns the emails received for a recipient, oldest first
+func (s *FakeSMTPServer) To(recipient string) []ReceivedEmail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var emails []ReceivedEmail
+	for _, email := range s.received {
+		for _, to := range email.To {
+			if strings.EqualFold(to, recipient) {
+				emails = append(emails, email)
+			}
+		}
+	}
+	return emails
+}
+
+// WaitFor returns the first email to recipient, failing the test if none arrives in time
+func (s *FakeSMTPServer) WaitFor(t *testing.T, recipient string) ReceivedEmail {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if emails := s.To(recipient); len(emails) > 0 {
+			return emails[0]
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("No email to %s", recipient)
+	return ReceivedEmail{}
+}
+
+// serve speaks just enough SMTP for net/smtp clients
+func (s *FakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(format string, args ...interface{}) {
+		_ = text.PrintfLine(format, args...)
+	}
+
+	var email ReceivedEmail
+	var auth string
+	reply("220 fake.smtp ESMTP ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			reply("250-fake.smtp")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			// AUTH PLAIN <base64 of "\x00user\x00password">
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			decoded, err := base64.StdEncoding.DecodeString(initial)
+			parts := bytes.Split(decoded, []byte{0})
+			if !strings.EqualFold(mechanism, "PLAIN") || err != nil || len(parts) != 3 {
+				reply("535 authentication failed")
+				continue
+			}
+			auth = string(parts[1]) + ":" + string(parts[2])
+			reply("235 authenticated")
+		case "MAIL":
+			email = ReceivedEmail{From: addressOf(arg), Auth: auth}
+			reply("250 OK")
+		case "RCPT":
+			recipient := addressOf(arg)
+			switch {
+			case strings.Contains(recipient, "rejected"):
+				reply("550 no such mailbox")
+			case strings.Contains(recipient, "busy"):
+				reply("451 try again later")
+			default:
+				email.To = append(email.To, recipient)
+				reply("250 OK")
+			}
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			email.Data = data
+			s.mu.Lock()
+			s.received = append(s.received, email)
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case "RSET":
+			email = ReceivedEmail{Auth: auth}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 %s not implemented", command)
+		}
+	}
+}
+
+// addressOf extracts the address from "FROM:<a@b>" or "TO:<a@b>"
+func addressOf(arg string) string {
+	start, end := strings.Index(arg, "<"), strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
+
+// emailParts reads the headers of a received email and its text and HTML alternatives
+func emailParts(email ReceivedEmail) (headers textproto.MIMEHeader, text, html string, err error) {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(email.Data)))
+	headers, err = reader.ReadMIMEHeader()
+	if err != nil {
+		return nil, "", "", err
+	}
+	_, params, err := mime.ParseMediaType(headers.Get("Content-Type"))
+	if err != nil {
+		return headers, "", "", err
+	}
+
+	// Parts are quoted-printable, which NextPart decodes
+	parts := multipart.NewReader(reader.R, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if errors.Is(err, io.EOF) {
+			return headers, text, html, nil
+		}
+		if err != nil {
+			return headers, "", "", err
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			return headers, "", "", err
+		}
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/html") {
+			html = string(body)
+		} else {
+			text = string(body)
+		}
+	}
+}
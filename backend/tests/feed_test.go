@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeed(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	getToken := func(username string) string {
+		w := do("POST", "/login", "", map[string]string{"username": username})
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp["token"].(string)
+	}
+	// titles lists the "title" or "name" of each item in a JSON array
+	titles := func(w *httptest.ResponseRecorder, field string) []string {
+		var items []map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &items)
+		names := []string{}
+		for _, item := range items {
+			names = append(names, item[field].(string))
+		}
+		return names
+	}
+	feed := func(token, query string) (string, []string) {
+		w := do("GET", "/feed"+query, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Source string `json:"source"`
+			Posts  []struct {
+				Title     string `json:"title"`
+				TopicName string `json:"topic_name"`
+			} `json:"posts"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		posts := []string{}
+		for _, p := range resp.Posts {
+			posts = append(posts, p.Title)
+		}
+		return resp.Source, posts
+	}
+
+	author := getToken("feed_author")
+	reader := getToken("feed_reader")
+
+	// Cooking is the busiest topic, so new users see it first
+	createTopic := func(name string, posts ...string) int64 {
+		w := do("POST", "/topics", author, map[string]string{"name": name, "description": "Desc"})
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		topicID := int64(resp["topic_id"].(float64))
+		for _, title := range posts {
+			do("POST", fmt.Sprintf("/topics/%d/posts", topicID), author, map[string]string{"title": title, "body": "Body of " + title})
+		}
+		_, err := dbConn.Exec(context.Background(), "UPDATE topics SET post_count = $1 WHERE topic_id = $2", len(posts), topicID)
+		assert.NoError(t, err)
+		return topicID
+	}
+	cookingID := createTopic("feedCooking", "Pasta", "Bread", "Soup")
+	gamingID := createTopic("feedGaming", "Chess")
+	createTopic("feedEmpty")
+
+	// Test Case 1: Users who follow nothing see popular topics
+	t.Run("Popular Fallback", func(t *testing.T) {
+		source, posts := feed(reader, "")
+		assert.Equal(t, "popular", source)
+		assert.Equal(t, []string{"Chess", "Soup", "Bread", "Pasta"}, posts)
+
+		_, posts = feed(reader, "?limit=2&offset=1")
+		assert.Equal(t, []string{"Soup", "Bread"}, posts)
+
+		w := do("GET", "/feed", "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	// Test Case 2: Following topics fills the feed with their posts only
+	t.Run("Follow Topics", func(t *testing.T) {
+		w := do("PUT", fmt.Sprintf("/topics/%d/follow", gamingID), reader, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		// Following twice is not an error
+		w = do("PUT", fmt.Sprintf("/topics/%d/follow", gamingID), reader, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = do("PUT", "/topics/999999/follow", reader, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		source, posts := feed(reader, "")
+		assert.Equal(t, "following", source)
+		assert.Equal(t, []string{"Chess"}, posts)
+
+		w = do("GET", "/users/me/followed-topics", reader, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"feedGaming"}, titles(w, "name"))
+
+		// Unfollowing the last topic brings back the popular feed
+		w = do("DELETE", fmt.Sprintf("/topics/%d/follow", gamingID), reader, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		source, _ = feed(reader, "")
+		assert.Equal(t, "popular", source)
+	})
+
+	// Test Case 3: Muted topics disappear from the user's sitewide listings and search
+	t.Run("Mute Topic", func(t *testing.T) {
+		do("PUT", fmt.Sprintf("/topics/%d/follow", cookingID), reader, nil)
+		w := do("PUT", fmt.Sprintf("/topics/%d/mute", cookingID), reader, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		// Muting unfollows
+		w = do("GET", "/users/me/followed-topics", reader, nil)
+		assert.Empty(t, titles(w, "name"))
+		w = do("GET", "/users/me/muted-topics", reader, nil)
+		assert.Equal(t, []string{"feedCooking"}, titles(w, "name"))
+
+		assert.NotContains(t, titles(do("GET", "/topics", reader, nil), "name"), "feedCooking")
+		assert.Empty(t, titles(do("GET", "/topics?q=Cooking", reader, nil), "name"))
+		assert.Equal(t, []string{"Chess"}, titles(do("GET", "/posts", reader, nil), "title"))
+		assert.Empty(t, titles(do("GET", "/posts?q=Pasta", reader, nil), "title"))
+
+		// The popular feed skips it too
+		_, posts := feed(reader, "")
+		assert.Equal(t, []string{"Chess"}, posts)
+
+		// Other users and visitors still see it, and the topic itself can still be opened
+		assert.Contains(t, titles(do("GET", "/topics", author, nil), "name"), "feedCooking")
+		assert.Len(t, titles(do("GET", "/posts", "", nil), "title"), 4)
+		w = do("GET", fmt.Sprintf("/topics/%d/posts", cookingID), reader, nil)
+		assert.Len(t, titles(w, "title"), 3)
+
+		// Following again unmutes
+		do("PUT", fmt.Sprintf("/topics/%d/follow", cookingID), reader, nil)
+		assert.Contains(t, titles(do("GET", "/topics", reader, nil), "name"), "feedCooking")
+		w = do("GET", "/users/me/muted-topics", reader, nil)
+		assert.Empty(t, titles(w, "name"))
+
+		do("PUT", fmt.Sprintf("/topics/%d/mute", cookingID), reader, nil)
+		w = do("DELETE", fmt.Sprintf("/topics/%d/mute", cookingID), reader, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Len(t, titles(do("GET", "/posts", reader, nil), "title"), 4)
+	})
+}
@@ -0,0 +1,284 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/jobs"
+	"github.com/stretchr/testify/assert"
+)
+
+// echoPayload is the payload of the test job kinds
+type echoPayload struct {
+	Message string `json:"message"`
+}
+
+func TestJobs(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+	ctx := context.Background()
+
+	// A queue driven by hand through RunNext, with kinds the router's workers do not claim
+	queue := jobs.NewQueue(database.New(dbConn), config.JobsConfig{
+		Concurrency:  1,
+		PollInterval: time.Hour,
+		Timeout:      5 * time.Second,
+		MaxAttempts:  3,
+		Retention:    time.Hour,
+	})
+	echo := jobs.NewKind[echoPayload]("test.echo")
+	failing := jobs.NewKind[echoPayload]("test.failing")
+	broken := jobs.NewKind[echoPayload]("test.broken")
+	panicking := jobs.NewKind[echoPayload]("test.panicking")
+
+	var echoed []string
+	jobs.Register(queue, echo, func(ctx context.Context, payload echoPayload) error {
+		echoed = append(echoed, payload.Message)
+		return nil
+	})
+	jobs.Register(queue, failing, func(ctx context.Context, payload echoPayload) error {
+		return errors.New("temporarily unavailable")
+	})
+	jobs.Register(queue, broken, func(ctx context.Context, payload echoPayload) error {
+		return jobs.Permanent(errors.New("cannot be fixed by retrying"))
+	})
+	jobs.Register(queue, panicking, func(ctx context.Context, payload echoPayload) error {
+		panic("boom")
+	})
+
+	// Helpers
+	type jobState struct {
+		Status    string
+		Attempts  int32
+		RunAt     time.Time
+		LastError *string
+	}
+	stateOf := func(jobID int64) jobState {
+		var state jobState
+		err := dbConn.QueryRow(ctx, "SELECT status, attempts, run_at, last_error FROM jobs WHERE job_id = $1", jobID).
+			Scan(&state.Status, &state.Attempts, &state.RunAt, &state.LastError)
+		assert.NoError(t, err)
+		return state
+	}
+	runNext := func() bool {
+		ran, err := queue.RunNext(ctx)
+		assert.NoError(t, err)
+		return ran
+	}
+	makeDue := func(jobID int64) {
+		_, err := dbConn.Exec(ctx, "UPDATE jobs SET run_at = NOW() WHERE job_id = $1", jobID)
+		assert.NoError(t, err)
+	}
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username string) string {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": username}).Body.Bytes(), &resp)
+		return resp["token"].(string)
+	}
+
+	// Test Case 1: A typed handler receives its decoded payload
+	t.Run("Run Job", func(t *testing.T) {
+		jobID, err := jobs.Enqueue(ctx, queue, echo, echoPayload{Message: "hello"})
+		assert.NoError(t, err)
+
+		assert.True(t, runNext())
+		assert.Equal(t, []string{"hello"}, echoed)
+		assert.Equal(t, "succeeded", stateOf(jobID).Status)
+		assert.Equal(t, int32(1), stateOf(jobID).Attempts)
+
+		assert.False(t, runNext())
+	})
+
+	// Test Case 2: Unique keys drop duplicates only while a job is queued
+	t.Run("Unique Key", func(t *testing.T) {
+		first, err := jobs.Enqueue(ctx, queue, echo, echoPayload{Message: "once"}, jobs.UniqueKey("echo:once"))
+		assert.NoError(t, err)
+		_, err = jobs.Enqueue(ctx, queue, echo, echoPayload{Message: "once"}, jobs.UniqueKey("echo:once"))
+		assert.ErrorIs(t, err, jobs.ErrDuplicate)
+
+		assert.True(t, runNext())
+		assert.Equal(t, "succeeded", stateOf(first).Status)
+
+		second, err := jobs.Enqueue(ctx, queue, echo, echoPayload{Message: "once"}, jobs.UniqueKey("echo:once"))
+		assert.NoError(t, err)
+		assert.NotEqual(t, first, second)
+		assert.True(t, runNext())
+	})
+
+	// Test Case 3: Scheduled jobs wait until they are due
+	t.Run("Run At", func(t *testing.T) {
+		jobID, err := jobs.Enqueue(ctx, queue, echo, echoPayload{Message: "later"}, jobs.RunAt(time.Now().Add(time.Hour)))
+		assert.NoError(t, err)
+		assert.False(t, runNext())
+		assert.Equal(t, "queued", stateOf(jobID).Status)
+
+		makeDue(jobID)
+		assert.True(t, runNext())
+		assert.Equal(t, "succeeded", stateOf(jobID).Status)
+	})
+
+	// Test Case 4: Failures are retried with backoff, then moved to the dead state
+	t.Run("Retry And Dead Letter", func(t *testing.T) {
+		jobID, err := jobs.Enqueue(ctx, queue, failing, echoPayload{}, jobs.MaxAttempts(2))
+		assert.NoError(t, err)
+
+		assert.True(t, runNext())
+		state := stateOf(jobID)
+		assert.Equal(t, "queued", state.Status)
+		assert.Equal(t, int32(1), state.Attempts)
+		assert.True(t, state.RunAt.After(time.Now().Add(5*time.Second)))
+		if assert.NotNil(t, state.LastError) {
+			assert.Equal(t, "temporarily unavailable", *state.LastError)
+		}
+
+		// Not retried before the backoff is over
+		assert.False(t, runNext())
+
+		makeDue(jobID)
+		assert.True(t, runNext())
+		state = stateOf(jobID)
+		assert.Equal(t, "dead", state.Status)
+		assert.Equal(t, int32(2), state.Attempts)
+
+		makeDue(jobID)
+		assert.False(t, runNext())
+	})
+
+	// Test Case 5: Permanent errors skip the retries, panics are retried
+	t.Run("Permanent Errors And Panics", func(t *testing.T) {
+		brokenID, err := jobs.Enqueue(ctx, queue, broken, echoPayload{})
+		assert.NoError(t, err)
+		assert.True(t, runNext())
+		assert.Equal(t, "dead", stateOf(brokenID).Status)
+		assert.Equal(t, int32(1), stateOf(brokenID).Attempts)
+
+		panicID, err := jobs.Enqueue(ctx, queue, panicking, echoPayload{})
+		assert.NoError(t, err)
+		assert.True(t, runNext())
+		state := stateOf(panicID)
+		assert.Equal(t, "queued", state.Status)
+		if assert.NotNil(t, state.LastError) {
+			assert.Contains(t, *state.LastError, "panic: boom")
+		}
+		_, err = dbConn.Exec(ctx, "DELETE FROM jobs WHERE job_id = $1", panicID)
+		assert.NoError(t, err)
+	})
+
+	// Test Case 6: A job whose worker died is taken over once its lease expires
+	t.Run("Expired Lease", func(t *testing.T) {
+		jobID, err := jobs.Enqueue(ctx, queue, echo, echoPayload{Message: "orphaned"})
+		assert.NoError(t, err)
+		_, err = dbConn.Exec(ctx, "UPDATE jobs SET status = 'running', attempts = 1, locked_until = NOW() + INTERVAL '1 minute' WHERE job_id = $1", jobID)
+		assert.NoError(t, err)
+		assert.False(t, runNext())
+
+		_, err = dbConn.Exec(ctx, "UPDATE jobs SET locked_until = NOW() - INTERVAL '1 second' WHERE job_id = $1", jobID)
+		assert.NoError(t, err)
+		assert.True(t, runNext())
+		assert.Equal(t, "succeeded", stateOf(jobID).Status)
+		assert.Equal(t, int32(2), stateOf(jobID).Attempts)
+		assert.Contains(t, echoed, "orphaned")
+	})
+
+	admin := login("jobs_admin")
+	_, err = dbConn.Exec(ctx, "UPDATE users SET role = 'admin' WHERE username = 'jobs_admin'")
+	assert.NoError(t, err)
+	member := login("jobs_member")
+
+	// Test Case 7: Admins list dead jobs and queue them again
+	t.Run("Admin Dead Letter", func(t *testing.T) {
+		w := do("GET", "/admin/jobs?status=dead", member, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("GET", "/admin/jobs?status=lost", admin, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do("GET", "/admin/jobs?status=dead", admin, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var dead []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dead))
+		assert.Len(t, dead, 2)
+		kinds := []string{}
+		for _, job := range dead {
+			assert.Equal(t, "dead", job["status"])
+			assert.NotNil(t, job["last_error"])
+			kinds = append(kinds, job["kind"].(string))
+		}
+		assert.ElementsMatch(t, []string{"test.failing", "test.broken"}, kinds)
+
+		deadID := int64(dead[0]["job_id"].(float64))
+		w = do("POST", fmt.Sprintf("/admin/jobs/%d/retry", deadID), admin, nil)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		var retried map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &retried))
+		assert.Equal(t, "queued", retried["status"])
+		assert.Equal(t, float64(0), retried["attempts"])
+
+		// Only dead jobs can be retried
+		w = do("POST", fmt.Sprintf("/admin/jobs/%d/retry", deadID), admin, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = do("POST", "/admin/jobs/999999999/retry", admin, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		_, err := dbConn.Exec(ctx, "DELETE FROM jobs WHERE kind LIKE 'test.%'")
+		assert.NoError(t, err)
+	})
+
+	// Test Case 8: Topic post counts are kept up to date by background jobs
+	t.Run("Post Counts", func(t *testing.T) {
+		w := do("POST", "/topics", member, map[string]string{"name": "Jobs Topic", "description": "Counted in the background"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var topic map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &topic)
+		topicID := int64(topic["topic_id"].(float64))
+
+		for i := range 2 {
+			w = do("POST", fmt.Sprintf("/topics/%d/posts", topicID), member, map[string]string{"title": fmt.Sprintf("Post %d", i), "body": "body"})
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		postCount := func() int64 {
+			var count int64
+			_ = dbConn.QueryRow(ctx, "SELECT post_count FROM topics WHERE topic_id = $1", topicID).Scan(&count)
+			return count
+		}
+		assert.Eventually(t, func() bool { return postCount() == 2 }, 5*time.Second, 50*time.Millisecond)
+
+		// Drifted counts are corrected by the reconciliation job
+		_, err := dbConn.Exec(ctx, "UPDATE topics SET post_count = 42 WHERE topic_id = $1", topicID)
+		assert.NoError(t, err)
+		_, err = jobs.Enqueue(ctx, queue, jobs.ReconcilePostCounts, struct{}{})
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool { return postCount() == 2 }, 5*time.Second, 50*time.Millisecond)
+	})
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test brute-force protection on accounts with a password
+func TestLoginLockout(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Helpers
+	login := func(username, password string) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(map[string]string{"username": username, "password": password})
+		req := httptest.NewRequest("POST", "/login", bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Setup: user with a password
+	payload := []byte(`{"username": "locked_user", "password": "correct horse"}`)
+	req := httptest.NewRequest("POST", "/users", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Test Case 1: Correct password logs in
+	t.Run("Correct Password", func(t *testing.T) {
+		w := login("locked_user", "correct horse")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	// Test Case 2: Wrong password is rejected
+	t.Run("Wrong Password", func(t *testing.T) {
+		w := login("locked_user", "wrong")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	// Test Case 3: Account locks after 5 failures, even for the right password
+	t.Run("Lockout After Repeated Failures", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusUnauthorized, login("locked_user", "wrong").Code)
+		}
+
+		// Fifth failure in a row locks the account
+		w := login("locked_user", "wrong")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+		w = login("locked_user", "correct horse")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+		// Owner is notified of the lockout
+		var count int
+		err := dbConn.QueryRow(context.Background(), `
+			SELECT COUNT(*) FROM notifications n
+			JOIN users u ON n.user_id = u.user_id
+			WHERE u.username = 'locked_user' AND n.type = 'account_locked'`).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	// Test Case 4: Only admins can read the audit trail
+	t.Run("Admin Lists Login Attempts", func(t *testing.T) {
+		w := login("admin_user", "")
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		token := resp["token"].(string)
+
+		listAttempts := func() *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/admin/login-attempts?username=locked_user&success=false", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		assert.Equal(t, http.StatusForbidden, listAttempts().Code)
+
+		_, err := dbConn.Exec(context.Background(), "UPDATE users SET role = 'admin' WHERE username = 'admin_user'")
+		assert.NoError(t, err)
+
+		w = listAttempts()
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var attempts []map[string]interface{}
+		err = json.Unmarshal(w.Body.Bytes(), &attempts)
+		assert.NoError(t, err)
+		// 5 wrong passwords and 1 blocked by the lockout
+		assert.Len(t, attempts, 6)
+		assert.Equal(t, "locked", attempts[0]["failure_reason"])
+	})
+}
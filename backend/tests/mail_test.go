@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/jobs"
+	"github.com/DamienFooxx/CVWOForum/internal/mail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMail(t *testing.T) {
+	server := StartFakeSMTP(t)
+	defer server.Close()
+
+	ctx := context.Background()
+	smtpConfig := config.SMTPConfig{
+		Host:     server.Host,
+		Port:     server.Port,
+		Username: "forum",
+		Password: "hunter2",
+		TLS:      "none",
+		Timeout:  5 * time.Second,
+	}
+	mailer := mail.NewSMTPMailer("CVWO Forum <noreply@forum.test>", smtpConfig)
+
+	// Test Case 1: Every template renders a subject, a text body and an HTML body
+	t.Run("Render Templates", func(t *testing.T) {
+		msg, err := mail.VerifyEmail.Render("alice@example.com", mail.VerifyEmailData{Username: "alice", VerifyURL: "https://forum.test/verify?token=abc", ExpiresIn: "24 hours"})
+		assert.NoError(t, err)
+		assert.Equal(t, "alice@example.com", msg.To)
+		assert.Equal(t, "Verify your email address", msg.Subject)
+		assert.Contains(t, msg.Text, "https://forum.test/verify?token=abc")
+		assert.Contains(t, msg.HTML, `href="https://forum.test/verify?token=abc"`)
+
+		msg, err = mail.PasswordReset.Render("alice@example.com", mail.PasswordResetData{Username: "alice", ResetURL: "https://forum.test/reset?token=def", ExpiresIn: "1 hour"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Reset your password", msg.Subject)
+		assert.Contains(t, msg.Text, "expires in 1 hour")
+
+		// User content is escaped in HTML, and cannot break the subject header
+		msg, err = mail.Reply.Render("alice@example.com", mail.ReplyData{
+			Username:    "alice",
+			ReplierName: "bob",
+			PostTitle:   "Multi\nline",
+			Excerpt:     "<script>alert(1)</script>",
+			ReplyURL:    "https://forum.test/posts/1",
+			SettingsURL: "https://forum.test/settings",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `bob replied in "Multi line"`, msg.Subject)
+		assert.Contains(t, msg.Text, "<script>alert(1)</script>")
+		assert.NotContains(t, msg.HTML, "<script>")
+		assert.Contains(t, msg.HTML, "&lt;script&gt;")
+
+		msg, err = mail.Digest.Render("alice@example.com", mail.DigestData{
+			Username:       "alice",
+			Period:         "weekly",
+			Posts:          []mail.DigestPost{{Title: "New post", TopicName: "General", Author: "bob", URL: "https://forum.test/posts/2"}},
+			Replies:        []mail.DigestReply{{PostTitle: "My post", ReplierName: "carol", Excerpt: "Nice", URL: "https://forum.test/posts/3"}},
+			UnsubscribeURL: "https://forum.test/unsubscribe",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "Your weekly forum digest", msg.Subject)
+		assert.Contains(t, msg.Text, "- New post in General by bob")
+		assert.Contains(t, msg.Text, `- carol in "My post": Nice`)
+		assert.Contains(t, msg.HTML, "https://forum.test/unsubscribe")
+	})
+
+	// Test Case 2: SMTP delivers a multipart email with both bodies
+	t.Run("Send Over SMTP", func(t *testing.T) {
+		msg, err := mail.VerifyEmail.Render("Alice Tan <alice@example.com>", mail.VerifyEmailData{Username: "Zoë", VerifyURL: "https://forum.test/verify?token=abc", ExpiresIn: "24 hours"})
+		assert.NoError(t, err)
+		assert.NoError(t, mailer.Send(ctx, msg))
+
+		email := server.WaitFor(t, "alice@example.com")
+		assert.Equal(t, "noreply@forum.test", email.From)
+		assert.Equal(t, "forum:hunter2", email.Auth)
+
+		headers, text, html, err := emailParts(email)
+		assert.NoError(t, err)
+		assert.Equal(t, "Verify your email address", headers.Get("Subject"))
+		assert.Equal(t, `"CVWO Forum" <noreply@forum.test>`, headers.Get("From"))
+		assert.Contains(t, headers.Get("Message-Id"), "@forum.test>")
+		assert.Contains(t, text, "Hi Zoë,")
+		assert.Contains(t, text, "https://forum.test/verify?token=abc")
+		assert.Contains(t, html, "<!DOCTYPE html>")
+	})
+
+	// Test Case 3: Rejections and bad addresses are permanent, temporary failures are not
+	t.Run("Failures", func(t *testing.T) {
+		err := mailer.Send(ctx, mail.Message{To: "rejected@example.com", Subject: "Hi", Text: "Hi"})
+		assert.Error(t, err)
+		assert.True(t, mail.IsPermanent(err))
+
+		err = mailer.Send(ctx, mail.Message{To: "busy@example.com", Subject: "Hi", Text: "Hi"})
+		assert.Error(t, err)
+		assert.False(t, mail.IsPermanent(err))
+
+		err = mailer.Send(ctx, mail.Message{To: "not an address", Subject: "Hi", Text: "Hi"})
+		assert.ErrorIs(t, err, mail.ErrInvalidMessage)
+		assert.True(t, mail.IsPermanent(err))
+
+		err = mailer.Send(ctx, mail.Message{To: "alice@example.com", Subject: "Hi\r\nBcc: eve@example.com", Text: "Hi"})
+		assert.ErrorIs(t, err, mail.ErrInvalidMessage)
+
+		// STARTTLS is required unless turned off, and the fake server does not offer it
+		strict := smtpConfig
+		strict.TLS = "starttls"
+		err = mail.NewSMTPMailer("noreply@forum.test", strict).Send(ctx, mail.Message{To: "alice@example.com", Subject: "Hi", Text: "Hi"})
+		assert.ErrorContains(t, err, "STARTTLS")
+		assert.False(t, mail.IsPermanent(err))
+	})
+
+	// Test Case 4: The file driver writes an .eml file per email
+	t.Run("File Driver", func(t *testing.T) {
+		dir := t.TempDir()
+		fileMailer := mail.New(config.MailConfig{Driver: "file", From: "noreply@forum.test", Dir: dir})
+		assert.NoError(t, fileMailer.Send(ctx, mail.Message{To: "alice@example.com", Subject: "Saved", Text: "On disk", HTML: "<p>On disk</p>"}))
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		assert.NoError(t, err)
+		if assert.Len(t, files, 1) {
+			data, err := os.ReadFile(files[0])
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(string(data), "From: <noreply@forum.test>\r\n"))
+			_, text, html, err := emailParts(ReceivedEmail{Data: data})
+			assert.NoError(t, err)
+			assert.Equal(t, "On disk", text)
+			assert.Equal(t, "<p>On disk</p>", html)
+		}
+	})
+}
+
+func TestMailQueue(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	// The router's workers send the queued emails to the shared fake server
+	SetupRouter(t, dbConn)
+	server := SharedSMTP(t)
+	ctx := context.Background()
+	queue := jobs.NewQueue(database.New(dbConn), config.JobsConfig{MaxAttempts: 3})
+
+	statusOf := func(to string) string {
+		var status string
+		_ = dbConn.QueryRow(ctx, "SELECT status FROM jobs WHERE kind = 'mail.send' AND payload->>'to' = $1", to).Scan(&status)
+		return status
+	}
+
+	// Test Case 1: Queued emails are sent by a background job
+	t.Run("Send Queued Email", func(t *testing.T) {
+		err := mail.Enqueue(ctx, queue, mail.PasswordReset, "queued@example.com", mail.PasswordResetData{Username: "queued", ResetURL: "https://forum.test/reset?token=xyz", ExpiresIn: "1 hour"})
+		assert.NoError(t, err)
+
+		email := server.WaitFor(t, "queued@example.com")
+		headers, text, _, err := emailParts(email)
+		assert.NoError(t, err)
+		assert.Equal(t, "Reset your password", headers.Get("Subject"))
+		assert.Contains(t, text, "https://forum.test/reset?token=xyz")
+		assert.Eventually(t, func() bool { return statusOf("queued@example.com") == "succeeded" }, 5*time.Second, 50*time.Millisecond)
+	})
+
+	// Test Case 2: Emails the server rejects are not retried
+	t.Run("Rejected Email", func(t *testing.T) {
+		err := mail.Enqueue(ctx, queue, mail.PasswordReset, "rejected@example.com", mail.PasswordResetData{Username: "rejected", ResetURL: "https://forum.test/reset", ExpiresIn: "1 hour"})
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool { return statusOf("rejected@example.com") == "dead" }, 5*time.Second, 50*time.Millisecond)
+	})
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownRendering(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": "markdown_user"}).Body.Bytes(), &resp)
+	token := resp["token"].(string)
+
+	_ = json.Unmarshal(do("POST", "/topics", token, map[string]string{"name": "markdownTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	topicID := int64(resp["topic_id"].(float64))
+
+	body := "# Title\n\n**bold** visit https://example.com\n\n| a | b |\n|---|--:|\n| 1 | 2 |\n\n<script>alert(1)</script>\n\n[click](javascript:alert(1))"
+	w := do("POST", fmt.Sprintf("/topics/%d/posts", topicID), token, map[string]string{"title": "Markdown", "body": body})
+	assert.Equal(t, http.StatusOK, w.Code)
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	postID := int64(resp["post_id"].(float64))
+
+	// Test Case 1: The source is kept and the rendered HTML is returned alongside it
+	t.Run("Render Post", func(t *testing.T) {
+		_ = json.Unmarshal(do("GET", fmt.Sprintf("/posts/%d", postID), "", nil).Body.Bytes(), &resp)
+		html := resp["body_html"].(string)
+		assert.Equal(t, body, resp["body"])
+		assert.Contains(t, html, "<h1>Title</h1>")
+		assert.Contains(t, html, "<strong>bold</strong>")
+		assert.Contains(t, html, `<a href="https://example.com"`)
+		assert.Contains(t, html, `<th align="right">b</th>`)
+	})
+
+	// Test Case 2: Scripts and unsafe links are stripped
+	t.Run("Sanitize", func(t *testing.T) {
+		html := resp["body_html"].(string)
+		assert.NotContains(t, html, "<script")
+		assert.NotContains(t, html, "javascript:")
+	})
+
+	// Test Case 3: Search results and comments include body_html
+	t.Run("Search And Comments", func(t *testing.T) {
+		var posts []map[string]interface{}
+		_ = json.Unmarshal(do("GET", "/posts?q=visit", "", nil).Body.Bytes(), &posts)
+		assert.Len(t, posts, 1)
+		assert.Contains(t, posts[0]["body_html"], "<h1>Title</h1>")
+
+		do("POST", fmt.Sprintf("/posts/%d/comments", postID), token, map[string]string{"body": "```go\nx := 1\n```"})
+		var comments []map[string]interface{}
+		_ = json.Unmarshal(do("GET", fmt.Sprintf("/posts/%d/comments", postID), "", nil).Body.Bytes(), &comments)
+		assert.Len(t, comments, 1)
+		assert.Contains(t, comments[0]["body_html"], `<code class="language-go">`)
+	})
+
+	// Test Case 4: Rows stored before rendering was added are rendered when read
+	t.Run("Legacy Rows", func(t *testing.T) {
+		_, err := dbConn.Exec(context.Background(), "UPDATE posts SET body_html = NULL WHERE post_id = $1", postID)
+		assert.NoError(t, err)
+
+		_ = json.Unmarshal(do("GET", fmt.Sprintf("/posts/%d", postID), "", nil).Body.Bytes(), &resp)
+		assert.Contains(t, resp["body_html"], "<h1>Title</h1>")
+	})
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMentions(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username string) (string, int64) {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": username}).Body.Bytes(), &resp)
+		return resp["token"].(string), int64(resp["user_id"].(float64))
+	}
+	mentionNotifications := func(userID int64) int {
+		var count int
+		_ = dbConn.QueryRow(context.Background(), "SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND type = 'mention'", userID).Scan(&count)
+		return count
+	}
+
+	alice, aliceID := login("mention_alice")
+	bob, bobID := login("mention_bob")
+	_, carolID := login("mention_carol")
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(do("POST", "/topics", alice, map[string]string{"name": "mentionTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	topicID := int64(resp["topic_id"].(float64))
+
+	var postID int64
+
+	// Test Case 1: Mentions in a post are linked and notified, unknown names and code are ignored
+	t.Run("Post Mentions", func(t *testing.T) {
+		body := "Hi @mention_bob and @nobody_here, also `@mention_carol`\n\n```\n@mention_carol\n```\n\nAnd me, @mention_alice."
+		w := do("POST", fmt.Sprintf("/topics/%d/posts", topicID), alice, map[string]string{"title": "Mentions", "body": body})
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		postID = int64(resp["post_id"].(float64))
+
+		_ = json.Unmarshal(do("GET", fmt.Sprintf("/posts/%d", postID), "", nil).Body.Bytes(), &resp)
+		html := resp["body_html"].(string)
+		assert.Contains(t, html, `<a href="/users/mention_bob" class="mention"`)
+		assert.NotContains(t, html, `href="/users/nobody_here"`)
+		assert.NotContains(t, html, `href="/users/mention_carol"`)
+
+		assert.Equal(t, 1, mentionNotifications(bobID))
+		assert.Equal(t, 0, mentionNotifications(carolID))
+		assert.Equal(t, 0, mentionNotifications(aliceID)) // Mentioning yourself does not notify
+
+		var payload map[string]interface{}
+		_ = dbConn.QueryRow(context.Background(), "SELECT payload FROM notifications WHERE user_id = $1", bobID).Scan(&payload)
+		assert.Equal(t, float64(postID), payload["post_id"])
+		assert.Equal(t, float64(aliceID), payload["mentioned_by"])
+	})
+
+	// Test Case 2: Editing a post only notifies users mentioned for the first time
+	t.Run("Edit Post", func(t *testing.T) {
+		w := do("PATCH", fmt.Sprintf("/posts/%d", postID), alice, map[string]string{"body": "Hi @mention_bob and @mention_carol"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Contains(t, resp["body_html"], `href="/users/mention_carol"`)
+		assert.NotNil(t, resp["updated_at"])
+
+		assert.Equal(t, 1, mentionNotifications(bobID))
+		assert.Equal(t, 1, mentionNotifications(carolID))
+
+		// Removed mentions are dropped
+		do("PATCH", fmt.Sprintf("/posts/%d", postID), alice, map[string]string{"body": "Just @mention_carol now"})
+		var mentions int
+		_ = dbConn.QueryRow(context.Background(), "SELECT COUNT(*) FROM mentions WHERE post_id = $1", postID).Scan(&mentions)
+		assert.Equal(t, 1, mentions)
+
+		// The title alone can change, and only the author may edit
+		w = do("PATCH", fmt.Sprintf("/posts/%d", postID), alice, map[string]string{"title": "Renamed"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = do("PATCH", fmt.Sprintf("/posts/%d", postID), bob, map[string]string{"title": "Hijacked"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = do("PATCH", fmt.Sprintf("/posts/%d", postID), alice, map[string]string{"title": ""})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test Case 3: Comment mentions and edits
+	t.Run("Comment Mentions", func(t *testing.T) {
+		w := do("POST", fmt.Sprintf("/posts/%d/comments", postID), bob, map[string]string{"body": "Thanks @mention_alice!"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		commentID := int64(resp["comment_id"].(float64))
+		assert.Contains(t, resp["body_html"], `href="/users/mention_alice"`)
+		assert.Equal(t, 1, mentionNotifications(aliceID))
+
+		var payload map[string]interface{}
+		_ = dbConn.QueryRow(context.Background(), "SELECT payload FROM notifications WHERE user_id = $1", aliceID).Scan(&payload)
+		assert.Equal(t, float64(commentID), payload["comment_id"])
+
+		w = do("PATCH", fmt.Sprintf("/comments/%d", commentID), bob, map[string]string{"body": "Thanks @mention_alice and @mention_carol!"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NotNil(t, resp["edited_at"])
+		assert.Equal(t, 1, mentionNotifications(aliceID))
+		assert.Equal(t, 2, mentionNotifications(carolID))
+
+		w = do("PATCH", fmt.Sprintf("/comments/%d", commentID), alice, map[string]string{"body": "Not mine"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
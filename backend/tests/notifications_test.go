@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifications(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username string) string {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": username}).Body.Bytes(), &resp)
+		return resp["token"].(string)
+	}
+	type notificationList struct {
+		Notifications []struct {
+			NotificationID int64                  `json:"notification_id"`
+			Type           string                 `json:"type"`
+			Payload        map[string]interface{} `json:"payload"`
+			ReadAt         *string                `json:"read_at"`
+		} `json:"notifications"`
+		UnreadCount int64 `json:"unread_count"`
+	}
+	list := func(token, query string) notificationList {
+		var resp notificationList
+		w := do("GET", "/notifications"+query, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	author := login("notify_author")
+	replier := login("notify_replier")
+	moderator := login("notify_mod")
+	_, err = dbConn.Exec(context.Background(), "UPDATE users SET role = 'moderator' WHERE username = 'notify_mod'")
+	assert.NoError(t, err)
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(do("POST", "/topics", author, map[string]string{"name": "notifyTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	topicID := int64(resp["topic_id"].(float64))
+	_ = json.Unmarshal(do("POST", fmt.Sprintf("/topics/%d/posts", topicID), author, map[string]string{"title": "Post", "body": "Body"}).Body.Bytes(), &resp)
+	postID := int64(resp["post_id"].(float64))
+
+	var commentID int64
+
+	// Test Case 1: Replies notify the post and comment authors, but not people replying to themselves
+	t.Run("Reply Notifications", func(t *testing.T) {
+		_ = json.Unmarshal(do("POST", fmt.Sprintf("/posts/%d/comments", postID), replier, map[string]string{"body": "Nice post"}).Body.Bytes(), &resp)
+		commentID = int64(resp["comment_id"].(float64))
+		do("POST", fmt.Sprintf("/posts/%d/comments", postID), author, map[string]interface{}{"body": "Thanks", "parent_id": commentID})
+		do("POST", fmt.Sprintf("/posts/%d/comments", postID), author, map[string]string{"body": "Talking to myself"})
+
+		notifications := list(author, "")
+		assert.Equal(t, int64(1), notifications.UnreadCount)
+		if assert.Len(t, notifications.Notifications, 1) {
+			assert.Equal(t, "post_reply", notifications.Notifications[0].Type)
+			assert.Equal(t, float64(commentID), notifications.Notifications[0].Payload["comment_id"])
+		}
+
+		// Commenting watched the post, so the replier also hears about the author's later comment
+		notifications = list(replier, "")
+		assert.Equal(t, int64(2), notifications.UnreadCount)
+		if assert.Len(t, notifications.Notifications, 2) {
+			assert.Equal(t, "watched_post", notifications.Notifications[0].Type)
+			assert.Equal(t, "comment_reply", notifications.Notifications[1].Type)
+			assert.Equal(t, float64(commentID), notifications.Notifications[1].Payload["parent_id"])
+		}
+	})
+
+	// Test Case 2: Mark notifications read, individually or all at once
+	t.Run("Mark Read", func(t *testing.T) {
+		do("POST", fmt.Sprintf("/posts/%d/comments", postID), replier, map[string]string{"body": "Another one"})
+		notifications := list(author, "")
+		assert.Equal(t, int64(2), notifications.UnreadCount)
+
+		first := notifications.Notifications[0].NotificationID
+		w := do("POST", "/notifications/read", author, map[string]interface{}{"notification_ids": []int64{first}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, float64(1), resp["marked"])
+		assert.Equal(t, float64(1), resp["unread_count"])
+
+		unread := list(author, "?unread=true")
+		assert.Len(t, unread.Notifications, 1)
+		assert.NotEqual(t, first, unread.Notifications[0].NotificationID)
+
+		// Someone else's notifications are left alone
+		w = do("POST", "/notifications/read", replier, map[string]interface{}{"notification_ids": []int64{unread.Notifications[0].NotificationID}})
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, float64(0), resp["marked"])
+
+		w = do("POST", "/notifications/read", author, map[string]interface{}{"all": true})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(0), list(author, "").UnreadCount)
+		assert.Len(t, list(author, "?limit=1").Notifications, 1)
+
+		w = do("POST", "/notifications/read", author, map[string]interface{}{})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test Case 3: Turned off types are not delivered
+	t.Run("Preferences", func(t *testing.T) {
+		w := do("GET", "/users/me/notification-preferences", author, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, true, resp["post_reply"])
+
+		w = do("PUT", "/users/me/notification-preferences", author, map[string]bool{"post_reply": false})
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, false, resp["post_reply"])
+		assert.Equal(t, true, resp["mention"])
+
+		w = do("PUT", "/users/me/notification-preferences", author, map[string]bool{"account_locked": false})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		before := len(list(author, "").Notifications)
+		do("POST", fmt.Sprintf("/posts/%d/comments", postID), replier, map[string]string{"body": "Muted"})
+		assert.Len(t, list(author, "").Notifications, before)
+	})
+
+	// Test Case 4: Moderators can remove content, and the author is told why
+	t.Run("Moderation", func(t *testing.T) {
+		w := do("DELETE", fmt.Sprintf("/moderation/comments/%d", commentID), replier, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("DELETE", fmt.Sprintf("/moderation/comments/%d", commentID), moderator, map[string]string{"reason": "Off topic"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = do("DELETE", fmt.Sprintf("/moderation/comments/%d", commentID), moderator, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		notifications := list(replier, "?unread=true")
+		found := false
+		for _, n := range notifications.Notifications {
+			if n.Type == "moderation" {
+				found = true
+				assert.Equal(t, "comment_removed", n.Payload["action"])
+				assert.Equal(t, "Off topic", n.Payload["reason"])
+			}
+		}
+		assert.True(t, found)
+
+		w = do("DELETE", fmt.Sprintf("/moderation/posts/%d", postID), moderator, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		notifications = list(author, "?unread=true")
+		if assert.NotEmpty(t, notifications.Notifications) {
+			assert.Equal(t, "moderation", notifications.Notifications[0].Type)
+			assert.Equal(t, "post_removed", notifications.Notifications[0].Payload["action"])
+		}
+	})
+
+	// Test Case 5: Notifications require a login
+	t.Run("Unauthenticated", func(t *testing.T) {
+		w := do("GET", "/notifications", "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
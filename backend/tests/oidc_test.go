@@ -0,0 +1,338 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/coreos/go-oidc/v3/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockIdP is a minimal OpenID Connect provider that issues ID tokens for whichever user is signing in
+type mockIdP struct {
+	*httptest.Server
+	priv *rsa.PrivateKey
+
+	mu        sync.Mutex
+	challenge string // PKCE challenge from the last authorization request
+	nonce     string
+	sub       string
+	username  string
+	email     string // Sent as a verified email claim when set
+	groups    []string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	idp := &mockIdP{priv: priv}
+	discovery := &oidctest.Server{
+		PublicKeys: []oidctest.PublicKey{{PublicKey: priv.Public(), KeyID: "test-key", Algorithm: oidc.RS256}},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", discovery)
+	mux.HandleFunc("/token", idp.token)
+	idp.Server = httptest.NewServer(mux)
+	discovery.SetIssuer(idp.URL)
+	return idp
+}
+
+// token checks the PKCE verifier against the challenge and returns a signed ID token
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != idp.challenge {
+		http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	claims := map[string]interface{}{
+		"iss":                idp.URL,
+		"aud":                "forum",
+		"sub":                idp.sub,
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              idp.nonce,
+		"preferred_username": idp.username,
+		"groups":             idp.groups,
+	}
+	if idp.email != "" {
+		claims["email"] = idp.email
+		claims["email_verified"] = true
+	}
+	claimsJSON, _ := json.Marshal(claims)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     oidctest.SignIDToken(idp.priv, "test-key", oidc.RS256, string(claimsJSON)),
+	})
+}
+
+func TestOIDC(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	idp := newMockIdP(t)
+	defer idp.Close()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.RateLimit.Enabled = false
+	cfg.OIDC = config.OIDCConfig{
+		IssuerURL:   idp.URL,
+		ClientID:    "forum",
+		RedirectURL: "http://localhost/auth/oidc/callback",
+		Scopes:      []string{"profile"},
+		GroupsClaim: "groups",
+		RoleMapping: map[string]string{"forum-admins": "admin"},
+	}
+	r := StartRouter(t, cfg, dbConn)
+
+	// Helpers
+	// callbackRequest builds the IdP's redirect back to the forum in the browser that started the login
+	callbackRequest := func(login *httptest.ResponseRecorder, state string) *http.Request {
+		req := httptest.NewRequest("GET", "/auth/oidc/callback?code=abc&state="+url.QueryEscape(state), nil)
+		for _, cookie := range login.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+		return req
+	}
+
+	// signIn walks through the login redirect and callback as the given IdP user
+	signIn := func(sub, username string, groups []string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/auth/oidc/login", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusFound {
+			t.Fatalf("Login did not redirect: %d %s", w.Code, w.Body.String())
+		}
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("Invalid redirect: %v", err)
+		}
+		query := location.Query()
+		assert.Equal(t, "S256", query.Get("code_challenge_method"))
+
+		idp.mu.Lock()
+		idp.challenge = query.Get("code_challenge")
+		idp.nonce = query.Get("nonce")
+		idp.sub, idp.username, idp.groups = sub, username, groups
+		idp.mu.Unlock()
+
+		callback := httptest.NewRecorder()
+		r.ServeHTTP(callback, callbackRequest(w, query.Get("state")))
+		return callback
+	}
+
+	var firstUserID float64
+
+	// Test Case 1: First login provisions a user
+	t.Run("Provision User", func(t *testing.T) {
+		w := signIn("sub-1", "sso_user", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp["token"])
+		assert.Equal(t, "sso_user", resp["username"])
+		assert.Equal(t, "member", resp["role"])
+		firstUserID = resp["user_id"].(float64)
+	})
+
+	// Test Case 2: Later logins reuse the linked user and sync the role from groups
+	t.Run("Existing Identity And Role Mapping", func(t *testing.T) {
+		w := signIn("sub-1", "renamed_at_idp", []string{"forum-admins"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.Equal(t, firstUserID, resp["user_id"])
+		assert.Equal(t, "sso_user", resp["username"])
+		assert.Equal(t, "admin", resp["role"])
+	})
+
+	// Test Case 3: SSO accounts have no password and cannot use password login, whatever their role
+	t.Run("Password Login Refused", func(t *testing.T) {
+		for _, password := range []string{"", "guess"} {
+			body, _ := json.Marshal(map[string]string{"username": "sso_user", "password": password})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("POST", "/login", bytes.NewBuffer(body)))
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.NotContains(t, w.Body.String(), "token")
+		}
+	})
+
+	// Test Case 4: Another IdP user with a taken username is not linked to it
+	t.Run("Username Collision", func(t *testing.T) {
+		w := signIn("sub-2", "sso_user", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.NotEqual(t, firstUserID, resp["user_id"])
+		assert.Equal(t, "sso_user-2", resp["username"])
+	})
+
+	// Test Case 5: States are single use
+	t.Run("Replayed State", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/auth/oidc/login", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		query := location.Query()
+
+		idp.mu.Lock()
+		idp.challenge = query.Get("code_challenge")
+		idp.nonce = query.Get("nonce")
+		idp.sub, idp.username, idp.groups = "sub-3", "replay", nil
+		idp.mu.Unlock()
+
+		callback := httptest.NewRecorder()
+		r.ServeHTTP(callback, callbackRequest(w, query.Get("state")))
+		assert.Equal(t, http.StatusOK, callback.Code)
+
+		callback = httptest.NewRecorder()
+		r.ServeHTTP(callback, callbackRequest(w, query.Get("state")))
+		assert.Equal(t, http.StatusBadRequest, callback.Code)
+	})
+
+	// Test Case 6: A token with the wrong nonce is rejected
+	t.Run("Nonce Mismatch", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/auth/oidc/login", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		query := location.Query()
+
+		idp.mu.Lock()
+		idp.challenge = query.Get("code_challenge")
+		idp.nonce = "something-else"
+		idp.sub, idp.username, idp.groups = "sub-4", "nonce", nil
+		idp.mu.Unlock()
+
+		callback := httptest.NewRecorder()
+		r.ServeHTTP(callback, callbackRequest(w, query.Get("state")))
+		assert.Equal(t, http.StatusUnauthorized, callback.Code)
+	})
+
+	// Test Case 7: A callback without the state cookie is refused, so an attacker cannot finish their own login in a victim's browser
+	t.Run("Missing State Cookie", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/auth/oidc/login", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		query := location.Query()
+
+		idp.mu.Lock()
+		idp.challenge = query.Get("code_challenge")
+		idp.nonce = query.Get("nonce")
+		idp.sub, idp.username, idp.groups = "sub-7", "csrf", nil
+		idp.mu.Unlock()
+
+		callback := httptest.NewRecorder()
+		r.ServeHTTP(callback, httptest.NewRequest("GET", "/auth/oidc/callback?code=abc&state="+url.QueryEscape(query.Get("state")), nil))
+		assert.Equal(t, http.StatusBadRequest, callback.Code)
+	})
+
+	// Test Case 8: Linking never trusts a matching username, since anyone can claim one with a password login
+	t.Run("No Link By Username", func(t *testing.T) {
+		cfg.OIDC.LinkExistingUsers = true
+		r = StartRouter(t, cfg, dbConn)
+
+		body, _ := json.Marshal(map[string]string{"username": "alice", "password": "attacker"})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/login", bytes.NewBuffer(body)))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		idp.mu.Lock()
+		idp.email = "alice@example.com"
+		idp.mu.Unlock()
+		w = signIn("sub-5", "alice", []string{"forum-admins"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.Equal(t, "alice-2", resp["username"])
+	})
+
+	// Test Case 9: Accounts are linked when the verified email matches a verified forum email
+	t.Run("Link By Verified Email", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]string{"username": "carol", "password": "password"})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/login", bytes.NewBuffer(body)))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var loginResp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &loginResp)
+
+		_, err := dbConn.Exec(context.Background(), "UPDATE users SET email = 'Carol@example.com', email_verified_at = NOW() WHERE username = 'carol'")
+		assert.NoError(t, err)
+
+		idp.mu.Lock()
+		idp.email = "carol@example.com"
+		idp.mu.Unlock()
+		w = signIn("sub-6", "carol_at_idp", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		err = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.Equal(t, loginResp["user_id"], resp["user_id"])
+		assert.Equal(t, "carol", resp["username"])
+
+		idp.mu.Lock()
+		idp.email = ""
+		idp.mu.Unlock()
+	})
+
+	// Test Case 10: The redirect flow hands the token to the frontend
+	t.Run("Post Login Redirect", func(t *testing.T) {
+		cfg.OIDC.PostLoginRedirect = "http://localhost:5173/sso"
+		r = StartRouter(t, cfg, dbConn)
+
+		w := signIn("sub-1", "sso_user", []string{"forum-admins"})
+		assert.Equal(t, http.StatusFound, w.Code)
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		assert.NoError(t, err)
+		fragment, err := url.ParseQuery(location.Fragment)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://localhost:5173/sso", location.Scheme+"://"+location.Host+location.Path)
+		assert.NotEmpty(t, fragment.Get("token"))
+		assert.Equal(t, strconv.FormatInt(int64(firstUserID), 10), fragment.Get("user_id"))
+	})
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/database"
+	"github.com/DamienFooxx/CVWOForum/internal/outbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutbox(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+	ctx := context.Background()
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username string) string {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": username}).Body.Bytes(), &resp)
+		return resp["token"].(string)
+	}
+	type eventState struct {
+		Type        string
+		DeliveredTo []string
+		Dispatched  bool
+		LastError   *string
+	}
+	eventsOfType := func(eventType string) []eventState {
+		rows, err := dbConn.Query(ctx, "SELECT type, delivered_to, dispatched_at IS NOT NULL, last_error FROM outbox_events WHERE type = $1 ORDER BY event_id", eventType)
+		assert.NoError(t, err)
+		defer rows.Close()
+		var states []eventState
+		for rows.Next() {
+			var state eventState
+			assert.NoError(t, rows.Scan(&state.Type, &state.DeliveredTo, &state.Dispatched, &state.LastError))
+			states = append(states, state)
+		}
+		return states
+	}
+	dispatched := func(eventType string) bool {
+		states := eventsOfType(eventType)
+		if len(states) == 0 {
+			return false
+		}
+		for _, state := range states {
+			if !state.Dispatched {
+				return false
+			}
+		}
+		return true
+	}
+
+	member := login("outbox_member")
+	var topicID, postID int64
+
+	// Test Case 1: Creating content writes events that are handed to every subscriber
+	t.Run("Dispatch Created Events", func(t *testing.T) {
+		w := do("POST", "/topics", member, map[string]string{"name": "Outbox Topic", "description": "Events"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		topicID = int64(resp["topic_id"].(float64))
+
+		w = do("POST", fmt.Sprintf("/topics/%d/posts", topicID), member, map[string]string{"title": "Outbox Post", "body": "Body"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		postID = int64(resp["post_id"].(float64))
+
+		w = do("POST", fmt.Sprintf("/posts/%d/comments", postID), member, map[string]string{"body": "Outbox comment"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		for _, eventType := range []string{"topic.created", "post.created", "comment.created"} {
+			assert.Eventually(t, func() bool { return dispatched(eventType) }, 5*time.Second, 50*time.Millisecond, eventType)
+		}
+		assert.ElementsMatch(t, []string{"webhooks"}, eventsOfType("topic.created")[0].DeliveredTo)
+		assert.ElementsMatch(t, []string{"webhooks", "realtime"}, eventsOfType("post.created")[0].DeliveredTo)
+		assert.ElementsMatch(t, []string{"webhooks", "realtime"}, eventsOfType("comment.created")[0].DeliveredTo)
+
+		// The realtime subscriber published to the followers of the topic and post
+		var count int
+		err := dbConn.QueryRow(ctx, "SELECT COUNT(*) FROM events WHERE channel = $1 AND type = 'post_created'", fmt.Sprintf("topic:%d", topicID)).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		err = dbConn.QueryRow(ctx, "SELECT COUNT(*) FROM events WHERE channel = $1 AND type = 'comment_created'", fmt.Sprintf("post:%d", postID)).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	// Test Case 2: Removals are recorded with the topic they happened in
+	t.Run("Content Removed", func(t *testing.T) {
+		w := do("DELETE", fmt.Sprintf("/posts/%d", postID), member, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Eventually(t, func() bool { return dispatched("content.removed") }, 5*time.Second, 50*time.Millisecond)
+		var payload outbox.ContentRemovedEvent
+		err := dbConn.QueryRow(ctx, "SELECT payload FROM outbox_events WHERE type = 'content.removed'").Scan(&payload)
+		assert.NoError(t, err)
+		assert.Equal(t, "post", payload.Kind)
+		assert.Equal(t, topicID, payload.TopicID)
+		assert.Equal(t, postID, payload.PostID)
+		assert.False(t, payload.ByModerator)
+	})
+
+	// Test Case 3: An event is only written if the change it describes is committed
+	t.Run("Rolled Back With The Change", func(t *testing.T) {
+		queries := database.New(dbConn)
+		failure := errors.New("change failed")
+		err := queries.InTx(ctx, func(tx *database.Queries) error {
+			if err := outbox.Write(ctx, tx, outbox.TopicCreated, outbox.TopicCreatedEvent{TopicID: topicID, Name: "Never created"}); err != nil {
+				return err
+			}
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+
+		var count int
+		err = dbConn.QueryRow(ctx, "SELECT COUNT(*) FROM outbox_events WHERE payload->>'name' = 'Never created'").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	// Test Case 4: Retried events skip the subscribers that already handled them
+	t.Run("Partial Redelivery", func(t *testing.T) {
+		payload, _ := json.Marshal(outbox.PostCreatedEvent{PostID: postID, TopicID: topicID, Title: "Redelivered", CreatedBy: 1, CreatedAt: time.Now().Format(time.RFC3339)})
+		var eventID int64
+		err := dbConn.QueryRow(ctx, "INSERT INTO outbox_events (type, payload, delivered_to, attempts, last_error) VALUES ('post.created', $1, '{realtime}', 1, 'webhooks: unavailable') RETURNING event_id", payload).Scan(&eventID)
+		assert.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			var done bool
+			_ = dbConn.QueryRow(ctx, "SELECT dispatched_at IS NOT NULL FROM outbox_events WHERE event_id = $1", eventID).Scan(&done)
+			return done
+		}, 5*time.Second, 50*time.Millisecond)
+
+		var deliveredTo []string
+		var lastError *string
+		err = dbConn.QueryRow(ctx, "SELECT delivered_to, last_error FROM outbox_events WHERE event_id = $1", eventID).Scan(&deliveredTo, &lastError)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"realtime", "webhooks"}, deliveredTo)
+		assert.Nil(t, lastError)
+
+		// Followers of the topic did not hear of the post a second time
+		var count int
+		err = dbConn.QueryRow(ctx, "SELECT COUNT(*) FROM events WHERE channel = $1 AND payload->>'title' = 'Redelivered'", fmt.Sprintf("topic:%d", topicID)).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
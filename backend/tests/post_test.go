@@ -9,8 +9,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/DamienFooxx/CVWOForum/internal/database"
-	"github.com/DamienFooxx/CVWOForum/internal/router"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,8 +18,7 @@ func TestPosts(t *testing.T) {
 	defer dbConn.Close()
 	ClearDB(t, dbConn)
 
-	queries := database.New(dbConn)
-	r := router.NewRouter(queries)
+	r := SetupRouter(t, dbConn)
 
 	// Helpers
 	getToken := func(username string) string {
@@ -166,13 +163,15 @@ func TestPosts(t *testing.T) {
 
 		// Verify post count decremented
 		urlTopic := fmt.Sprintf("/topics/%d", topicID)
-		reqTopic := httptest.NewRequest("GET", urlTopic, nil)
-		wTopic := httptest.NewRecorder()
-		r.ServeHTTP(wTopic, reqTopic)
-		var topicResp map[string]interface{}
-		json.Unmarshal(wTopic.Body.Bytes(), &topicResp)
-		// Count should be 2.
-		assert.Equal(t, float64(2), topicResp["post_count"])
+		// Count should be 2, once the background recount has run.
+		assert.Eventually(t, func() bool {
+			reqTopic := httptest.NewRequest("GET", urlTopic, nil)
+			wTopic := httptest.NewRecorder()
+			r.ServeHTTP(wTopic, reqTopic)
+			var topicResp map[string]interface{}
+			json.Unmarshal(wTopic.Body.Bytes(), &topicResp)
+			return topicResp["post_count"] == float64(2)
+		}, 5*time.Second, 50*time.Millisecond)
 	})
 
 	// Test Case 6: Delete Post Non-Creator
@@ -195,7 +194,14 @@ func TestPosts(t *testing.T) {
 		wReg := httptest.NewRecorder()
 		r.ServeHTTP(wReg, reqReg)
 
-		token2 := getToken("user2_post")
+		// user2 registered with a password, so it is needed to log in
+		reqLogin, _ := http.NewRequest("POST", "/login", bytes.NewBufferString(`{"username": "user2_post", "password": "password"}`))
+		reqLogin.Header.Set("Content-Type", "application/json")
+		wLogin := httptest.NewRecorder()
+		r.ServeHTTP(wLogin, reqLogin)
+		var loginResp map[string]interface{}
+		_ = json.Unmarshal(wLogin.Body.Bytes(), &loginResp)
+		token2, _ := loginResp["token"].(string)
 
 		// Try to delete user1's post with user2's token
 		url := fmt.Sprintf("/posts/%d", postID)
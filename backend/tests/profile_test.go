@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfiles(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	getToken := func(username string) string {
+		w := do("POST", "/login", "", map[string]string{"username": username})
+		return decode(w)["token"].(string)
+	}
+
+	owner := getToken("profile_owner")
+	other := getToken("profile_other")
+
+	w := do("POST", "/topics", owner, map[string]string{"name": "profileTopic", "description": "Desc"})
+	topicID := int64(decode(w)["topic_id"].(float64))
+	do("POST", fmt.Sprintf("/topics/%d/posts", topicID), owner, map[string]string{"title": "profilePost", "body": "Body"})
+
+	// Test Case 1: Update own profile
+	t.Run("Update Profile", func(t *testing.T) {
+		w := do("PATCH", "/users/me", owner, map[string]string{"display_name": "  Owner  ", "bio": "Hello"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		resp := decode(w)
+		assert.Equal(t, "Owner", resp["display_name"])
+		assert.Equal(t, "Hello", resp["bio"])
+		assert.Equal(t, "public", resp["profile_visibility"])
+
+		// Fields not sent are unchanged
+		w = do("PATCH", "/users/me", owner, map[string]string{"display_name": "The Owner"})
+		assert.Equal(t, "Hello", decode(w)["bio"])
+	})
+
+	// Test Case 2: Invalid settings are rejected
+	t.Run("Invalid Visibility", func(t *testing.T) {
+		w := do("PATCH", "/users/me", owner, map[string]string{"profile_visibility": "friends"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test Case 3: Public profile with counts and recent activity
+	t.Run("Public Profile", func(t *testing.T) {
+		w := do("GET", "/users/profile_owner", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		resp := decode(w)
+		assert.Equal(t, "The Owner", resp["display_name"])
+		assert.Equal(t, "Hello", resp["bio"])
+		assert.Nil(t, resp["show_activity"])
+
+		stats := resp["stats"].(map[string]interface{})
+		assert.Equal(t, float64(1), stats["topic_count"])
+		assert.Equal(t, float64(1), stats["post_count"])
+		assert.Equal(t, float64(0), stats["comment_count"])
+
+		activity := resp["recent_activity"].([]interface{})
+		assert.Len(t, activity, 2)
+	})
+
+	// Test Case 4: Members-only profiles are hidden from anonymous visitors
+	t.Run("Members Visibility", func(t *testing.T) {
+		do("PATCH", "/users/me", owner, map[string]string{"profile_visibility": "members"})
+
+		resp := decode(do("GET", "/users/profile_owner", "", nil))
+		assert.Equal(t, "profile_owner", resp["username"])
+		assert.Nil(t, resp["bio"])
+		assert.Nil(t, resp["stats"])
+
+		resp = decode(do("GET", "/users/profile_owner", other, nil))
+		assert.Equal(t, "Hello", resp["bio"])
+		assert.NotNil(t, resp["stats"])
+	})
+
+	// Test Case 5: Hidden activity is still shown to the owner and moderators
+	t.Run("Hidden Activity", func(t *testing.T) {
+		do("PATCH", "/users/me", owner, map[string]interface{}{"profile_visibility": "public", "show_activity": false})
+
+		resp := decode(do("GET", "/users/profile_owner", other, nil))
+		assert.Equal(t, "Hello", resp["bio"])
+		assert.Nil(t, resp["stats"])
+		assert.Nil(t, resp["recent_activity"])
+
+		resp = decode(do("GET", "/users/profile_owner", owner, nil))
+		assert.Equal(t, false, resp["show_activity"])
+		assert.NotNil(t, resp["recent_activity"])
+
+		_, err := dbConn.Exec(context.Background(), "UPDATE users SET role = 'moderator' WHERE username = 'profile_other'")
+		assert.NoError(t, err)
+		resp = decode(do("GET", "/users/profile_owner", other, nil))
+		assert.NotNil(t, resp["recent_activity"])
+	})
+
+	// Test Case 6: Unknown user
+	t.Run("Profile Not Found", func(t *testing.T) {
+		w := do("GET", "/users/nobody_here", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	// Test Case 7: Only admins can list users
+	t.Run("User Directory", func(t *testing.T) {
+		w := do("GET", "/users", owner, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		_, err := dbConn.Exec(context.Background(), "UPDATE users SET role = 'admin' WHERE username = 'profile_owner'")
+		assert.NoError(t, err)
+
+		w = do("GET", "/users?limit=1", owner, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var users []map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &users)
+		assert.Len(t, users, 1)
+
+		w = do("GET", "/users?q=the%20owner", owner, nil)
+		_ = json.Unmarshal(w.Body.Bytes(), &users)
+		assert.Len(t, users, 1)
+		assert.Equal(t, "profile_owner", users[0]["username"])
+	})
+}
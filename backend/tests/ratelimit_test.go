@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Helpers
+	login := func(r http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+		payload := []byte(`{"username": "ratelimited", "password": "password"}`)
+		req := httptest.NewRequest("POST", "/login", bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, backend := range []string{"memory", "postgres"} {
+		// Test Case: Auth routes allow 2 requests a minute per IP
+		t.Run("Login Limited With "+backend+" Backend", func(t *testing.T) {
+			ClearDB(t, dbConn)
+			cfg.RateLimit.Enabled = true
+			cfg.RateLimit.Backend = backend
+			cfg.RateLimit.Auth = config.RateLimitRule{Requests: 2, Period: time.Minute}
+			r := StartRouter(t, cfg, dbConn)
+
+			w := login(r, "10.0.0.1:1234")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+
+			w = login(r, "10.0.0.1:1234")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+			// Bucket is empty, refills one token every 30 seconds
+			w = login(r, "10.0.0.1:1234")
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+			assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+			// Other clients have their own bucket
+			w = login(r, "10.0.0.2:1234")
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+
+	// Test Case: Reads are not limited by the auth bucket
+	t.Run("Reads Use Separate Bucket", func(t *testing.T) {
+		ClearDB(t, dbConn)
+		cfg.RateLimit.Enabled = true
+		cfg.RateLimit.Backend = "memory"
+		cfg.RateLimit.Auth = config.RateLimitRule{Requests: 1, Period: time.Minute}
+		r := StartRouter(t, cfg, dbConn)
+
+		assert.Equal(t, http.StatusOK, login(r, "10.0.0.3:1234").Code)
+		assert.Equal(t, http.StatusTooManyRequests, login(r, "10.0.0.3:1234").Code)
+
+		req := httptest.NewRequest("GET", "/topics", nil)
+		req.RemoteAddr = "10.0.0.3:1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	// Test Case: Behind a proxy, a client cannot dodge its bucket by forging X-Forwarded-For
+	t.Run("Forged Forwarded For Ignored", func(t *testing.T) {
+		ClearDB(t, dbConn)
+		cfg.RateLimit.Enabled = true
+		cfg.RateLimit.Backend = "memory"
+		cfg.RateLimit.TrustProxyHeaders = true
+		cfg.RateLimit.Auth = config.RateLimitRule{Requests: 1, Period: time.Minute}
+		r := StartRouter(t, cfg, dbConn)
+
+		loginVia := func(forwardedFor string) int {
+			req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(`{"username": "ratelimited", "password": "password"}`))
+			req.RemoteAddr = "10.0.0.100:1234" // The proxy
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusOK, loginVia("1.1.1.1, 203.0.113.7"))
+		assert.Equal(t, http.StatusTooManyRequests, loginVia("2.2.2.2, 203.0.113.7"))
+		assert.Equal(t, http.StatusOK, loginVia("1.1.1.1, 203.0.113.8"))
+	})
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTracking(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	getToken := func(username string) string {
+		w := do("POST", "/login", "", map[string]string{"username": username})
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp["token"].(string)
+	}
+	// field maps each item of a JSON array, by its "key", to its "field", nil when missing
+	field := func(w *httptest.ResponseRecorder, key, field string) map[string]interface{} {
+		assert.Equal(t, http.StatusOK, w.Code)
+		var items []map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &items)
+		values := map[string]interface{}{}
+		for _, item := range items {
+			values[item[key].(string)] = item[field]
+		}
+		return values
+	}
+
+	author := getToken("reads_author")
+	reader := getToken("reads_reader")
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(do("POST", "/topics", author, map[string]string{"name": "readsTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	topicID := int64(resp["topic_id"].(float64))
+	createPost := func(title string) int64 {
+		_ = json.Unmarshal(do("POST", fmt.Sprintf("/topics/%d/posts", topicID), author, map[string]string{"title": title, "body": "Body"}).Body.Bytes(), &resp)
+		return int64(resp["post_id"].(float64))
+	}
+	comment := func(postID int64) {
+		w := do("POST", fmt.Sprintf("/posts/%d/comments", postID), author, map[string]string{"body": "Comment"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	unread := func(token string) map[string]interface{} {
+		return field(do("GET", fmt.Sprintf("/topics/%d/posts", topicID), token, nil), "title", "unread_comment_count")
+	}
+	hasNewPosts := func(token string) interface{} {
+		return field(do("GET", "/topics", token, nil), "name", "has_new_posts")["readsTopic"]
+	}
+	first := createPost("First")
+	second := createPost("Second")
+
+	// Test Case 1: Comments after the user's read position are unread, until they read the post
+	t.Run("Unread Comments", func(t *testing.T) {
+		comment(first)
+		comment(first)
+		assert.Equal(t, map[string]interface{}{"First": float64(2), "Second": float64(0)}, unread(reader))
+		assert.Equal(t, map[string]interface{}{"First": float64(0), "Second": float64(0)}, unread(author)) // Their own comments
+		assert.Equal(t, map[string]interface{}{"First": nil, "Second": nil}, unread(""))
+
+		do("GET", fmt.Sprintf("/posts/%d/comments", first), reader, nil)
+		assert.Equal(t, float64(0), unread(reader)["First"])
+
+		comment(first)
+		assert.Equal(t, float64(1), unread(reader)["First"])
+		w := do("PUT", fmt.Sprintf("/posts/%d/read", first), reader, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, float64(0), unread(reader)["First"])
+
+		// Read positions only move forward
+		w = do("PUT", fmt.Sprintf("/posts/%d/read", first), reader, map[string]int64{"last_read_comment_id": 0})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, float64(0), unread(reader)["First"])
+	})
+
+	// Test Case 2: Topics have new posts when someone else posted since the user's last visit
+	t.Run("New Posts", func(t *testing.T) {
+		assert.Equal(t, false, hasNewPosts(reader))
+
+		createPost("Third")
+		assert.Equal(t, true, hasNewPosts(reader))
+		assert.Equal(t, false, hasNewPosts(author))
+		assert.Nil(t, hasNewPosts(""))
+
+		// Search results carry it too
+		assert.Equal(t, true, field(do("GET", "/topics?q=readsTop", reader, nil), "name", "has_new_posts")["readsTopic"])
+
+		// Searching the topic's posts is not a visit, the new post may not be among the results
+		w := do("GET", fmt.Sprintf("/topics/%d/posts?q=First", topicID), reader, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, true, hasNewPosts(reader))
+
+		// Listing the topic's posts is a visit
+		assert.Equal(t, float64(0), unread(reader)["Third"])
+		assert.Equal(t, false, hasNewPosts(reader))
+	})
+
+	// Test Case 3: Marking a topic read clears every post in it
+	t.Run("Mark Topic Read", func(t *testing.T) {
+		comment(first)
+		comment(second)
+		createPost("Fourth")
+		assert.Equal(t, true, hasNewPosts(reader))
+
+		w := do("POST", fmt.Sprintf("/topics/%d/read", topicID), reader, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, false, hasNewPosts(reader))
+		for title, count := range unread(reader) {
+			assert.Equal(t, float64(0), count, title)
+		}
+
+		// Later comments are unread again
+		comment(second)
+		assert.Equal(t, float64(1), unread(reader)["Second"])
+
+		w = do("POST", "/topics/999999/read", reader, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = do("POST", fmt.Sprintf("/topics/%d/read", topicID), "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
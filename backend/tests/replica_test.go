@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/dbConnection"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicaRouting(t *testing.T) {
+	// Test Case 1: Only sqlc Get/List/Search/Count SELECTs without row locks go to the replica
+	t.Run("Read Only Queries", func(t *testing.T) {
+		tests := []struct {
+			name string
+			sql  string
+			want bool
+		}{
+			{"Get", "-- name: GetUser :one\nSELECT user_id FROM users WHERE user_id = $1\n", true},
+			{"List", "-- name: ListTopics :many\nSELECT topic_id FROM topics\n", true},
+			{"Search", "-- name: SearchPosts :many\nSELECT post_id FROM posts WHERE title ILIKE $1\n", true},
+			{"Count", "-- name: CountUsers :one\nSELECT COUNT(*) FROM users\n", true},
+			{"Lowercase Select", "-- name: GetUser :one\n  select user_id from users\n", true},
+			{"Fetch Stays On Primary", "-- name: FetchUserSessionsRevokedAt :one\nSELECT sessions_revoked_at FROM users WHERE user_id = $1\n", false},
+			{"Lock Stays On Primary", "-- name: LockAttachmentQuota :one\nSELECT user_id FROM users WHERE user_id = $1\n", false},
+			{"Create", "-- name: CreateUser :one\nINSERT INTO users (username) VALUES ($1) RETURNING user_id\n", false},
+			{"Read Name Writing", "-- name: GetOrCreateTag :one\nINSERT INTO tags (name) VALUES ($1) RETURNING tag_id\n", false},
+			{"Common Table Expression", "-- name: ListMoved :many\nWITH moved AS (UPDATE posts SET topic_id = $1 RETURNING post_id) SELECT post_id FROM moved\n", false},
+			{"For Update", "-- name: GetJob :one\nSELECT job_id FROM jobs WHERE job_id = $1 FOR UPDATE\n", false},
+			{"For Update Across Lines", "-- name: GetJob :one\nSELECT job_id FROM jobs\nWHERE job_id = $1\nFOR\n    UPDATE SKIP LOCKED\n", false},
+			{"For No Key Update", "-- name: GetJob :one\nSELECT job_id FROM jobs WHERE job_id = $1 FOR NO KEY UPDATE\n", false},
+			{"For Share", "-- name: GetUser :one\nSELECT user_id FROM users WHERE user_id = $1 FOR SHARE\n", false},
+			{"For Key Share", "-- name: GetUser :one\nSELECT user_id FROM users WHERE user_id = $1 FOR KEY SHARE\n", false},
+			{"No Header", "SELECT user_id FROM users", false},
+			{"Header Only", "-- name: GetUser :one", false},
+			{"Other Comment", "-- GetUser\nSELECT user_id FROM users\n", false},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.want, dbConnection.IsReadOnlyQuery(tt.sql))
+			})
+		}
+	})
+
+	// Test Case 2: A server that is not a standby is never used as the replica
+	t.Run("Not A Standby", func(t *testing.T) {
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		primary, err := dbConnection.NewDB(cfg.DatabaseURL, cfg.DB)
+		if err != nil {
+			t.Fatalf("Failed to connect to DB: %v", err)
+		}
+		replica, err := dbConnection.NewDB(cfg.DatabaseURL, cfg.DB)
+		if err != nil {
+			t.Fatalf("Failed to connect to DB: %v", err)
+		}
+		db := dbConnection.NewRoutedDB(primary, replica, time.Hour, time.Hour)
+		defer db.Close()
+
+		assert.False(t, db.ReplicaHealthy())
+	})
+}
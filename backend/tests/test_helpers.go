@@ -3,9 +3,13 @@ package tests
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/DamienFooxx/CVWOForum/internal/database"
 	"github.com/DamienFooxx/CVWOForum/internal/dbConnection"
+	"github.com/DamienFooxx/CVWOForum/internal/router"
+	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -16,7 +20,7 @@ func SetupDB(t *testing.T) *pgxpool.Pool {
 		t.Fatalf("Failed to load config: %v", err)
 	}
 
-	db, err := dbConnection.NewDB(cfg.DatabaseURL)
+	db, err := dbConnection.NewDB(cfg.DatabaseURL, cfg.DB)
 	if err != nil {
 		t.Fatalf("Failed to connect to DB: %v", err)
 	}
@@ -24,9 +28,47 @@ func SetupDB(t *testing.T) *pgxpool.Pool {
 	return db
 }
 
+// SetupRouter builds the router on top of the test database.
+// Rate limiting is turned off so tests can make as many requests as they need,
+// and admin tests do not need to enrol in 2FA unless they are testing that policy.
+func SetupRouter(t *testing.T, db *pgxpool.Pool) *chi.Mux {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.RateLimit.Enabled = false
+	cfg.TwoFactor.RequiredRoles = nil
+	cfg.Storage.LocalDir = t.TempDir()
+	cfg.Jobs.PollInterval = 50 * time.Millisecond
+	cfg.Outbox.PollInterval = 50 * time.Millisecond
+
+	// Emails go to the in-process SMTP server
+	smtpServer := SharedSMTP(t)
+	cfg.Mail = config.MailConfig{
+		Driver: "smtp",
+		From:   "CVWO Forum <noreply@forum.test>",
+		SMTP: config.SMTPConfig{
+			Host:    smtpServer.Host,
+			Port:    smtpServer.Port,
+			TLS:     "none",
+			Timeout: 5 * time.Second,
+		},
+	}
+
+	return StartRouter(t, cfg, db)
+}
+
+// StartRouter builds the router for cfg and runs its background workers until the test ends,
+// so workers from one test never pick up the jobs and events of the next
+func StartRouter(t *testing.T, cfg *config.Config, db *pgxpool.Pool) *chi.Mux {
+	r, workers := router.NewRouter(cfg, database.New(db))
+	t.Cleanup(workers.Start(context.Background()))
+	return r
+}
+
 // ClearDB truncates all tables to ensure a clean state
 func ClearDB(t *testing.T, db *pgxpool.Pool) {
-	_, err := db.Exec(context.Background(), "TRUNCATE users, topics, posts, comments CASCADE")
+	_, err := db.Exec(context.Background(), "TRUNCATE users, topics, posts, comments, rate_limit_buckets, login_attempts, login_throttles, personal_access_tokens, user_identities, oidc_login_states, user_totp, totp_recovery_codes, attachments, events, webhooks, jobs, outbox_events, email_tokens, topic_follows, topic_mutes, digest_subscriptions, digests, post_watches, post_reads, topic_visits, bookmark_folders, bookmarks CASCADE")
 	if err != nil {
 		t.Fatalf("Failed to clear DB: %v", err)
 	}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonalAccessTokens(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/login", "", map[string]string{"username": "bot_owner"})
+	var loginResp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &loginResp)
+	session := loginResp["token"].(string)
+
+	w = do("POST", "/topics", session, map[string]string{"name": "botTopic", "description": "Desc"})
+	var topicResp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &topicResp)
+	topicID := int64(topicResp["topic_id"].(float64))
+
+	w = do("POST", fmt.Sprintf("/topics/%d/posts", topicID), session, map[string]string{"title": "botPost", "body": "Body"})
+	var postResp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &postResp)
+	postID := int64(postResp["post_id"].(float64))
+
+	var pat string
+	var tokenID int64
+
+	// Test Case 1: Create a comment-only token
+	t.Run("Create Token", func(t *testing.T) {
+		w := do("POST", "/users/me/tokens", session, map[string]interface{}{
+			"name":   "comment bot",
+			"scopes": []string{"comment"},
+		})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var resp map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.Contains(t, resp["token"], "cvwo_pat_")
+		assert.Equal(t, []interface{}{"comment"}, resp["scopes"])
+
+		pat = resp["token"].(string)
+		tokenID = int64(resp["token_id"].(float64))
+	})
+
+	// Test Case 2: Unknown scopes are rejected
+	t.Run("Create Token Unknown Scope", func(t *testing.T) {
+		w := do("POST", "/users/me/tokens", session, map[string]interface{}{
+			"name":   "bad bot",
+			"scopes": []string{"everything"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test Case 3: Token works within its scopes only
+	t.Run("Scope Enforcement", func(t *testing.T) {
+		w := do("POST", fmt.Sprintf("/posts/%d/comments", postID), pat, map[string]string{"body": "beep boop"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, loginResp["user_id"], resp["commented_by"])
+
+		w = do("POST", fmt.Sprintf("/topics/%d/posts", topicID), pat, map[string]string{"title": "t", "body": "b"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	// Test Case 4: Tokens cannot manage tokens
+	t.Run("Token Cannot Create Tokens", func(t *testing.T) {
+		w := do("POST", "/users/me/tokens", pat, map[string]interface{}{
+			"name":   "child bot",
+			"scopes": []string{"comment"},
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	// Test Case 5: Listing shows last use but never the secret
+	t.Run("List Tokens", func(t *testing.T) {
+		w := do("GET", "/users/me/tokens", session, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp []map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.Len(t, resp, 1)
+		assert.NotNil(t, resp[0]["last_used_at"])
+		assert.NotContains(t, resp[0], "token")
+	})
+
+	// Test Case 6: Revoked tokens stop working
+	t.Run("Revoke Token", func(t *testing.T) {
+		w := do("DELETE", fmt.Sprintf("/users/me/tokens/%d", tokenID), session, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = do("POST", fmt.Sprintf("/posts/%d/comments", postID), pat, map[string]string{"body": "still here?"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = do("DELETE", fmt.Sprintf("/users/me/tokens/%d", tokenID), session, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -9,8 +9,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/DamienFooxx/CVWOForum/internal/database"
-	"github.com/DamienFooxx/CVWOForum/internal/router"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,8 +19,7 @@ func TestTopics(t *testing.T) {
 	ClearDB(t, dbConn)
 
 	// Create
-	queries := database.New(dbConn)
-	r := router.NewRouter(queries)
+	r := SetupRouter(t, dbConn)
 
 	// Helper to get token
 	getToken := func(username string) string {
@@ -216,7 +213,14 @@ func TestTopics(t *testing.T) {
 		wReg := httptest.NewRecorder()
 		r.ServeHTTP(wReg, reqReg)
 
-		token2 := getToken("user2")
+		// user2 registered with a password, so it is needed to log in
+		reqLogin, _ := http.NewRequest("POST", "/login", bytes.NewBufferString(`{"username": "user2", "password": "password"}`))
+		reqLogin.Header.Set("Content-Type", "application/json")
+		wLogin := httptest.NewRecorder()
+		r.ServeHTTP(wLogin, reqLogin)
+		var loginResp map[string]interface{}
+		_ = json.Unmarshal(wLogin.Body.Bytes(), &loginResp)
+		token2, _ := loginResp["token"].(string)
 
 		// Try to delete user1's topic with user2's token
 		url := fmt.Sprintf("/topics/%d", topicID)
@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DamienFooxx/CVWOForum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// totpAt computes the code an authenticator app would show at t
+func totpAt(t *testing.T, secret string, at time.Time) string {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("Invalid secret: %v", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1_000_000)
+}
+
+func TestTwoFactor(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	// Enforce 2FA for admins, as in production
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.RateLimit.Enabled = false
+	cfg.TwoFactor.RequiredRoles = []string{"moderator", "admin"}
+	r := StartRouter(t, cfg, dbConn)
+
+	// Codes are used one time step apart so none is rejected as a replay.
+	// Wait out the end of a step so the whole test runs inside one.
+	if remaining := 30 - time.Now().Unix()%30; remaining < 5 {
+		time.Sleep(time.Duration(remaining) * time.Second)
+	}
+	now := time.Now()
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	w := do("POST", "/login", "", map[string]string{"username": "mod_user", "password": "password"})
+	session := decode(w)["token"].(string)
+
+	_, err = dbConn.Exec(context.Background(), "UPDATE users SET role = 'admin' WHERE username = 'mod_user'")
+	assert.NoError(t, err)
+
+	var secret string
+	var recoveryCodes []interface{}
+
+	// Test Case 1: Admins without 2FA cannot use admin routes
+	t.Run("Policy Requires Enrollment", func(t *testing.T) {
+		w := do("GET", "/admin/login-attempts", session, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	// Test Case 2: Setup returns a secret and provisioning URI
+	t.Run("Setup", func(t *testing.T) {
+		w := do("POST", "/users/me/2fa/setup", session, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		resp := decode(w)
+		secret = resp["secret"].(string)
+		assert.NotEmpty(t, secret)
+		assert.True(t, strings.HasPrefix(resp["provisioning_uri"].(string), "otpauth://totp/"))
+		assert.Contains(t, resp["provisioning_uri"], "secret="+secret)
+	})
+
+	// Test Case 3: Enabling needs a valid code and returns recovery codes
+	t.Run("Enable", func(t *testing.T) {
+		w := do("POST", "/users/me/2fa/enable", session, map[string]string{"code": "000000"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = do("POST", "/users/me/2fa/enable", session, map[string]string{"code": totpAt(t, secret, now.Add(-30*time.Second))})
+		assert.Equal(t, http.StatusOK, w.Code)
+		recoveryCodes = decode(w)["recovery_codes"].([]interface{})
+		assert.Len(t, recoveryCodes, 10)
+
+		w = do("GET", "/users/me/2fa", session, nil)
+		assert.Equal(t, true, decode(w)["enabled"])
+		assert.Equal(t, float64(10), decode(w)["recovery_codes_remaining"])
+	})
+
+	// Test Case 4: A session from before 2FA was verified is still refused, as are tokens it creates
+	t.Run("Single Factor Session Refused", func(t *testing.T) {
+		w := do("GET", "/admin/login-attempts", session, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("POST", "/users/me/tokens", session, map[string]interface{}{"name": "single factor", "scopes": []string{"moderate"}})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = do("GET", "/admin/login-attempts", decode(w)["token"].(string), nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	var mfaToken string
+
+	// Test Case 5: The password step returns an interim token only
+	t.Run("Login Requires Second Factor", func(t *testing.T) {
+		w := do("POST", "/login", "", map[string]string{"username": "mod_user", "password": "password"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		resp := decode(w)
+		assert.Equal(t, true, resp["mfa_required"])
+		assert.Nil(t, resp["token"])
+		mfaToken = resp["mfa_token"].(string)
+
+		// The interim token is not a session
+		w = do("POST", "/topics", mfaToken, map[string]string{"name": "sneaky", "description": "desc"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	// Test Case 6: Completing login with a code, which cannot be replayed
+	t.Run("Complete Login", func(t *testing.T) {
+		w := do("POST", "/login/2fa", "", map[string]string{"mfa_token": mfaToken, "code": "123456"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		code := totpAt(t, secret, now)
+		w = do("POST", "/login/2fa", "", map[string]string{"mfa_token": mfaToken, "code": code})
+		assert.Equal(t, http.StatusOK, w.Code)
+		verified := decode(w)["token"].(string)
+
+		w = do("POST", "/login/2fa", "", map[string]string{"mfa_token": mfaToken, "code": code})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = do("GET", "/admin/login-attempts", verified, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Tokens created from the verified session carry it over
+		w = do("POST", "/users/me/tokens", verified, map[string]interface{}{"name": "two factor", "scopes": []string{"moderate"}})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = do("GET", "/admin/login-attempts", decode(w)["token"].(string), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	// Test Case 7: Recovery codes work once
+	t.Run("Recovery Code", func(t *testing.T) {
+		code := recoveryCodes[0].(string)
+		w := do("POST", "/login/2fa", "", map[string]string{"mfa_token": mfaToken, "recovery_code": code})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, decode(w)["token"])
+
+		w = do("POST", "/login/2fa", "", map[string]string{"mfa_token": mfaToken, "recovery_code": code})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = do("GET", "/users/me/2fa", session, nil)
+		assert.Equal(t, float64(9), decode(w)["recovery_codes_remaining"])
+	})
+
+	// Test Case 8: Disabling needs a code
+	t.Run("Disable", func(t *testing.T) {
+		w := do("DELETE", "/users/me/2fa", session, map[string]string{"code": "000000"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = do("DELETE", "/users/me/2fa", session, map[string]string{"code": totpAt(t, secret, now.Add(30*time.Second))})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = do("POST", "/login", "", map[string]string{"username": "mod_user", "password": "password"})
+		assert.NotEmpty(t, decode(w)["token"])
+	})
+}
@@ -7,8 +7,6 @@ import (
 	"net/http/httptest"
 	"testing"
 
-	"github.com/DamienFooxx/CVWOForum/internal/database"
-	"github.com/DamienFooxx/CVWOForum/internal/router"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,8 +16,7 @@ func TestCreateUser(t *testing.T) {
 	defer dbConn.Close()
 
 	// Setup router
-	queries := database.New(dbConn)
-	r := router.NewRouter(queries)
+	r := SetupRouter(t, dbConn)
 
 	// Test Case 1: User creation with bio
 	t.Run("Create User with Bio", func(t *testing.T) {
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostWatches(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username string) (string, int64) {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": username}).Body.Bytes(), &resp)
+		return resp["token"].(string), int64(resp["user_id"].(float64))
+	}
+	// notifications counts the notifications of a type a user has received
+	notifications := func(userID int64, typ string) int {
+		var count int
+		_ = dbConn.QueryRow(context.Background(), "SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND type = $2", userID, typ).Scan(&count)
+		return count
+	}
+	watching := func(token string) map[string]string {
+		w := do("GET", "/users/me/watching", token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var posts []struct {
+			Title string `json:"title"`
+			Level string `json:"level"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &posts)
+		levels := map[string]string{}
+		for _, p := range posts {
+			levels[p.Title] = p.Level
+		}
+		return levels
+	}
+
+	author, authorID := login("watch_author")
+	commenter, commenterID := login("watch_commenter")
+	watcher, watcherID := login("watch_watcher")
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(do("POST", "/topics", author, map[string]string{"name": "watchTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	topicID := int64(resp["topic_id"].(float64))
+	_ = json.Unmarshal(do("POST", fmt.Sprintf("/topics/%d/posts", topicID), author, map[string]string{"title": "Watched", "body": "Body"}).Body.Bytes(), &resp)
+	postID := int64(resp["post_id"].(float64))
+	comment := func(token, body string) {
+		w := do("POST", fmt.Sprintf("/posts/%d/comments", postID), token, map[string]string{"body": body})
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// Test Case 1: Authors and commenters watch posts automatically, anyone can watch explicitly
+	t.Run("Watch Post", func(t *testing.T) {
+		comment(commenter, "First")
+		assert.Equal(t, map[string]string{"Watched": "all"}, watching(author))
+		assert.Equal(t, map[string]string{"Watched": "all"}, watching(commenter))
+		assert.Empty(t, watching(watcher))
+
+		w := do("PUT", fmt.Sprintf("/posts/%d/watch", postID), watcher, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, "all", resp["level"])
+		assert.Equal(t, map[string]string{"Watched": "all"}, watching(watcher))
+
+		w = do("PUT", fmt.Sprintf("/posts/%d/watch", postID), watcher, map[string]string{"level": "loud"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = do("PUT", "/posts/999999/watch", watcher, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = do("PUT", fmt.Sprintf("/posts/%d/watch", postID), "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	// Test Case 2: New comments notify every watcher once, without repeating reply notifications
+	t.Run("Notify Watchers", func(t *testing.T) {
+		assert.Equal(t, 1, notifications(authorID, "post_reply"))
+
+		comment(author, "Second")
+		assert.Equal(t, 1, notifications(watcherID, "watched_post"))
+		assert.Equal(t, 1, notifications(commenterID, "watched_post"))
+
+		// The author is told about the reply to their post, not about a new comment on a watched post too
+		comment(watcher, "Third")
+		assert.Equal(t, 2, notifications(authorID, "post_reply"))
+		assert.Equal(t, 0, notifications(authorID, "watched_post"))
+		assert.Equal(t, 2, notifications(commenterID, "watched_post"))
+		assert.Equal(t, 1, notifications(watcherID, "watched_post")) // Not for their own comment
+	})
+
+	// Test Case 3: Direct replies only, muting and unwatching
+	t.Run("Watch Levels", func(t *testing.T) {
+		w := do("PUT", fmt.Sprintf("/posts/%d/watch", postID), commenter, map[string]string{"level": "direct"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = do("PUT", fmt.Sprintf("/posts/%d/watch", postID), author, map[string]string{"level": "muted"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		comment(watcher, "Fourth")
+		assert.Equal(t, 2, notifications(commenterID, "watched_post"))
+		assert.Equal(t, 2, notifications(authorID, "post_reply")) // Muted posts drop even direct replies
+
+		// Commenting again keeps the level the user chose
+		comment(author, "Fifth")
+		assert.Equal(t, map[string]string{"Watched": "muted"}, watching(author))
+
+		w = do("DELETE", fmt.Sprintf("/posts/%d/watch", postID), author, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, watching(author))
+		// Without a watch, replies to the author are notified as usual
+		comment(watcher, "Sixth")
+		assert.Equal(t, 3, notifications(authorID, "post_reply"))
+		assert.Equal(t, 0, notifications(authorID, "watched_post"))
+	})
+}
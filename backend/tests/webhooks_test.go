@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// receivedWebhook is a delivery seen by the test receiver
+type receivedWebhook struct {
+	Event     string
+	Signature string
+	Body      []byte
+}
+
+func TestWebhooks(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	t.Setenv("WEBHOOK_POLL_INTERVAL", "100ms")
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+
+	// Receivers, one that accepts everything and one that always fails
+	received := make(chan receivedWebhook, 16)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedWebhook{Event: r.Header.Get("X-Forum-Event"), Signature: r.Header.Get("X-Forum-Signature"), Body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username string) string {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": username}).Body.Bytes(), &resp)
+		return resp["token"].(string)
+	}
+
+	admin := login("webhook_admin")
+	_, err = dbConn.Exec(context.Background(), "UPDATE users SET role = 'admin' WHERE username = 'webhook_admin'")
+	assert.NoError(t, err)
+	member := login("webhook_member")
+	deliveries := func(webhookID int64) []map[string]interface{} {
+		var resp []map[string]interface{}
+		_ = json.Unmarshal(do("GET", fmt.Sprintf("/admin/webhooks/%d/deliveries", webhookID), admin, nil).Body.Bytes(), &resp)
+		return resp
+	}
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(do("POST", "/topics", member, map[string]string{"name": "hookedTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	hookedTopicID := int64(resp["topic_id"].(float64))
+	_ = json.Unmarshal(do("POST", "/topics", member, map[string]string{"name": "otherTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	otherTopicID := int64(resp["topic_id"].(float64))
+
+	var webhookID int64
+	var secret string
+
+	// Test Case 1: Only admins manage webhooks, and event types are checked
+	t.Run("Create Webhook", func(t *testing.T) {
+		body := map[string]interface{}{"url": receiver.URL, "event_types": []string{"post.created", "comment.created"}, "topic_id": hookedTopicID}
+		w := do("POST", "/admin/webhooks", member, body)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("POST", "/admin/webhooks", admin, map[string]interface{}{"url": receiver.URL, "event_types": []string{"post.liked"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = do("POST", "/admin/webhooks", admin, map[string]interface{}{"url": "ftp://example.com", "event_types": []string{"post.created"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do("POST", "/admin/webhooks", admin, body)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		webhookID = int64(resp["webhook_id"].(float64))
+		secret = resp["secret"].(string)
+		assert.True(t, strings.HasPrefix(secret, "whsec_"))
+
+		// The secret is not listed again
+		w = do("GET", "/admin/webhooks", admin, nil)
+		assert.NotContains(t, w.Body.String(), secret)
+	})
+
+	// Test Case 2: Signed deliveries for subscribed events in the topic
+	t.Run("Signed Delivery", func(t *testing.T) {
+		do("POST", fmt.Sprintf("/topics/%d/posts", otherTopicID), member, map[string]string{"title": "Elsewhere", "body": "Body"})
+		do("POST", fmt.Sprintf("/topics/%d/posts", hookedTopicID), member, map[string]string{"title": "Hooked", "body": "Body"})
+
+		select {
+		case delivery := <-received:
+			assert.Equal(t, "post.created", delivery.Event)
+
+			// t=<unix>,v1=<hmac of "<t>.<body>">
+			parts := strings.Split(delivery.Signature, ",")
+			if assert.Len(t, parts, 2) {
+				timestamp := strings.TrimPrefix(parts[0], "t=")
+				mac := hmac.New(sha256.New, []byte(secret))
+				mac.Write([]byte(timestamp + "."))
+				mac.Write(delivery.Body)
+				assert.Equal(t, "v1="+hex.EncodeToString(mac.Sum(nil)), parts[1])
+			}
+
+			var body map[string]interface{}
+			_ = json.Unmarshal(delivery.Body, &body)
+			assert.Equal(t, "post.created", body["event"])
+			data := body["data"].(map[string]interface{})
+			assert.Equal(t, "Hooked", data["title"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("Webhook was not delivered")
+		}
+
+		// The post in the other topic was never queued
+		log := deliveries(webhookID)
+		if assert.Len(t, log, 1) {
+			assert.Equal(t, "succeeded", log[0]["status"])
+			assert.Equal(t, float64(204), log[0]["last_status_code"])
+		}
+	})
+
+	// Test Case 3: Failed deliveries are retried later, and can be redelivered by hand
+	t.Run("Failed Delivery", func(t *testing.T) {
+		w := do("POST", "/admin/webhooks", admin, map[string]interface{}{"url": failing.URL, "event_types": []string{"topic.created"}})
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		failingID := int64(resp["webhook_id"].(float64))
+
+		do("POST", "/topics", member, map[string]string{"name": "newTopic", "description": "Desc"})
+
+		var log []map[string]interface{}
+		for i := 0; i < 50; i++ {
+			log = deliveries(failingID)
+			if len(log) == 1 && log[0]["attempts"] == float64(1) && log[0]["last_status_code"] != nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if assert.Len(t, log, 1) {
+			assert.Equal(t, "pending", log[0]["status"])
+			assert.Equal(t, float64(500), log[0]["last_status_code"])
+			assert.NotNil(t, log[0]["next_attempt_at"])
+			assert.NotNil(t, log[0]["last_error"])
+		}
+
+		deliveryID := int64(log[0]["delivery_id"].(float64))
+		w = do("POST", fmt.Sprintf("/admin/webhooks/%d/deliveries/%d/redeliver", failingID, deliveryID), admin, nil)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		w = do("POST", fmt.Sprintf("/admin/webhooks/%d/deliveries/%d/redeliver", webhookID, deliveryID), admin, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Len(t, deliveries(failingID), 2)
+	})
+
+	// Test Case 4: Deleting a webhook
+	t.Run("Delete Webhook", func(t *testing.T) {
+		w := do("DELETE", fmt.Sprintf("/admin/webhooks/%d", webhookID), admin, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = do("DELETE", fmt.Sprintf("/admin/webhooks/%d", webhookID), admin, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = do("GET", fmt.Sprintf("/admin/webhooks/%d/deliveries", webhookID), admin, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
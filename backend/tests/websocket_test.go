@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/stretchr/testify/assert"
+)
+
+// wsMessage is a message from the /ws endpoint
+type wsMessage struct {
+	Type   string `json:"type"`
+	PostID int64  `json:"post_id"`
+	Users  []struct {
+		Username string `json:"username"`
+	} `json:"users"`
+	User *struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Message string `json:"message"`
+}
+
+func TestWebSocket(t *testing.T) {
+	err := os.Setenv("JWT_SECRET", "secret")
+	if err != nil {
+		t.Fatalf("Failed to set JWT_SECRET: %v", err)
+	}
+	t.Setenv("WS_MAX_CONNS_PER_USER", "2")
+	dbConn := SetupDB(t)
+	defer dbConn.Close()
+	ClearDB(t, dbConn)
+
+	r := SetupRouter(t, dbConn)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	// Helpers
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username string) string {
+		var resp map[string]interface{}
+		_ = json.Unmarshal(do("POST", "/login", "", map[string]string{"username": username}).Body.Bytes(), &resp)
+		return resp["token"].(string)
+	}
+	dial := func(t *testing.T, token string) *websocket.Conn {
+		conn, resp, err := websocket.Dial(context.Background(), wsURL+"?token="+token, nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v (%v)", err, resp)
+		}
+		return conn
+	}
+	send := func(conn *websocket.Conn, messageType string, postID int64) {
+		_ = wsjson.Write(context.Background(), conn, map[string]interface{}{"type": messageType, "post_id": postID})
+	}
+	// read returns the next message, or ok false if none arrives within timeout
+	read := func(conn *websocket.Conn, timeout time.Duration) (wsMessage, bool) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		var message wsMessage
+		if err := wsjson.Read(ctx, conn, &message); err != nil {
+			return message, false
+		}
+		return message, true
+	}
+
+	alice := login("ws_alice")
+	bob := login("ws_bob")
+	carol := login("ws_carol")
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(do("POST", "/topics", alice, map[string]string{"name": "wsTopic", "description": "Desc"}).Body.Bytes(), &resp)
+	topicID := int64(resp["topic_id"].(float64))
+	_ = json.Unmarshal(do("POST", fmt.Sprintf("/topics/%d/posts", topicID), alice, map[string]string{"title": "Thread", "body": "Body"}).Body.Bytes(), &resp)
+	postID := int64(resp["post_id"].(float64))
+
+	// Test Case 1: Presence, typing indicators and leaving a room
+	t.Run("Presence And Typing", func(t *testing.T) {
+		aliceConn := dial(t, alice)
+		defer aliceConn.CloseNow()
+		bobConn := dial(t, bob)
+		defer bobConn.CloseNow()
+
+		send(aliceConn, "join", postID)
+		message, ok := read(aliceConn, 2*time.Second)
+		assert.True(t, ok)
+		assert.Equal(t, "presence", message.Type)
+		assert.Len(t, message.Users, 1)
+
+		send(bobConn, "join", postID)
+		message, _ = read(bobConn, 2*time.Second)
+		assert.Equal(t, "presence", message.Type)
+		assert.Len(t, message.Users, 2)
+		message, _ = read(aliceConn, 2*time.Second)
+		assert.Len(t, message.Users, 2)
+
+		// A burst of typing is relayed once, and never back to the typist
+		send(aliceConn, "typing", postID)
+		send(aliceConn, "typing", postID)
+		message, ok = read(bobConn, 2*time.Second)
+		if assert.True(t, ok) && assert.Equal(t, "typing", message.Type) {
+			assert.Equal(t, "ws_alice", message.User.Username)
+		}
+		_, ok = read(bobConn, 300*time.Millisecond)
+		assert.False(t, ok)
+
+		send(bobConn, "leave", postID)
+		message, _ = read(aliceConn, 2*time.Second)
+		assert.Equal(t, "presence", message.Type)
+		if assert.Len(t, message.Users, 1) {
+			assert.Equal(t, "ws_alice", message.Users[0].Username)
+		}
+	})
+
+	// Test Case 2: Joining a post that does not exist
+	t.Run("Unknown Post", func(t *testing.T) {
+		conn := dial(t, alice)
+		defer conn.CloseNow()
+
+		send(conn, "join", postID+1000)
+		message, _ := read(conn, 2*time.Second)
+		assert.Equal(t, "error", message.Type)
+	})
+
+	// Test Case 3: Connections are capped per user
+	t.Run("Connection Cap", func(t *testing.T) {
+		first := dial(t, carol)
+		defer first.CloseNow()
+		second := dial(t, carol)
+		defer second.CloseNow()
+
+		_, resp, err := websocket.Dial(context.Background(), wsURL+"?token="+carol, nil)
+		assert.Error(t, err)
+		if assert.NotNil(t, resp) {
+			assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		}
+	})
+
+	// Test Case 4: Flooding joins and leaves is cut off and does not drown the rest of the room
+	t.Run("Join Leave Flood", func(t *testing.T) {
+		dave := login("ws_dave")
+		floodConn := dial(t, dave)
+		defer floodConn.CloseNow()
+		bobConn := dial(t, bob)
+		defer bobConn.CloseNow()
+
+		send(bobConn, "join", postID)
+		message, _ := read(bobConn, 2*time.Second)
+		assert.Equal(t, "presence", message.Type)
+
+		for i := 0; i < 50; i++ {
+			send(floodConn, "join", postID)
+			send(floodConn, "leave", postID)
+		}
+
+		// A fresh connection types once the flood is over, bob must still be connected to see it.
+		// Presence changes before it are coalesced, so he sees a handful of updates rather than one per message.
+		otherConn := dial(t, dave)
+		defer otherConn.CloseNow()
+		send(otherConn, "join", postID)
+		send(otherConn, "typing", postID)
+
+		presenceUpdates := 0
+		for {
+			message, ok := read(bobConn, 2*time.Second)
+			if !assert.True(t, ok) || message.Type == "typing" {
+				break
+			}
+			presenceUpdates++
+		}
+		assert.Less(t, presenceUpdates, 10)
+	})
+
+	// Test Case 5: A token is required
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, resp, err := websocket.Dial(context.Background(), wsURL, nil)
+		assert.Error(t, err)
+		if assert.NotNil(t, resp) {
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		}
+	})
+}